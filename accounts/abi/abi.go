@@ -15,8 +15,11 @@ package abi
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+
+	"github.com/ethereum/go-ethereum/crypto"
 )
 
 //ABI保存有关合同上下文的信息，并提供
@@ -142,3 +145,23 @@ func (abi *ABI) MethodById(sigdata []byte) (*Method, error) {
 	return nil, fmt.Errorf("no method with id: %#x", sigdata[:4])
 }
 
+//revertselector是solidity在require/revert失败时编码
+//还原原因所用的Error(string)方法选择器。
+var revertSelector = crypto.Keccak256([]byte("Error(string)"))[:4]
+
+//unpackrevert解析还原数据中以Error(string)编码的还原原因，
+//如果数据不是这种格式，则返回错误。
+func UnpackRevert(data []byte) (string, error) {
+	if len(data) < 4 {
+		return "", errors.New("invalid data for unpacking")
+	}
+	if !bytes.Equal(data[:4], revertSelector) {
+		return "", errors.New("invalid data for unpacking")
+	}
+	typ, _ := NewType("string", nil)
+	var unpacked string
+	if err := (Arguments{{Type: typ}}).Unpack(&unpacked, data[4:]); err != nil {
+		return "", err
+	}
+	return unpacked, nil
+}
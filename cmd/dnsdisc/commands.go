@@ -0,0 +1,174 @@
+
+//<developer>
+//    <name>linapex 曹一峰</name>
+//    <email>linapex@163.com</email>
+//    <wx>superexc</wx>
+//    <qqgroup>128148617</qqgroup>
+//    <url>https://jsq.ink</url>
+//    <role>pku engineer</role>
+//    <date>2019-03-16 19:16:32</date>
+//</624450067092298585>
+
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/dnsdisc"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var commandSign = cli.Command{
+	Name:      "sign",
+	Usage:     "sign a node tree",
+	ArgsUsage: "<tree-directory> <keyfile>",
+	Description: `
+Build the tree from the node records in <tree-directory>/nodes.json and the
+links in <tree-directory>/enrtree-info.json, then sign its root with the
+secp256k1 key in <keyfile> (hex encoded, as written by 'bootnode -genkey').
+
+The signature and the resulting enrtree:// URL are stored back into
+enrtree-info.json.`,
+	Flags: []cli.Flag{
+		domainFlag,
+		seqFlag,
+	},
+	Action: func(ctx *cli.Context) error {
+		if ctx.NArg() < 2 {
+			utils.Fatalf("Need tree directory and key file as arguments")
+		}
+		dir, keyfile := ctx.Args().Get(0), ctx.Args().Get(1)
+
+		def, err := loadTreeDefinition(dir)
+		if err != nil {
+			utils.Fatalf("%v", err)
+		}
+		key, err := crypto.LoadECDSA(keyfile)
+		if err != nil {
+			utils.Fatalf("Failed to load key: %v", err)
+		}
+//确定域名和序列号，命令行标志优先
+		domain := ctx.String(domainFlag.Name)
+		if domain == "" {
+			if def.Meta.URL == "" {
+				utils.Fatalf("Missing domain name, use --%s", domainFlag.Name)
+			}
+			if domain, _, err = dnsdisc.ParseURL(def.Meta.URL); err != nil {
+				utils.Fatalf("Invalid URL in tree definition: %v", err)
+			}
+		}
+		seq := def.Meta.Seq + 1
+		if ctx.IsSet(seqFlag.Name) {
+			seq = ctx.Uint(seqFlag.Name)
+		}
+//构建并签署树
+		tree, err := dnsdisc.MakeTree(seq, def.Nodes, def.Meta.Links)
+		if err != nil {
+			utils.Fatalf("Failed to build tree: %v", err)
+		}
+		url, err := tree.Sign(key, domain)
+		if err != nil {
+			utils.Fatalf("Failed to sign tree: %v", err)
+		}
+		def.Meta.Seq = tree.Seq()
+		def.Meta.Sig = tree.Signature()
+		def.Meta.URL = url
+		def.Meta.LastModified = time.Now()
+		if err := writeTreeMetadata(dir, def); err != nil {
+			utils.Fatalf("%v", err)
+		}
+		fmt.Println(url)
+		return nil
+	},
+}
+
+var commandToTXT = cli.Command{
+	Name:      "to-txt",
+	Usage:     "export a signed node tree as DNS TXT records",
+	ArgsUsage: "<tree-directory> [<output-file>]",
+	Description: `
+Write all TXT records of the signed tree in <tree-directory> as a JSON object
+mapping DNS names to record content. The output goes to <output-file> if given,
+to standard output otherwise.`,
+	Action: func(ctx *cli.Context) error {
+		if ctx.NArg() < 1 {
+			utils.Fatalf("Need tree directory as argument")
+		}
+		dir, output := ctx.Args().Get(0), ctx.Args().Get(1)
+
+		def, err := loadTreeDefinition(dir)
+		if err != nil {
+			utils.Fatalf("%v", err)
+		}
+		if def.Meta.URL == "" || def.Meta.Sig == "" {
+			utils.Fatalf("Tree is not signed, run 'sign' first")
+		}
+		domain, pubkey, err := dnsdisc.ParseURL(def.Meta.URL)
+		if err != nil {
+			utils.Fatalf("Invalid URL in tree definition: %v", err)
+		}
+		tree, err := dnsdisc.MakeTree(def.Meta.Seq, def.Nodes, def.Meta.Links)
+		if err != nil {
+			utils.Fatalf("Failed to build tree: %v", err)
+		}
+		if err := tree.SetSignature(pubkey, def.Meta.Sig); err != nil {
+			utils.Fatalf("Tree signature does not match its content, run 'sign' again: %v", err)
+		}
+		writeJSON(output, tree.ToTXT(domain))
+		return nil
+	},
+}
+
+var commandSync = cli.Command{
+	Name:      "sync",
+	Usage:     "download a node tree from DNS",
+	ArgsUsage: "<url> [<tree-directory>]",
+	Description: `
+Download the complete tree at the given enrtree:// URL, verifying the root
+signature and all entry hashes. The tree is written to <tree-directory>, or
+its node count is printed if no directory is given.`,
+	Flags: []cli.Flag{
+		cli.DurationFlag{
+			Name:  "timeout",
+			Usage: "timeout of a single DNS lookup",
+			Value: 5 * time.Second,
+		},
+	},
+	Action: func(ctx *cli.Context) error {
+		if ctx.NArg() < 1 {
+			utils.Fatalf("Need tree URL as argument")
+		}
+		url, dir := ctx.Args().Get(0), ctx.Args().Get(1)
+
+		client, err := dnsdisc.NewClient(dnsdisc.Config{Timeout: ctx.Duration("timeout")})
+		if err != nil {
+			utils.Fatalf("%v", err)
+		}
+		tree, err := client.SyncTree(url)
+		if err != nil {
+			utils.Fatalf("Sync failed: %v", err)
+		}
+		def := &treeDefinition{
+			Meta: treeMetadata{
+				URL:          url,
+				Seq:          tree.Seq(),
+				Sig:          tree.Signature(),
+				Links:        tree.Links(),
+				LastModified: time.Now(),
+			},
+			Nodes: tree.Nodes(),
+		}
+		if dir == "" {
+			fmt.Printf("Synced tree %s: seq %d, %d nodes, %d links\n", url, def.Meta.Seq, len(def.Nodes), len(def.Meta.Links))
+			return nil
+		}
+		if err := writeTreeDefinition(dir, def); err != nil {
+			utils.Fatalf("%v", err)
+		}
+		return nil
+	},
+}
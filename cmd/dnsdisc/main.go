@@ -0,0 +1,55 @@
+
+//<developer>
+//    <name>linapex 曹一峰</name>
+//    <email>linapex@163.com</email>
+//    <wx>superexc</wx>
+//    <qqgroup>128148617</qqgroup>
+//    <url>https://jsq.ink</url>
+//    <role>pku engineer</role>
+//    <date>2019-03-16 19:16:32</date>
+//</624450067092298584>
+
+
+//dnsdisc创建、签名和下载EIP-1459 DNS节点树。
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"gopkg.in/urfave/cli.v1"
+)
+
+//git sha1提交发布的哈希（通过链接器标志设置）
+var gitCommit = ""
+
+var app *cli.App
+
+func init() {
+	app = utils.NewApp(gitCommit, "an EIP-1459 DNS node tree tool")
+	app.Commands = []cli.Command{
+		commandSign,
+		commandToTXT,
+		commandSync,
+	}
+}
+
+//常用命令行标志。
+var (
+	domainFlag = cli.StringFlag{
+		Name:  "domain",
+		Usage: "domain name of the tree",
+	}
+	seqFlag = cli.UintFlag{
+		Name:  "seq",
+		Usage: "sequence number of the tree (default: previous sequence number + 1)",
+	}
+)
+
+func main() {
+	if err := app.Run(os.Args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
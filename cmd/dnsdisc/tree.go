@@ -0,0 +1,140 @@
+
+//<developer>
+//    <name>linapex 曹一峰</name>
+//    <email>linapex@163.com</email>
+//    <wx>superexc</wx>
+//    <qqgroup>128148617</qqgroup>
+//    <url>https://jsq.ink</url>
+//    <role>pku engineer</role>
+//    <date>2019-03-16 19:16:32</date>
+//</624450067092298586>
+
+
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/p2p/dnsdisc"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+const (
+	metaFile  = "enrtree-info.json"
+	nodesFile = "nodes.json"
+)
+
+//treeDefinition是树目录的内容：元数据和节点列表。
+type treeDefinition struct {
+	Meta  treeMetadata
+	Nodes []*enode.Node
+}
+
+//treeMetadata是存储在enrtree-info.json中的树元数据。
+type treeMetadata struct {
+	URL          string    `json:"url,omitempty"`
+	Seq          uint      `json:"seq"`
+	Sig          string    `json:"signature,omitempty"`
+	Links        []string  `json:"links"`
+	LastModified time.Time `json:"lastModified"`
+}
+
+//loadTreeDefinition从目录中读取树定义。两个文件都是可选的。
+func loadTreeDefinition(dir string) (*treeDefinition, error) {
+	def := new(treeDefinition)
+	if err := readJSON(filepath.Join(dir, metaFile), &def.Meta); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	var texts []string
+	if err := readJSON(filepath.Join(dir, nodesFile), &texts); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for i, text := range texts {
+		n, err := parseNode(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid node %d in %s: %v", i, nodesFile, err)
+		}
+		def.Nodes = append(def.Nodes, n)
+	}
+	return def, nil
+}
+
+//writeTreeDefinition将树定义写入目录。
+func writeTreeDefinition(dir string, def *treeDefinition) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if err := writeTreeMetadata(dir, def); err != nil {
+		return err
+	}
+	texts := make([]string, len(def.Nodes))
+	for i, n := range def.Nodes {
+		texts[i] = dnsdisc.NodeText(n)
+	}
+	return writeJSONFile(filepath.Join(dir, nodesFile), texts)
+}
+
+//writeTreeMetadata只更新目录中的树元数据。
+func writeTreeMetadata(dir string, def *treeDefinition) error {
+	if def.Meta.Links == nil {
+		def.Meta.Links = []string{}
+	}
+	return writeJSONFile(filepath.Join(dir, metaFile), &def.Meta)
+}
+
+//parseNode解析“enr:”文本格式或admin_nodeInfo返回的十六进制格式的节点记录。
+func parseNode(text string) (*enode.Node, error) {
+	if !strings.HasPrefix(text, "0x") {
+		return dnsdisc.ParseNode(text)
+	}
+	enc, err := hex.DecodeString(text[2:])
+	if err != nil {
+		return nil, err
+	}
+	var r enr.Record
+	if err := rlp.DecodeBytes(enc, &r); err != nil {
+		return nil, err
+	}
+	return enode.New(enode.ValidSchemes, &r)
+}
+
+func readJSON(file string, v interface{}) error {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("can't parse %s: %v", file, err)
+	}
+	return nil
+}
+
+func writeJSONFile(file string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, append(data, '\n'), 0644)
+}
+
+//writeJSON将值以JSON格式写入文件，如果文件名为空则写入标准输出。
+func writeJSON(file string, v interface{}) {
+	if file == "" || file == "-" {
+		data, _ := json.MarshalIndent(v, "", "  ")
+		fmt.Println(string(data))
+		return
+	}
+	if err := writeJSONFile(file, v); err != nil {
+		utils.Fatalf("Failed to write %s: %v", file, err)
+	}
+}
@@ -0,0 +1,145 @@
+
+//<developer>
+//    <name>linapex 曹一峰</name>
+//    <email>linapex@163.com</email>
+//    <wx>superexc</wx>
+//    <qqgroup>128148617</qqgroup>
+//    <url>https://jsq.ink</url>
+//    <role>pku engineer</role>
+//    <date>2019-03-16 19:16:32</date>
+//</624450067943657472>
+
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/core/vm/runtime"
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+var (
+	FuzzReferenceFlag = cli.StringFlag{
+		Name:  "reference",
+		Usage: "path to the external EVM executing fuzz cases via the JSON trace protocol",
+	}
+	FuzzRunsFlag = cli.IntFlag{
+		Name:  "runs",
+		Usage: "number of generated cases to execute (0 = unlimited)",
+		Value: 1000,
+	}
+	FuzzSeedFlag = cli.Int64Flag{
+		Name:  "seed",
+		Usage: "seed of the case generator (0 = current time)",
+	}
+	FuzzSizeFlag = cli.IntFlag{
+		Name:  "size",
+		Usage: "number of random bytes the generator consumes per case",
+		Value: 1024,
+	}
+)
+
+var fuzzExecCommand = cli.Command{
+	Action:    fuzzExecCmd,
+	Name:      "fuzzexec",
+	Usage:     "executes a differential fuzz case and emits a json trace",
+	ArgsUsage: "[<file>]",
+	Description: `
+Reads a fuzz case (JSON) from the given file or from stdin, executes it with
+the built-in interpreter and prints one JSON step per line followed by the
+execution result. This is the candidate side of the differential fuzzing
+protocol used by "evm difffuzz".`,
+}
+
+var diffFuzzCommand = cli.Command{
+	Action: diffFuzzCmd,
+	Name:   "difffuzz",
+	Usage:  "compares the built-in interpreter against an external EVM on generated cases",
+	Flags: []cli.Flag{
+		FuzzReferenceFlag,
+		FuzzRunsFlag,
+		FuzzSeedFlag,
+		FuzzSizeFlag,
+	},
+	Description: `
+Generates structured bytecode and pre-state, runs every case through the
+built-in interpreter and through the --reference EVM (which receives the case
+as JSON on stdin and must print a vm.JSONLogger compatible trace), and stops
+at the first diverging step, printing the case and the divergence.`,
+}
+
+func fuzzExecCmd(ctx *cli.Context) error {
+	var (
+		src []byte
+		err error
+	)
+	if file := ctx.Args().First(); file != "" {
+		src, err = ioutil.ReadFile(file)
+	} else {
+		src, err = ioutil.ReadAll(os.Stdin)
+	}
+	if err != nil {
+		return err
+	}
+	var c runtime.FuzzCase
+	if err := json.Unmarshal(src, &c); err != nil {
+		return fmt.Errorf("invalid fuzz case: %v", err)
+	}
+	config := &vm.LogConfig{
+		DisableMemory: ctx.GlobalBool(DisableMemoryFlag.Name),
+		DisableStack:  ctx.GlobalBool(DisableStackFlag.Name),
+	}
+//执行错误是跟踪的一部分，而不是命令的失败
+	c.Run(vm.NewJSONLogger(config, os.Stdout))
+	return nil
+}
+
+func diffFuzzCmd(ctx *cli.Context) error {
+	path := ctx.String(FuzzReferenceFlag.Name)
+	if path == "" {
+		return errors.New("--reference is required")
+	}
+	ext := &runtime.ExternalVM{Path: path, Args: ctx.Args()}
+
+	seed := ctx.Int64(FuzzSeedFlag.Name)
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	var (
+		rnd   = rand.New(rand.NewSource(seed))
+		runs  = ctx.Int(FuzzRunsFlag.Name)
+		input = make([]byte, ctx.Int(FuzzSizeFlag.Name))
+	)
+	fmt.Fprintf(os.Stderr, "Differential fuzzing with seed %d\n", seed)
+
+	for i := 0; runs == 0 || i < runs; i++ {
+		rnd.Read(input)
+
+		c, div, err := runtime.Differential(input, ext)
+		if err != nil {
+			return fmt.Errorf("case %d: %v", i, err)
+		}
+		if div != nil {
+			reportDivergence(os.Stdout, i, c, div)
+			return fmt.Errorf("divergence found after %d cases", i+1)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "No divergence in %d cases\n", runs)
+	return nil
+}
+
+//reportDivergence打印导致差异的测试用例，以便可以使用fuzzexec复现。
+func reportDivergence(w io.Writer, index int, c *runtime.FuzzCase, div *runtime.Divergence) {
+	blob, _ := json.MarshalIndent(c, "", "  ")
+	fmt.Fprintf(w, "Case %d diverged: %v\n", index, div)
+	fmt.Fprintf(w, "%s\n", blob)
+}
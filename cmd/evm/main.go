@@ -141,6 +141,8 @@ func init() {
 		disasmCommand,
 		runCommand,
 		stateTestCommand,
+		fuzzExecCommand,
+		diffFuzzCommand,
 	}
 }
 
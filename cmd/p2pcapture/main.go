@@ -0,0 +1,122 @@
+
+//<developer>
+//    <name>linapex 曹一峰</name>
+//    <email>linapex@163.com</email>
+//    <wx>superexc</wx>
+//    <qqgroup>128148617</qqgroup>
+//    <url>https://jsq.ink</url>
+//    <role>pku engineer</role>
+//    <date>2019-03-16 19:16:32</date>
+//</624450103113682956>
+
+
+//p2pcapture查看和重放节点用--capturefile记录的p2p消息捕获。
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/p2p"
+	"gopkg.in/urfave/cli.v1"
+)
+
+//git sha1提交发布的哈希（通过链接器标志设置）
+var gitCommit = ""
+
+var app *cli.App
+
+func init() {
+	app = utils.NewApp(gitCommit, "a p2p message capture tool")
+	app.Commands = []cli.Command{
+		commandDump,
+		commandReplay,
+	}
+}
+
+//常用命令行标志。
+var (
+	peerFlag = cli.StringFlag{
+		Name:  "peer",
+		Usage: "only use messages of peers whose node ID starts with this hex prefix",
+	}
+	payloadFlag = cli.BoolFlag{
+		Name:  "payload",
+		Usage: "print the RLP payload of each message",
+	}
+)
+
+var commandDump = cli.Command{
+	Name:      "dump",
+	Usage:     "print the messages in a capture file",
+	ArgsUsage: "<capture-file>",
+	Description: `
+Print one line per captured message: the time it was sent or received, the
+remote peer, the direction ("<-" for messages received by the capturing node,
+"->" for messages it sent), the protocol, the message code and the size.`,
+	Flags: []cli.Flag{
+		peerFlag,
+		payloadFlag,
+	},
+	Action: func(ctx *cli.Context) error {
+		if ctx.NArg() < 1 {
+			utils.Fatalf("Need capture file as argument")
+		}
+		records, err := readCapture(ctx.Args().First(), ctx.String(peerFlag.Name))
+		if err != nil {
+			utils.Fatalf("%v", err)
+		}
+		for _, rec := range records {
+			dir := "->"
+			if rec.Inbound {
+				dir = "<-"
+			}
+			fmt.Printf("%s %s %s %s/%d code=%d size=%d\n",
+				time.Unix(0, int64(rec.Time)).Format("2006-01-02 15:04:05.000000"),
+				rec.Peer.TerminalString(), dir, rec.Protocol, rec.Version, rec.Code, rec.Size)
+			if ctx.Bool(payloadFlag.Name) {
+				fmt.Printf("    %s\n", hex.EncodeToString(rec.Payload))
+			}
+		}
+		return nil
+	},
+}
+
+func main() {
+	if err := app.Run(os.Args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+//readCapture读取捕获文件中节点ID以prefix开头的对等端的所有记录。
+func readCapture(file string, prefix string) ([]*p2p.CaptureRecord, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var (
+		records []*p2p.CaptureRecord
+		r       = p2p.NewCaptureReader(f)
+	)
+	prefix = strings.ToLower(strings.TrimPrefix(prefix, "0x"))
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return records, fmt.Errorf("invalid capture record %d: %v", len(records), err)
+		}
+		if strings.HasPrefix(rec.Peer.String(), prefix) {
+			records = append(records, rec)
+		}
+	}
+}
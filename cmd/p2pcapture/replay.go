@@ -0,0 +1,184 @@
+
+//<developer>
+//    <name>linapex 曹一峰</name>
+//    <email>linapex@163.com</email>
+//    <wx>superexc</wx>
+//    <qqgroup>128148617</qqgroup>
+//    <url>https://jsq.ink</url>
+//    <role>pku engineer</role>
+//    <date>2019-03-16 19:16:32</date>
+//</624450103113682957>
+
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/eth"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/simulations/pipes"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	nodeFlag = cli.StringFlag{
+		Name:  "node",
+		Usage: "enode URL of a running node to replay against",
+	}
+	datadirFlag = cli.StringFlag{
+		Name:  "datadir",
+		Usage: "data directory of an in-process node to replay against (use a copy, the node modifies it)",
+	}
+	networkIdFlag = cli.Uint64Flag{
+		Name:  "networkid",
+		Usage: "network identifier of the in-process node",
+		Value: eth.DefaultConfig.NetworkId,
+	}
+	timingFlag = cli.BoolFlag{
+		Name:  "timing",
+		Usage: "keep the original intervals between messages",
+	}
+	lingerFlag = cli.DurationFlag{
+		Name:  "linger",
+		Usage: "time to wait for responses after the last message",
+		Value: 10 * time.Second,
+	}
+	outputFlag = cli.StringFlag{
+		Name:  "output",
+		Usage: "write the replayed messages and the node's responses to this capture file",
+	}
+)
+
+var commandReplay = cli.Command{
+	Name:      "replay",
+	Usage:     "replay the messages a peer sent to the capturing node",
+	ArgsUsage: "<capture-file>",
+	Description: `
+Connect to a node as the captured peer and send it the messages the capturing
+node received from that peer, in their original order. Messages the capturing
+node sent are skipped; the node's actual responses are printed and, with
+--output, written to a new capture file that can be compared to the original.
+
+The node is either a running node given by --node, or an in-process node
+started on --datadir and connected through an in-memory pipe. Captures with
+more than one peer must be narrowed down with --peer.`,
+	Flags: []cli.Flag{
+		peerFlag,
+		nodeFlag,
+		datadirFlag,
+		networkIdFlag,
+		timingFlag,
+		lingerFlag,
+		outputFlag,
+	},
+	Action: func(ctx *cli.Context) error {
+		if ctx.NArg() < 1 {
+			utils.Fatalf("Need capture file as argument")
+		}
+		records, err := readCapture(ctx.Args().First(), ctx.String(peerFlag.Name))
+		if err != nil {
+			utils.Fatalf("%v", err)
+		}
+		if len(records) == 0 {
+			utils.Fatalf("No messages to replay")
+		}
+		for _, rec := range records[1:] {
+			if rec.Peer != records[0].Peer {
+				utils.Fatalf("Capture contains more than one peer, use --%s", peerFlag.Name)
+			}
+		}
+		cfg := p2p.ReplayConfig{
+			Name:   "p2pcapture",
+			Timing: ctx.Bool(timingFlag.Name),
+			Linger: ctx.Duration(lingerFlag.Name),
+		}
+		var output *p2p.CaptureWriter
+		if file := ctx.String(outputFlag.Name); file != "" {
+			f, err := os.Create(file)
+			if err != nil {
+				return fmt.Errorf("failed to create output file: %v", err)
+			}
+			defer f.Close()
+			output = p2p.NewCaptureWriter(f)
+		}
+		cfg.Output = func(rec *p2p.CaptureRecord) {
+			dir := "->"
+			if rec.Inbound {
+				dir = "<-"
+			}
+			fmt.Printf("%s %s/%d code=%d size=%d\n", dir, rec.Protocol, rec.Version, rec.Code, rec.Size)
+			if output != nil {
+				if err := output.Write(rec); err != nil {
+					log.Error("Failed to write output", "err", err)
+				}
+			}
+		}
+//连接到运行中的节点或进程内节点
+		fd, dest, stop, err := dialReplayNode(ctx)
+		if err != nil {
+			return err
+		}
+		defer stop()
+
+		log.Info("Replaying capture", "peer", records[0].Peer, "messages", len(records), "node", dest.ID())
+//返回错误而不是退出进程，以便延迟的stop和文件关闭得以执行
+		if err := p2p.ReplayCapture(fd, dest, records, cfg); err != nil {
+			return fmt.Errorf("replay failed: %v", err)
+		}
+		return nil
+	},
+}
+
+//dialReplayNode连接到要重放的节点。返回的stop函数停止进程内节点。
+func dialReplayNode(ctx *cli.Context) (net.Conn, *enode.Node, func(), error) {
+	if url := ctx.String(nodeFlag.Name); url != "" {
+		dest, err := enode.ParseV4(url)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("invalid node URL: %v", err)
+		}
+		fd, err := net.DialTimeout("tcp", fmt.Sprintf("%v:%d", dest.IP(), dest.TCP()), 10*time.Second)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		return fd, dest, func() {}, nil
+	}
+	datadir := ctx.String(datadirFlag.Name)
+	if datadir == "" {
+		return nil, nil, nil, fmt.Errorf("need --%s or --%s", nodeFlag.Name, datadirFlag.Name)
+	}
+//启动不监听、不拨号的进程内节点，通过内存管道接受重放连接
+	stack, err := node.New(&node.Config{
+		Name:    "p2pcapture",
+		DataDir: datadir,
+		P2P: p2p.Config{
+			MaxPeers:    1,
+			NoDiscovery: true,
+			NoDial:      true,
+		},
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	config := eth.DefaultConfig
+	config.NetworkId = ctx.Uint64(networkIdFlag.Name)
+	utils.RegisterEthService(stack, &config)
+	if err := stack.Start(); err != nil {
+		return nil, nil, nil, err
+	}
+	srv := stack.Server()
+	fd, remote, err := pipes.NetPipe()
+	if err != nil {
+		stack.Stop()
+		return nil, nil, nil, err
+	}
+	go srv.SetupConn(remote, 0, nil)
+	return fd, srv.Self(), func() { stack.Stop() }, nil
+}
@@ -45,6 +45,7 @@ import (
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/metrics/influxdb"
+	"github.com/ethereum/go-ethereum/miner"
 	"github.com/ethereum/go-ethereum/node"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/discv5"
@@ -160,9 +161,17 @@ var (
 	defaultSyncMode = eth.DefaultConfig.SyncMode
 	SyncModeFlag    = TextMarshalerFlag{
 		Name:  "syncmode",
-		Usage: `Blockchain sync mode ("fast", "full", or "light")`,
+		Usage: `Blockchain sync mode ("fast", "full", "light", "snap" or "pruned")`,
 		Value: &defaultSyncMode,
 	}
+	SyncCheckpointFlag = cli.StringFlag{
+		Name:  "synccheckpoint",
+		Usage: "Trusted block hash to sync from: headers are downloaded backwards from it and state is fetched at it",
+	}
+	BodyRetentionFlag = cli.Uint64Flag{
+		Name:  "bodyretention",
+		Usage: "Number of recent blocks to keep bodies and receipts for, older blocks keep only headers (0 = keep all, pruned sync defaults to 90000)",
+	}
 	GCModeFlag = cli.StringFlag{
 		Name:  "gcmode",
 		Usage: `Blockchain garbage collection mode ("full", "archive")`,
@@ -236,6 +245,16 @@ var (
 		Usage: "Number of recent ethash mining DAGs to keep on disk (1+GB each)",
 		Value: eth.DefaultConfig.Ethash.DatasetsOnDisk,
 	}
+	EthashStratumFlag = cli.StringFlag{
+		Name:  "ethash.stratum",
+		Usage: "Stratum (EthereumStratum/1.0) server listening address for remote miners (e.g. \"0.0.0.0:8008\", default = disabled)",
+		Value: eth.DefaultConfig.Ethash.StratumAddr,
+	}
+	EthashStratumDifficultyFlag = cli.Uint64Flag{
+		Name:  "ethash.stratumdiff",
+		Usage: "Minimum number of hashes per stratum share (workers may raise it with password \"d=<hashes>\")",
+		Value: eth.DefaultConfig.Ethash.StratumDifficulty,
+	}
 //事务池设置
 	TxPoolLocalsFlag = cli.StringFlag{
 		Name:  "txpool.locals",
@@ -255,6 +274,11 @@ var (
 		Usage: "Time interval to regenerate the local transaction journal",
 		Value: core.DefaultTxPoolConfig.Rejournal,
 	}
+	TxPoolSnapshotFlag = cli.StringFlag{
+		Name:  "txpool.snapshot",
+		Usage: "Disk snapshot of remote transactions to survive node restarts (disabled if empty)",
+		Value: core.DefaultTxPoolConfig.Snapshot,
+	}
 	TxPoolPriceLimitFlag = cli.Uint64Flag{
 		Name:  "txpool.pricelimit",
 		Usage: "Minimum gas price limit to enforce for acceptance into the pool",
@@ -290,6 +314,16 @@ var (
 		Usage: "Maximum amount of time non-executable transaction are queued",
 		Value: eth.DefaultConfig.TxPool.Lifetime,
 	}
+	TxPoolSenderRateFlag = cli.Uint64Flag{
+		Name:  "txpool.senderrate",
+		Usage: "Average number of remote transactions per second admitted from a single sender (0 = unlimited)",
+		Value: eth.DefaultConfig.TxPool.SenderRate,
+	}
+	TxPoolSenderBurstFlag = cli.Uint64Flag{
+		Name:  "txpool.senderburst",
+		Usage: "Maximum number of remote transactions admitted from a single sender at once",
+		Value: eth.DefaultConfig.TxPool.SenderBurst,
+	}
 //性能调整设置
 	CacheFlag = cli.IntFlag{
 		Name:  "cache",
@@ -387,6 +421,11 @@ var (
 		Name:  "miner.noverify",
 		Usage: "Disable remote sealing verification",
 	}
+	MinerOrderingFlag = cli.StringFlag{
+		Name:  "miner.ordering",
+		Usage: `Transaction ordering policy for block building ("price", "fifo" or "fair")`,
+		Value: miner.OrderingPriceAndNonce,
+	}
 //帐户设置
 	UnlockedAccountFlag = cli.StringFlag{
 		Name:  "unlock",
@@ -498,6 +537,21 @@ var (
 		Usage: "Maximum number of pending connection attempts (defaults used if set to 0)",
 		Value: 0,
 	}
+	MinOutboundPeersFlag = cli.IntFlag{
+		Name:  "minoutpeers",
+		Usage: "Minimum number of peer slots reserved for outbound connections",
+		Value: 0,
+	}
+	InboundSubnetLimitFlag = cli.IntFlag{
+		Name:  "inboundsubnetlimit",
+		Usage: "Maximum number of inbound peers from the same IP subnet (no limit if set to 0)",
+		Value: 0,
+	}
+	InboundSubnetBitsFlag = cli.UintFlag{
+		Name:  "inboundsubnetbits",
+		Usage: "Prefix length of the IPv4 subnets counted by --inboundsubnetlimit (defaults used if set to 0)",
+		Value: 0,
+	}
 	ListenPortFlag = cli.IntFlag{
 		Name:  "port",
 		Usage: "Network listening port",
@@ -539,10 +593,26 @@ var (
 		Name:  "v5disc",
 		Usage: "Enables the experimental RLPx V5 (Topic Discovery) mechanism",
 	}
+	DiscoveryV51Flag = cli.BoolFlag{
+		Name:  "v51disc",
+		Usage: "Enables the V5.1 discovery mechanism, which runs alongside V4 on the same UDP port",
+	}
 	NetrestrictFlag = cli.StringFlag{
 		Name:  "netrestrict",
 		Usage: "Restricts network communication to the given IP networks (CIDR masks)",
 	}
+	DNSDiscoveryFlag = cli.StringFlag{
+		Name:  "discovery.dns",
+		Usage: "Comma separated list of enrtree:// URLs of DNS node trees used to find peers",
+	}
+	CaptureFileFlag = cli.StringFlag{
+		Name:  "capture.file",
+		Usage: "Append all sub-protocol messages exchanged with peers to this file (see p2pcapture)",
+	}
+	CaptureProtocolsFlag = cli.StringFlag{
+		Name:  "capture.protocols",
+		Usage: "Comma separated list of sub-protocols to capture (default = all)",
+	}
 
 //将URL留给用户并部署到的ATM
 	JSpathFlag = cli.StringFlag{
@@ -938,6 +1008,15 @@ func SetP2PConfig(ctx *cli.Context, cfg *p2p.Config) {
 	if ctx.GlobalIsSet(MaxPendingPeersFlag.Name) {
 		cfg.MaxPendingPeers = ctx.GlobalInt(MaxPendingPeersFlag.Name)
 	}
+	if ctx.GlobalIsSet(MinOutboundPeersFlag.Name) {
+		cfg.MinOutboundPeers = ctx.GlobalInt(MinOutboundPeersFlag.Name)
+	}
+	if ctx.GlobalIsSet(InboundSubnetLimitFlag.Name) {
+		cfg.InboundSubnetLimit = ctx.GlobalInt(InboundSubnetLimitFlag.Name)
+	}
+	if ctx.GlobalIsSet(InboundSubnetBitsFlag.Name) {
+		cfg.InboundSubnetBits = ctx.GlobalUint(InboundSubnetBitsFlag.Name)
+	}
 	if ctx.GlobalIsSet(NoDiscoverFlag.Name) || lightClient {
 		cfg.NoDiscovery = true
 	}
@@ -951,6 +1030,18 @@ func SetP2PConfig(ctx *cli.Context, cfg *p2p.Config) {
 	} else if forceV5Discovery {
 		cfg.DiscoveryV5 = true
 	}
+	if ctx.GlobalIsSet(DiscoveryV51Flag.Name) {
+		cfg.DiscoveryV51 = ctx.GlobalBool(DiscoveryV51Flag.Name)
+	}
+	if urls := ctx.GlobalString(DNSDiscoveryFlag.Name); urls != "" {
+		cfg.DiscoveryDNS = splitAndTrim(urls)
+	}
+	if ctx.GlobalIsSet(CaptureFileFlag.Name) {
+		cfg.CaptureFile = ctx.GlobalString(CaptureFileFlag.Name)
+	}
+	if protocols := ctx.GlobalString(CaptureProtocolsFlag.Name); protocols != "" {
+		cfg.CaptureProtocols = splitAndTrim(protocols)
+	}
 
 	if netrestrict := ctx.GlobalString(NetrestrictFlag.Name); netrestrict != "" {
 		list, err := netutil.ParseNetlist(netrestrict)
@@ -966,6 +1057,7 @@ func SetP2PConfig(ctx *cli.Context, cfg *p2p.Config) {
 		cfg.ListenAddr = ":0"
 		cfg.NoDiscovery = true
 		cfg.DiscoveryV5 = false
+		cfg.DiscoveryV51 = false
 	}
 }
 
@@ -1032,6 +1124,9 @@ func setTxPool(ctx *cli.Context, cfg *core.TxPoolConfig) {
 	if ctx.GlobalIsSet(TxPoolRejournalFlag.Name) {
 		cfg.Rejournal = ctx.GlobalDuration(TxPoolRejournalFlag.Name)
 	}
+	if ctx.GlobalIsSet(TxPoolSnapshotFlag.Name) {
+		cfg.Snapshot = ctx.GlobalString(TxPoolSnapshotFlag.Name)
+	}
 	if ctx.GlobalIsSet(TxPoolPriceLimitFlag.Name) {
 		cfg.PriceLimit = ctx.GlobalUint64(TxPoolPriceLimitFlag.Name)
 	}
@@ -1053,6 +1148,12 @@ func setTxPool(ctx *cli.Context, cfg *core.TxPoolConfig) {
 	if ctx.GlobalIsSet(TxPoolLifetimeFlag.Name) {
 		cfg.Lifetime = ctx.GlobalDuration(TxPoolLifetimeFlag.Name)
 	}
+	if ctx.GlobalIsSet(TxPoolSenderRateFlag.Name) {
+		cfg.SenderRate = ctx.GlobalUint64(TxPoolSenderRateFlag.Name)
+	}
+	if ctx.GlobalIsSet(TxPoolSenderBurstFlag.Name) {
+		cfg.SenderBurst = ctx.GlobalUint64(TxPoolSenderBurstFlag.Name)
+	}
 }
 
 func setEthash(ctx *cli.Context, cfg *eth.Config) {
@@ -1074,6 +1175,12 @@ func setEthash(ctx *cli.Context, cfg *eth.Config) {
 	if ctx.GlobalIsSet(EthashDatasetsOnDiskFlag.Name) {
 		cfg.Ethash.DatasetsOnDisk = ctx.GlobalInt(EthashDatasetsOnDiskFlag.Name)
 	}
+	if ctx.GlobalIsSet(EthashStratumFlag.Name) {
+		cfg.Ethash.StratumAddr = ctx.GlobalString(EthashStratumFlag.Name)
+	}
+	if ctx.GlobalIsSet(EthashStratumDifficultyFlag.Name) {
+		cfg.Ethash.StratumDifficulty = ctx.GlobalUint64(EthashStratumDifficultyFlag.Name)
+	}
 }
 
 func setWhitelist(ctx *cli.Context, cfg *eth.Config) {
@@ -1169,6 +1276,14 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 	if ctx.GlobalIsSet(SyncModeFlag.Name) {
 		cfg.SyncMode = *GlobalTextMarshaler(ctx, SyncModeFlag.Name).(*downloader.SyncMode)
 	}
+	if ctx.GlobalIsSet(SyncCheckpointFlag.Name) {
+		if err := cfg.SyncCheckpoint.UnmarshalText([]byte(ctx.GlobalString(SyncCheckpointFlag.Name))); err != nil {
+			Fatalf("Invalid sync checkpoint hash: %v", err)
+		}
+	}
+	if ctx.GlobalIsSet(BodyRetentionFlag.Name) {
+		cfg.BodyRetention = ctx.GlobalUint64(BodyRetentionFlag.Name)
+	}
 	if ctx.GlobalIsSet(LightServFlag.Name) {
 		cfg.LightServ = ctx.GlobalInt(LightServFlag.Name)
 	}
@@ -1227,6 +1342,9 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 	if ctx.GlobalIsSet(MinerNoVerfiyFlag.Name) {
 		cfg.MinerNoverify = ctx.Bool(MinerNoVerfiyFlag.Name)
 	}
+	if ctx.GlobalIsSet(MinerOrderingFlag.Name) {
+		cfg.MinerOrdering = ctx.GlobalString(MinerOrderingFlag.Name)
+	}
 	if ctx.GlobalIsSet(VMEnableDebugFlag.Name) {
 //TODO（FJL）：强制启用--dev模式
 		cfg.EnablePreimageRecording = ctx.GlobalBool(VMEnableDebugFlag.Name)
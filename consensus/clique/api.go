@@ -114,3 +114,19 @@ func (api *API) Discard(address common.Address) {
 	delete(api.clique.proposals, address)
 }
 
+//Status返回最近window个块（默认64个）内每个签名者密封、错过的块和最后密封的块，
+//用于检测停止工作的签名者。
+func (api *API) Status(window *uint64) (*Status, error) {
+	size := uint64(defaultStatusWindow)
+	if window != nil {
+		size = *window
+	}
+	if size > maxStatusWindow {
+		size = maxStatusWindow
+	}
+	header := api.chain.CurrentHeader()
+	if header == nil {
+		return nil, errUnknownBlock
+	}
+	return api.clique.status(api.chain, header, size)
+}
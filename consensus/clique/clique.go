@@ -18,6 +18,7 @@ import (
 	"errors"
 	"math/big"
 	"math/rand"
+	"sort"
 	"sync"
 	"time"
 
@@ -290,6 +291,10 @@ func (c *Clique) verifyHeader(chain consensus.ChainReader, header *types.Header,
 	if checkpoint && !bytes.Equal(header.Nonce[:], nonceDropVote) {
 		return errInvalidCheckpointVote
 	}
+//治理模式下签名者由合约管理，不允许头内投票
+	if governanceActive(c.config, number) && (header.Coinbase != (common.Address{}) || !bytes.Equal(header.Nonce[:], nonceDropVote)) {
+		return errGovernanceVote
+	}
 //检查额外数据是否包含虚荣和签名
 	if len(header.Extra) < extraVanity {
 		return errMissingVanity
@@ -355,8 +360,19 @@ func (c *Clique) verifyCascadingFields(chain consensus.ChainReader, header *type
 	if err != nil {
 		return err
 	}
-//如果该块是检查点块，请验证签名者列表
-	if number%c.config.Epoch == 0 {
+//如果该块是检查点块，请验证签名者列表。治理模式下的列表来自合约
+//状态，只能在处理块时验证，这里仅检查其格式。
+	if governanceEpoch(c.config, number) {
+		signers := checkpointSigners(header)
+		if len(signers) == 0 || !sort.IsSorted(signersAscending(signers)) {
+			return errInvalidCheckpointSigners
+		}
+		for i := 1; i < len(signers); i++ {
+			if signers[i] == signers[i-1] {
+				return errInvalidCheckpointSigners
+			}
+		}
+	} else if number%c.config.Epoch == 0 {
 		signers := make([]byte, len(snap.Signers)*common.AddressLength)
 		for i, signer := range snap.signers() {
 			copy(signers[i*common.AddressLength:], signer[:])
@@ -505,6 +521,7 @@ func (c *Clique) verifySeal(chain consensus.ChainReader, header *types.Header, p
 			return errWrongDifficulty
 		}
 	}
+	recordSeal(snap, header, signer)
 	return nil
 }
 
@@ -521,7 +538,7 @@ func (c *Clique) Prepare(chain consensus.ChainReader, header *types.Header) erro
 	if err != nil {
 		return err
 	}
-	if number%c.config.Epoch != 0 {
+	if number%c.config.Epoch != 0 && !governanceActive(c.config, number) {
 		c.lock.RLock()
 
 //收集所有有意义的投票提案
@@ -576,6 +593,12 @@ func (c *Clique) Prepare(chain consensus.ChainReader, header *types.Header) erro
 //完成执行共识。引擎，确保没有设置叔叔，也没有阻止
 //奖励，并返回最后一个块。
 func (c *Clique) Finalize(chain consensus.ChainReader, header *types.Header, state *state.StateDB, txs []*types.Transaction, uncles []*types.Header, receipts []*types.Receipt) (*types.Block, error) {
+//治理模式的检查点从合约状态中获取签名者列表
+	if governanceEpoch(c.config, header.Number.Uint64()) {
+		if err := c.finalizeGovernance(chain, header, state); err != nil {
+			return nil, err
+		}
+	}
 //在POA中没有集体奖励，所以国家保持原样，叔叔们被抛弃。
 	header.Root = state.IntermediateRoot(chain.Config().IsEIP158(header.Number))
 	header.UncleHash = types.CalcUncleHash(nil)
@@ -0,0 +1,130 @@
+
+//<developer>
+//    <name>linapex 曹一峰</name>
+//    <email>linapex@163.com</email>
+//    <wx>superexc</wx>
+//    <qqgroup>128148617</qqgroup>
+//    <url>https://jsq.ink</url>
+//    <role>pku engineer</role>
+//    <date>2019-03-16 19:16:34</date>
+//</624450074620989440>
+
+
+package clique
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+//maxGovernanceSigners是从治理合约中接受的最大签名者数目，超过此
+//数目的列表被视为无效。
+const maxGovernanceSigners = 128
+
+var (
+//如果在治理模式下的块包含头内投票，则返回errGovernanceVote。
+	errGovernanceVote = errors.New("vote in governance mode block")
+
+//如果治理检查点块中的签名者列表与治理合约的状态不匹配，
+//则返回errMismatchingGovernanceSigners。
+	errMismatchingGovernanceSigners = errors.New("signer list mismatches governance contract")
+)
+
+//governanceActive返回签名者集在给定块上是否由治理合约管理。
+func governanceActive(config *params.CliqueConfig, number uint64) bool {
+	return config.Governance != nil && number > 0 && number >= config.GovernanceBlock
+}
+
+//governanceEpoch返回给定块是否是从治理合约读取签名者列表的检查点。
+func governanceEpoch(config *params.CliqueConfig, number uint64) bool {
+	return governanceActive(config, number) && number%config.Epoch == 0
+}
+
+//governanceSigners从治理合约存储中读取授权签名者列表，该列表是
+//配置的存储槽中的solidity动态地址数组。返回的列表已去重并
+//按升序排序，如果合约没有提供有效的列表，则返回nil。
+func governanceSigners(config *params.CliqueConfig, statedb *state.StateDB) []common.Address {
+	slot := common.BigToHash(new(big.Int).SetUint64(config.GovernanceSlot))
+
+	length := statedb.GetState(*config.Governance, slot).Big()
+	if length.Sign() == 0 || length.Cmp(big.NewInt(maxGovernanceSigners)) > 0 {
+		return nil
+	}
+//数组元素从槽哈希开始连续存储
+	base := crypto.Keccak256Hash(slot[:]).Big()
+
+	seen := make(map[common.Address]struct{})
+	signers := make([]common.Address, 0, length.Uint64())
+	for i := uint64(0); i < length.Uint64(); i++ {
+		key := common.BigToHash(new(big.Int).Add(base, new(big.Int).SetUint64(i)))
+		signer := common.BytesToAddress(statedb.GetState(*config.Governance, key).Bytes())
+		if signer == (common.Address{}) {
+			continue
+		}
+		if _, ok := seen[signer]; ok {
+			continue
+		}
+		seen[signer] = struct{}{}
+		signers = append(signers, signer)
+	}
+	if len(signers) == 0 {
+		return nil
+	}
+	sort.Sort(signersAscending(signers))
+	return signers
+}
+
+//checkpointSigners提取检查点头的额外数据中的签名者列表。
+func checkpointSigners(header *types.Header) []common.Address {
+	signers := make([]common.Address, (len(header.Extra)-extraVanity-extraSeal)/common.AddressLength)
+	for i := 0; i < len(signers); i++ {
+		copy(signers[i][:], header.Extra[extraVanity+i*common.AddressLength:])
+	}
+	return signers
+}
+
+//finalizeGovernance将治理合约中的签名者列表与检查点头进行协调。
+//对于尚未密封的头（本地挖掘），列表被写入额外数据；对于已密封的头
+//（导入），列表必须与合约状态完全匹配。
+func (c *Clique) finalizeGovernance(chain consensus.ChainReader, header *types.Header, statedb *state.StateDB) error {
+	if len(header.Extra) < extraVanity+extraSeal {
+		return errMissingSignature
+	}
+	signers := governanceSigners(c.config, statedb)
+	if signers == nil {
+//合约未提供有效列表，保留当前签名者集以免链停止
+		number := header.Number.Uint64()
+		snap, err := c.snapshot(chain, number-1, header.ParentHash, nil)
+		if err != nil {
+			return err
+		}
+		signers = snap.signers()
+		log.Warn("Governance contract has no valid signer list", "number", number, "contract", *c.config.Governance)
+	}
+	list := make([]byte, len(signers)*common.AddressLength)
+	for i, signer := range signers {
+		copy(list[i*common.AddressLength:], signer[:])
+	}
+	seal := header.Extra[len(header.Extra)-extraSeal:]
+	if bytes.Equal(seal, make([]byte, extraSeal)) {
+		extra := make([]byte, 0, extraVanity+len(list)+extraSeal)
+		extra = append(extra, header.Extra[:extraVanity]...)
+		extra = append(extra, list...)
+		header.Extra = append(extra, seal...)
+		return nil
+	}
+	if !bytes.Equal(header.Extra[extraVanity:len(header.Extra)-extraSeal], list) {
+		return errMismatchingGovernanceSigners
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+
+//<developer>
+//    <name>linapex 曹一峰</name>
+//    <email>linapex@163.com</email>
+//    <wx>superexc</wx>
+//    <qqgroup>128148617</qqgroup>
+//    <url>https://jsq.ink</url>
+//    <role>pku engineer</role>
+//    <date>2019-03-16 19:16:34</date>
+//</624450074666078208>
+
+
+package clique
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+//newGovernanceChain创建一个由A签名的集团链，其治理合约授权A和B，
+//并使用给定的函数在密封前修改检查点头。
+func newGovernanceChain(t *testing.T, accounts *testerAccountPool, tamper func(header *types.Header)) (*core.BlockChain, *Clique, []*types.Block) {
+	var (
+		contract = common.HexToAddress("0x1000")
+		signerA  = accounts.address("A")
+		signerB  = accounts.address("B")
+		base     = crypto.Keccak256Hash(common.Hash{}.Bytes()).Big()
+	)
+	genesis := &core.Genesis{
+		ExtraData: make([]byte, extraVanity+common.AddressLength+extraSeal),
+		Alloc: core.GenesisAlloc{
+			contract: {
+				Balance: new(big.Int),
+				Code:    []byte{0x00},
+				Storage: map[common.Hash]common.Hash{
+					common.Hash{}:          common.BigToHash(big.NewInt(2)),
+					common.BigToHash(base): common.BytesToHash(signerB.Bytes()),
+					common.BigToHash(new(big.Int).Add(base, common.Big1)): common.BytesToHash(signerA.Bytes()),
+				},
+			},
+		},
+	}
+	copy(genesis.ExtraData[extraVanity:], signerA[:])
+
+	db := ethdb.NewMemDatabase()
+	genesis.Commit(db)
+
+	config := *params.TestChainConfig
+	config.Clique = &params.CliqueConfig{
+		Period:     1,
+		Epoch:      3,
+		Governance: &contract,
+	}
+	engine := New(config.Clique, db)
+	engine.fakeDiff = true
+
+//检查点在最终确定时需要虚荣和签名的空间来填入合约的签名者列表
+	blocks, _ := core.GenerateChain(&config, genesis.ToBlock(db), engine, db, 4, func(i int, block *core.BlockGen) {
+		block.SetExtra(make([]byte, extraVanity+extraSeal))
+	})
+	for i, block := range blocks {
+		header := block.Header()
+		if i > 0 {
+			header.ParentHash = blocks[i-1].Hash()
+		}
+		if header.Number.Uint64()%config.Clique.Epoch != 0 {
+			header.Extra = make([]byte, extraVanity+extraSeal)
+		} else if tamper != nil {
+			tamper(header)
+		}
+		header.Difficulty = diffInTurn
+
+//最后一个块由仅通过治理合约授权的B签名
+		signer := "A"
+		if i == len(blocks)-1 {
+			signer = "B"
+		}
+		accounts.sign(header, signer)
+		blocks[i] = block.WithSeal(header)
+	}
+	chain, err := core.NewBlockChain(db, nil, &config, engine, vm.Config{}, nil)
+	if err != nil {
+		t.Fatalf("failed to create test chain: %v", err)
+	}
+	return chain, engine, blocks
+}
+
+//测试治理模式下检查点从合约存储中读取签名者集，并且快照缓存该结果。
+func TestGovernanceSigners(t *testing.T) {
+	accounts := newTesterAccountPool()
+
+	chain, engine, blocks := newGovernanceChain(t, accounts, nil)
+	defer chain.Stop()
+
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to import governance chain: %v", err)
+	}
+	head := blocks[len(blocks)-1]
+	snap, err := engine.snapshot(chain, head.NumberU64(), head.Hash(), nil)
+	if err != nil {
+		t.Fatalf("failed to retrieve snapshot: %v", err)
+	}
+	if len(snap.Signers) != 2 {
+		t.Fatalf("signer count mismatch: have %d, want %d", len(snap.Signers), 2)
+	}
+	for _, name := range []string{"A", "B"} {
+		if _, ok := snap.Signers[accounts.address(name)]; !ok {
+			t.Errorf("signer %s not authorized", name)
+		}
+	}
+}
+
+//测试签名者列表与治理合约不匹配的检查点被拒绝。
+func TestGovernanceMismatchingCheckpoint(t *testing.T) {
+	accounts := newTesterAccountPool()
+
+	chain, _, blocks := newGovernanceChain(t, accounts, func(header *types.Header) {
+		header.Extra = make([]byte, extraVanity+common.AddressLength+extraSeal)
+		accounts.checkpoint(header, []string{"A"})
+	})
+	defer chain.Stop()
+
+	if _, err := chain.InsertChain(blocks[:3]); err != errMismatchingGovernanceSigners {
+		t.Fatalf("checkpoint error mismatch: have %v, want %v", err, errMismatchingGovernanceSigners)
+	}
+}
@@ -0,0 +1,127 @@
+
+//<developer>
+//    <name>linapex 曹一峰</name>
+//    <email>linapex@163.com</email>
+//    <wx>superexc</wx>
+//    <qqgroup>128148617</qqgroup>
+//    <url>https://jsq.ink</url>
+//    <role>pku engineer</role>
+//    <date>2019-03-16 19:16:34</date>
+//</624450074633572352>
+
+
+package clique
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+const (
+defaultStatusWindow = 64   //未指定时状态统计的块数
+maxStatusWindow     = 8192 //状态统计允许的最大块数
+)
+
+var (
+inturnMeter    = metrics.NewRegisteredMeter("clique/blocks/inturn", nil)    //轮到的签名者密封的块
+outOfTurnMeter = metrics.NewRegisteredMeter("clique/blocks/outofturn", nil) //非轮到的签名者密封的块（即错过的槽）
+)
+
+//SignerStatus是单个签名者在统计窗口内的活跃度。
+type SignerStatus struct {
+Produced  uint64 `json:"produced"`  //签名者在窗口内密封的块
+InTurn    uint64 `json:"inTurn"`    //其中轮到签名者时密封的块
+Missed    uint64 `json:"missed"`    //轮到签名者但由其他签名者密封的块
+LastBlock uint64 `json:"lastBlock"` //签名者在窗口内密封的最后一个块（0=窗口内没有）
+}
+
+//Status是最近一个块窗口内签名者的活跃度统计。
+type Status struct {
+Number        uint64                           `json:"number"`        //窗口中最后一个块的块号
+NumBlocks     uint64                           `json:"numBlocks"`     //窗口中的块数
+InturnPercent float64                          `json:"inturnPercent"` //由轮到的签名者密封的块的百分比
+Signers       map[common.Address]*SignerStatus `json:"signers"`       //每个签名者的统计
+}
+
+//recordSeal在已验证的头上更新活跃度指标。
+func recordSeal(snap *Snapshot, header *types.Header, signer common.Address) {
+	if !metrics.Enabled {
+		return
+	}
+	number := header.Number.Uint64()
+	if inturn := snap.inturnSigner(number); inturn == signer {
+		inturnMeter.Mark(1)
+	} else {
+		outOfTurnMeter.Mark(1)
+		metrics.GetOrRegisterCounter("clique/missed/"+inturn.Hex(), nil).Inc(1)
+	}
+	metrics.GetOrRegisterGauge("clique/lastblock/"+signer.Hex(), nil).Update(int64(number))
+}
+
+//status统计以给定头结束的窗口内每个签名者密封和错过的块。非轮到的块被记为
+//当时轮到的签名者错过了槽。
+func (c *Clique) status(chain consensus.ChainReader, head *types.Header, window uint64) (*Status, error) {
+	number := head.Number.Uint64()
+	if window > number {
+		window = number
+	}
+	status := &Status{
+		Number:    number,
+		NumBlocks: window,
+		Signers:   make(map[common.Address]*SignerStatus),
+	}
+	entry := func(signer common.Address) *SignerStatus {
+		if status.Signers[signer] == nil {
+			status.Signers[signer] = new(SignerStatus)
+		}
+		return status.Signers[signer]
+	}
+	if window == 0 {
+		return status, nil
+	}
+//收集窗口内的头，从旧到新
+	headers := make([]*types.Header, window)
+	for i, header := int(window)-1, head; i >= 0; i-- {
+		if header == nil {
+			return nil, errUnknownBlock
+		}
+		headers[i] = header
+		header = chain.GetHeader(header.ParentHash, header.Number.Uint64()-1)
+	}
+//从窗口开始前的快照重放每个头，以便知道每个高度轮到的签名者
+	start := headers[0].Number.Uint64()
+	snap, err := c.snapshot(chain, start-1, headers[0].ParentHash, nil)
+	if err != nil {
+		return nil, err
+	}
+	inturns := 0
+	for _, header := range headers {
+		signer, err := ecrecover(header, c.signatures)
+		if err != nil {
+			return nil, err
+		}
+		number := header.Number.Uint64()
+
+		stats := entry(signer)
+		stats.Produced++
+		stats.LastBlock = number
+
+		if expected := snap.inturnSigner(number); expected == signer {
+			stats.InTurn++
+			inturns++
+		} else {
+			entry(expected).Missed++
+		}
+		if snap, err = snap.apply([]*types.Header{header}); err != nil {
+			return nil, err
+		}
+	}
+//确保当前所有签名者都出现在结果中，即使他们在窗口内没有密封任何块
+	for _, signer := range snap.signers() {
+		entry(signer)
+	}
+	status.InturnPercent = float64(inturns) * 100 / float64(window)
+	return status, nil
+}
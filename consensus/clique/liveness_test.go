@@ -0,0 +1,114 @@
+
+//<developer>
+//    <name>linapex 曹一峰</name>
+//    <email>linapex@163.com</email>
+//    <wx>superexc</wx>
+//    <qqgroup>128148617</qqgroup>
+//    <url>https://jsq.ink</url>
+//    <role>pku engineer</role>
+//    <date>2019-03-16 19:16:34</date>
+//</624450074641960960>
+
+
+package clique
+
+import (
+	"bytes"
+	"sort"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+//测试签名者状态正确统计轮到的块和错过的槽。
+func TestSignerStatus(t *testing.T) {
+	accounts := newTesterAccountPool()
+
+//按地址排序签名者，使第n个块轮到names[n%3]
+	names := []string{"A", "B", "C"}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := accounts.address(names[i]), accounts.address(names[j])
+		return bytes.Compare(a[:], b[:]) < 0
+	})
+	genesis := &core.Genesis{
+		ExtraData: make([]byte, extraVanity+common.AddressLength*len(names)+extraSeal),
+	}
+	for i, name := range names {
+		copy(genesis.ExtraData[extraVanity+i*common.AddressLength:], accounts.address(name).Bytes())
+	}
+	db := ethdb.NewMemDatabase()
+	genesis.Commit(db)
+
+	config := *params.TestChainConfig
+	config.Clique = &params.CliqueConfig{Period: 1, Epoch: 30000}
+	engine := New(config.Clique, db)
+	engine.fakeDiff = true
+
+//块1-3轮到的签名者密封，块4-6由其他签名者密封
+	sealers := []int{1, 2, 0, 2, 0, 1}
+
+	blocks, _ := core.GenerateChain(&config, genesis.ToBlock(db), engine, db, len(sealers), nil)
+	for i, block := range blocks {
+		header := block.Header()
+		if i > 0 {
+			header.ParentHash = blocks[i-1].Hash()
+		}
+		header.Extra = make([]byte, extraVanity+extraSeal)
+		header.Difficulty = diffInTurn
+
+		accounts.sign(header, names[sealers[i]])
+		blocks[i] = block.WithSeal(header)
+	}
+	chain, err := core.NewBlockChain(db, nil, &config, engine, vm.Config{}, nil)
+	if err != nil {
+		t.Fatalf("failed to create test chain: %v", err)
+	}
+	defer chain.Stop()
+
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to import chain: %v", err)
+	}
+	tests := []struct {
+		window  uint64
+		percent float64
+		want    map[string]SignerStatus
+	}{
+		{
+			window:  6,
+			percent: 50,
+			want: map[string]SignerStatus{
+				names[0]: {Produced: 2, InTurn: 1, Missed: 1, LastBlock: 5},
+				names[1]: {Produced: 2, InTurn: 1, Missed: 1, LastBlock: 6},
+				names[2]: {Produced: 2, InTurn: 1, Missed: 1, LastBlock: 4},
+			},
+		},
+		{
+			window:  3,
+			percent: 0,
+			want: map[string]SignerStatus{
+				names[0]: {Produced: 1, InTurn: 0, Missed: 1, LastBlock: 5},
+				names[1]: {Produced: 1, InTurn: 0, Missed: 1, LastBlock: 6},
+				names[2]: {Produced: 1, InTurn: 0, Missed: 1, LastBlock: 4},
+			},
+		},
+	}
+	for i, tt := range tests {
+		status, err := engine.status(chain, chain.CurrentHeader(), tt.window)
+		if err != nil {
+			t.Fatalf("test %d: failed to retrieve status: %v", i, err)
+		}
+		if status.NumBlocks != tt.window || status.InturnPercent != tt.percent {
+			t.Errorf("test %d: window mismatch: have %d blocks %v%%, want %d blocks %v%%", i, status.NumBlocks, status.InturnPercent, tt.window, tt.percent)
+		}
+		for name, want := range tt.want {
+			have := status.Signers[accounts.address(name)]
+			if have == nil || *have != want {
+				t.Errorf("test %d: signer %s status mismatch: have %+v, want %+v", i, name, have, want)
+			}
+		}
+	}
+}
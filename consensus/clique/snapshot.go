@@ -219,6 +219,27 @@ func (s *Snapshot) apply(headers []*types.Header) (*Snapshot, error) {
 		}
 		snap.Recents[number] = signer
 
+//治理模式下忽略投票，签名者集仅在检查点从合约结果中更新
+		if governanceActive(s.config, number) {
+			if len(snap.Votes) > 0 || len(snap.Tally) > 0 {
+				snap.Votes = nil
+				snap.Tally = make(map[common.Address]Tally)
+			}
+			if number%s.config.Epoch == 0 {
+				snap.Signers = make(map[common.Address]struct{})
+				for _, signer := range checkpointSigners(header) {
+					snap.Signers[signer] = struct{}{}
+				}
+//签名者集已更改，删除新窗口之外的所有最近签名者
+				limit := uint64(len(snap.Signers)/2 + 1)
+				for block := range snap.Recents {
+					if block+limit <= number {
+						delete(snap.Recents, block)
+					}
+				}
+			}
+			continue
+		}
 //标题已授权，放弃签名者以前的任何投票
 		for i, vote := range snap.Votes {
 			if vote.Signer == signer && vote.Address == header.Coinbase {
@@ -298,6 +319,15 @@ func (s *Snapshot) signers() []common.Address {
 	return sigs
 }
 
+//inturnSigner返回给定块高度上轮到签名的签名者。
+func (s *Snapshot) inturnSigner(number uint64) common.Address {
+	signers := s.signers()
+	if len(signers) == 0 {
+		return common.Address{}
+	}
+	return signers[number%uint64(len(signers))]
+}
+
 //如果给定块高度的签名者依次是或不是，则Inturn返回。
 func (s *Snapshot) inturn(number uint64, signer common.Address) bool {
 	signers, offset := s.signers(), 0
@@ -46,7 +46,7 @@ var (
 	two256 = new(big.Int).Exp(big.NewInt(2), big.NewInt(256), big.NewInt(0))
 
 //sharedethash是可以在多个用户之间共享的完整实例。
-	sharedEthash = New(Config{"", 3, 0, "", 1, 0, ModeNormal}, nil, false)
+	sharedEthash = New(Config{CachesInMem: 3, DatasetsInMem: 1, PowMode: ModeNormal}, nil, false)
 
 //AlgorithmRevision是用于文件命名的数据结构版本。
 	algorithmRevision = 23
@@ -399,6 +399,9 @@ type Config struct {
 	DatasetsInMem  int
 	DatasetsOnDisk int
 	PowMode        Mode
+
+StratumAddr       string //stratum服务器的侦听地址（空=禁用）
+StratumDifficulty uint64 //每个stratum份额的默认和最小哈希数
 }
 
 //sealttask用远程密封器螺纹的相对结果通道包装密封块。
@@ -451,6 +454,7 @@ fetchWorkCh  chan *sealWork   //用于远程封口机获取采矿作业的通道
 submitWorkCh chan *mineResult //用于远程封口机提交其采矿结果的通道
 fetchRateCh  chan chan uint64 //用于收集本地或远程密封程序提交的哈希率的通道。
 submitRateCh chan *hashrate   //用于远程密封程序提交其挖掘哈希的通道
+stratum      *stratumServer   //向远程矿工推送工作的stratum服务器
 
 //下面的字段是用于测试的挂钩
 shared    *Ethash       //共享POW验证程序以避免缓存重新生成
@@ -489,6 +493,14 @@ func New(config Config, notify []string, noverify bool) *Ethash {
 		submitRateCh: make(chan *hashrate),
 		exitCh:       make(chan chan error),
 	}
+	if config.StratumAddr != "" {
+		stratum, err := newStratumServer(ethash, config.StratumAddr, config.StratumDifficulty)
+		if err != nil {
+			log.Error("Failed to start stratum server", "addr", config.StratumAddr, "err", err)
+		} else {
+			ethash.stratum = stratum
+		}
+	}
 	go ethash.remote(notify, noverify)
 	return ethash
 }
@@ -572,6 +584,10 @@ func (ethash *Ethash) Close() error {
 		if ethash.exitCh == nil {
 			return
 		}
+//在远程密封器之前停止stratum服务器，因为它依赖于远程密封器
+		if ethash.stratum != nil {
+			ethash.stratum.close()
+		}
 		errc := make(chan error)
 		ethash.exitCh <- errc
 		err = <-errc
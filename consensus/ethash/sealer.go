@@ -311,6 +311,11 @@ func (ethash *Ethash) remote(notify []string, noverify bool) {
 //通知并请求新工作可用性的URL
 			notifyWork()
 
+//将新工作推送给连接的stratum矿工
+			if ethash.stratum != nil {
+				ethash.stratum.notify(currentWork)
+			}
+
 		case work := <-ethash.fetchWorkCh:
 //将当前采矿工作返回给远程矿工。
 			if currentBlock == nil {
@@ -0,0 +1,557 @@
+
+//<developer>
+//    <name>linapex 曹一峰</name>
+//    <email>linapex@163.com</email>
+//    <wx>superexc</wx>
+//    <qqgroup>128148617</qqgroup>
+//    <url>https://jsq.ink</url>
+//    <role>pku engineer</role>
+//    <date>2019-03-16 19:16:34</date>
+//</624450075199803392>
+
+
+package ethash
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+const (
+stratumProtocol       = "EthereumStratum/1.0.0" //实现的stratum协议版本
+stratumMaxSessions    = 256                     //同时连接的矿工的最大数目
+stratumMaxLineSize    = 4096                    //单个请求行的最大字节数
+stratumQueueSize      = 16                      //每个会话排队等待发送的最大消息数
+stratumIdleTimeout    = 10 * time.Minute        //在断开连接之前允许矿工沉默的最长时间
+stratumWriteTimeout   = 5 * time.Second         //向矿工写入单个消息的最长时间
+stratumReportInterval = 5 * time.Second         //向远程密封器报告工人哈希率的时间间隔
+
+//defaultStratumDifficulty是未配置时的份额难度（stratum难度1）
+	defaultStratumDifficulty = 1 << 32
+)
+
+//stratumDiff1是stratum难度1对应的哈希数。
+var stratumDiff1 = float64(1 << 32)
+
+//stratumError是stratum协议中返回给矿工的错误。
+type stratumError struct {
+	code    int
+	message string
+}
+
+//MarshalJSON实现json.Marshaler，按[code, message, traceback]格式编码错误。
+func (e *stratumError) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]interface{}{e.code, e.message, nil})
+}
+
+var (
+	errStratumOther        = &stratumError{20, "Other/Unknown"}
+	errStratumStaleJob     = &stratumError{21, "Job not found (=stale)"}
+	errStratumDuplicate    = &stratumError{22, "Duplicate share"}
+	errStratumLowDiff      = &stratumError{23, "Low difficulty share"}
+	errStratumUnauthorized = &stratumError{24, "Unauthorized worker"}
+	errStratumUnsubscribed = &stratumError{25, "Not subscribed"}
+)
+
+//stratumRequest是矿工发送的请求。
+type stratumRequest struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params []string        `json:"params"`
+}
+
+//stratumResponse是对矿工请求的回复。
+type stratumResponse struct {
+	ID     json.RawMessage `json:"id"`
+	Result interface{}     `json:"result"`
+	Error  *stratumError   `json:"error"`
+}
+
+//stratumNotification是服务器主动推送给矿工的消息。
+type stratumNotification struct {
+	ID     interface{}   `json:"id"`
+	Method string        `json:"method"`
+	Params []interface{} `json:"params"`
+}
+
+//stratumJob是推送给矿工的一个工作包。
+type stratumJob struct {
+	id     string
+hash   common.Hash //块头的密封哈希
+seed   common.Hash //DAG的种子哈希
+target *big.Int    //块的边界条件
+	number uint64
+clean  bool //块高度是否改变，矿工应放弃旧的工作
+
+shares map[uint64]struct{} //已经提交的nonce，用于拒绝重复的份额
+	lock   sync.Mutex
+}
+
+//stratumSession是单个已连接矿工的状态。
+type stratumSession struct {
+	conn       net.Conn
+extranonce string //分配给会话的nonce前缀
+	queue      chan interface{}
+	term       chan struct{}
+
+	worker     string
+id         common.Hash   //用于哈希率记录的工人标识
+difficulty uint64        //每个份额的哈希数
+hashrate   metrics.Meter //根据接受的份额估算的哈希率
+	subscribed bool
+	authorized bool
+	lock       sync.Mutex
+}
+
+//send将消息排队发送给矿工，如果会话已经关闭则丢弃。
+func (s *stratumSession) send(msg interface{}) {
+	select {
+	case s.queue <- msg:
+	case <-s.term:
+	}
+}
+
+//writeLoop将排队的消息写入连接，以免慢速矿工阻塞其他会话。写入失败后继续
+//丢弃消息，直到会话终止，以免发送方被阻塞。
+func (s *stratumSession) writeLoop() {
+	var (
+		enc    = json.NewEncoder(s.conn)
+		failed bool
+	)
+	for {
+		select {
+		case msg := <-s.queue:
+			if failed {
+				continue
+			}
+			s.conn.SetWriteDeadline(time.Now().Add(stratumWriteTimeout))
+			if err := enc.Encode(msg); err != nil {
+				log.Debug("Failed to send stratum message", "remote", s.conn.RemoteAddr(), "err", err)
+				s.conn.Close()
+				failed = true
+			}
+		case <-s.term:
+			return
+		}
+	}
+}
+
+//stratumServer是一个EthereumStratum/1.0 TCP服务器，它向连接的矿工推送新工作，
+//按每个工人的难度接受份额，并通过远程密封器记录工人的哈希率。
+type stratumServer struct {
+	ethash     *Ethash
+	listener   net.Listener
+difficulty uint64 //新工人的默认和最小份额难度
+nonces     uint32 //用于分配会话nonce前缀的计数器
+jobSeq     uint64 //用于分配工作标识的计数器
+
+work     chan [4]string //远程密封器推送的最新工作
+	jobs     map[string]*stratumJob
+	current  *stratumJob
+	sessions map[*stratumSession]struct{}
+	lock     sync.Mutex
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+//newStratumServer在给定地址上开始侦听stratum连接。
+func newStratumServer(ethash *Ethash, addr string, difficulty uint64) (*stratumServer, error) {
+	if difficulty == 0 {
+		difficulty = defaultStratumDifficulty
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	s := &stratumServer{
+		ethash:     ethash,
+		listener:   listener,
+		difficulty: difficulty,
+		work:       make(chan [4]string, 1),
+		jobs:       make(map[string]*stratumJob),
+		sessions:   make(map[*stratumSession]struct{}),
+		quit:       make(chan struct{}),
+	}
+	s.wg.Add(2)
+	go s.loop()
+	go s.accept()
+
+	log.Info("Stratum server started", "addr", listener.Addr(), "difficulty", difficulty)
+	return s, nil
+}
+
+//close停止侦听并断开所有矿工。
+func (s *stratumServer) close() {
+	close(s.quit)
+	s.listener.Close()
+
+	s.lock.Lock()
+	for session := range s.sessions {
+		session.conn.Close()
+	}
+	s.lock.Unlock()
+
+	s.wg.Wait()
+}
+
+//notify将新工作交给服务器循环。只保留最新的工作，因此远程密封器永远不会被阻塞。
+func (s *stratumServer) notify(work [4]string) {
+	select {
+	case <-s.work:
+	default:
+	}
+	s.work <- work
+}
+
+//loop是服务器的主循环，负责广播新工作和报告工人哈希率。
+func (s *stratumServer) loop() {
+	defer s.wg.Done()
+
+	report := time.NewTicker(stratumReportInterval)
+	defer report.Stop()
+
+	for {
+		select {
+		case work := <-s.work:
+			s.broadcast(s.newJob(work))
+
+		case <-report.C:
+			s.reportHashrates()
+
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+//newJob从远程密封器的工作包创建新工作，并删除过时的工作。
+func (s *stratumServer) newJob(work [4]string) *stratumJob {
+	number, _ := strconv.ParseUint(strings.TrimPrefix(work[3], "0x"), 16, 64)
+	job := &stratumJob{
+		id:     fmt.Sprintf("%x", atomic.AddUint64(&s.jobSeq, 1)),
+		hash:   common.HexToHash(work[0]),
+		seed:   common.HexToHash(work[1]),
+		target: common.HexToHash(work[2]).Big(),
+		number: number,
+		shares: make(map[uint64]struct{}),
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for id, old := range s.jobs {
+		if old.number+staleThreshold <= number {
+			delete(s.jobs, id)
+		}
+	}
+	job.clean = s.current == nil || s.current.number != number
+
+	s.jobs[job.id] = job
+	s.current = job
+	return job
+}
+
+//broadcast将工作推送给所有已授权的矿工。
+func (s *stratumServer) broadcast(job *stratumJob) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for session := range s.sessions {
+		session.lock.Lock()
+		authorized := session.authorized
+		session.lock.Unlock()
+
+		if !authorized {
+			continue
+		}
+		select {
+		case session.queue <- s.jobNotification(job, job.clean):
+		default:
+			log.Debug("Dropping stratum job to slow miner", "remote", session.conn.RemoteAddr(), "job", job.id)
+		}
+	}
+}
+
+//jobNotification创建给定工作的mining.notify消息。
+func (s *stratumServer) jobNotification(job *stratumJob, clean bool) *stratumNotification {
+	return &stratumNotification{
+		Method: "mining.notify",
+		Params: []interface{}{job.id, hex.EncodeToString(job.seed[:]), hex.EncodeToString(job.hash[:]), clean},
+	}
+}
+
+//reportHashrates通过远程密封器的哈希率记录提交每个工人估算的哈希率。
+func (s *stratumServer) reportHashrates() {
+	s.lock.Lock()
+	rates := make(map[common.Hash]uint64)
+	for session := range s.sessions {
+		session.lock.Lock()
+		if session.authorized {
+			rates[session.id] = uint64(session.hashrate.Rate1())
+		}
+		session.lock.Unlock()
+	}
+	s.lock.Unlock()
+
+	for id, rate := range rates {
+		done := make(chan struct{})
+		select {
+		case s.ethash.submitRateCh <- &hashrate{id: id, rate: rate, ping: time.Now(), done: done}:
+		case <-s.quit:
+			return
+		}
+		<-done
+	}
+}
+
+//accept接受新的矿工连接，直到侦听器关闭。
+func (s *stratumServer) accept() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			select {
+			case <-s.quit:
+			default:
+				log.Error("Stratum listener failed", "err", err)
+			}
+			return
+		}
+		session := &stratumSession{
+			conn:       conn,
+			extranonce: fmt.Sprintf("%04x", atomic.AddUint32(&s.nonces, 1)&0xffff),
+			queue:      make(chan interface{}, stratumQueueSize),
+			term:       make(chan struct{}),
+			difficulty: s.difficulty,
+			hashrate:   metrics.NewMeterForced(),
+		}
+		s.lock.Lock()
+		select {
+		case <-s.quit:
+			s.lock.Unlock()
+			conn.Close()
+			return
+		default:
+		}
+		if len(s.sessions) >= stratumMaxSessions {
+			s.lock.Unlock()
+			log.Debug("Rejecting stratum miner, too many sessions", "remote", conn.RemoteAddr())
+			conn.Close()
+			continue
+		}
+		s.sessions[session] = struct{}{}
+		s.lock.Unlock()
+
+		s.wg.Add(1)
+		go s.handle(session)
+	}
+}
+
+//handle读取并处理单个矿工的请求，直到连接断开。
+func (s *stratumServer) handle(session *stratumSession) {
+	defer s.wg.Done()
+	defer func() {
+		s.lock.Lock()
+		delete(s.sessions, session)
+		s.lock.Unlock()
+
+		close(session.term)
+		session.conn.Close()
+		session.hashrate.Stop()
+	}()
+	go session.writeLoop()
+
+	log.Debug("Stratum miner connected", "remote", session.conn.RemoteAddr())
+
+	scanner := bufio.NewScanner(session.conn)
+	scanner.Buffer(make([]byte, stratumMaxLineSize), stratumMaxLineSize)
+	for {
+		session.conn.SetReadDeadline(time.Now().Add(stratumIdleTimeout))
+		if !scanner.Scan() {
+			log.Debug("Stratum miner disconnected", "remote", session.conn.RemoteAddr(), "worker", session.worker, "err", scanner.Err())
+			return
+		}
+		var req stratumRequest
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			log.Debug("Invalid stratum request", "remote", session.conn.RemoteAddr(), "err", err)
+			return
+		}
+		result, err := s.handleRequest(session, &req)
+		session.send(&stratumResponse{ID: req.ID, Result: result, Error: err})
+
+//授权成功后立即发送份额难度和当前工作
+		if req.Method == "mining.authorize" && err == nil {
+			s.sendWork(session)
+		}
+	}
+}
+
+//sendWork向新授权的矿工发送其份额难度和当前工作。
+func (s *stratumServer) sendWork(session *stratumSession) {
+	session.lock.Lock()
+	difficulty := session.difficulty
+	session.lock.Unlock()
+
+	session.send(&stratumNotification{
+		Method: "mining.set_difficulty",
+		Params: []interface{}{float64(difficulty) / stratumDiff1},
+	})
+	s.lock.Lock()
+	job := s.current
+	s.lock.Unlock()
+
+	if job != nil {
+		session.send(s.jobNotification(job, true))
+	}
+}
+
+//handleRequest执行单个stratum方法。
+func (s *stratumServer) handleRequest(session *stratumSession, req *stratumRequest) (interface{}, *stratumError) {
+	switch req.Method {
+	case "mining.subscribe":
+		session.lock.Lock()
+		session.subscribed = true
+		session.lock.Unlock()
+
+		return []interface{}{
+			[]string{"mining.notify", session.extranonce, stratumProtocol},
+			session.extranonce,
+		}, nil
+
+	case "mining.extranonce.subscribe":
+		return true, nil
+
+	case "mining.authorize":
+		if len(req.Params) < 1 || req.Params[0] == "" {
+			return false, errStratumUnauthorized
+		}
+		session.lock.Lock()
+		defer session.lock.Unlock()
+
+		if !session.subscribed {
+			return false, errStratumUnsubscribed
+		}
+		session.worker = req.Params[0]
+		session.id = crypto.Keccak256Hash([]byte(session.extranonce + "/" + session.worker))
+		session.authorized = true
+
+//密码可以用“d=<难度>”提高工人的份额难度。难度不能低于服务器配置的值，
+//否则工人可以用大量低难度份额迫使节点进行无限次的哈希验证
+		if len(req.Params) > 1 {
+			for _, field := range strings.Split(req.Params[1], ",") {
+				if strings.HasPrefix(field, "d=") {
+					if difficulty, err := strconv.ParseUint(field[2:], 10, 64); err == nil {
+						if difficulty < s.difficulty {
+							difficulty = s.difficulty
+						}
+						session.difficulty = difficulty
+					}
+				}
+			}
+		}
+		log.Info("Stratum worker authorized", "remote", session.conn.RemoteAddr(), "worker", session.worker, "difficulty", session.difficulty)
+		return true, nil
+
+	case "mining.submit":
+		if len(req.Params) < 3 {
+			return false, errStratumOther
+		}
+		return s.submitShare(session, req.Params[1], req.Params[2])
+
+	default:
+		return nil, errStratumOther
+	}
+}
+
+//submitShare验证矿工提交的份额。如果份额同时满足块的难度，则将解决方案提交给远程密封器。
+func (s *stratumServer) submitShare(session *stratumSession, jobID string, suffix string) (interface{}, *stratumError) {
+	session.lock.Lock()
+	authorized, difficulty := session.authorized, session.difficulty
+	session.lock.Unlock()
+
+	if !authorized {
+		return false, errStratumUnauthorized
+	}
+	s.lock.Lock()
+	job := s.jobs[jobID]
+	s.lock.Unlock()
+
+	if job == nil {
+		return false, errStratumStaleJob
+	}
+//完整的nonce是会话前缀加上矿工提交的后缀
+	blob, err := hex.DecodeString(session.extranonce + strings.TrimPrefix(suffix, "0x"))
+	if err != nil || len(blob) != 8 {
+		return false, errStratumOther
+	}
+	nonce := binary.BigEndian.Uint64(blob)
+
+	job.lock.Lock()
+	if _, ok := job.shares[nonce]; ok {
+		job.lock.Unlock()
+		return false, errStratumDuplicate
+	}
+	job.shares[nonce] = struct{}{}
+	job.lock.Unlock()
+
+	digest, result := s.ethash.computePoW(job.number, job.hash, nonce)
+
+	value := new(big.Int).SetBytes(result)
+	if value.Cmp(new(big.Int).Div(two256, new(big.Int).SetUint64(difficulty))) > 0 {
+		return false, errStratumLowDiff
+	}
+	session.hashrate.Mark(int64(difficulty))
+
+	if value.Cmp(job.target) <= 0 {
+		errc := make(chan error, 1)
+		select {
+		case s.ethash.submitWorkCh <- &mineResult{
+			nonce:     types.EncodeNonce(nonce),
+			mixDigest: common.BytesToHash(digest),
+			hash:      job.hash,
+			errc:      errc,
+		}:
+		case <-s.quit:
+			return false, errStratumOther
+		}
+		if err := <-errc; err != nil {
+			log.Warn("Stratum block solution rejected", "worker", session.worker, "number", job.number, "err", err)
+		} else {
+			log.Info("Stratum worker found block", "worker", session.worker, "number", job.number, "sealhash", job.hash)
+		}
+	}
+	return true, nil
+}
+
+//computePoW使用验证缓存计算给定密封哈希和nonce的混合摘要和POW值。
+func (ethash *Ethash) computePoW(number uint64, hash common.Hash, nonce uint64) ([]byte, []byte) {
+	cache := ethash.cache(number)
+
+	size := datasetSize(number)
+	if ethash.config.PowMode == ModeTest {
+		size = 32 * 1024
+	}
+	digest, result := hashimotoLight(size, cache.cache, hash.Bytes(), nonce)
+
+//在终结器中取消映射缓存。确保缓存保持活动状态直到调用桥本灯后。
+	runtime.KeepAlive(cache)
+	return digest, result
+}
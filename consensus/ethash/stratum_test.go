@@ -0,0 +1,211 @@
+
+//<developer>
+//    <name>linapex 曹一峰</name>
+//    <email>linapex@163.com</email>
+//    <wx>superexc</wx>
+//    <qqgroup>128148617</qqgroup>
+//    <url>https://jsq.ink</url>
+//    <role>pku engineer</role>
+//    <date>2019-03-16 19:16:34</date>
+//</624450075254329344>
+
+
+package ethash
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+//stratumTestMessage是测试矿工收到的任意stratum消息。
+type stratumTestMessage struct {
+	ID     *int              `json:"id"`
+	Method string            `json:"method"`
+	Params []interface{}     `json:"params"`
+	Result interface{}       `json:"result"`
+	Error  []json.RawMessage `json:"error"`
+}
+
+//stratumTestMiner是一个通过stratum协议连接的简单矿工。
+type stratumTestMiner struct {
+	conn    net.Conn
+	scanner *bufio.Scanner
+	nextID  int
+}
+
+func newStratumTestMiner(t *testing.T, addr string) *stratumTestMiner {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("failed to connect to stratum server: %v", err)
+	}
+	return &stratumTestMiner{conn: conn, scanner: bufio.NewScanner(conn)}
+}
+
+//call发送请求并返回其响应，期间收到的通知被忽略。
+func (m *stratumTestMiner) call(t *testing.T, method string, params ...string) *stratumTestMessage {
+	m.nextID++
+	req, _ := json.Marshal(map[string]interface{}{"id": m.nextID, "method": method, "params": params})
+	if _, err := m.conn.Write(append(req, '\n')); err != nil {
+		t.Fatalf("failed to send %s: %v", method, err)
+	}
+	for {
+		msg := m.read(t)
+		if msg.ID != nil && *msg.ID == m.nextID {
+			return msg
+		}
+	}
+}
+
+//wait读取消息，直到收到给定方法的通知。
+func (m *stratumTestMiner) wait(t *testing.T, method string) *stratumTestMessage {
+	for {
+		if msg := m.read(t); msg.Method == method {
+			return msg
+		}
+	}
+}
+
+func (m *stratumTestMiner) read(t *testing.T) *stratumTestMessage {
+	m.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if !m.scanner.Scan() {
+		t.Fatalf("failed to read stratum message: %v", m.scanner.Err())
+	}
+	msg := new(stratumTestMessage)
+	if err := json.Unmarshal(m.scanner.Bytes(), msg); err != nil {
+		t.Fatalf("failed to decode stratum message: %v", err)
+	}
+	return msg
+}
+
+//errorCode返回响应中的stratum错误代码，如果没有错误则返回0。
+func (msg *stratumTestMessage) errorCode() int {
+	var code int
+	if len(msg.Error) > 0 {
+		json.Unmarshal(msg.Error[0], &code)
+	}
+	return code
+}
+
+//测试stratum矿工收到推送的工作，提交的份额按工人难度验证，满足块难度的份额被提交为块。
+func TestStratum(t *testing.T) {
+	ethash := New(Config{PowMode: ModeTest, StratumAddr: "127.0.0.1:0", StratumDifficulty: 1}, nil, false)
+	defer ethash.Close()
+	ethash.SetThreads(-1)
+
+	if ethash.stratum == nil {
+		t.Fatalf("stratum server not started")
+	}
+	miner := newStratumTestMiner(t, ethash.stratum.listener.Addr().String())
+	defer miner.conn.Close()
+
+//授权前必须先订阅
+	if res := miner.call(t, "mining.authorize", "rig", "x"); res.errorCode() != errStratumUnsubscribed.code {
+		t.Fatalf("unsubscribed authorize error mismatch: have %d, want %d", res.errorCode(), errStratumUnsubscribed.code)
+	}
+	res := miner.call(t, "mining.subscribe", "test", stratumProtocol)
+	result, ok := res.Result.([]interface{})
+	if !ok || len(result) != 2 {
+		t.Fatalf("invalid subscribe result: %v", res.Result)
+	}
+	extranonce := result[1].(string)
+
+	if res := miner.call(t, "mining.authorize", "rig", "d=2"); res.Result != true {
+		t.Fatalf("failed to authorize: %v", res.Error[1])
+	}
+	msg := miner.wait(t, "mining.set_difficulty")
+	if have, want := msg.Params[0].(float64), 2/stratumDiff1; have != want {
+		t.Errorf("share difficulty mismatch: have %v, want %v", have, want)
+	}
+//推送一个难度很高的块，份额被接受但不会产生块
+	results := make(chan *types.Block, 1)
+
+	header := &types.Header{Number: big.NewInt(1), Difficulty: new(big.Int).Lsh(big.NewInt(1), 200)}
+	ethash.Seal(nil, types.NewBlockWithHeader(header), results, nil)
+
+	msg = miner.wait(t, "mining.notify")
+	job := msg.Params[0].(string)
+	if have, want := msg.Params[2].(string), hex.EncodeToString(ethash.SealHash(header).Bytes()); have != want {
+		t.Errorf("job header hash mismatch: have %s, want %s", have, want)
+	}
+	if msg.Params[3] != true {
+		t.Errorf("first job not marked clean")
+	}
+//solve搜索满足工人难度的nonce后缀
+	solve := func(header *types.Header) string {
+		for nonce := uint64(0); ; nonce++ {
+			suffix := fmt.Sprintf("%012x", nonce)
+			blob, _ := hex.DecodeString(extranonce + suffix)
+
+			_, result := ethash.computePoW(header.Number.Uint64(), ethash.SealHash(header), new(big.Int).SetBytes(blob).Uint64())
+			if new(big.Int).SetBytes(result).Cmp(new(big.Int).Div(two256, big.NewInt(2))) <= 0 {
+				return suffix
+			}
+		}
+	}
+	suffix := solve(header)
+	if res := miner.call(t, "mining.submit", "rig", job, suffix); res.Result != true {
+		t.Fatalf("valid share rejected: %s", res.Error[1])
+	}
+	if res := miner.call(t, "mining.submit", "rig", job, suffix); res.errorCode() != errStratumDuplicate.code {
+		t.Errorf("duplicate share error mismatch: have %d, want %d", res.errorCode(), errStratumDuplicate.code)
+	}
+	if res := miner.call(t, "mining.submit", "rig", "ffff", suffix); res.errorCode() != errStratumStaleJob.code {
+		t.Errorf("stale share error mismatch: have %d, want %d", res.errorCode(), errStratumStaleJob.code)
+	}
+	select {
+	case block := <-results:
+		t.Fatalf("share sealed block %d", block.NumberU64())
+	default:
+	}
+//推送一个与工人难度相同的块，任何有效份额都会产生块（难度1的目标2^256在工作包中溢出为零）
+	header = &types.Header{Number: big.NewInt(2), Difficulty: big.NewInt(2)}
+	ethash.Seal(nil, types.NewBlockWithHeader(header), results, nil)
+
+	msg = miner.wait(t, "mining.notify")
+	if res := miner.call(t, "mining.submit", "rig", msg.Params[0].(string), solve(header)); res.Result != true {
+		t.Fatalf("block solution rejected: %s", res.Error[1])
+	}
+	select {
+	case block := <-results:
+		if err := ethash.VerifySeal(nil, block.Header()); err != nil {
+			t.Errorf("sealed block failed verification: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("sealed block not delivered")
+	}
+}
+
+//测试工人不能用密码将份额难度降低到服务器配置的值以下。
+func TestStratumMinDifficulty(t *testing.T) {
+	ethash := New(Config{PowMode: ModeTest, StratumAddr: "127.0.0.1:0", StratumDifficulty: 4}, nil, false)
+	defer ethash.Close()
+	ethash.SetThreads(-1)
+
+	for _, test := range []struct {
+		password string
+		want     uint64
+	}{
+		{"d=1", 4},
+		{"d=0", 4},
+		{"d=8", 8},
+	} {
+		miner := newStratumTestMiner(t, ethash.stratum.listener.Addr().String())
+		miner.call(t, "mining.subscribe", "test", stratumProtocol)
+		if res := miner.call(t, "mining.authorize", "rig", test.password); res.Result != true {
+			t.Fatalf("%s: failed to authorize: %v", test.password, res.Error[1])
+		}
+		msg := miner.wait(t, "mining.set_difficulty")
+		if have, want := msg.Params[0].(float64), float64(test.want)/stratumDiff1; have != want {
+			t.Errorf("%s: share difficulty mismatch: have %v, want %v", test.password, have, want)
+		}
+		miner.conn.Close()
+	}
+}
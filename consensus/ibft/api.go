@@ -0,0 +1,88 @@
+
+//<developer>
+//    <name>linapex 曹一峰</name>
+//    <email>linapex@163.com</email>
+//    <wx>superexc</wx>
+//    <qqgroup>128148617</qqgroup>
+//    <url>https://jsq.ink</url>
+//    <role>pku engineer</role>
+//    <date>2019-03-16 19:16:34</date>
+//</624450074708385792>
+
+
+package ibft
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+//API是面向用户的RPC API，允许查看验证者集合和共识状态。
+type API struct {
+	chain  consensus.ChainReader
+	engine *Engine
+}
+
+//Status是本地轮状态机的状态。
+type Status struct {
+	Height    uint64         `json:"height"`
+	Round     uint64         `json:"round"`
+	Proposer  common.Address `json:"proposer"`
+	Locked    *common.Hash   `json:"locked"`
+	Validator bool           `json:"validator"`
+	Peers     int            `json:"peers"`
+}
+
+//GetValidators检索验证者集合。
+func (api *API) GetValidators() ([]common.Address, error) {
+	return api.engine.validatorsOf(api.chain)
+}
+
+//GetCommitters检索对指定块签署了提交签名的验证者。
+func (api *API) GetCommitters(number *rpc.BlockNumber) ([]common.Address, error) {
+//检索请求的块号（如果未请求，则为当前块号）
+	var header *types.Header
+	if number == nil || *number == rpc.LatestBlockNumber {
+		header = api.chain.CurrentHeader()
+	} else {
+		header = api.chain.GetHeaderByNumber(uint64(number.Int64()))
+	}
+	if header == nil {
+		return nil, errUnknownBlock
+	}
+	extra, err := ExtractExtra(header)
+	if err != nil {
+		return nil, err
+	}
+	digest := commitDigest(proposalHash(header))
+
+	committers := make([]common.Address, 0, len(extra.CommittedSeal))
+	for _, seal := range extra.CommittedSeal {
+		committer, err := ecrecover(digest, seal)
+		if err != nil {
+			return nil, errInvalidCommittedSeals
+		}
+		committers = append(committers, committer)
+	}
+	return committers, nil
+}
+
+//Status返回本地轮状态机的当前高度、轮、提议者和锁定的块。
+func (api *API) Status() *Status {
+	height, round, locked := api.engine.core.status()
+
+	status := &Status{
+		Height:    height,
+		Round:     round,
+		Proposer:  api.engine.proposer(height, round),
+		Validator: api.engine.isValidator(api.engine.local()),
+		Peers:     api.engine.peers.len(),
+	}
+	if locked != nil {
+		hash := locked.Hash()
+		status.Locked = &hash
+	}
+	return status
+}
@@ -0,0 +1,515 @@
+
+//<developer>
+//    <name>linapex 曹一峰</name>
+//    <email>linapex@163.com</email>
+//    <wx>superexc</wx>
+//    <qqgroup>128148617</qqgroup>
+//    <url>https://jsq.ink</url>
+//    <role>pku engineer</role>
+//    <date>2019-03-16 19:16:34</date>
+//</624450074724990976>
+
+
+package ibft
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+const (
+maxBacklog      = 1024 //为未来的高度或轮缓冲的最大消息数
+maxFutureRounds = 32   //接受轮更改请求的当前轮之后的最大轮数，防止轮更改集合无限增长
+)
+
+//sealTask是本地矿工提交的等待提议的块。
+type sealTask struct {
+	block   *types.Block
+	results chan<- *types.Block
+	stop    <-chan struct{}
+}
+
+//core是单个验证者的轮状态机。
+type core struct {
+	engine *Engine
+
+height  uint64 //当前正在达成一致的块号
+round   uint64 //当前高度内的轮
+desired uint64 //本地请求的最高轮
+
+proposal    *types.Block //当前轮接受的提议
+digest      common.Hash  //当前提议的哈希
+locked      *types.Block //在某轮看到法定数量的准备消息后锁定的块
+lockedRound uint64       //锁定块被准备的轮
+lockedCert  [][]byte     //锁定块的准备证书（法定数量的签名准备消息）
+
+prepares     map[common.Address]*message            //当前轮每个验证者的准备消息
+commits      map[common.Address]*message            //当前轮每个验证者的提交消息
+roundChanges map[uint64]map[common.Address]struct{} //每个未来轮的轮更改请求
+
+proposed  bool       //本地节点是否已在当前轮提议
+prepared  bool       //本地节点是否已在当前轮发送提交消息
+candidate *sealTask  //本地矿工为当前高度提交的块
+backlog   []*message //未来高度或轮的消息
+
+	timer   *time.Timer
+	running bool
+	lock    sync.Mutex
+}
+
+//newCore创建一个新的轮状态机。
+func newCore(engine *Engine) *core {
+	return &core{
+		engine:       engine,
+		prepares:     make(map[common.Address]*message),
+		commits:      make(map[common.Address]*message),
+		roundChanges: make(map[uint64]map[common.Address]struct{}),
+	}
+}
+
+//start在链头之后的高度开始状态机。
+func (c *core) start() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.running = true
+	c.syncHeight()
+}
+
+//stop停止轮计时器。
+func (c *core) stop() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.running = false
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+}
+
+//syncHeight在链通过同步前进时移动到链头之后的高度。
+func (c *core) syncHeight() {
+	c.engine.lock.RLock()
+	chain := c.engine.chain
+	c.engine.lock.RUnlock()
+
+	if chain == nil {
+		return
+	}
+	if next := chain.CurrentHeader().Number.Uint64() + 1; next > c.height {
+		c.startHeight(next)
+	}
+}
+
+//startHeight在新高度从第0轮重新开始共识。
+func (c *core) startHeight(height uint64) {
+	c.height = height
+	c.locked, c.lockedRound, c.lockedCert = nil, 0, nil
+	c.roundChanges = make(map[uint64]map[common.Address]struct{})
+	if c.candidate != nil && c.candidate.block.NumberU64() != height {
+		c.candidate = nil
+	}
+	c.startRound(0)
+}
+
+//startRound在当前高度进入给定的轮。
+func (c *core) startRound(round uint64) {
+	c.round, c.desired = round, round
+	c.proposal, c.digest = nil, common.Hash{}
+	c.prepares = make(map[common.Address]*message)
+	c.commits = make(map[common.Address]*message)
+	c.proposed, c.prepared = false, false
+
+	for r := range c.roundChanges {
+		if r <= round {
+			delete(c.roundChanges, r)
+		}
+	}
+	c.resetTimer(round)
+	c.propose()
+	c.replayBacklog()
+}
+
+//resetTimer为给定的轮设置超时，超时后本地节点请求下一轮。
+func (c *core) resetTimer(round uint64) {
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+	if !c.running {
+		return
+	}
+	height := c.height
+	c.timer = time.AfterFunc(c.engine.timeout(round), func() {
+		c.lock.Lock()
+		defer c.lock.Unlock()
+
+		if c.height == height && c.desired == round {
+			c.handleTimeout()
+		}
+	})
+}
+
+//handleTimeout请求进入下一轮，并为该轮重新设置计时器。
+func (c *core) handleTimeout() {
+	if !c.engine.isValidator(c.engine.local()) {
+		c.resetTimer(c.desired)
+		return
+	}
+	c.desired++
+	log.Debug("IBFT round timed out", "height", c.height, "round", c.round, "next", c.desired)
+
+	c.resetTimer(c.desired)
+	c.broadcast(c.roundChangeMessage(c.desired))
+}
+
+//roundChangeMessage创建请求进入给定轮的消息。如果本地节点已锁定块，消息携带
+//该块及其准备证书，使其他验证者也锁定它，并让下一轮的提议者重新提议它。
+func (c *core) roundChangeMessage(round uint64) *message {
+	msg := &message{Code: msgRoundChange, Height: c.height, Round: round}
+	if c.locked != nil {
+		payload, err := rlp.EncodeToBytes(c.locked)
+		if err != nil {
+			log.Error("Failed to encode IBFT locked block", "err", err)
+			return msg
+		}
+		msg.Proposal, msg.Prepares = payload, c.lockedCert
+	}
+	return msg
+}
+
+//setCandidate记录本地矿工的块，如果本地节点是提议者则提议它。
+func (c *core) setCandidate(task *sealTask) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.syncHeight()
+	if number := task.block.NumberU64(); number != c.height {
+		log.Debug("Discarding stale IBFT candidate", "number", number, "height", c.height)
+		return
+	}
+	c.candidate = task
+	c.propose()
+}
+
+//propose如果本地节点是当前轮的提议者，则广播预准备消息。锁定的块优先于
+//本地候选块，以保证安全性。
+func (c *core) propose() {
+	if c.proposed || c.engine.proposer(c.height, c.round) != c.engine.local() {
+		return
+	}
+	block := c.locked
+	if block != nil {
+//锁定的块可能由前一轮的提议者签名，以本地验证者的身份重新签名
+		var err error
+		if block, err = c.reseal(block); err != nil {
+			log.Debug("Failed to reseal IBFT locked block", "err", err)
+			return
+		}
+	} else if c.candidate != nil {
+		block = c.candidate.block
+	}
+	if block == nil {
+		return
+	}
+	payload, err := rlp.EncodeToBytes(block)
+	if err != nil {
+		log.Error("Failed to encode IBFT proposal", "err", err)
+		return
+	}
+	c.proposed = true
+	c.broadcast(&message{Code: msgPreprepare, Height: c.height, Round: c.round, Proposal: payload})
+}
+
+//reseal用本地验证者密钥替换块的提议者签名，块的内容和sigHash保持不变。
+func (c *core) reseal(block *types.Block) (*types.Block, error) {
+	header := block.Header()
+	extra, err := ExtractExtra(header)
+	if err != nil {
+		return nil, err
+	}
+	if extra.Seal, err = c.engine.sign(sigHash(header).Bytes()); err != nil {
+		return nil, err
+	}
+	extra.CommittedSeal = nil
+	if header.Extra, err = encodeExtra(header.Extra[:extraVanity], extra); err != nil {
+		return nil, err
+	}
+	return block.WithSeal(header), nil
+}
+
+//broadcast签名本地消息，将其发送给所有对等方并在本地处理。
+func (c *core) broadcast(msg *message) {
+	sig, err := c.engine.sign(msg.sigHash())
+	if err != nil {
+		log.Debug("Failed to sign IBFT message", "err", err)
+		return
+	}
+	msg.Signature, msg.sender = sig, c.engine.local()
+
+	payload, err := rlp.EncodeToBytes(msg)
+	if err != nil {
+		log.Error("Failed to encode IBFT message", "err", err)
+		return
+	}
+	c.engine.seen.Add(crypto.Keccak256Hash(payload), struct{}{})
+	c.engine.gossip(payload, "")
+
+	if err := c.handle(msg); err != nil {
+		log.Debug("Failed to handle local IBFT message", "code", msg.Code, "err", err)
+	}
+}
+
+//handleMessage处理来自网络的已验证签名的消息。
+func (c *core) handleMessage(msg *message) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.syncHeight()
+	return c.handle(msg)
+}
+
+//handle根据当前高度和轮分派消息。
+func (c *core) handle(msg *message) error {
+	if !c.engine.isValidator(msg.sender) {
+		return errUnauthorized
+	}
+	if msg.Height < c.height {
+		return errOldMessage
+	}
+	if msg.Height > c.height || (msg.Round > c.round && msg.Code != msgRoundChange) {
+		c.addBacklog(msg)
+		return nil
+	}
+	if msg.Round < c.round && msg.Code != msgRoundChange {
+		return errOldMessage
+	}
+	switch msg.Code {
+	case msgPreprepare:
+		return c.handlePreprepare(msg)
+	case msgPrepare:
+		c.prepares[msg.sender] = msg
+		c.checkPrepared()
+	case msgCommit:
+		if signer, err := ecrecover(commitDigest(msg.Digest), msg.CommittedSeal); err != nil || signer != msg.sender {
+			return errInvalidCommittedSeals
+		}
+		c.commits[msg.sender] = msg
+		c.checkCommitted()
+	case msgRoundChange:
+		return c.handleRoundChange(msg)
+	}
+	return nil
+}
+
+//handlePreprepare验证当前轮的提议，如果有效则广播准备消息。
+func (c *core) handlePreprepare(msg *message) error {
+	if c.proposal != nil {
+		return nil
+	}
+	if msg.sender != c.engine.proposer(c.height, c.round) {
+		return errNotProposer
+	}
+	block, err := msg.proposal()
+	if err != nil || block.NumberU64() != c.height {
+		return errInvalidProposal
+	}
+//锁定的块在以后的轮中由新的提议者重新签名，因此按不含签名的内容比较
+	if c.locked != nil && sigHash(block.Header()) != sigHash(c.locked.Header()) {
+		return errLockedProposal
+	}
+	author, err := c.engine.verifyProposal(block)
+	if err != nil {
+		return err
+	}
+	if author != msg.sender {
+		return errInvalidProposal
+	}
+	c.proposal, c.digest = block, proposalHash(block.Header())
+
+	if c.engine.isValidator(c.engine.local()) {
+		c.broadcast(&message{Code: msgPrepare, Height: c.height, Round: c.round, Digest: c.digest})
+	}
+//在提议之前到达的准备和提交消息现在可以计数
+	c.checkPrepared()
+	c.checkCommitted()
+	return nil
+}
+
+//checkPrepared在看到法定数量的准备消息后锁定提议并广播提交消息。
+func (c *core) checkPrepared() {
+	if c.proposal == nil || c.prepared {
+		return
+	}
+	var cert [][]byte
+	for _, msg := range c.prepares {
+		if msg.Digest != c.digest {
+			continue
+		}
+		payload, err := rlp.EncodeToBytes(msg)
+		if err != nil {
+			log.Error("Failed to encode IBFT prepare", "err", err)
+			return
+		}
+		cert = append(cert, payload)
+	}
+	if len(cert) < quorum(len(c.engine.validatorSet())) {
+		return
+	}
+	c.prepared = true
+	c.locked, c.lockedRound, c.lockedCert = c.proposal, c.round, cert
+
+	if !c.engine.isValidator(c.engine.local()) {
+		return
+	}
+	seal, err := c.engine.sign(commitDigest(c.digest))
+	if err != nil {
+		log.Debug("Failed to sign IBFT commit", "err", err)
+		return
+	}
+	c.broadcast(&message{Code: msgCommit, Height: c.height, Round: c.round, Digest: c.digest, CommittedSeal: seal})
+}
+
+//checkCommitted在看到法定数量的提交消息后最终确定提议。
+func (c *core) checkCommitted() {
+	if c.proposal == nil {
+		return
+	}
+	var seals [][]byte
+	for _, validator := range c.engine.validatorSet() {
+		if msg, ok := c.commits[validator]; ok && msg.Digest == c.digest {
+			seals = append(seals, msg.CommittedSeal)
+		}
+	}
+	if len(seals) < quorum(len(c.engine.validatorSet())) {
+		return
+	}
+	header := c.proposal.Header()
+	extra, err := ExtractExtra(header)
+	if err != nil {
+		return
+	}
+	extra.CommittedSeal = seals
+	if header.Extra, err = encodeExtra(header.Extra[:extraVanity], extra); err != nil {
+		log.Error("Failed to encode committed seals", "err", err)
+		return
+	}
+	block := c.proposal.WithSeal(header)
+	log.Info("Committed IBFT block", "number", block.Number(), "round", c.round, "hash", block.Hash(), "seals", len(seals))
+
+//如果提议来自本地矿工，则将最终块交给它，否则直接导入本地链，
+//使链不依赖于提议者在提交后仍然在线
+	if task := c.candidate; task != nil && sigHash(task.block.Header()) == sigHash(header) {
+		go func() {
+			select {
+			case task.results <- block:
+			case <-task.stop:
+			}
+		}()
+	} else {
+		go c.engine.importCommitted(block)
+	}
+	c.startHeight(c.height + 1)
+}
+
+//handleRoundChange记录轮更改请求。在f+1个请求之后本地节点也请求该轮，
+//在法定数量的请求之后进入该轮。请求携带的准备证书如果比本地的锁更新，
+//则本地节点改为锁定证书中的块。
+func (c *core) handleRoundChange(msg *message) error {
+	if msg.Round <= c.round || msg.Round > c.round+maxFutureRounds {
+		return nil
+	}
+	if len(msg.Proposal) > 0 {
+		block, round, err := c.verifyCertificate(msg)
+		if err != nil {
+			return err
+		}
+		if c.locked == nil || round > c.lockedRound {
+			c.locked, c.lockedRound, c.lockedCert = block, round, msg.Prepares
+		}
+	}
+	set := c.roundChanges[msg.Round]
+	if set == nil {
+		set = make(map[common.Address]struct{})
+		c.roundChanges[msg.Round] = set
+	}
+	set[msg.sender] = struct{}{}
+
+	validators := len(c.engine.validatorSet())
+	switch {
+	case len(set) >= quorum(validators):
+		log.Debug("IBFT round changed", "height", c.height, "round", msg.Round)
+		c.startRound(msg.Round)
+
+	case len(set) > (validators-1)/3 && msg.Round > c.desired && c.engine.isValidator(c.engine.local()):
+		c.desired = msg.Round
+		c.resetTimer(c.desired)
+		c.broadcast(c.roundChangeMessage(msg.Round))
+	}
+	return nil
+}
+
+//verifyCertificate检查轮更改消息携带的准备证书：法定数量的不同验证者在当前
+//高度、早于请求轮的同一轮中准备了所携带的块。返回该块和它被准备的轮。
+func (c *core) verifyCertificate(msg *message) (*types.Block, uint64, error) {
+	block, err := msg.proposal()
+	if err != nil || block.NumberU64() != c.height {
+		return nil, 0, errInvalidCertificate
+	}
+	digest := proposalHash(block.Header())
+
+	var round uint64
+	signers := make(map[common.Address]struct{})
+	for i, payload := range msg.Prepares {
+		prepare, err := decodeMessage(payload)
+		if err != nil || prepare.Code != msgPrepare || prepare.Height != c.height || prepare.Round >= msg.Round || prepare.Digest != digest {
+			return nil, 0, errInvalidCertificate
+		}
+		if i > 0 && prepare.Round != round {
+			return nil, 0, errInvalidCertificate
+		}
+		if !c.engine.isValidator(prepare.sender) {
+			return nil, 0, errInvalidCertificate
+		}
+		round = prepare.Round
+		signers[prepare.sender] = struct{}{}
+	}
+	if len(signers) < quorum(len(c.engine.validatorSet())) {
+		return nil, 0, errInvalidCertificate
+	}
+	return block, round, nil
+}
+
+//addBacklog缓冲未来高度或轮的消息，必要时丢弃最旧的消息。
+func (c *core) addBacklog(msg *message) {
+	if len(c.backlog) >= maxBacklog {
+		c.backlog = c.backlog[1:]
+	}
+	c.backlog = append(c.backlog, msg)
+}
+
+//replayBacklog重新处理当前高度和轮的缓冲消息。
+func (c *core) replayBacklog() {
+	backlog := c.backlog
+	c.backlog = nil
+
+	for _, msg := range backlog {
+		if msg.Height < c.height {
+			continue
+		}
+		c.handle(msg)
+	}
+}
+
+//status返回状态机的当前高度、轮和锁定的块。
+func (c *core) status() (uint64, uint64, *types.Block) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.height, c.round, c.locked
+}
@@ -0,0 +1,108 @@
+
+//<developer>
+//    <name>linapex 曹一峰</name>
+//    <email>linapex@163.com</email>
+//    <wx>superexc</wx>
+//    <qqgroup>128148617</qqgroup>
+//    <url>https://jsq.ink</url>
+//    <role>pku engineer</role>
+//    <date>2019-03-16 19:16:34</date>
+//</624450074712580096>
+
+
+package ibft
+
+import (
+	"bytes"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"golang.org/x/crypto/sha3"
+)
+
+//Extra是存储在头的额外数据中虚荣前缀之后的共识数据。
+type Extra struct {
+Validators    []common.Address //固定的验证者集合
+Seal          []byte           //提议者对头的签名
+CommittedSeal [][]byte         //法定数量验证者对提议的提交签名
+}
+
+//ExtractExtra从头的额外数据中解码共识数据。
+func ExtractExtra(header *types.Header) (*Extra, error) {
+	if len(header.Extra) < extraVanity {
+		return nil, errInvalidExtraData
+	}
+	extra := new(Extra)
+	if err := rlp.DecodeBytes(header.Extra[extraVanity:], extra); err != nil {
+		return nil, errInvalidExtraData
+	}
+	return extra, nil
+}
+
+//encodeExtra将给定的虚荣和共识数据组装为头的额外数据字段。
+func encodeExtra(vanity []byte, extra *Extra) ([]byte, error) {
+	if len(vanity) < extraVanity {
+		vanity = append(common.CopyBytes(vanity), bytes.Repeat([]byte{0x00}, extraVanity-len(vanity))...)
+	}
+	payload, err := rlp.EncodeToBytes(extra)
+	if err != nil {
+		return nil, err
+	}
+	return append(common.CopyBytes(vanity[:extraVanity]), payload...), nil
+}
+
+//GenesisExtra为创世块创建包含初始验证者集合的额外数据。
+func GenesisExtra(validators []common.Address) []byte {
+	extra, _ := encodeExtra(nil, &Extra{Validators: validators})
+	return extra
+}
+
+//filteredHeader返回删除了提交签名的头副本，如果keepSeal为false，还删除
+//提议者签名。
+func filteredHeader(header *types.Header, keepSeal bool) *types.Header {
+	cpy := types.CopyHeader(header)
+	extra, err := ExtractExtra(cpy)
+	if err != nil {
+		return cpy
+	}
+	if !keepSeal {
+		extra.Seal = []byte{}
+	}
+	extra.CommittedSeal = [][]byte{}
+
+	if payload, err := encodeExtra(cpy.Extra[:extraVanity], extra); err == nil {
+		cpy.Extra = payload
+	}
+	return cpy
+}
+
+//sigHash返回提议者签名的哈希，它是不含任何签名的整个头的哈希。同一块的内容
+//在不同轮中由不同的提议者签名时具有相同的sigHash。
+func sigHash(header *types.Header) (hash common.Hash) {
+	hasher := sha3.NewLegacyKeccak256()
+	rlp.Encode(hasher, filteredHeader(header, false))
+	hasher.Sum(hash[:0])
+	return hash
+}
+
+//proposalHash返回提议的哈希，验证者对该哈希达成一致。它包括提议者
+//签名，但不包括提交签名，因此每个验证者收集的不同提交签名集合不会改变它。
+func proposalHash(header *types.Header) common.Hash {
+	return filteredHeader(header, true).Hash()
+}
+
+//commitDigest返回验证者为提交给定提议而签名的摘要。
+func commitDigest(proposal common.Hash) []byte {
+	return crypto.Keccak256(proposal[:], []byte{byte(msgCommit)})
+}
+
+//ecrecover从签名中提取签名者的以太坊地址。
+func ecrecover(hash []byte, sig []byte) (common.Address, error) {
+	pubkey, err := crypto.SigToPub(hash, sig)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return crypto.PubkeyToAddress(*pubkey), nil
+}
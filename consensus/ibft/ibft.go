@@ -0,0 +1,578 @@
+
+//<developer>
+//    <name>linapex 曹一峰</name>
+//    <email>linapex@163.com</email>
+//    <wx>superexc</wx>
+//    <qqgroup>128148617</qqgroup>
+//    <url>https://jsq.ink</url>
+//    <role>pku engineer</role>
+//    <date>2019-03-16 19:16:34</date>
+//</624450074695802880>
+
+
+//包ibft实现具有即时最终性的拜占庭容错共识引擎。
+//
+//验证者集合在创世块中固定。每个高度以轮为单位进行：当前轮的提议者
+//广播预准备消息，验证者回复准备消息，看到法定数量的准备消息后
+//广播包含提交签名的提交消息。法定数量的提交签名被写入块头的
+//额外数据中，因此任何节点都可以独立验证块已被最终确定。
+package ibft
+
+import (
+	"errors"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+const (
+extraVanity           = 32   //固定为验证者虚荣保留的额外数据前缀字节数
+inmemoryMessages      = 4096 //为去重而记住的最近共识消息数
+defaultRequestTimeout = 3000 //未配置时第0轮的超时毫秒数
+maxRoundTimeoutShift  = 8    //轮超时加倍的最大次数
+)
+
+var (
+//mixDigest是IBFT块的固定混合摘要，用于将其与其他引擎的块区分开。
+	mixDigest = common.HexToHash("0x63746963616c2062797a616e74696e65206661756c7420746f6c6572616e6365")
+
+uncleHash  = types.CalcUncleHash(nil) //IBFT中不允许叔叔
+difficulty = big.NewInt(1)            //每个块的固定难度
+)
+
+//将块或共识消息标记为无效的各种错误消息。
+var (
+//当请求的块不是本地区块链的一部分时，返回errUnknownBlock。
+	errUnknownBlock = errors.New("unknown block")
+
+//如果额外数据无法解码为共识数据，则返回errInvalidExtraData。
+	errInvalidExtraData = errors.New("invalid extra-data")
+
+//如果块的混合摘要不是IBFT摘要，则返回errInvalidMixDigest。
+	errInvalidMixDigest = errors.New("invalid mix digest")
+
+//如果块的nonce不为零，则返回errInvalidNonce。
+	errInvalidNonce = errors.New("non-zero nonce")
+
+//如果块包含非空的叔叔列表，则返回errInvalidUncleHash。
+	errInvalidUncleHash = errors.New("non empty uncle hash")
+
+//如果块的难度不是1，则返回errInvalidDifficulty。
+	errInvalidDifficulty = errors.New("invalid difficulty")
+
+//如果块中的验证者集合与创世块不同，则返回errInvalidValidators。
+	errInvalidValidators = errors.New("mismatching validator set")
+
+//如果块或消息由非验证者签名，则返回errUnauthorized。
+	errUnauthorized = errors.New("unauthorized validator")
+
+//如果签名无法恢复，则返回errInvalidSignature。
+	errInvalidSignature = errors.New("invalid signature")
+
+//如果提交签名无效或重复，则返回errInvalidCommittedSeals。
+	errInvalidCommittedSeals = errors.New("invalid committed seals")
+
+//如果提交签名少于法定数量，则返回errInsufficientCommittedSeals。
+	errInsufficientCommittedSeals = errors.New("insufficient committed seals")
+
+//如果预准备消息不是由当前轮的提议者发送的，则返回errNotProposer。
+	errNotProposer = errors.New("message not from proposer")
+
+//如果提议块无效，则返回errInvalidProposal。
+	errInvalidProposal = errors.New("invalid proposal")
+
+//如果提议与本地锁定的块不同，则返回errLockedProposal。
+	errLockedProposal = errors.New("proposal mismatches locked block")
+
+//如果轮更改消息携带的准备证书无效，则返回errInvalidCertificate。
+	errInvalidCertificate = errors.New("invalid prepared certificate")
+
+//如果消息属于已完成的高度或轮，则返回errOldMessage。
+	errOldMessage = errors.New("old message")
+
+//如果块的时间戳早于父块的时间戳加上最小块周期，
+//则返回ErrInvalidTimestamp。
+	ErrInvalidTimestamp = errors.New("invalid timestamp")
+)
+
+//SignerFn是一个签名者回调函数，用于请求由备用帐户对哈希进行签名。
+type SignerFn func(accounts.Account, []byte) ([]byte, error)
+
+//Engine是IBFT共识引擎。
+type Engine struct {
+config *params.IBFTConfig //共识引擎配置参数
+
+chain      consensus.ChainReader    //用于验证提议的本地链
+verify     func(*types.Block) error //可选的完整块验证（执行交易）
+insert     func(*types.Block) error //可选的已最终确定块的本地导入
+validators []common.Address         //创世块中的固定验证者集合
+
+signer common.Address //签名密钥的以太坊地址
+signFn SignerFn       //用于授权哈希的签名程序函数
+lock   sync.RWMutex   //保护上面的字段
+
+core  *core         //轮状态机
+peers *peerSet      //运行共识协议的对等方
+seen  *lru.ARCCache //最近处理的消息哈希，用于去重
+}
+
+//New创建一个IBFT共识引擎。
+func New(config *params.IBFTConfig) *Engine {
+	conf := *config
+	if conf.RequestTimeout == 0 {
+		conf.RequestTimeout = defaultRequestTimeout
+	}
+	seen, _ := lru.NewARC(inmemoryMessages)
+
+	engine := &Engine{
+		config: &conf,
+		peers:  newPeerSet(),
+		seen:   seen,
+	}
+	engine.core = newCore(engine)
+	return engine
+}
+
+//Start将引擎连接到本地链，以便它可以参与共识。verify是可选的回调，
+//用于在接受提议前完整验证（执行）提议块。insert是可选的回调，用于将不是由
+//本地矿工提议的已最终确定块导入本地链，这样即使提议者在提交后离线，链也能前进。
+func (e *Engine) Start(chain consensus.ChainReader, verify func(*types.Block) error, insert func(*types.Block) error) error {
+	validators, err := e.validatorsOf(chain)
+	if err != nil {
+		return err
+	}
+	e.lock.Lock()
+	e.chain, e.verify, e.insert, e.validators = chain, verify, insert, validators
+	e.lock.Unlock()
+
+	e.core.start()
+	return nil
+}
+
+//started返回引擎是否已连接到本地链。
+func (e *Engine) started() bool {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	return e.chain != nil
+}
+
+//validatorsOf从创世块中检索验证者集合。
+func (e *Engine) validatorsOf(chain consensus.ChainReader) ([]common.Address, error) {
+	e.lock.RLock()
+	validators := e.validators
+	e.lock.RUnlock()
+
+	if validators != nil {
+		return validators, nil
+	}
+	genesis := chain.GetHeaderByNumber(0)
+	if genesis == nil {
+		return nil, errUnknownBlock
+	}
+	extra, err := ExtractExtra(genesis)
+	if err != nil {
+		return nil, err
+	}
+	if len(extra.Validators) == 0 {
+		return nil, errInvalidValidators
+	}
+	return extra.Validators, nil
+}
+
+//validatorSet返回引擎启动时加载的验证者集合。
+func (e *Engine) validatorSet() []common.Address {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	return e.validators
+}
+
+//isValidator返回给定地址是否是验证者。
+func (e *Engine) isValidator(addr common.Address) bool {
+	for _, validator := range e.validatorSet() {
+		if validator == addr {
+			return true
+		}
+	}
+	return false
+}
+
+//proposer返回给定高度和轮的提议者，验证者按轮转方式依次提议。
+func (e *Engine) proposer(height, round uint64) common.Address {
+	validators := e.validatorSet()
+	if len(validators) == 0 {
+		return common.Address{}
+	}
+	return validators[(height+round)%uint64(len(validators))]
+}
+
+//quorum返回最终确定块所需的签名数，即在最多f个拜占庭验证者的情况下
+//n-f个验证者。
+func quorum(n int) int {
+	return n - (n-1)/3
+}
+
+//local返回本地验证者地址，如果没有授权则返回零地址。
+func (e *Engine) local() common.Address {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	return e.signer
+}
+
+//sign用本地验证者密钥对给定哈希签名。
+func (e *Engine) sign(hash []byte) ([]byte, error) {
+	e.lock.RLock()
+	signer, signFn := e.signer, e.signFn
+	e.lock.RUnlock()
+
+	if signFn == nil {
+		return nil, errUnauthorized
+	}
+	return signFn(accounts.Account{Address: signer}, hash)
+}
+
+//timeout返回给定轮的超时，每轮加倍。
+func (e *Engine) timeout(round uint64) time.Duration {
+	if round > maxRoundTimeoutShift {
+		round = maxRoundTimeoutShift
+	}
+	return time.Duration(e.config.RequestTimeout) * time.Millisecond << round
+}
+
+//Author实现consensus.Engine，返回提议者的以太坊地址。
+func (e *Engine) Author(header *types.Header) (common.Address, error) {
+	extra, err := ExtractExtra(header)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return ecrecover(sigHash(header).Bytes(), extra.Seal)
+}
+
+//VerifyHeader检查头是否符合共识规则，包括法定数量的提交签名。
+func (e *Engine) VerifyHeader(chain consensus.ChainReader, header *types.Header, seal bool) error {
+	return e.verifyHeader(chain, header, nil)
+}
+
+//VerifyHeaders类似于VerifyHeader，但同时验证一批头。
+func (e *Engine) VerifyHeaders(chain consensus.ChainReader, headers []*types.Header, seals []bool) (chan<- struct{}, <-chan error) {
+	abort := make(chan struct{})
+	results := make(chan error, len(headers))
+
+	go func() {
+		for i, header := range headers {
+			err := e.verifyHeader(chain, header, headers[:i])
+
+			select {
+			case <-abort:
+				return
+			case results <- err:
+			}
+		}
+	}()
+	return abort, results
+}
+
+//verifyHeader检查已最终确定的头是否符合共识规则。
+func (e *Engine) verifyHeader(chain consensus.ChainReader, header *types.Header, parents []*types.Header) error {
+	if err := e.verifyProposalHeader(chain, header, parents); err != nil {
+		return err
+	}
+	if header.Number.Uint64() == 0 {
+		return nil
+	}
+	return e.verifyCommittedSeals(chain, header)
+}
+
+//verifyProposalHeader检查头是否符合除提交签名以外的所有共识规则，
+//用于在达成一致之前验证提议。
+func (e *Engine) verifyProposalHeader(chain consensus.ChainReader, header *types.Header, parents []*types.Header) error {
+	if header.Number == nil {
+		return errUnknownBlock
+	}
+	number := header.Number.Uint64()
+
+//不要浪费时间检查未来的块
+	if header.Time.Cmp(big.NewInt(time.Now().Unix())) > 0 {
+		return consensus.ErrFutureBlock
+	}
+	extra, err := ExtractExtra(header)
+	if err != nil {
+		return err
+	}
+	if header.MixDigest != mixDigest {
+		return errInvalidMixDigest
+	}
+	if header.Nonce != (types.BlockNonce{}) {
+		return errInvalidNonce
+	}
+	if header.UncleHash != uncleHash {
+		return errInvalidUncleHash
+	}
+	if number == 0 {
+		return nil
+	}
+	if header.Difficulty == nil || header.Difficulty.Cmp(difficulty) != 0 {
+		return errInvalidDifficulty
+	}
+//验证者集合在创世块中固定
+	validators, err := e.validatorsOf(chain)
+	if err != nil {
+		return err
+	}
+	if len(extra.Validators) != len(validators) {
+		return errInvalidValidators
+	}
+	for i, validator := range validators {
+		if extra.Validators[i] != validator {
+			return errInvalidValidators
+		}
+	}
+//确保块的时间戳与其父块的时间戳不太接近
+	var parent *types.Header
+	if len(parents) > 0 {
+		parent = parents[len(parents)-1]
+	} else {
+		parent = chain.GetHeader(header.ParentHash, number-1)
+	}
+	if parent == nil || parent.Number.Uint64() != number-1 || parent.Hash() != header.ParentHash {
+		return consensus.ErrUnknownAncestor
+	}
+	if parent.Time.Uint64()+e.config.Period > header.Time.Uint64() {
+		return ErrInvalidTimestamp
+	}
+//提议者必须是验证者
+	proposer, err := ecrecover(sigHash(header).Bytes(), extra.Seal)
+	if err != nil {
+		return errInvalidSignature
+	}
+	for _, validator := range validators {
+		if validator == proposer {
+			return nil
+		}
+	}
+	return errUnauthorized
+}
+
+//verifyCommittedSeals检查头是否包含来自不同验证者的法定数量的有效提交签名。
+func (e *Engine) verifyCommittedSeals(chain consensus.ChainReader, header *types.Header) error {
+	validators, err := e.validatorsOf(chain)
+	if err != nil {
+		return err
+	}
+	extra, err := ExtractExtra(header)
+	if err != nil {
+		return err
+	}
+	digest := commitDigest(proposalHash(header))
+
+	signed := make(map[common.Address]bool)
+	for _, seal := range extra.CommittedSeal {
+		signer, err := ecrecover(digest, seal)
+		if err != nil {
+			return errInvalidCommittedSeals
+		}
+		known := false
+		for _, validator := range validators {
+			if validator == signer {
+				known = true
+				break
+			}
+		}
+		if !known || signed[signer] {
+			return errInvalidCommittedSeals
+		}
+		signed[signer] = true
+	}
+	if len(signed) < quorum(len(validators)) {
+		return errInsufficientCommittedSeals
+	}
+	return nil
+}
+
+//importCommitted将已最终确定的块导入本地链（如果配置了导入回调）。
+func (e *Engine) importCommitted(block *types.Block) {
+	e.lock.RLock()
+	chain, insert := e.chain, e.insert
+	e.lock.RUnlock()
+
+	if insert == nil {
+		return
+	}
+//每个验证者收集的提交签名可能不同，如果已经从网络导入了同一提议，则不再导入
+//本地版本，以免产生只有提交签名不同的兄弟块
+	if header := chain.GetHeaderByNumber(block.NumberU64()); header != nil && proposalHash(header) == proposalHash(block.Header()) {
+		return
+	}
+	if err := insert(block); err != nil {
+		log.Warn("Failed to import committed IBFT block", "number", block.Number(), "hash", block.Hash(), "err", err)
+	}
+}
+
+//verifyProposal检查提议块是否可以被接受，并返回其提议者。
+func (e *Engine) verifyProposal(block *types.Block) (common.Address, error) {
+	e.lock.RLock()
+	chain, verify := e.chain, e.verify
+	e.lock.RUnlock()
+
+	header := block.Header()
+	if err := e.verifyProposalHeader(chain, header, nil); err != nil {
+		return common.Address{}, err
+	}
+	if len(block.Uncles()) > 0 || types.DeriveSha(block.Transactions()) != header.TxHash {
+		return common.Address{}, errInvalidProposal
+	}
+	if verify != nil {
+		if err := verify(block); err != nil {
+			return common.Address{}, err
+		}
+	}
+	return e.Author(header)
+}
+
+//VerifyUncles实现consensus.Engine，IBFT中不允许叔叔。
+func (e *Engine) VerifyUncles(chain consensus.ChainReader, block *types.Block) error {
+	if len(block.Uncles()) > 0 {
+		return errors.New("uncles not allowed")
+	}
+	return nil
+}
+
+//VerifySeal实现consensus.Engine，检查提议者签名和提交签名。
+func (e *Engine) VerifySeal(chain consensus.ChainReader, header *types.Header) error {
+	if header.Number.Uint64() == 0 {
+		return errUnknownBlock
+	}
+	if _, err := e.Author(header); err != nil {
+		return errInvalidSignature
+	}
+	return e.verifyCommittedSeals(chain, header)
+}
+
+//Prepare实现consensus.Engine，准备头的共识字段。
+func (e *Engine) Prepare(chain consensus.ChainReader, header *types.Header) error {
+	header.Nonce = types.BlockNonce{}
+	header.MixDigest = mixDigest
+	header.Difficulty = new(big.Int).Set(difficulty)
+
+	number := header.Number.Uint64()
+	parent := chain.GetHeader(header.ParentHash, number-1)
+	if parent == nil {
+		return consensus.ErrUnknownAncestor
+	}
+	validators, err := e.validatorsOf(chain)
+	if err != nil {
+		return err
+	}
+	extra, err := encodeExtra(header.Extra, &Extra{Validators: validators, Seal: []byte{}, CommittedSeal: [][]byte{}})
+	if err != nil {
+		return err
+	}
+	header.Extra = extra
+
+//确保时间戳具有正确的延迟
+	header.Time = new(big.Int).Add(parent.Time, new(big.Int).SetUint64(e.config.Period))
+	if header.Time.Int64() < time.Now().Unix() {
+		header.Time = big.NewInt(time.Now().Unix())
+	}
+	return nil
+}
+
+//Finalize实现consensus.Engine，IBFT中没有块奖励，也不允许叔叔。
+func (e *Engine) Finalize(chain consensus.ChainReader, header *types.Header, state *state.StateDB, txs []*types.Transaction, uncles []*types.Header, receipts []*types.Receipt) (*types.Block, error) {
+	header.Root = state.IntermediateRoot(chain.Config().IsEIP158(header.Number))
+	header.UncleHash = uncleHash
+
+	return types.NewBlock(header, txs, nil, receipts), nil
+}
+
+//Authorize向共识引擎注入验证者私钥以签名提议和共识消息。
+func (e *Engine) Authorize(signer common.Address, signFn SignerFn) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.signer = signer
+	e.signFn = signFn
+}
+
+//Seal实现consensus.Engine，签名提议并将其交给轮状态机。如果本地验证者是
+//当前轮的提议者，块将被提议，一旦达成一致，带有提交签名的块将被推送
+//到结果通道。
+func (e *Engine) Seal(chain consensus.ChainReader, block *types.Block, results chan<- *types.Block, stop <-chan struct{}) error {
+	header := block.Header()
+
+	number := header.Number.Uint64()
+	if number == 0 {
+		return errUnknownBlock
+	}
+	if !e.started() {
+		if err := e.Start(chain, nil, nil); err != nil {
+			return err
+		}
+	}
+	if !e.isValidator(e.local()) {
+		return errUnauthorized
+	}
+	sig, err := e.sign(sigHash(header).Bytes())
+	if err != nil {
+		return err
+	}
+	extra, err := ExtractExtra(header)
+	if err != nil {
+		return err
+	}
+	extra.Seal = sig
+	if header.Extra, err = encodeExtra(header.Extra[:extraVanity], extra); err != nil {
+		return err
+	}
+	task := &sealTask{block: block.WithSeal(header), results: results, stop: stop}
+
+//等待到块的时间戳，然后交给状态机
+	delay := time.Unix(header.Time.Int64(), 0).Sub(time.Now())
+	go func() {
+		select {
+		case <-stop:
+			return
+		case <-time.After(delay):
+		}
+		e.core.setCandidate(task)
+	}()
+	return nil
+}
+
+//SealHash返回块在被签名之前的哈希。
+func (e *Engine) SealHash(header *types.Header) common.Hash {
+	return sigHash(header)
+}
+
+//CalcDifficulty实现consensus.Engine，IBFT块的难度固定。
+func (e *Engine) CalcDifficulty(chain consensus.ChainReader, time uint64, parent *types.Header) *big.Int {
+	return new(big.Int).Set(difficulty)
+}
+
+//APIs实现consensus.Engine，返回面向用户的RPC API。
+func (e *Engine) APIs(chain consensus.ChainReader) []rpc.API {
+	return []rpc.API{{
+		Namespace: "ibft",
+		Version:   "1.0",
+		Service:   &API{chain: chain, engine: e},
+		Public:    false,
+	}}
+}
+
+//Close实现consensus.Engine，停止轮状态机。
+func (e *Engine) Close() error {
+	e.core.stop()
+	return nil
+}
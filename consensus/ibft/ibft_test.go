@@ -0,0 +1,440 @@
+
+//<developer>
+//    <name>linapex 曹一峰</name>
+//    <email>linapex@163.com</email>
+//    <wx>superexc</wx>
+//    <qqgroup>128148617</qqgroup>
+//    <url>https://jsq.ink</url>
+//    <role>pku engineer</role>
+//    <date>2019-03-16 19:16:34</date>
+//</624450074762911744>
+
+
+package ibft
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	ethcore "github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+//testNetwork是一组内存验证者，每个验证者有自己的本地链。
+type testNetwork struct {
+	keys    []*ecdsa.PrivateKey
+	engines []*Engine
+	chains  []*ethcore.BlockChain
+	pipes   map[int][]*p2p.MsgPipeRW
+
+online map[int]bool //连接到网络的验证者
+closed bool         //网络是否已关闭，之后不再导入块
+lock   sync.Mutex   //保护在线状态和关闭期间的块导入
+}
+
+//newTestNetwork创建具有n个验证者的IBFT链，并在给定的在线验证者之间建立连接。
+func newTestNetwork(t *testing.T, n int, timeout uint64, online []int) *testNetwork {
+	network := &testNetwork{pipes: make(map[int][]*p2p.MsgPipeRW), online: make(map[int]bool)}
+
+	validators := make([]common.Address, n)
+	for i := 0; i < n; i++ {
+		key, _ := crypto.GenerateKey()
+		network.keys = append(network.keys, key)
+		validators[i] = crypto.PubkeyToAddress(key.PublicKey)
+	}
+	config := *params.TestChainConfig
+	config.Ethash = nil
+	config.IBFT = &params.IBFTConfig{RequestTimeout: timeout}
+
+	genesis := &ethcore.Genesis{
+		Config:     &config,
+		ExtraData:  GenesisExtra(validators),
+		Difficulty: big.NewInt(1),
+		Mixhash:    mixDigest,
+	}
+	for i := 0; i < n; i++ {
+		db := ethdb.NewMemDatabase()
+		genesis.MustCommit(db)
+
+		chain, err := ethcore.NewBlockChain(db, nil, &config, New(config.IBFT), vm.Config{}, nil)
+		if err != nil {
+			t.Fatalf("failed to create test chain: %v", err)
+		}
+		network.chains = append(network.chains, chain)
+
+		key := network.keys[i]
+		engine := New(config.IBFT)
+		engine.Authorize(validators[i], func(account accounts.Account, hash []byte) ([]byte, error) {
+			return crypto.Sign(hash, key)
+		})
+		network.engines = append(network.engines, engine)
+	}
+	for _, i := range online {
+		if err := network.engines[i].Start(network.chains[i], nil, network.inserter(i)); err != nil {
+			t.Fatalf("failed to start validator %d: %v", i, err)
+		}
+		network.online[i] = true
+	}
+	for x := 0; x < len(online); x++ {
+		for y := x + 1; y < len(online); y++ {
+			a, b := online[x], online[y]
+			rw1, rw2 := p2p.MsgPipe()
+			go network.engines[a].runPeer(fmt.Sprint(b), rw1)
+			go network.engines[b].runPeer(fmt.Sprint(a), rw2)
+			network.pipes[a] = append(network.pipes[a], rw1)
+			network.pipes[b] = append(network.pipes[b], rw2)
+		}
+	}
+//等待所有对等方注册，以免第一个提议在连接建立之前被广播而丢失
+	for _, i := range online {
+		for start := time.Now(); network.engines[i].peers.len() < len(online)-1; time.Sleep(time.Millisecond) {
+			if time.Since(start) > time.Second {
+				t.Fatalf("validator %d: peers not connected in time", i)
+			}
+		}
+	}
+	return network
+}
+
+//inserter返回将已最终确定的块导入给定验证者本地链的回调。与eth处理程序广播导入的
+//块一样，块也被传播到其他在线验证者的链，使错过提交消息的验证者能够跟上。
+func (n *testNetwork) inserter(index int) func(*types.Block) error {
+	return func(block *types.Block) error {
+		n.lock.Lock()
+		defer n.lock.Unlock()
+
+		if n.closed {
+			return nil
+		}
+		if _, err := n.chains[index].InsertChain(types.Blocks{block}); err != nil {
+			return err
+		}
+		for i, chain := range n.chains {
+			if i != index && n.online[i] {
+				chain.InsertChain(types.Blocks{block})
+			}
+		}
+		return nil
+	}
+}
+
+//disconnect断开给定验证者与所有其他验证者的连接并停止它。
+func (n *testNetwork) disconnect(index int) {
+	n.lock.Lock()
+	n.online[index] = false
+	n.lock.Unlock()
+
+	for _, pipe := range n.pipes[index] {
+		pipe.Close()
+	}
+	n.engines[index].Close()
+}
+
+func (n *testNetwork) close() {
+	for i := range n.pipes {
+		n.disconnect(i)
+	}
+	for _, engine := range n.engines {
+		engine.Close()
+	}
+	n.lock.Lock()
+	n.closed = true
+	n.lock.Unlock()
+
+	for _, chain := range n.chains {
+		chain.Stop()
+	}
+}
+
+//newBlock让给定的验证者在自己的链上为下一个块构建未签名的候选块。
+func (n *testNetwork) newBlock(t *testing.T, index int) *types.Block {
+	engine, chain := n.engines[index], n.chains[index]
+	parent := chain.CurrentBlock()
+
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		Number:     new(big.Int).Add(parent.Number(), common.Big1),
+		GasLimit:   parent.GasLimit(),
+		Coinbase:   crypto.PubkeyToAddress(n.keys[index].PublicKey),
+	}
+	if err := engine.Prepare(chain, header); err != nil {
+		t.Fatalf("validator %d: failed to prepare header: %v", index, err)
+	}
+	statedb, _ := chain.StateAt(parent.Root())
+	block, _ := engine.Finalize(chain, header, statedb, nil, nil, nil)
+	return block
+}
+
+//seal让给定的验证者在自己的链上为下一个块构建候选块并开始密封。
+func (n *testNetwork) seal(t *testing.T, index int, stop chan struct{}) chan *types.Block {
+	engine, chain := n.engines[index], n.chains[index]
+	block := n.newBlock(t, index)
+
+	results := make(chan *types.Block, 1)
+	if err := engine.Seal(chain, block, results, stop); err != nil {
+		t.Fatalf("validator %d: failed to seal: %v", index, err)
+	}
+	return results
+}
+
+//signMessage用给定验证者的密钥签名共识消息，并返回其线路编码。
+func (n *testNetwork) signMessage(t *testing.T, index int, msg *message) []byte {
+	sig, err := crypto.Sign(msg.sigHash(), n.keys[index])
+	if err != nil {
+		t.Fatalf("validator %d: failed to sign message: %v", index, err)
+	}
+	msg.Signature = sig
+
+	payload, err := rlp.EncodeToBytes(msg)
+	if err != nil {
+		t.Fatalf("validator %d: failed to encode message: %v", index, err)
+	}
+	return payload
+}
+
+//waitHead等待给定验证者的本地链到达给定高度。
+func (n *testNetwork) waitHead(t *testing.T, index int, number uint64) {
+	for start := time.Now(); n.chains[index].CurrentBlock().NumberU64() < number; time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("validator %d: block %d not imported in time", index, number)
+		}
+	}
+}
+
+//测试验证者就提议者的块达成一致，每个验证者导入相同的最终块，并且最终块带有可验证的
+//提交签名。
+func TestCommit(t *testing.T) {
+	network := newTestNetwork(t, 4, 10000, []int{0, 1, 2, 3})
+	defer network.close()
+
+	stop := make(chan struct{})
+	defer close(stop)
+
+	for i := range network.engines {
+		network.seal(t, i, stop)
+	}
+	for i := range network.engines {
+		network.waitHead(t, i, 1)
+	}
+//收集了不同提交签名的验证者的块哈希可能不同，但它们最终确定的是同一提议
+	block := network.chains[0].CurrentBlock()
+	for i, chain := range network.chains {
+		if hash := proposalHash(chain.CurrentHeader()); hash != proposalHash(block.Header()) {
+			t.Errorf("validator %d: head proposal mismatch: have %x, want %x", i, hash, proposalHash(block.Header()))
+		}
+	}
+	if err := network.engines[0].VerifyHeader(network.chains[0], block.Header(), true); err != nil {
+		t.Fatalf("failed to verify committed header: %v", err)
+	}
+//第1块第0轮的提议者是第二个验证者
+	if author, _ := network.engines[0].Author(block.Header()); author != crypto.PubkeyToAddress(network.keys[1].PublicKey) {
+		t.Errorf("author mismatch: have %x, want proposer", author)
+	}
+//删除或重复提交签名必须使块无效
+	header := block.Header()
+	extra, _ := ExtractExtra(header)
+
+	short := *extra
+	short.CommittedSeal = extra.CommittedSeal[:quorum(4)-1]
+	header.Extra, _ = encodeExtra(header.Extra[:extraVanity], &short)
+	if err := network.engines[0].verifyCommittedSeals(network.chains[0], header); err != errInsufficientCommittedSeals {
+		t.Errorf("short seals: have %v, want %v", err, errInsufficientCommittedSeals)
+	}
+	duplicate := *extra
+	duplicate.CommittedSeal = append([][]byte{extra.CommittedSeal[0]}, extra.CommittedSeal...)
+	header.Extra, _ = encodeExtra(header.Extra[:extraVanity], &duplicate)
+	if err := network.engines[0].verifyCommittedSeals(network.chains[0], header); err != errInvalidCommittedSeals {
+		t.Errorf("duplicate seals: have %v, want %v", err, errInvalidCommittedSeals)
+	}
+//提交签名不影响提议哈希
+	if proposalHash(header) != proposalHash(block.Header()) {
+		t.Errorf("proposal hash depends on committed seals")
+	}
+}
+
+//测试离线的提议者会导致轮更改，后续轮的提议者的块被最终确定。
+func TestRoundChange(t *testing.T) {
+	network := newTestNetwork(t, 4, 100, []int{0, 2, 3})
+	defer network.close()
+
+	stop := make(chan struct{})
+	defer close(stop)
+
+	for _, i := range []int{0, 2, 3} {
+		network.seal(t, i, stop)
+	}
+	for _, i := range []int{0, 2, 3} {
+		network.waitHead(t, i, 1)
+	}
+	block := network.chains[0].CurrentBlock()
+	if err := network.engines[0].VerifyHeader(network.chains[0], block.Header(), true); err != nil {
+		t.Fatalf("failed to verify committed header: %v", err)
+	}
+	if author, _ := network.engines[0].Author(block.Header()); author == crypto.PubkeyToAddress(network.keys[1].PublicKey) {
+		t.Errorf("block authored by offline proposer")
+	}
+}
+
+//测试提议者在提交后立即离线，它的矿工没有导入块时，其他验证者导入最终块并继续
+//为下一个高度达成一致。
+func TestProposerOfflineAfterCommit(t *testing.T) {
+	network := newTestNetwork(t, 4, 10000, []int{0, 1, 2, 3})
+	defer network.close()
+
+	stop := make(chan struct{})
+	defer close(stop)
+
+//第1块第0轮的提议者是第二个验证者，它的最终块从不被测试导入
+	for i := range network.engines {
+		network.seal(t, i, stop)
+	}
+	network.waitHead(t, 0, 1)
+	network.disconnect(1)
+
+	for _, i := range []int{0, 2, 3} {
+		network.waitHead(t, i, 1)
+	}
+//第2块第0轮的提议者是第三个验证者，剩下的验证者仍构成法定数量
+	for _, i := range []int{0, 2, 3} {
+		network.seal(t, i, stop)
+	}
+	for _, i := range []int{0, 2, 3} {
+		network.waitHead(t, i, 2)
+	}
+	if author, _ := network.engines[0].Author(network.chains[0].CurrentHeader()); author != crypto.PubkeyToAddress(network.keys[2].PublicKey) {
+		t.Errorf("author mismatch: have %x, want proposer", author)
+	}
+}
+
+//测试在第0轮被准备并锁定的块在第1轮由不同的提议者重新签名并最终确定。被测
+//验证者是第1轮的提议者，它自己没有锁定，而是从轮更改消息携带的准备证书中得知锁定的块。
+func TestLockedProposalRoundChange(t *testing.T) {
+	network := newTestNetwork(t, 4, 100000, []int{2})
+	defer network.close()
+
+	engine := network.engines[2]
+	validator := func(index int) common.Address { return crypto.PubkeyToAddress(network.keys[index].PublicKey) }
+
+//连接一个观察者对等方来捕获被测验证者广播的消息
+	rw1, rw2 := p2p.MsgPipe()
+	defer rw1.Close()
+	go engine.runPeer("observer", rw1)
+
+	msgs := make(chan *message, 64)
+	go func() {
+		for {
+			msg, err := rw2.ReadMsg()
+			if err != nil {
+				return
+			}
+			var payload []byte
+			if err := msg.Decode(&payload); err != nil {
+				return
+			}
+			if m, err := decodeMessage(payload); err == nil {
+				msgs <- m
+			}
+		}
+	}()
+	wait := func(code, round uint64) *message {
+		timeout := time.After(5 * time.Second)
+		for {
+			select {
+			case msg := <-msgs:
+				if msg.Code == code && msg.Round == round {
+					return msg
+				}
+			case <-timeout:
+				t.Fatalf("message %d for round %d not broadcast in time", code, round)
+			}
+		}
+	}
+//第1块第0轮的提议者是第二个验证者，它的块被其他验证者准备
+	block := network.newBlock(t, 1)
+	header := block.Header()
+	extra, _ := ExtractExtra(header)
+	extra.Seal, _ = crypto.Sign(sigHash(header).Bytes(), network.keys[1])
+	header.Extra, _ = encodeExtra(header.Extra[:extraVanity], extra)
+	block = block.WithSeal(header)
+
+	digest := proposalHash(block.Header())
+	proposal, _ := rlp.EncodeToBytes(block)
+
+	var cert [][]byte
+	for _, i := range []int{0, 1, 3} {
+		cert = append(cert, network.signMessage(t, i, &message{Code: msgPrepare, Height: 1, Round: 0, Digest: digest}))
+	}
+//不足法定数量的证书被拒绝
+	invalid := network.signMessage(t, 1, &message{Code: msgRoundChange, Height: 1, Round: 1, Proposal: proposal, Prepares: cert[:2]})
+	if err := engine.handlePayload("observer", invalid); err != errInvalidCertificate {
+		t.Fatalf("short certificate: have %v, want %v", err, errInvalidCertificate)
+	}
+//锁定的验证者携带证书请求第1轮，另一个验证者也请求第1轮
+	if err := engine.handlePayload("observer", network.signMessage(t, 0, &message{Code: msgRoundChange, Height: 1, Round: 1, Proposal: proposal, Prepares: cert})); err != nil {
+		t.Fatalf("failed to handle round change: %v", err)
+	}
+	if err := engine.handlePayload("observer", network.signMessage(t, 3, &message{Code: msgRoundChange, Height: 1, Round: 1})); err != nil {
+		t.Fatalf("failed to handle round change: %v", err)
+	}
+//被测验证者加入轮更改，携带证书，并重新提议锁定的块
+	if change := wait(msgRoundChange, 1); len(change.Prepares) != len(cert) {
+		t.Fatalf("round change certificate mismatch: have %d prepares, want %d", len(change.Prepares), len(cert))
+	}
+	preprepare := wait(msgPreprepare, 1)
+	reproposed, err := preprepare.proposal()
+	if err != nil {
+		t.Fatalf("failed to decode proposal: %v", err)
+	}
+	if sigHash(reproposed.Header()) != sigHash(block.Header()) {
+		t.Fatalf("reproposed block mismatch: have %x, want %x", sigHash(reproposed.Header()), sigHash(block.Header()))
+	}
+	if author, _ := engine.Author(reproposed.Header()); author != validator(2) {
+		t.Fatalf("reproposal author mismatch: have %x, want %x", author, validator(2))
+	}
+//其他验证者准备并提交重新提议的块
+	digest = proposalHash(reproposed.Header())
+	for _, i := range []int{0, 3} {
+		if err := engine.handlePayload("observer", network.signMessage(t, i, &message{Code: msgPrepare, Height: 1, Round: 1, Digest: digest})); err != nil {
+			t.Fatalf("validator %d: failed to handle prepare: %v", i, err)
+		}
+	}
+	wait(msgCommit, 1)
+	for _, i := range []int{0, 3} {
+		seal, _ := crypto.Sign(commitDigest(digest), network.keys[i])
+		if err := engine.handlePayload("observer", network.signMessage(t, i, &message{Code: msgCommit, Height: 1, Round: 1, Digest: digest, CommittedSeal: seal})); err != nil {
+			t.Fatalf("validator %d: failed to handle commit: %v", i, err)
+		}
+	}
+	network.waitHead(t, 2, 1)
+
+	head := network.chains[2].CurrentHeader()
+	if sigHash(head) != sigHash(block.Header()) {
+		t.Errorf("committed block mismatch: have %x, want %x", sigHash(head), sigHash(block.Header()))
+	}
+	if author, _ := engine.Author(head); author != validator(2) {
+		t.Errorf("author mismatch: have %x, want %x", author, validator(2))
+	}
+}
+
+func TestQuorum(t *testing.T) {
+	tests := []struct{ validators, quorum int }{
+		{1, 1}, {2, 2}, {3, 3}, {4, 3}, {5, 4}, {7, 5}, {10, 7},
+	}
+	for _, tt := range tests {
+		if have := quorum(tt.validators); have != tt.quorum {
+			t.Errorf("validators %d: quorum mismatch: have %d, want %d", tt.validators, have, tt.quorum)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+
+//<developer>
+//    <name>linapex 曹一峰</name>
+//    <email>linapex@163.com</email>
+//    <wx>superexc</wx>
+//    <qqgroup>128148617</qqgroup>
+//    <url>https://jsq.ink</url>
+//    <role>pku engineer</role>
+//    <date>2019-03-16 19:16:34</date>
+//</624450074737745920>
+
+
+package ibft
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+//共识消息代码。
+const (
+msgPreprepare  uint64 = iota //提议者广播的新块提议
+msgPrepare                   //验证者接受了当前轮的提议
+msgCommit                    //验证者看到法定数量的准备消息并提交提议
+msgRoundChange               //验证者请求进入更高的轮
+)
+
+//message是验证者之间交换的签名共识消息。
+type message struct {
+	Code          uint64
+	Height        uint64
+	Round         uint64
+Digest        common.Hash //准备和提交消息的提议哈希
+Proposal      []byte      //预准备消息中RLP编码的提议块，或轮更改消息中锁定的块
+CommittedSeal []byte      //提交消息中对提议的提交签名
+Prepares      [][]byte    //轮更改消息中锁定块的准备证书（RLP编码的签名准备消息）
+	Signature     []byte
+
+sender common.Address //从签名恢复的发送者，不编码
+}
+
+//sigHash返回消息发送者签名的哈希，它是不含签名的所有字段的哈希。
+func (m *message) sigHash() []byte {
+	blob, _ := rlp.EncodeToBytes([]interface{}{m.Code, m.Height, m.Round, m.Digest, m.Proposal, m.CommittedSeal, m.Prepares})
+	return crypto.Keccak256(blob)
+}
+
+//decodeMessage解码线路上的共识消息并恢复其发送者。
+func decodeMessage(payload []byte) (*message, error) {
+	msg := new(message)
+	if err := rlp.DecodeBytes(payload, msg); err != nil {
+		return nil, err
+	}
+	sender, err := ecrecover(msg.sigHash(), msg.Signature)
+	if err != nil {
+		return nil, errInvalidSignature
+	}
+	msg.sender = sender
+	return msg, nil
+}
+
+//proposal解码预准备消息中的提议块。
+func (m *message) proposal() (*types.Block, error) {
+	block := new(types.Block)
+	if err := rlp.DecodeBytes(m.Proposal, block); err != nil {
+		return nil, err
+	}
+	return block, nil
+}
@@ -0,0 +1,183 @@
+
+//<developer>
+//    <name>linapex 曹一峰</name>
+//    <email>linapex@163.com</email>
+//    <wx>superexc</wx>
+//    <qqgroup>128148617</qqgroup>
+//    <url>https://jsq.ink</url>
+//    <role>pku engineer</role>
+//    <date>2019-03-16 19:16:34</date>
+//</624450074750328832>
+
+
+package ibft
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p"
+)
+
+const (
+protocolName       = "ibft"           //共识协议的名称
+protocolVersion    = 1                //共识协议的版本
+protocolLength     = 1                //协议使用的消息代码数
+protocolMaxMsgSize = 10 * 1024 * 1024 //共识消息的最大大小（包含完整的提议块）
+
+consensusMsg = 0x00 //承载签名共识消息的唯一消息代码
+
+peerQueueSize = 256 //每个对等方排队等待发送的最大消息数
+)
+
+//peer是运行共识协议的远程节点。
+type peer struct {
+	id    string
+	rw    p2p.MsgReadWriter
+	queue chan []byte
+	term  chan struct{}
+}
+
+//writeLoop将排队的消息发送给对等方，以免慢速对等方阻塞状态机。
+func (p *peer) writeLoop() {
+	for {
+		select {
+		case payload := <-p.queue:
+			if err := p2p.Send(p.rw, consensusMsg, payload); err != nil {
+				log.Trace("Failed to send IBFT message", "peer", p.id, "err", err)
+				return
+			}
+		case <-p.term:
+			return
+		}
+	}
+}
+
+//peerSet是运行共识协议的活动对等方集合。
+type peerSet struct {
+	peers map[string]*peer
+	lock  sync.RWMutex
+}
+
+//newPeerSet创建一个新的对等方集合。
+func newPeerSet() *peerSet {
+	return &peerSet{peers: make(map[string]*peer)}
+}
+
+//register注入新的对等方，如果已经存在则返回错误。
+func (ps *peerSet) register(p *peer) error {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	if _, ok := ps.peers[p.id]; ok {
+		return fmt.Errorf("peer %s already registered", p.id)
+	}
+	ps.peers[p.id] = p
+	return nil
+}
+
+//unregister删除对等方并停止其写入循环。
+func (ps *peerSet) unregister(id string) {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	if p, ok := ps.peers[id]; ok {
+		close(p.term)
+		delete(ps.peers, id)
+	}
+}
+
+//len返回当前对等方的数目。
+func (ps *peerSet) len() int {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	return len(ps.peers)
+}
+
+//Protocols返回共识协议，用于在验证者之间交换共识消息。
+func (e *Engine) Protocols() []p2p.Protocol {
+	return []p2p.Protocol{{
+		Name:    protocolName,
+		Version: protocolVersion,
+		Length:  protocolLength,
+		Run: func(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+			return e.runPeer(p.ID().String(), rw)
+		},
+	}}
+}
+
+//runPeer是共识协议的对等方处理程序，读取消息直到连接断开。
+func (e *Engine) runPeer(id string, rw p2p.MsgReadWriter) error {
+	p := &peer{id: id, rw: rw, queue: make(chan []byte, peerQueueSize), term: make(chan struct{})}
+	if err := e.peers.register(p); err != nil {
+		return err
+	}
+	defer e.peers.unregister(id)
+
+	go p.writeLoop()
+
+	for {
+		msg, err := rw.ReadMsg()
+		if err != nil {
+			return err
+		}
+		if msg.Size > protocolMaxMsgSize {
+			msg.Discard()
+			return fmt.Errorf("message too large: %v > %v", msg.Size, protocolMaxMsgSize)
+		}
+		if msg.Code != consensusMsg {
+			msg.Discard()
+			return fmt.Errorf("invalid message code %d", msg.Code)
+		}
+		var payload []byte
+		if err := msg.Decode(&payload); err != nil {
+			return err
+		}
+		if err := e.handlePayload(id, payload); err != nil {
+			log.Trace("Failed to handle IBFT message", "peer", id, "err", err)
+		}
+	}
+}
+
+//handlePayload处理来自对等方的共识消息，并将有效的新消息转发给其他对等方，
+//使没有直接连接的验证者也能收到。
+func (e *Engine) handlePayload(from string, payload []byte) error {
+	hash := crypto.Keccak256Hash(payload)
+	if e.seen.Contains(hash) {
+		return nil
+	}
+	e.seen.Add(hash, struct{}{})
+
+	if !e.started() {
+		return errUnknownBlock
+	}
+	msg, err := decodeMessage(payload)
+	if err != nil {
+		return err
+	}
+	if !e.isValidator(msg.sender) {
+		return errUnauthorized
+	}
+	e.gossip(payload, from)
+	return e.core.handleMessage(msg)
+}
+
+//gossip将消息排队发送给除源对等方以外的所有对等方，队列已满的对等方将被跳过。
+func (e *Engine) gossip(payload []byte, except string) {
+	e.peers.lock.RLock()
+	defer e.peers.lock.RUnlock()
+
+	for id, p := range e.peers.peers {
+		if id == except {
+			continue
+		}
+		select {
+		case p.queue <- payload:
+		default:
+			log.Trace("Dropping IBFT message to slow peer", "peer", id)
+		}
+	}
+}
@@ -59,6 +59,7 @@ const (
 	maxTimeFutureBlocks = 30
 	badBlockLimit       = 10
 	triesInMemory       = 128
+	bodyPruneInterval   = time.Minute
 
 //blockchainversion确保不兼容的数据库强制从头开始重新同步。
 	BlockChainVersion uint64 = 3
@@ -71,6 +72,7 @@ Disabled       bool          //是否禁用trie写缓存（存档节点）
 TrieCleanLimit int           //用于在内存中缓存trie节点的内存允许量（MB）
 TrieDirtyLimit int           //开始将脏的trie节点刷新到磁盘的内存限制（MB）
 TrieTimeLimit  time.Duration //刷新内存中当前磁盘的时间限制
+BodyRetention  uint64        //保留主体和收据的最近块数，更早的在后台删除（零表示全部保留）
 }
 
 //区块链表示给定数据库的标准链，其中包含一个Genesis
@@ -110,6 +112,8 @@ procmu  sync.RWMutex //块处理器锁
 checkpoint       int          //检查站向新检查站计数
 currentBlock     atomic.Value //当前区块链头
 currentFastBlock atomic.Value //快速同步链的当前磁头（可能在区块链上方！）
+bodyTail         uint64       //仍保存主体和收据的最低块号（原子访问）
+bodyTailLock     sync.Mutex   //串行化主体修剪边界的更新
 
 stateCache    state.Database //要在导入之间重用的状态数据库（包含状态缓存）
 bodyCache     *lru.Cache     //缓存最新的块体
@@ -144,6 +148,10 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 			TrieTimeLimit:  5 * time.Minute,
 		}
 	}
+//确保链配置激活的所有自定义预编译合同都可用
+	if err := vm.ValidatePrecompiles(chainConfig); err != nil {
+		return nil, err
+	}
 	bodyCache, _ := lru.New(bodyCacheLimit)
 	bodyRLPCache, _ := lru.New(bodyCacheLimit)
 	receiptsCache, _ := lru.New(receiptsCacheLimit)
@@ -183,6 +191,7 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 	if err := bc.loadLastState(); err != nil {
 		return nil, err
 	}
+	bc.bodyTail = rawdb.ReadBodyTail(db)
 //检查块哈希的当前状态，确保链中没有任何坏块
 	for hash := range BadHashes {
 		if header := bc.GetHeaderByHash(hash); header != nil {
@@ -198,6 +207,10 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 	}
 //取得这个国家的所有权
 	go bc.update()
+	if cacheConfig.BodyRetention > 0 {
+		bc.wg.Add(1)
+		go bc.pruneBodies()
+	}
 	return bc, nil
 }
 
@@ -1538,6 +1551,108 @@ func (bc *BlockChain) update() {
 	}
 }
 
+//pruneBodies定期删除保留窗口之前的规范块的主体、收据和交易索引，只保留头。
+//保留窗口至少包含内存中的状态所对应的块。
+func (bc *BlockChain) pruneBodies() {
+	defer bc.wg.Done()
+
+	retention := bc.cacheConfig.BodyRetention
+	if retention < triesInMemory {
+		retention = triesInMemory
+	}
+	ticker := time.NewTicker(bodyPruneInterval)
+	defer ticker.Stop()
+	for {
+		if head := bc.CurrentBlock().NumberU64(); head > retention {
+			bc.pruneBodiesBelow(head - retention)
+		}
+		select {
+		case <-ticker.C:
+		case <-bc.quit:
+			return
+		}
+	}
+}
+
+//pruneBodiesBelow删除编号低于limit的规范块的主体和收据。创世块总是保留。
+func (bc *BlockChain) pruneBodiesBelow(limit uint64) {
+	bc.bodyTailLock.Lock()
+	defer bc.bodyTailLock.Unlock()
+
+	tail := atomic.LoadUint64(&bc.bodyTail)
+	if tail == 0 {
+		tail = 1
+	}
+	if tail >= limit {
+		return
+	}
+	var (
+		start  = time.Now()
+		batch  = bc.db.NewBatch()
+		pruned int
+	)
+	for number := tail; number < limit; number++ {
+		if atomic.LoadInt32(&bc.procInterrupt) == 1 {
+			limit = number
+			break
+		}
+		hash := rawdb.ReadCanonicalHash(bc.db, number)
+		if body := rawdb.ReadBody(bc.db, hash, number); body != nil {
+			for _, tx := range body.Transactions {
+				rawdb.DeleteTxLookupEntry(batch, tx.Hash())
+			}
+			rawdb.DeleteBody(batch, hash, number)
+			rawdb.DeleteReceipts(batch, hash, number)
+			pruned++
+		}
+		bc.bodyCache.Remove(hash)
+		bc.bodyRLPCache.Remove(hash)
+		bc.receiptsCache.Remove(hash)
+		bc.blockCache.Remove(hash)
+
+		if batch.ValueSize() >= ethdb.IdealBatchSize {
+			rawdb.WriteBodyTail(batch, number+1)
+			if err := batch.Write(); err != nil {
+				log.Crit("Failed to prune block bodies", "err", err)
+			}
+			batch.Reset()
+			atomic.StoreUint64(&bc.bodyTail, number+1)
+		}
+	}
+	rawdb.WriteBodyTail(batch, limit)
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to prune block bodies", "err", err)
+	}
+	atomic.StoreUint64(&bc.bodyTail, limit)
+
+	if pruned > 0 {
+		log.Info("Pruned old block bodies", "count", pruned, "tail", limit, "elapsed", common.PrettyDuration(time.Since(start)))
+	}
+}
+
+//BodyTail返回仍保存主体和收据的最低块号，更早的块只有头。
+func (bc *BlockChain) BodyTail() uint64 {
+	return atomic.LoadUint64(&bc.bodyTail)
+}
+
+//SetBodyTail记录编号低于number的块只有头，例如修剪同步跳过了它们的主体和收据。
+//边界只会提高。
+func (bc *BlockChain) SetBodyTail(number uint64) {
+	bc.bodyTailLock.Lock()
+	defer bc.bodyTailLock.Unlock()
+
+	if number <= atomic.LoadUint64(&bc.bodyTail) {
+		return
+	}
+	rawdb.WriteBodyTail(bc.db, number)
+	atomic.StoreUint64(&bc.bodyTail, number)
+}
+
+//IsBodyPruned返回给定编号的块的主体和收据是否已被修剪。创世块总是保留。
+func (bc *BlockChain) IsBodyPruned(number uint64) bool {
+	return number > 0 && number < bc.BodyTail()
+}
+
 //bad blocks返回客户端在网络上看到的最后一个“坏块”的列表
 func (bc *BlockChain) BadBlocks() []*types.Block {
 	blocks := make([]*types.Block, 0, bc.badBlocks.Len())
@@ -1606,6 +1721,40 @@ func (bc *BlockChain) InsertHeaderChain(chain []*types.Header, checkFreq int) (i
 	return bc.hc.InsertHeaderChain(chain, whFunc, start)
 }
 
+//InsertTrustedHeaderChain将一批头插入本地链而不进行共识验证。
+//它只能用于已经通过哈希链与受信任检查点关联的头，因此只检查
+//头是否有序且相互链接。与InsertHeaderChain不同，数据库中已有
+//但尚未链接到本地链的头也会被写入。
+func (bc *BlockChain) InsertTrustedHeaderChain(chain []*types.Header) (int, error) {
+	if len(chain) == 0 {
+		return 0, nil
+	}
+	start := time.Now()
+	for i := 1; i < len(chain); i++ {
+		if chain[i].Number.Uint64() != chain[i-1].Number.Uint64()+1 || chain[i].ParentHash != chain[i-1].Hash() {
+			return 0, fmt.Errorf("non contiguous insert: item %d is #%d [%x…], item %d is #%d [%x…] (parent [%x…])", i-1, chain[i-1].Number,
+				chain[i-1].Hash().Bytes()[:4], i, chain[i].Number, chain[i].Hash().Bytes()[:4], chain[i].ParentHash[:4])
+		}
+	}
+	bc.chainmu.Lock()
+	defer bc.chainmu.Unlock()
+
+	bc.wg.Add(1)
+	defer bc.wg.Done()
+
+	for i, header := range chain {
+		if atomic.LoadInt32(&bc.procInterrupt) == 1 {
+			return i, errors.New("aborted")
+		}
+		if _, err := bc.hc.WriteHeader(header); err != nil {
+			return i, err
+		}
+	}
+	last := chain[len(chain)-1]
+	log.Debug("Imported trusted block headers", "count", len(chain), "elapsed", common.PrettyDuration(time.Since(start)), "number", last.Number, "hash", last.Hash())
+	return 0, nil
+}
+
 //当前头检索规范链的当前头。这个
 //从HeaderChain的内部缓存中检索头。
 func (bc *BlockChain) CurrentHeader() *types.Header {
@@ -1480,3 +1480,71 @@ func BenchmarkBlockChain_1x1000Executions(b *testing.B) {
 	benchmarkLargeNumberOfValueToNonexisting(b, numTxs, numBlocks, recipientFn, dataFn)
 }
 
+
+//测试受信任的头链在不经过共识验证的情况下被导入。
+func TestInsertTrustedHeaderChain(t *testing.T) {
+	db, blockchain, err := newCanonical(ethash.NewFakeFailer(5), 0, false)
+	if err != nil {
+		t.Fatalf("failed to create pristine chain: %v", err)
+	}
+	defer blockchain.Stop()
+
+	headers := makeHeaderChain(blockchain.CurrentHeader(), 10, ethash.NewFaker(), db, 0)
+	if _, err := blockchain.InsertHeaderChain(headers, 1); err == nil {
+		t.Fatal("invalid header chain imported with verification")
+	}
+	if _, err := blockchain.InsertTrustedHeaderChain(append(headers[:3:3], headers[4:]...)); err == nil {
+		t.Fatal("non contiguous header chain imported")
+	}
+	if _, err := blockchain.InsertTrustedHeaderChain(headers); err != nil {
+		t.Fatalf("failed to import trusted header chain: %v", err)
+	}
+	if head := blockchain.CurrentHeader().Hash(); head != headers[9].Hash() {
+		t.Fatalf("head header mismatch: have %x, want %x", head, headers[9].Hash())
+	}
+	for _, header := range headers {
+		if hash := rawdb.ReadCanonicalHash(db, header.Number.Uint64()); hash != header.Hash() {
+			t.Fatalf("canonical hash mismatch for #%d: have %x, want %x", header.Number, hash, header.Hash())
+		}
+	}
+}
+
+//测试修剪删除旧块的主体和收据但保留头，并且修剪进度在重启后仍然有效。
+func TestBodyPruning(t *testing.T) {
+	db, blockchain, err := newCanonical(ethash.NewFaker(), 200, true)
+	if err != nil {
+		t.Fatalf("failed to create pristine chain: %v", err)
+	}
+	blockchain.pruneBodiesBelow(72)
+	blockchain.Stop()
+
+	for number := uint64(0); number <= 200; number++ {
+		hash := rawdb.ReadCanonicalHash(db, number)
+		if rawdb.ReadHeader(db, hash, number) == nil {
+			t.Fatalf("header #%d missing", number)
+		}
+		pruned := number > 0 && number < 72
+		if body := rawdb.ReadBody(db, hash, number); (body == nil) != pruned {
+			t.Fatalf("body #%d presence mismatch: have %v, want %v", number, body != nil, !pruned)
+		}
+		if receipts := rawdb.ReadReceipts(db, hash, number); (receipts == nil) != pruned {
+			t.Fatalf("receipts #%d presence mismatch: have %v, want %v", number, receipts != nil, !pruned)
+		}
+	}
+	if tail := rawdb.ReadBodyTail(db); tail != 72 {
+		t.Fatalf("stored body tail mismatch: have %d, want %d", tail, 72)
+	}
+//重新打开链，修剪进度应该被加载
+	blockchain, _ = NewBlockChain(db, nil, params.AllEthashProtocolChanges, ethash.NewFaker(), vm.Config{}, nil)
+	defer blockchain.Stop()
+
+	if tail := blockchain.BodyTail(); tail != 72 {
+		t.Fatalf("body tail mismatch: have %d, want %d", tail, 72)
+	}
+	if block := blockchain.GetBlockByNumber(50); block != nil {
+		t.Fatalf("pruned block #50 returned")
+	}
+	if block := blockchain.GetBlockByNumber(72); block == nil {
+		t.Fatalf("retained block #72 missing")
+	}
+}
@@ -28,5 +28,8 @@ var (
 //如果事务的nonce高于
 //下一个基于本地链的期望值。
 	ErrNonceTooHigh = errors.New("nonce too high")
+
+//如果请求的块的主体和收据已被修剪，只剩下块头，则返回ErrBodyPruned。
+	ErrBodyPruned = errors.New("block body and receipts pruned")
 )
 
@@ -0,0 +1,192 @@
+
+//<developer>
+//    <name>linapex 曹一峰</name>
+//    <email>linapex@163.com</email>
+//    <wx>superexc</wx>
+//    <qqgroup>128148617</qqgroup>
+//    <url>https://jsq.ink</url>
+//    <role>pku engineer</role>
+//    <date>2019-03-16 19:16:35</date>
+//</624450078836264961>
+
+
+//包forkid实现EIP-2124分叉标识符。
+package forkid
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"math"
+	"math/big"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+var (
+//如果远程分叉校验和是本地已通过分叉的子集，但远程不知道
+//本地的下一个分叉，则返回ErrRemoteStale。
+	ErrRemoteStale = errors.New("remote needs update")
+
+//如果远程分叉校验和与本地链不兼容，或者远程宣布的分叉
+//本地已经通过却不知道，则返回ErrLocalIncompatibleOrStale。
+	ErrLocalIncompatibleOrStale = errors.New("local incompatible or needs update")
+)
+
+//Blockchain定义计算分叉标识所需的区块链方法。
+type Blockchain interface {
+//Config检索链的分叉配置。
+	Config() *params.ChainConfig
+
+//Genesis检索链的创世块。
+	Genesis() *types.Block
+
+//CurrentHeader检索链的当前头。
+	CurrentHeader() *types.Header
+}
+
+//ID是EIP-2124分叉标识符：已通过分叉的校验和以及下一个已知分叉。
+type ID struct {
+Hash [4]byte //创世哈希和已通过分叉块号的CRC32校验和
+Next uint64  //下一个即将到来的分叉的块号，如果不知道则为0
+}
+
+//Filter检查远程分叉标识是否与本地链兼容。
+type Filter func(id ID) error
+
+//NewID计算给定链当前头的分叉标识。
+func NewID(chain Blockchain) ID {
+	return newID(chain.Config(), chain.Genesis().Hash(), chain.CurrentHeader().Number.Uint64())
+}
+
+//newID是NewID的内部版本，接受分解的链参数以便测试。
+func newID(config *params.ChainConfig, genesis common.Hash, head uint64) ID {
+	hash := crc32.ChecksumIEEE(genesis[:])
+
+	var next uint64
+	for _, fork := range gatherForks(config) {
+		if fork <= head {
+			hash = checksumUpdate(hash, fork)
+			continue
+		}
+		next = fork
+		break
+	}
+	return ID{Hash: checksumToBytes(hash), Next: next}
+}
+
+//NewFilter创建一个过滤器，根据给定链的当前头验证远程分叉标识。
+func NewFilter(chain Blockchain) Filter {
+	return newFilter(chain.Config(), chain.Genesis().Hash(), func() uint64 {
+		return chain.CurrentHeader().Number.Uint64()
+	})
+}
+
+//newFilter是NewFilter的内部版本，接受分解的链参数以便测试。
+func newFilter(config *params.ChainConfig, genesis common.Hash, headfn func() uint64) Filter {
+//预先计算每个分叉之后的校验和
+	forks := gatherForks(config)
+	sums := make([][4]byte, len(forks)+1)
+
+	hash := crc32.ChecksumIEEE(genesis[:])
+	sums[0] = checksumToBytes(hash)
+	for i, fork := range forks {
+		hash = checksumUpdate(hash, fork)
+		sums[i+1] = checksumToBytes(hash)
+	}
+//添加一个永远不会到达的哨兵分叉，以简化下面的循环
+	forks = append(forks, math.MaxUint64)
+
+	return func(id ID) error {
+		head := headfn()
+		for i, fork := range forks {
+//跳过本地已经通过的分叉
+			if head >= fork {
+				continue
+			}
+//sums[i]是本地当前的校验和
+			if sums[i] == id.Hash {
+//规则1：校验和相同时，远程宣布的下一个分叉不能已被本地通过
+				if id.Next > 0 && head >= id.Next {
+					return ErrLocalIncompatibleOrStale
+				}
+				return nil
+			}
+//规则2：远程是本地过去的状态，它必须知道本地随后通过的分叉
+			for j := 0; j < i; j++ {
+				if sums[j] == id.Hash {
+					if forks[j] != id.Next {
+						return ErrRemoteStale
+					}
+					return nil
+				}
+			}
+//规则3：远程是本地未来的状态，本地仍在同步中
+			for j := i + 1; j < len(sums); j++ {
+				if sums[j] == id.Hash {
+					return nil
+				}
+			}
+//规则4：不匹配任何已知状态
+			return ErrLocalIncompatibleOrStale
+		}
+		return ErrLocalIncompatibleOrStale
+	}
+}
+
+//checksumUpdate用新的分叉块号更新CRC32校验和。
+func checksumUpdate(hash uint32, fork uint64) uint32 {
+	var blob [8]byte
+	binary.BigEndian.PutUint64(blob[:], fork)
+	return crc32.Update(hash, crc32.IEEETable, blob[:])
+}
+
+//checksumToBytes将uint32校验和转换为[4]byte。
+func checksumToBytes(hash uint32) [4]byte {
+	var blob [4]byte
+	binary.BigEndian.PutUint32(blob[:], hash)
+	return blob
+}
+
+//gatherForks收集链配置中所有分叉的块号，排序并去重。
+//在创世时激活的分叉不影响标识，因此被忽略。
+func gatherForks(config *params.ChainConfig) []uint64 {
+	var forks []uint64
+
+	kind := reflect.TypeOf(params.ChainConfig{})
+	conf := reflect.ValueOf(config).Elem()
+	for i := 0; i < kind.NumField(); i++ {
+		field := kind.Field(i)
+		if !strings.HasSuffix(field.Name, "Block") || field.Type != reflect.TypeOf(new(big.Int)) {
+			continue
+		}
+		if rule := conf.Field(i).Interface().(*big.Int); rule != nil {
+			forks = append(forks, rule.Uint64())
+		}
+	}
+//自定义预编译合同的激活同样改变共识规则
+	for _, precompile := range config.Precompiles {
+		if precompile.Block != nil {
+			forks = append(forks, precompile.Block.Uint64())
+		}
+	}
+//切换到治理合约提供的签名者列表同样改变共识规则
+	if config.Clique != nil && config.Clique.Governance != nil {
+		forks = append(forks, config.Clique.GovernanceBlock)
+	}
+	sort.Slice(forks, func(i, j int) bool { return forks[i] < forks[j] })
+
+	var unique []uint64
+	for _, fork := range forks {
+		if fork == 0 || (len(unique) > 0 && unique[len(unique)-1] == fork) {
+			continue
+		}
+		unique = append(unique, fork)
+	}
+	return unique
+}
@@ -0,0 +1,124 @@
+
+//<developer>
+//    <name>linapex 曹一峰</name>
+//    <email>linapex@163.com</email>
+//    <wx>superexc</wx>
+//    <qqgroup>128148617</qqgroup>
+//    <url>https://jsq.ink</url>
+//    <role>pku engineer</role>
+//    <date>2019-03-16 19:16:35</date>
+//</624450078836264962>
+
+
+package forkid
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+//测试主网在各个分叉前后的分叉标识与EIP-2124中的规范值一致。
+func TestCreation(t *testing.T) {
+	tests := []struct {
+		head uint64
+		want ID
+	}{
+{0, ID{Hash: checksumToBytes(0xfc64ec04), Next: 1150000}},       //未同步
+{1149999, ID{Hash: checksumToBytes(0xfc64ec04), Next: 1150000}}, //最后一个前沿块
+{1150000, ID{Hash: checksumToBytes(0x97c2c34c), Next: 1920000}}, //第一个宅基地块
+{1919999, ID{Hash: checksumToBytes(0x97c2c34c), Next: 1920000}}, //最后一个宅基地块
+{1920000, ID{Hash: checksumToBytes(0x91d1f948), Next: 2463000}}, //第一个DAO块
+{2462999, ID{Hash: checksumToBytes(0x91d1f948), Next: 2463000}}, //最后一个DAO块
+{2463000, ID{Hash: checksumToBytes(0x7a64da13), Next: 2675000}}, //第一个EIP150块
+{2674999, ID{Hash: checksumToBytes(0x7a64da13), Next: 2675000}}, //最后一个EIP150块
+{2675000, ID{Hash: checksumToBytes(0x3edd5b10), Next: 4370000}}, //第一个EIP155/158块
+{4369999, ID{Hash: checksumToBytes(0x3edd5b10), Next: 4370000}}, //最后一个EIP155/158块
+{4370000, ID{Hash: checksumToBytes(0xa00bc324), Next: 0}},       //第一个拜占庭块，没有已知的后续分叉
+	}
+	for i, tt := range tests {
+		if have := newID(params.MainnetChainConfig, params.MainnetGenesisHash, tt.head); have != tt.want {
+			t.Errorf("test %d: fork ID mismatch: have %x, want %x", i, have, tt.want)
+		}
+	}
+}
+
+//测试clique治理合约的激活块被视为分叉。
+func TestGatherGovernanceFork(t *testing.T) {
+	config := *params.AllCliqueProtocolChanges
+	config.Clique = &params.CliqueConfig{Period: 15, Epoch: 30000, Governance: &common.Address{0x01}, GovernanceBlock: 5000}
+
+	forks := gatherForks(&config)
+	if len(forks) != 1 || forks[0] != 5000 {
+		t.Fatalf("forks mismatch: have %v, want %v", forks, []uint64{5000})
+	}
+	config.Clique = &params.CliqueConfig{Period: 15, Epoch: 30000, GovernanceBlock: 5000}
+	if forks := gatherForks(&config); len(forks) != 0 {
+		t.Fatalf("forks mismatch without governance contract: have %v, want none", forks)
+	}
+}
+
+//测试远程分叉标识按EIP-2124的规则被接受或拒绝。
+func TestValidation(t *testing.T) {
+	tests := []struct {
+		head uint64
+		id   ID
+		err  error
+	}{
+//本地和远程处于同一状态，都不知道下一个分叉
+		{4370000, ID{Hash: checksumToBytes(0xa00bc324), Next: 0}, nil},
+
+//本地和远程处于同一状态，远程宣布本地尚未到达的分叉
+		{4370000, ID{Hash: checksumToBytes(0xa00bc324), Next: math.MaxUint64}, nil},
+
+//本地处于EIP150之后，远程宣布了本地已经通过的分叉
+		{2675000, ID{Hash: checksumToBytes(0x7a64da13), Next: 2600000}, ErrRemoteStale},
+
+//本地已通过一个远程宣布但本地不知道的分叉
+		{7280000, ID{Hash: checksumToBytes(0xa00bc324), Next: 7280000}, ErrLocalIncompatibleOrStale},
+
+//远程处于本地过去的状态，并且知道本地的下一个分叉
+		{4370000, ID{Hash: checksumToBytes(0x3edd5b10), Next: 4370000}, nil},
+
+//远程处于本地过去的状态，但不知道本地随后通过的分叉
+		{4370000, ID{Hash: checksumToBytes(0x3edd5b10), Next: 0}, ErrRemoteStale},
+
+//本地仍在同步，远程处于本地未来的状态
+		{0, ID{Hash: checksumToBytes(0xa00bc324), Next: 0}, nil},
+		{2675000, ID{Hash: checksumToBytes(0xa00bc324), Next: 0}, nil},
+
+//远程处于完全不同的链上
+		{4370000, ID{Hash: checksumToBytes(0xafec6b27), Next: 0}, ErrLocalIncompatibleOrStale},
+	}
+	for i, tt := range tests {
+		filter := newFilter(params.MainnetChainConfig, params.MainnetGenesisHash, func() uint64 { return tt.head })
+		if err := filter(tt.id); err != tt.err {
+			t.Errorf("test %d: validation error mismatch: have %v, want %v", i, err, tt.err)
+		}
+	}
+}
+
+//测试分叉标识的RLP编码与EIP-2124一致。
+func TestEncoding(t *testing.T) {
+	tests := []struct {
+		id   ID
+		want []byte
+	}{
+		{ID{Hash: checksumToBytes(0), Next: 0}, []byte{0xc6, 0x84, 0x00, 0x00, 0x00, 0x00, 0x80}},
+		{ID{Hash: checksumToBytes(0xdeadbeef), Next: 0xBADDCAFE}, []byte{0xca, 0x84, 0xde, 0xad, 0xbe, 0xef, 0x84, 0xba, 0xdd, 0xca, 0xfe}},
+	}
+	for i, tt := range tests {
+		have, err := rlp.EncodeToBytes(tt.id)
+		if err != nil {
+			t.Errorf("test %d: failed to encode fork ID: %v", i, err)
+			continue
+		}
+		if !bytes.Equal(have, tt.want) {
+			t.Errorf("test %d: RLP mismatch: have %x, want %x", i, have, tt.want)
+		}
+	}
+}
@@ -122,6 +122,22 @@ func WriteFastTrieProgress(db DatabaseWriter, count uint64) {
 	}
 }
 
+//ReadBodyTail检索仍保存主体和收据的最低块号。
+func ReadBodyTail(db DatabaseReader) uint64 {
+	data, _ := db.Get(bodyTailKey)
+	if len(data) == 0 {
+		return 0
+	}
+	return new(big.Int).SetBytes(data).Uint64()
+}
+
+//WriteBodyTail存储仍保存主体和收据的最低块号。
+func WriteBodyTail(db DatabaseWriter, number uint64) {
+	if err := db.Put(bodyTailKey, new(big.Int).SetUint64(number).Bytes()); err != nil {
+		log.Crit("Failed to store body tail", "err", err)
+	}
+}
+
 //readheaderrlp以其原始RLP数据库编码检索块头。
 func ReadHeaderRLP(db DatabaseReader, hash common.Hash, number uint64) rlp.RawValue {
 	data, _ := db.Get(headerKey(number, hash))
@@ -37,6 +37,9 @@ var (
 //FastTrieProgressKey跟踪在快速同步期间导入的Trie条目数。
 	fastTrieProgressKey = []byte("TrieSync")
 
+//bodyTailKey跟踪仍保存主体和收据的最低块号，更早的块已被修剪。
+	bodyTailKey = []byte("BodyTail")
+
 //数据项前缀（使用单字节避免混合数据类型，避免使用“i”，用于索引）。
 headerPrefix       = []byte("h") //headerPrefix+num（uint64 big endian）+hash->header
 headerTDSuffix     = []byte("t") //headerPrefix+num（uint64 big endian）+hash+headerTsuffix->td
@@ -0,0 +1,125 @@
+
+//<developer>
+//    <name>linapex 曹一峰</name>
+//    <email>linapex@163.com</email>
+//    <wx>superexc</wx>
+//    <qqgroup>128148617</qqgroup>
+//    <url>https://jsq.ink</url>
+//    <role>pku engineer</role>
+//    <date>2019-03-16 19:16:35</date>
+//</624450080811782144>
+
+
+package core
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+//txDropHistoryLimit是事务池保留的已删除事务记录的最大数目。
+const txDropHistoryLimit = 4096
+
+//TxDropReason描述事务离开池而未被包括在链中的原因。
+type TxDropReason uint8
+
+const (
+	TxDropUnknown                TxDropReason = iota
+TxDropUnderpriced                         //池已满，被价格更高的交易挤出
+TxDropPriceThreshold                      //低于新设置的最低天然气价格
+TxDropReplaced                            //被同一nonce的另一个交易替换
+TxDropReplacementUnderpriced              //作为替换交易提升时价格上涨不足，同一nonce的交易保留
+TxDropNonceTooLow                         //帐户nonce已超过交易nonce
+TxDropInsufficientFunds                   //余额不足或超出区块燃气限制
+TxDropExpired                             //在队列中停留的时间超过了生命周期
+TxDropAccountLimit                        //超出每个帐户的队列限额
+TxDropPoolLimit                           //超出全局挂起或排队限额
+TxDropDeadline                            //私有交易未在截止区块前被包括
+)
+
+var txDropReasonNames = map[TxDropReason]string{
+	TxDropUnknown:                "unknown",
+	TxDropUnderpriced:            "underpriced",
+	TxDropPriceThreshold:         "below price threshold",
+	TxDropReplaced:               "replaced",
+	TxDropReplacementUnderpriced: "underpriced replacement",
+	TxDropNonceTooLow:            "nonce too low",
+	TxDropInsufficientFunds:      "insufficient funds",
+	TxDropExpired:                "lifetime expired",
+	TxDropAccountLimit:           "account limit exceeded",
+	TxDropPoolLimit:              "pool limit exceeded",
+	TxDropDeadline:               "private deadline passed",
+}
+
+//字符串返回删除原因的可读名称。
+func (r TxDropReason) String() string {
+	if name, ok := txDropReasonNames[r]; ok {
+		return name
+	}
+	return txDropReasonNames[TxDropUnknown]
+}
+
+//TxDropRecord记录了事务何时以及为何从池中删除。
+type TxDropRecord struct {
+	Hash       common.Hash
+	From       common.Address
+	Nonce      uint64
+	Reason     TxDropReason
+ReplacedBy common.Hash //替换事务的哈希（如果有）
+	Time       time.Time
+}
+
+//txDropHistory是最近删除的事务的有界历史记录，按时间顺序
+//收回最旧的条目。
+//
+//注意，txDropHistory不是线程安全的，它由池锁保护。
+type txDropHistory struct {
+limit   int                           //保留的最大记录数
+records map[common.Hash]*TxDropRecord //按哈希索引的最新记录
+order   []*TxDropRecord               //按插入顺序的记录，用于收回
+}
+
+//newTxDropHistory创建一个最多保留limit条记录的删除历史记录。
+func newTxDropHistory(limit int) *txDropHistory {
+	return &txDropHistory{
+		limit:   limit,
+		records: make(map[common.Hash]*TxDropRecord),
+	}
+}
+
+//add插入新的删除记录，必要时收回最旧的记录。
+func (h *txDropHistory) add(record *TxDropRecord) {
+	if h.limit <= 0 {
+		return
+	}
+	h.records[record.Hash] = record
+	h.order = append(h.order, record)
+
+	for len(h.order) > h.limit {
+		old := h.order[0]
+		h.order[0] = nil
+		h.order = h.order[1:]
+
+//只有在事务没有被再次删除时才删除索引
+		if h.records[old.Hash] == old {
+			delete(h.records, old.Hash)
+		}
+	}
+}
+
+//get检索事务的最新删除记录，如果未知，则为nil。
+func (h *txDropHistory) get(hash common.Hash) *TxDropRecord {
+	return h.records[hash]
+}
+
+//list按从旧到新的顺序返回所有保留的删除记录。
+func (h *txDropHistory) list() []*TxDropRecord {
+	records := make([]*TxDropRecord, 0, len(h.records))
+	for _, record := range h.order {
+		if h.records[record.Hash] == record {
+			records = append(records, record)
+		}
+	}
+	return records
+}
@@ -0,0 +1,73 @@
+
+//<developer>
+//    <name>linapex 曹一峰</name>
+//    <email>linapex@163.com</email>
+//    <wx>superexc</wx>
+//    <qqgroup>128148617</qqgroup>
+//    <url>https://jsq.ink</url>
+//    <role>pku engineer</role>
+//    <date>2019-03-16 19:16:35</date>
+//</624450103113682958>
+
+
+package core
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+//senderBucket是单个发送者的令牌桶。
+type senderBucket struct {
+tokens  float64   //当前允许进入池的交易数
+updated time.Time //上次补充令牌的时间
+}
+
+//senderLimiter限制每个发送者的远程交易进入池的速率，防止单个帐户
+//通过不断替换交易造成的抖动挤出其他交易。
+//
+//注意，所有方法都假定池锁被保持！
+type senderLimiter struct {
+	rate    float64
+	burst   float64
+	senders map[common.Address]*senderBucket
+}
+
+//newSenderLimiter创建具有给定速率（每秒交易）和突发的限制器。
+func newSenderLimiter(rate, burst uint64) *senderLimiter {
+	return &senderLimiter{
+		rate:    float64(rate),
+		burst:   float64(burst),
+		senders: make(map[common.Address]*senderBucket),
+	}
+}
+
+//allow报告发送者的下一个交易现在是否可以进入池，如果可以则消耗一个令牌。
+func (l *senderLimiter) allow(addr common.Address, now time.Time) bool {
+	bucket := l.senders[addr]
+	if bucket == nil {
+		bucket = &senderBucket{tokens: l.burst, updated: now}
+		l.senders[addr] = bucket
+	}
+	bucket.tokens += now.Sub(bucket.updated).Seconds() * l.rate
+	if bucket.tokens > l.burst {
+		bucket.tokens = l.burst
+	}
+	bucket.updated = now
+
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens--
+	return true
+}
+
+//prune删除已经补满的桶，它们与新发送者的桶没有区别。
+func (l *senderLimiter) prune(now time.Time) {
+	for addr, bucket := range l.senders {
+		if bucket.tokens+now.Sub(bucket.updated).Seconds()*l.rate >= l.burst {
+			delete(l.senders, addr)
+		}
+	}
+}
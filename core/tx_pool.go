@@ -14,7 +14,6 @@ package core
 
 import (
 	"errors"
-	"fmt"
 	"math"
 	"math/big"
 	"sort"
@@ -40,6 +39,9 @@ var (
 //如果事务包含无效签名，则返回errInvalidSender。
 	ErrInvalidSender = errors.New("invalid sender")
 
+//如果事务已经在池中，则返回ErrAlreadyKnown。
+	ErrAlreadyKnown = errors.New("known transaction")
+
 //如果事务的nonce低于
 //一个存在于本地链中。
 	ErrNonceTooLow = errors.New("nonce too low")
@@ -52,6 +54,9 @@ var (
 //另一个没有要求的价格上涨。
 	ErrReplaceUnderpriced = errors.New("replacement transaction underpriced")
 
+//如果发送者的远程交易进入池的速率超过限制，则返回ErrSenderThrottled。
+	ErrSenderThrottled = errors.New("sender admission rate exceeded")
+
 //如果执行事务的总成本为
 //高于用户帐户的余额。
 	ErrInsufficientFunds = errors.New("insufficient funds for gas * price + value")
@@ -95,6 +100,7 @@ queuedNofundsCounter   = metrics.NewRegisteredCounter("txpool/queued/nofunds", n
 //一般Tx指标
 	invalidTxCounter     = metrics.NewRegisteredCounter("txpool/invalid", nil)
 	underpricedTxCounter = metrics.NewRegisteredCounter("txpool/underpriced", nil)
+	throttledTxCounter   = metrics.NewRegisteredCounter("txpool/throttled", nil)
 )
 
 //txstatus是由池看到的事务的当前状态。
@@ -123,6 +129,7 @@ Locals    []common.Address //默认情况下应视为本地地址的地址
 NoLocals  bool             //是否应禁用本地事务处理
 Journal   string           //在节点重新启动后幸存的本地事务日志
 Rejournal time.Duration    //重新生成本地事务日记帐的时间间隔
+Snapshot  string           //在节点重新启动后保留的远程事务快照（空表示禁用）
 
 PriceLimit uint64 //用于验收的最低天然气价格
 PriceBump  uint64 //替换已存在交易的最低价格波动百分比（nonce）
@@ -133,6 +140,9 @@ AccountQueue uint64 //每个帐户允许的最大不可执行事务槽数
 GlobalQueue  uint64 //所有帐户的最大不可执行事务槽数
 
 Lifetime time.Duration //非可执行事务排队的最长时间
+
+SenderRate  uint64 //每个发送者每秒允许进入池的远程交易的平均数（0表示不限制，默认）
+SenderBurst uint64 //每个发送者一次可以进入池的最大远程交易数
 }
 
 //DefaultTxPoolConfig包含事务的默认配置
@@ -150,6 +160,9 @@ var DefaultTxPoolConfig = TxPoolConfig{
 	GlobalQueue:  1024,
 
 	Lifetime: 3 * time.Hour,
+
+	SenderRate:  0,
+	SenderBurst: 256,
 }
 
 //清理检查提供的用户配置并更改
@@ -188,6 +201,10 @@ func (config *TxPoolConfig) sanitize() TxPoolConfig {
 		log.Warn("Sanitizing invalid txpool lifetime", "provided", conf.Lifetime, "updated", DefaultTxPoolConfig.Lifetime)
 		conf.Lifetime = DefaultTxPoolConfig.Lifetime
 	}
+	if conf.SenderRate > 0 && conf.SenderBurst < 1 {
+		log.Warn("Sanitizing invalid txpool sender burst", "provided", conf.SenderBurst, "updated", DefaultTxPoolConfig.SenderBurst)
+		conf.SenderBurst = DefaultTxPoolConfig.SenderBurst
+	}
 	return conf
 }
 
@@ -214,14 +231,18 @@ currentState  *state.StateDB      //区块链头中的当前状态
 pendingState  *state.ManagedState //挂起状态跟踪虚拟当前
 currentMaxGas uint64              //交易上限的当前天然气限额
 
-locals  *accountSet //要免除逐出规则的本地事务集
-journal *txJournal  //备份到磁盘的本地事务日志
+locals   *accountSet //要免除逐出规则的本地事务集
+journal  *txJournal  //备份到磁盘的本地事务日志
+snapshot *txJournal  //备份到磁盘的远程事务快照
 
 pending map[common.Address]*txList   //所有当前可处理的事务
 queue   map[common.Address]*txList   //排队但不可处理的事务
 beats   map[common.Address]time.Time //每个已知帐户的最后一个心跳
 all     *txLookup                    //允许查找的所有事务
 priced  *txPricedList                //按价格排序的所有交易记录
+drops   *txDropHistory               //最近删除的事务及其原因
+private map[common.Hash]uint64       //不向对等方传播的事务及其截止区块（0表示无）
+senders *senderLimiter               //每个发送者的远程交易准入速率限制（如果启用）
 
 wg sync.WaitGroup //用于关机同步
 
@@ -244,6 +265,8 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 		queue:       make(map[common.Address]*txList),
 		beats:       make(map[common.Address]time.Time),
 		all:         newTxLookup(),
+		drops:       newTxDropHistory(txDropHistoryLimit),
+		private:     make(map[common.Hash]uint64),
 		chainHeadCh: make(chan ChainHeadEvent, chainHeadChanSize),
 		gasPrice:    new(big.Int).SetUint64(config.PriceLimit),
 	}
@@ -253,6 +276,9 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 		pool.locals.add(addr)
 	}
 	pool.priced = newTxPricedList(pool.all)
+	if config.SenderRate > 0 {
+		pool.senders = newSenderLimiter(config.SenderRate, config.SenderBurst)
+	}
 	pool.reset(nil, chain.CurrentBlock().Header())
 
 //如果启用了本地事务和日记，则从磁盘加载
@@ -266,6 +292,14 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 			log.Warn("Failed to rotate transaction journal", "err", err)
 		}
 	}
+//如果启用了远程事务快照，则根据当前状态重新验证并加载
+	if config.Snapshot != "" {
+		pool.snapshot = newTxJournal(config.Snapshot)
+
+		if err := pool.snapshot.load(pool.loadRemotes); err != nil {
+			log.Warn("Failed to load transaction snapshot", "err", err)
+		}
+	}
 //从区块链订阅事件
 	pool.chainHeadSub = pool.chain.SubscribeChainHeadEvent(pool.chainHeadCh)
 
@@ -339,10 +373,14 @@ func (pool *TxPool) loop() {
 //任何年龄足够大的非本地人都应该被除名。
 				if time.Since(pool.beats[addr]) > pool.config.Lifetime {
 					for _, tx := range pool.queue[addr].Flatten() {
+						pool.recordDrop(tx, TxDropExpired, common.Hash{})
 						pool.removeTx(tx.Hash(), true)
 					}
 				}
 			}
+			if pool.senders != nil {
+				pool.senders.prune(time.Now())
+			}
 			pool.mu.Unlock()
 
 //处理本地事务日记帐轮换
@@ -354,6 +392,11 @@ func (pool *TxPool) loop() {
 				}
 				pool.mu.Unlock()
 			}
+			if pool.snapshot != nil {
+				pool.mu.RLock()
+				pool.saveSnapshot()
+				pool.mu.RUnlock()
+			}
 		}
 	}
 }
@@ -371,8 +414,11 @@ func (pool *TxPool) lockedReset(oldHead, newHead *types.Header) {
 //的事务池对于链状态有效。
 func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 //如果要重新定位旧状态，请重新拒绝所有已删除的事务
-	var reinject types.Transactions
-
+	var (
+		reinject types.Transactions
+		included types.Transactions
+deep     bool //跳过了深度重组，不知道新链包含了哪些事务
+	)
 	if oldHead != nil && oldHead.Hash() != newHead.ParentHash {
 //如果REORG太深，请避免这样做（将在快速同步期间发生）
 		oldNum := oldHead.Number.Uint64()
@@ -380,9 +426,10 @@ func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 
 		if depth := uint64(math.Abs(float64(oldNum) - float64(newNum))); depth > 64 {
 			log.Debug("Skipping deep transaction reorg", "depth", depth)
+			deep = true
 		} else {
 //REORG看起来很浅，足以将所有事务拉入内存
-			var discarded, included types.Transactions
+			var discarded types.Transactions
 
 			var (
 				rem = pool.chain.GetBlock(oldHead.Hash(), oldHead.Number.Uint64())
@@ -416,6 +463,11 @@ func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 			}
 			reinject = types.TxDifference(discarded, included)
 		}
+	} else if oldHead != nil && newHead != nil {
+//新头部直接扩展了旧头部，只有它的交易被包括了
+		if block := pool.chain.GetBlock(newHead.Hash(), newHead.Number.Uint64()); block != nil {
+			included = block.Transactions()
+		}
 	}
 //将内部状态初始化为当前头部
 	if newHead == nil {
@@ -433,13 +485,13 @@ newHead = pool.chain.CurrentBlock().Header() //测试过程中的特殊情况
 //插入由于重新排序而丢弃的任何事务
 	log.Debug("Reinjecting stale transactions", "count", len(reinject))
 	senderCacher.recover(pool.signer, reinject)
-	pool.addTxsLocked(reinject, false)
+	pool.addTxsLocked(reinject, false, false)
 
 //验证挂起事务池，这将删除
 //包含在块中的任何交易或
 //已因另一个交易（例如
 //更高的天然气价格）
-	pool.demoteUnexecutables()
+	pool.demoteUnexecutables(included, deep)
 
 //将所有帐户更新为最新的已知挂起的当前帐户
 	for addr, list := range pool.pending {
@@ -449,6 +501,9 @@ txs := list.Flatten() //很重，但会被缓存，矿工无论如何都需要
 //检查队列并尽可能将事务转移到挂起的
 //或者去掉那些已经失效的
 	pool.promoteExecutables(nil)
+
+//删除错过截止区块的私有事务
+	pool.expirePrivate(newHead.Number.Uint64())
 }
 
 //stop终止事务池。
@@ -463,6 +518,11 @@ func (pool *TxPool) Stop() {
 	if pool.journal != nil {
 		pool.journal.close()
 	}
+	if pool.snapshot != nil {
+		pool.mu.RLock()
+		pool.saveSnapshot()
+		pool.mu.RUnlock()
+	}
 	log.Info("Transaction pool stopped")
 }
 
@@ -488,6 +548,7 @@ func (pool *TxPool) SetGasPrice(price *big.Int) {
 
 	pool.gasPrice = price
 	for _, tx := range pool.priced.Cap(price, pool.locals) {
+		pool.recordDrop(tx, TxDropPriceThreshold, common.Hash{})
 		pool.removeTx(tx.Hash(), false)
 	}
 	log.Info("Transaction pool price threshold updated", "price", price)
@@ -579,6 +640,37 @@ func (pool *TxPool) local() map[common.Address]types.Transactions {
 	return txs
 }
 
+//remote检索池中当前的所有远程事务，并按来源分组
+//帐户和按nonce排序。私有事务永远不会被包括在内。
+func (pool *TxPool) remote() map[common.Address]types.Transactions {
+	txs := make(map[common.Address]types.Transactions)
+	for _, lists := range []map[common.Address]*txList{pool.pending, pool.queue} {
+		for addr, list := range lists {
+			if pool.locals.contains(addr) {
+				continue
+			}
+			for _, tx := range list.Flatten() {
+				if _, ok := pool.private[tx.Hash()]; !ok {
+					txs[addr] = append(txs[addr], tx)
+				}
+			}
+		}
+	}
+	return txs
+}
+
+//saveSnapshot将所有远程事务写入磁盘快照。与本地日记不同，快照
+//不保持打开以进行追加。
+//
+//注意，此方法假定池锁被保持！
+func (pool *TxPool) saveSnapshot() {
+	if err := pool.snapshot.rotate(pool.remote()); err != nil {
+		log.Warn("Failed to write transaction snapshot", "err", err)
+		return
+	}
+	pool.snapshot.close()
+}
+
 //validatetx根据共识检查交易是否有效
 //规则并遵守本地节点的一些启发式限制（价格和大小）。
 func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
@@ -637,7 +729,7 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 	hash := tx.Hash()
 	if pool.all.Get(hash) != nil {
 		log.Trace("Discarding already known transaction", "hash", hash)
-		return false, fmt.Errorf("known transaction: %x", hash)
+		return false, ErrAlreadyKnown
 	}
 //如果事务未能通过基本验证，则放弃它
 	if err := pool.validateTx(tx, local); err != nil {
@@ -658,6 +750,7 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 		for _, tx := range drop {
 			log.Trace("Discarding freshly underpriced transaction", "hash", tx.Hash(), "price", tx.GasPrice())
 			underpricedTxCounter.Inc(1)
+			pool.recordDrop(tx, TxDropUnderpriced, common.Hash{})
 			pool.removeTx(tx.Hash(), false)
 		}
 	}
@@ -675,6 +768,7 @@ from, _ := types.Sender(pool.signer, tx) //已验证
 			pool.all.Remove(old.Hash())
 			pool.priced.Removed()
 			pendingReplaceCounter.Inc(1)
+			pool.recordDrop(old, TxDropReplaced, hash)
 		}
 		pool.all.Add(tx)
 		pool.priced.Put(tx)
@@ -725,6 +819,7 @@ from, _ := types.Sender(pool.signer, tx) //已验证
 		pool.all.Remove(old.Hash())
 		pool.priced.Removed()
 		queuedReplaceCounter.Inc(1)
+		pool.recordDrop(old, TxDropReplaced, hash)
 	}
 	if pool.all.Get(hash) == nil {
 		pool.all.Add(tx)
@@ -740,6 +835,10 @@ func (pool *TxPool) journalTx(from common.Address, tx *types.Transaction) {
 	if pool.journal == nil || !pool.locals.contains(from) {
 		return
 	}
+//私有事务不会被记录，以免重新启动后作为普通本地事务广播
+	if _, ok := pool.private[tx.Hash()]; ok {
+		return
+	}
 	if err := pool.journal.insert(tx); err != nil {
 		log.Warn("Failed to journal local transaction", "err", err)
 	}
@@ -763,6 +862,7 @@ func (pool *TxPool) promoteTx(addr common.Address, hash common.Hash, tx *types.T
 		pool.priced.Removed()
 
 		pendingDiscardCounter.Inc(1)
+		pool.recordDrop(tx, TxDropReplacementUnderpriced, common.Hash{})
 		return false
 	}
 //否则放弃任何以前的交易并标记此
@@ -771,6 +871,7 @@ func (pool *TxPool) promoteTx(addr common.Address, hash common.Hash, tx *types.T
 		pool.priced.Removed()
 
 		pendingReplaceCounter.Inc(1)
+		pool.recordDrop(old, TxDropReplaced, hash)
 	}
 //故障保护以绕过直接挂起的插入（测试）
 	if pool.all.Get(hash) == nil {
@@ -788,35 +889,102 @@ func (pool *TxPool) promoteTx(addr common.Address, hash common.Hash, tx *types.T
 //同时将发送方作为本地发送方，确保它绕过本地发送方
 //定价限制。
 func (pool *TxPool) AddLocal(tx *types.Transaction) error {
-	return pool.addTx(tx, !pool.config.NoLocals)
+	return pool.addTx(tx, !pool.config.NoLocals, false)
+}
+
+//AddPrivate将单个事务作为本地事务排入池中，但它永远不会被传播到
+//对等方，只能包含在本地挖掘的块中。如果deadline不为零，事务在
+//该区块号之后仍未被包括时将被删除。
+func (pool *TxPool) AddPrivate(tx *types.Transaction, deadline uint64) error {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+//在插入之前标记事务，以便任何事件和日志都将其视为私有
+	hash := tx.Hash()
+	prev, known := pool.private[hash]
+	pool.private[hash] = deadline
+
+	replace, err := pool.add(tx, !pool.config.NoLocals)
+	if err != nil {
+		if known {
+			pool.private[hash] = prev
+		} else {
+			delete(pool.private, hash)
+		}
+		return err
+	}
+	if !replace {
+from, _ := types.Sender(pool.signer, tx) //已验证
+		pool.promoteExecutables([]common.Address{from})
+	}
+	return nil
+}
+
+//IsPrivate报告事务是否通过AddPrivate提交，因此不得传播给对等方。
+func (pool *TxPool) IsPrivate(hash common.Hash) bool {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	_, ok := pool.private[hash]
+	return ok
+}
+
+//expirePrivate删除在给定区块号之前未被包括的私有事务，并忘记
+//已离开池的私有事务。
+//
+//注意，此方法假定池锁被保持！
+func (pool *TxPool) expirePrivate(number uint64) {
+	for hash, deadline := range pool.private {
+		tx := pool.all.Get(hash)
+		if tx == nil {
+			delete(pool.private, hash)
+			continue
+		}
+		if deadline != 0 && number >= deadline {
+			log.Trace("Removed expired private transaction", "hash", hash, "deadline", deadline)
+			pool.recordDrop(tx, TxDropDeadline, common.Hash{})
+			pool.removeTx(hash, true)
+			delete(pool.private, hash)
+		}
+	}
 }
 
 //如果单个事务有效，则addremote将其排入池中。如果
 //发送方不属于本地跟踪的发送方，完全定价约束将
 //申请。
 func (pool *TxPool) AddRemote(tx *types.Transaction) error {
-	return pool.addTx(tx, false)
+	return pool.addTx(tx, false, true)
 }
 
 //addlocals将一批事务排队放入池中，如果它们有效，
 //同时将发送者标记为本地发送者，确保他们四处走动
 //本地定价限制。
 func (pool *TxPool) AddLocals(txs []*types.Transaction) []error {
-	return pool.addTxs(txs, !pool.config.NoLocals)
+	return pool.addTxs(txs, !pool.config.NoLocals, false)
 }
 
 //如果一批事务有效，addremotes会将其排队放入池中。
 //如果发送方不在本地跟踪的发送方中，则完全定价约束
 //将适用。
 func (pool *TxPool) AddRemotes(txs []*types.Transaction) []error {
-	return pool.addTxs(txs, false)
+	return pool.addTxs(txs, false, true)
+}
+
+//loadRemotes将从快照恢复的远程交易排入池中。它们在关闭前已经被接受过，
+//因此不受发送者准入限制。
+func (pool *TxPool) loadRemotes(txs []*types.Transaction) []error {
+	return pool.addTxs(txs, false, false)
 }
 
-//addtx将单个事务排队放入池中（如果该事务有效）。
-func (pool *TxPool) addTx(tx *types.Transaction, local bool) error {
+//addtx将单个事务排队放入池中（如果该事务有效）。如果设置了throttle，
+//远程事务受发送者准入限制。
+func (pool *TxPool) addTx(tx *types.Transaction, local bool, throttle bool) error {
 	pool.mu.Lock()
 	defer pool.mu.Unlock()
 
+	if throttle && !local && pool.throttled(tx) {
+		return ErrSenderThrottled
+	}
 //尝试插入事务并更新任何状态
 	replace, err := pool.add(tx, local)
 	if err != nil {
@@ -831,21 +999,25 @@ from, _ := types.Sender(pool.signer, tx) //已验证
 }
 
 //如果一批事务有效，addtx将尝试对其进行排队。
-func (pool *TxPool) addTxs(txs []*types.Transaction, local bool) []error {
+func (pool *TxPool) addTxs(txs []*types.Transaction, local bool, throttle bool) []error {
 	pool.mu.Lock()
 	defer pool.mu.Unlock()
 
-	return pool.addTxsLocked(txs, local)
+	return pool.addTxsLocked(txs, local, throttle)
 }
 
 //addtxtslocked尝试对一批事务进行排队，如果它们有效，
 //同时假定事务池锁已被持有。
-func (pool *TxPool) addTxsLocked(txs []*types.Transaction, local bool) []error {
+func (pool *TxPool) addTxsLocked(txs []*types.Transaction, local bool, throttle bool) []error {
 //添加交易批次，跟踪接受的交易
 	dirty := make(map[common.Address]struct{})
 	errs := make([]error, len(txs))
 
 	for i, tx := range txs {
+		if throttle && !local && pool.throttled(tx) {
+			errs[i] = ErrSenderThrottled
+			continue
+		}
 		var replace bool
 		if replace, errs[i] = pool.add(tx, local); errs[i] == nil && !replace {
 from, _ := types.Sender(pool.signer, tx) //已验证
@@ -863,6 +1035,24 @@ from, _ := types.Sender(pool.signer, tx) //已验证
 	return errs
 }
 
+//throttled报告远程事务是否因其发送者的事务进入池过快而应被丢弃，以免它
+//挤出其他事务。已知事务和无法恢复发送者的事务不消耗令牌，它们由add照常处理。
+func (pool *TxPool) throttled(tx *types.Transaction) bool {
+	if pool.senders == nil || pool.all.Get(tx.Hash()) != nil {
+		return false
+	}
+	from, err := types.Sender(pool.signer, tx)
+	if err != nil || pool.locals.contains(from) {
+		return false
+	}
+	if pool.senders.allow(from, time.Now()) {
+		return false
+	}
+	log.Trace("Discarding throttled transaction", "hash", tx.Hash(), "from", from)
+	throttledTxCounter.Inc(1)
+	return true
+}
+
 //status返回一批事务的状态（未知/挂起/排队）
 //通过散列标识。
 func (pool *TxPool) Status(hashes []common.Hash) []TxStatus {
@@ -889,6 +1079,41 @@ func (pool *TxPool) Get(hash common.Hash) *types.Transaction {
 	return pool.all.Get(hash)
 }
 
+//Dropped返回事务最近一次从池中删除的记录，如果事务
+//仍在池中或者不在删除历史记录中，则返回nil。
+func (pool *TxPool) Dropped(hash common.Hash) *TxDropRecord {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	if pool.all.Get(hash) != nil {
+		return nil
+	}
+	return pool.drops.get(hash)
+}
+
+//DropHistory按从旧到新的顺序返回所有保留的删除记录。
+func (pool *TxPool) DropHistory() []*TxDropRecord {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	return pool.drops.list()
+}
+
+//recordDrop将事务的删除原因添加到删除历史记录中。
+//
+//注意，此方法假定池锁被保持！
+func (pool *TxPool) recordDrop(tx *types.Transaction, reason TxDropReason, replacement common.Hash) {
+from, _ := types.Sender(pool.signer, tx) //已验证
+	pool.drops.add(&TxDropRecord{
+		Hash:       tx.Hash(),
+		From:       from,
+		Nonce:      tx.Nonce(),
+		Reason:     reason,
+		ReplacedBy: replacement,
+		Time:       time.Now(),
+	})
+}
+
 //removetx从队列中删除单个事务，移动所有后续事务
 //事务返回到未来队列。
 func (pool *TxPool) removeTx(hash common.Hash, outofbound bool) {
@@ -958,6 +1183,7 @@ continue //以防有人用不存在的帐户打电话
 			log.Trace("Removed old queued transaction", "hash", hash)
 			pool.all.Remove(hash)
 			pool.priced.Removed()
+			pool.recordDrop(tx, TxDropNonceTooLow, common.Hash{})
 		}
 //放弃所有成本过高的交易（低余额或无天然气）
 		drops, _ := list.Filter(pool.currentState.GetBalance(addr), pool.currentMaxGas)
@@ -966,6 +1192,7 @@ continue //以防有人用不存在的帐户打电话
 			log.Trace("Removed unpayable queued transaction", "hash", hash)
 			pool.all.Remove(hash)
 			pool.priced.Removed()
+			pool.recordDrop(tx, TxDropInsufficientFunds, common.Hash{})
 			queuedNofundsCounter.Inc(1)
 		}
 //收集所有可执行事务并升级它们
@@ -983,6 +1210,7 @@ continue //以防有人用不存在的帐户打电话
 				pool.all.Remove(hash)
 				pool.priced.Removed()
 				queuedRateLimitCounter.Inc(1)
+				pool.recordDrop(tx, TxDropAccountLimit, common.Hash{})
 				log.Trace("Removed cap-exceeding queued transaction", "hash", hash)
 			}
 		}
@@ -1031,6 +1259,7 @@ continue //以防有人用不存在的帐户打电话
 							hash := tx.Hash()
 							pool.all.Remove(hash)
 							pool.priced.Removed()
+							pool.recordDrop(tx, TxDropPoolLimit, common.Hash{})
 
 //将当前帐户更新为删除的交易记录
 							if nonce := tx.Nonce(); pool.pendingState.GetNonce(offenders[i]) > nonce {
@@ -1053,6 +1282,7 @@ continue //以防有人用不存在的帐户打电话
 						hash := tx.Hash()
 						pool.all.Remove(hash)
 						pool.priced.Removed()
+						pool.recordDrop(tx, TxDropPoolLimit, common.Hash{})
 
 //将当前帐户更新为删除的交易记录
 						if nonce := tx.Nonce(); pool.pendingState.GetNonce(addr) > nonce {
@@ -1091,6 +1321,7 @@ if !pool.locals.contains(addr) { //不要删除本地变量
 //如果小于溢出，则删除所有事务
 			if size := uint64(list.Len()); size <= drop {
 				for _, tx := range list.Flatten() {
+					pool.recordDrop(tx, TxDropPoolLimit, common.Hash{})
 					pool.removeTx(tx.Hash(), true)
 				}
 				drop -= size
@@ -1100,6 +1331,7 @@ if !pool.locals.contains(addr) { //不要删除本地变量
 //否则只删除最后几个事务
 			txs := list.Flatten()
 			for i := len(txs) - 1; i >= 0 && drop > 0; i-- {
+				pool.recordDrop(txs[i], TxDropPoolLimit, common.Hash{})
 				pool.removeTx(txs[i].Hash(), true)
 				drop--
 				queuedRateLimitCounter.Inc(1)
@@ -1111,7 +1343,14 @@ if !pool.locals.contains(addr) { //不要删除本地变量
 //DemoteNextExecutables从池中删除无效和已处理的事务
 //可执行/挂起队列以及任何无法执行的后续事务
 //将移回将来的队列。
-func (pool *TxPool) demoteUnexecutables() {
+//
+//included是新链头包含的事务，它们不被记录为删除。deep表示跳过了深度重组，
+//此时nonce过低的事务很可能已被包括，同样不记录为删除。
+func (pool *TxPool) demoteUnexecutables(included types.Transactions, deep bool) {
+	mined := make(map[common.Hash]struct{}, len(included))
+	for _, tx := range included {
+		mined[tx.Hash()] = struct{}{}
+	}
 //迭代所有帐户并降级任何不可执行的事务
 	for addr, list := range pool.pending {
 		nonce := pool.currentState.GetNonce(addr)
@@ -1122,6 +1361,9 @@ func (pool *TxPool) demoteUnexecutables() {
 			log.Trace("Removed old pending transaction", "hash", hash)
 			pool.all.Remove(hash)
 			pool.priced.Removed()
+			if _, ok := mined[hash]; !ok && !deep {
+				pool.recordDrop(tx, TxDropNonceTooLow, common.Hash{})
+			}
 		}
 //删除所有成本过高的事务（余额不足或没有汽油），并将任何无效的事务排队等待稍后处理。
 		drops, invalids := list.Filter(pool.currentState.GetBalance(addr), pool.currentMaxGas)
@@ -1130,6 +1372,7 @@ func (pool *TxPool) demoteUnexecutables() {
 			log.Trace("Removed unpayable pending transaction", "hash", hash)
 			pool.all.Remove(hash)
 			pool.priced.Removed()
+			pool.recordDrop(tx, TxDropInsufficientFunds, common.Hash{})
 			pendingNofundsCounter.Inc(1)
 		}
 		for _, tx := range invalids {
@@ -949,6 +949,62 @@ func TestTransactionPendingLimiting(t *testing.T) {
 	}
 }
 
+//测试单个发送者的远程交易在超过准入突发后被限制，而本地交易、快照恢复的
+//交易和其他发送者的交易不受影响，并且令牌随时间补充。
+func TestTransactionSenderAdmissionLimit(t *testing.T) {
+	t.Parallel()
+
+//创建限制较小的池和两个有资金的帐户
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(ethdb.NewMemDatabase()))
+	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
+
+	config := testTxPoolConfig
+	config.SenderRate = 1
+	config.SenderBurst = 4
+
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	key, _ := crypto.GenerateKey()
+	other, _ := crypto.GenerateKey()
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000))
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(other.PublicKey), big.NewInt(1000000))
+
+//突发以内的远程交易被接受，之后的被限制
+	for i := uint64(0); i < config.SenderBurst; i++ {
+		if err := pool.AddRemote(transaction(i, 100000, key)); err != nil {
+			t.Fatalf("tx %d: failed to add transaction: %v", i, err)
+		}
+	}
+	if err := pool.AddRemote(transaction(config.SenderBurst, 100000, key)); err != ErrSenderThrottled {
+		t.Fatalf("throttled tx error mismatch: have %v, want %v", err, ErrSenderThrottled)
+	}
+//其他发送者、快照恢复的交易和本地交易不受限制
+	if err := pool.AddRemote(transaction(0, 100000, other)); err != nil {
+		t.Fatalf("failed to add other sender's transaction: %v", err)
+	}
+	if errs := pool.loadRemotes([]*types.Transaction{transaction(config.SenderBurst, 100000, key)}); errs[0] != nil {
+		t.Fatalf("failed to load snapshot transaction: %v", errs[0])
+	}
+	if err := pool.AddLocal(transaction(config.SenderBurst+1, 100000, key)); err != nil {
+		t.Fatalf("failed to add local transaction: %v", err)
+	}
+//令牌补充后，远程交易再次被接受
+	pool.mu.Lock()
+	pool.senders.senders[crypto.PubkeyToAddress(key.PublicKey)].updated = time.Now().Add(-2 * time.Second)
+	pool.mu.Unlock()
+
+	if err := pool.AddRemote(transaction(config.SenderBurst+2, 100000, key)); err != nil {
+		t.Fatalf("failed to add transaction after refill: %v", err)
+	}
+	if pending, _ := pool.Stats(); pending != int(config.SenderBurst)+4 {
+		t.Fatalf("pending transactions mismatched: have %d, want %d", pending, config.SenderBurst+4)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
 //测试是否以同样的方式强制执行事务限制
 //事务将逐个添加或分批添加。
 func TestTransactionQueueLimitingEquivalency(t *testing.T)   { testTransactionLimitingEquivalency(t, 1) }
@@ -1732,6 +1788,249 @@ txs = append(txs, pricedTransaction(2, 100000, big.NewInt(1), keys[2])) //仅排
 	}
 }
 
+//测试从池中删除的事务记录了正确的删除原因。
+func TestTransactionDropReasons(t *testing.T) {
+	t.Parallel()
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(ethdb.NewMemDatabase()))
+	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
+
+	pool := NewTxPool(testTxPoolConfig, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	key, _ := crypto.GenerateKey()
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000))
+
+//替换挂起的事务，然后提高价格阈值以删除排队的事务
+	original := pricedTransaction(0, 100000, big.NewInt(1), key)
+	replacement := pricedTransaction(0, 100000, big.NewInt(2), key)
+	future := pricedTransaction(2, 100000, big.NewInt(1), key)
+
+	if err := pool.AddRemote(original); err != nil {
+		t.Fatalf("failed to add original transaction: %v", err)
+	}
+	if err := pool.AddRemote(future); err != nil {
+		t.Fatalf("failed to add future transaction: %v", err)
+	}
+	if err := pool.AddRemote(replacement); err != nil {
+		t.Fatalf("failed to add replacement transaction: %v", err)
+	}
+	pool.SetGasPrice(big.NewInt(2))
+
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+	record := pool.Dropped(original.Hash())
+	if record == nil {
+		t.Fatalf("replaced transaction not recorded")
+	}
+	if record.Reason != TxDropReplaced || record.ReplacedBy != replacement.Hash() {
+		t.Errorf("replaced transaction: have %v by %x, want %v by %x", record.Reason, record.ReplacedBy, TxDropReplaced, replacement.Hash())
+	}
+	if record = pool.Dropped(future.Hash()); record == nil || record.Reason != TxDropPriceThreshold {
+		t.Errorf("repriced transaction: have %v, want %v", record, TxDropPriceThreshold)
+	}
+	if record = pool.Dropped(replacement.Hash()); record != nil {
+		t.Errorf("pooled transaction reported as dropped: %v", record.Reason)
+	}
+	if history := pool.DropHistory(); len(history) != 2 {
+		t.Errorf("drop history size mismatch: have %d, want %d", len(history), 2)
+	}
+}
+
+//测试提升时价格上涨不足的替换事务被记录为替换价格过低，而不是被替换。
+func TestTransactionDropReplacementUnderpriced(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	account, _ := deriveSender(transaction(0, 0, key))
+	pool.currentState.AddBalance(account, big.NewInt(1000000))
+
+	pending := pricedTransaction(0, 100000, big.NewInt(2), key)
+	queued := pricedTransaction(0, 100000, big.NewInt(1), key)
+
+	pool.promoteTx(account, pending.Hash(), pending)
+	pool.all.Add(queued)
+	pool.priced.Put(queued)
+	if pool.promoteTx(account, queued.Hash(), queued) {
+		t.Fatalf("underpriced replacement promoted")
+	}
+	record := pool.Dropped(queued.Hash())
+	if record == nil || record.Reason != TxDropReplacementUnderpriced {
+		t.Fatalf("drop reason mismatch: have %v, want %v", record, TxDropReplacementUnderpriced)
+	}
+	if record.ReplacedBy != (common.Hash{}) {
+		t.Errorf("rejected replacement recorded as replaced by %x", record.ReplacedBy)
+	}
+	if record := pool.Dropped(pending.Hash()); record != nil {
+		t.Errorf("kept transaction reported as dropped: %v", record.Reason)
+	}
+}
+
+//测试跳过深度重组后，nonce已被超过的挂起事务不被记录为nonce过低，
+//因为它们很可能已被新链包括。
+func TestTransactionDropDeepReorg(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	account, _ := deriveSender(transaction(0, 0, key))
+	pool.currentState.AddBalance(account, big.NewInt(1000000))
+
+	tx := transaction(0, 100000, key)
+	if err := pool.AddRemote(tx); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	pool.currentState.SetNonce(account, 1)
+	pool.lockedReset(&types.Header{Number: big.NewInt(0)}, &types.Header{Number: big.NewInt(100), GasLimit: 1000000})
+
+	if pending, _ := pool.Stats(); pending != 0 {
+		t.Fatalf("pending transactions mismatched: have %d, want %d", pending, 0)
+	}
+	if record := pool.Dropped(tx.Hash()); record != nil {
+		t.Errorf("included transaction reported as dropped: %v", record.Reason)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
+//测试远程事务在池重新启动后从快照中恢复，而本地和私有事务
+//不会被写入快照。
+func TestTransactionSnapshotting(t *testing.T) {
+	t.Parallel()
+
+	file, err := ioutil.TempFile("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary snapshot: %v", err)
+	}
+	snapshot := file.Name()
+	defer os.Remove(snapshot)
+
+	file.Close()
+	os.Remove(snapshot)
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(ethdb.NewMemDatabase()))
+	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
+
+	config := testTxPoolConfig
+	config.Snapshot = snapshot
+
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+
+	local, _ := crypto.GenerateKey()
+	remote, _ := crypto.GenerateKey()
+	private, _ := crypto.GenerateKey()
+
+	for _, key := range []*ecdsa.PrivateKey{local, remote, private} {
+		pool.currentState.AddBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000000))
+	}
+	if err := pool.AddLocal(pricedTransaction(0, 100000, big.NewInt(1), local)); err != nil {
+		t.Fatalf("failed to add local transaction: %v", err)
+	}
+	if err := pool.AddRemote(pricedTransaction(0, 100000, big.NewInt(1), remote)); err != nil {
+		t.Fatalf("failed to add remote transaction: %v", err)
+	}
+	if err := pool.AddRemote(pricedTransaction(2, 100000, big.NewInt(1), remote)); err != nil {
+		t.Fatalf("failed to add queued remote transaction: %v", err)
+	}
+	if err := pool.AddPrivate(pricedTransaction(0, 100000, big.NewInt(1), private), 0); err != nil {
+		t.Fatalf("failed to add private transaction: %v", err)
+	}
+	pending, queued := pool.Stats()
+	if pending != 3 || queued != 1 {
+		t.Fatalf("pool content mismatch: have %d/%d, want %d/%d", pending, queued, 3, 1)
+	}
+	pool.Stop()
+
+//重新启动池，只有远程事务应该被恢复
+	pool = NewTxPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	pending, queued = pool.Stats()
+	if pending != 1 {
+		t.Fatalf("pending transactions mismatched: have %d, want %d", pending, 1)
+	}
+	if queued != 1 {
+		t.Fatalf("queued transactions mismatched: have %d, want %d", queued, 1)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
+//测试私有事务被标记为不可传播，并在截止区块之后被删除。
+func TestTransactionPrivateDeadline(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000))
+
+	private := transaction(0, 100000, key)
+	if err := pool.AddPrivate(private, 2); err != nil {
+		t.Fatalf("failed to add private transaction: %v", err)
+	}
+	if !pool.IsPrivate(private.Hash()) {
+		t.Fatalf("private transaction not marked private")
+	}
+//重复提交不能清除私有标记
+	if err := pool.AddPrivate(private, 2); err == nil {
+		t.Fatalf("duplicate private transaction accepted")
+	}
+	if !pool.IsPrivate(private.Hash()) {
+		t.Fatalf("duplicate submission cleared private mark")
+	}
+//截止区块之前事务保持挂起
+	pool.lockedReset(nil, &types.Header{Number: big.NewInt(1), GasLimit: 1000000})
+	if pending, _ := pool.Stats(); pending != 1 {
+		t.Fatalf("pending transactions mismatched: have %d, want %d", pending, 1)
+	}
+	pool.lockedReset(nil, &types.Header{Number: big.NewInt(2), GasLimit: 1000000})
+	if pending, _ := pool.Stats(); pending != 0 {
+		t.Fatalf("pending transactions mismatched: have %d, want %d", pending, 0)
+	}
+	if pool.IsPrivate(private.Hash()) {
+		t.Errorf("expired private transaction still tracked")
+	}
+	if record := pool.Dropped(private.Hash()); record == nil || record.Reason != TxDropDeadline {
+		t.Errorf("drop reason mismatch: have %v, want %v", record, TxDropDeadline)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
+//测试删除历史记录是有界的，并且首先收回最旧的记录。
+func TestTransactionDropHistoryLimit(t *testing.T) {
+	history := newTxDropHistory(2)
+	for i := byte(1); i <= 3; i++ {
+		history.add(&TxDropRecord{Hash: common.Hash{i}, Nonce: uint64(i)})
+	}
+	if record := history.get(common.Hash{1}); record != nil {
+		t.Errorf("oldest record not evicted")
+	}
+	for i := byte(2); i <= 3; i++ {
+		if record := history.get(common.Hash{i}); record == nil || record.Nonce != uint64(i) {
+			t.Errorf("record %d: have %v, want nonce %d", i, record, i)
+		}
+	}
+//再次删除同一事务时，旧的记录不能收回新的记录
+	history.add(&TxDropRecord{Hash: common.Hash{2}, Nonce: 4})
+	history.add(&TxDropRecord{Hash: common.Hash{5}, Nonce: 5})
+
+	if record := history.get(common.Hash{2}); record == nil || record.Nonce != 4 {
+		t.Errorf("re-dropped record lost: %v", record)
+	}
+	if records := history.list(); len(records) != 2 {
+		t.Errorf("history size mismatch: have %d, want %d", len(records), 2)
+	}
+}
+
 //基准测试验证挂起队列的内容的速度
 //事务池。
 func BenchmarkPendingDemotion100(b *testing.B)   { benchmarkPendingDemotion(b, 100) }
@@ -1753,7 +2052,7 @@ func benchmarkPendingDemotion(b *testing.B, size int) {
 //基准池验证速度
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		pool.demoteUnexecutables()
+		pool.demoteUnexecutables(nil, false)
 	}
 }
 
@@ -18,6 +18,7 @@ import (
 	"io"
 	"math/big"
 	"sync/atomic"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
@@ -33,6 +34,7 @@ var (
 
 type Transaction struct {
 	data txdata
+time time.Time //本地首次看到交易的时间
 //高速缓存
 	hash atomic.Value
 	size atomic.Value
@@ -97,7 +99,7 @@ func newTransaction(nonce uint64, to *common.Address, amount *big.Int, gasLimit
 		d.Price.Set(gasPrice)
 	}
 
-	return &Transaction{data: d}
+	return &Transaction{data: d, time: time.Now()}
 }
 
 //chainID返回为此事务签名的链ID（如果有）
@@ -130,6 +132,7 @@ func (tx *Transaction) DecodeRLP(s *rlp.Stream) error {
 	err := s.Decode(&tx.data)
 	if err == nil {
 		tx.size.Store(common.StorageSize(rlp.ListSize(size)))
+		tx.time = time.Now()
 	}
 
 	return err
@@ -164,7 +167,7 @@ func (tx *Transaction) UnmarshalJSON(input []byte) error {
 		}
 	}
 
-	*tx = Transaction{data: dec}
+	*tx = Transaction{data: dec, time: time.Now()}
 	return nil
 }
 
@@ -175,6 +178,13 @@ func (tx *Transaction) Value() *big.Int    { return new(big.Int).Set(tx.data.Amo
 func (tx *Transaction) Nonce() uint64      { return tx.data.AccountNonce }
 func (tx *Transaction) CheckNonce() bool   { return true }
 
+//FirstSeen返回本地创建或解码事务的时间。它不是共识字段，
+//不会被编码。
+func (tx *Transaction) FirstSeen() time.Time { return tx.time }
+
+//SetFirstSeen覆盖首次看到事务的时间。必须在事务被共享之前调用。
+func (tx *Transaction) SetFirstSeen(t time.Time) { tx.time = t }
+
 //返回事务的收件人地址。
 //如果交易是合同创建，则返回零。
 func (tx *Transaction) To() *common.Address {
@@ -236,7 +246,7 @@ func (tx *Transaction) WithSignature(signer Signer, sig []byte) (*Transaction, e
 	if err != nil {
 		return nil, err
 	}
-	cpy := &Transaction{data: tx.data}
+	cpy := &Transaction{data: tx.data, time: tx.time}
 	cpy.data.R, cpy.data.S, cpy.data.V = r, s, v
 	return cpy, nil
 }
@@ -15,7 +15,9 @@ package vm
 import (
 	"crypto/sha256"
 	"errors"
+	"fmt"
 	"math/big"
+	"sync"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/math"
@@ -55,6 +57,72 @@ var PrecompiledContractsByzantium = map[common.Address]PrecompiledContract{
 	common.BytesToAddress([]byte{8}): &bn256Pairing{},
 }
 
+var (
+customPrecompilesLock sync.RWMutex                          //保护自定义预编译注册表
+customPrecompiles     = make(map[string]PrecompiledContract) //按名称注册的自定义预编译实现
+)
+
+//registerprecompile以给定名称注册自定义预编译合同实现。链
+//配置可以通过params.ChainConfig.Precompiles中的名称在某个地址和块激活它。
+//注册应在创建任何区块链之前完成，通常在嵌入程序的init函数中。
+func RegisterPrecompile(name string, p PrecompiledContract) error {
+	if name == "" {
+		return errors.New("empty precompile name")
+	}
+	if p == nil {
+		return fmt.Errorf("nil implementation for precompile %q", name)
+	}
+	customPrecompilesLock.Lock()
+	defer customPrecompilesLock.Unlock()
+
+	if _, ok := customPrecompiles[name]; ok {
+		return fmt.Errorf("precompile %q already registered", name)
+	}
+	customPrecompiles[name] = p
+	return nil
+}
+
+//lookupprecompile按名称检索已注册的自定义预编译合同。
+func lookupPrecompile(name string) PrecompiledContract {
+	customPrecompilesLock.RLock()
+	defer customPrecompilesLock.RUnlock()
+
+	return customPrecompiles[name]
+}
+
+//validateprecompiles检查链配置中激活的所有自定义预编译合同
+//是否都已注册，并且没有覆盖任何内置预编译合同。
+func ValidatePrecompiles(config *params.ChainConfig) error {
+	for addr, p := range config.Precompiles {
+		if p == nil {
+			return fmt.Errorf("precompile %x: missing configuration", addr)
+		}
+		if _, ok := PrecompiledContractsByzantium[addr]; ok {
+			return fmt.Errorf("precompile %x: address reserved for built-in precompile", addr)
+		}
+		if lookupPrecompile(p.Name) == nil {
+			return fmt.Errorf("precompile %x: implementation %q not registered", addr, p.Name)
+		}
+	}
+	return nil
+}
+
+//activeprecompile返回给定链配置和块号处于活动状态的预编译合同
+//（内置或自定义）。
+func activePrecompile(config *params.ChainConfig, number *big.Int, addr common.Address) PrecompiledContract {
+	precompiles := PrecompiledContractsHomestead
+	if config.IsByzantium(number) {
+		precompiles = PrecompiledContractsByzantium
+	}
+	if p := precompiles[addr]; p != nil {
+		return p
+	}
+	if name, ok := config.CustomPrecompile(addr, number); ok {
+		return lookupPrecompile(name)
+	}
+	return nil
+}
+
 //runPrecompiledContract运行并评估预编译合同的输出。
 func RunPrecompiledContract(p PrecompiledContract, input []byte, contract *Contract) (ret []byte, err error) {
 	gas := p.RequiredGas(input)
@@ -18,6 +18,7 @@ import (
 	"testing"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
 )
 
 //预编译测试定义预编译合同测试的输入/输出对。
@@ -478,3 +479,52 @@ func BenchmarkPrecompiledBn256Pairing(bench *testing.B) {
 	}
 }
 
+
+//testPrecompile是一个返回输入并收取固定气体的自定义预编译合同。
+type testPrecompile struct{}
+
+func (c *testPrecompile) RequiredGas(input []byte) uint64  { return 100 }
+func (c *testPrecompile) Run(input []byte) ([]byte, error) { return input, nil }
+
+func TestCustomPrecompiles(t *testing.T) {
+	if err := RegisterPrecompile("test-echo", &testPrecompile{}); err != nil {
+		t.Fatalf("failed to register precompile: %v", err)
+	}
+	if err := RegisterPrecompile("test-echo", &testPrecompile{}); err == nil {
+		t.Fatalf("duplicate registration succeeded")
+	}
+	addr := common.HexToAddress("0x0100")
+	config := &params.ChainConfig{
+		ByzantiumBlock: big.NewInt(0),
+		Precompiles: map[common.Address]*params.PrecompileConfig{
+			addr: {Name: "test-echo", Block: big.NewInt(5)},
+		},
+	}
+	if err := ValidatePrecompiles(config); err != nil {
+		t.Fatalf("valid config rejected: %v", err)
+	}
+//在激活块之前不应处于活动状态，之后应处于活动状态
+	if p := activePrecompile(config, big.NewInt(4), addr); p != nil {
+		t.Errorf("precompile active before activation block")
+	}
+	if p := activePrecompile(config, big.NewInt(5), addr); p == nil {
+		t.Errorf("precompile inactive at activation block")
+	}
+//内置预编译合同不受影响
+	if p := activePrecompile(config, big.NewInt(0), common.BytesToAddress([]byte{1})); p == nil {
+		t.Errorf("built-in precompile missing")
+	}
+//未注册的实现和内置地址应被拒绝
+	bad := &params.ChainConfig{Precompiles: map[common.Address]*params.PrecompileConfig{
+		addr: {Name: "test-unknown", Block: big.NewInt(0)},
+	}}
+	if err := ValidatePrecompiles(bad); err == nil {
+		t.Errorf("unregistered precompile accepted")
+	}
+	bad = &params.ChainConfig{Precompiles: map[common.Address]*params.PrecompileConfig{
+		common.BytesToAddress([]byte{1}): {Name: "test-echo", Block: big.NewInt(0)},
+	}}
+	if err := ValidatePrecompiles(bad); err == nil {
+		t.Errorf("precompile overriding built-in accepted")
+	}
+}
@@ -39,11 +39,7 @@ type (
 //Run运行给定的合同，并负责运行回退到字节码解释器的预编译。
 func run(evm *EVM, contract *Contract, input []byte, readOnly bool) ([]byte, error) {
 	if contract.CodeAddr != nil {
-		precompiles := PrecompiledContractsHomestead
-		if evm.ChainConfig().IsByzantium(evm.BlockNumber) {
-			precompiles = PrecompiledContractsByzantium
-		}
-		if p := precompiles[*contract.CodeAddr]; p != nil {
+		if p := activePrecompile(evm.ChainConfig(), evm.BlockNumber, *contract.CodeAddr); p != nil {
 			return RunPrecompiledContract(p, input, contract)
 		}
 	}
@@ -193,11 +189,7 @@ func (evm *EVM) Call(caller ContractRef, addr common.Address, input []byte, gas
 		snapshot = evm.StateDB.Snapshot()
 	)
 	if !evm.StateDB.Exist(addr) {
-		precompiles := PrecompiledContractsHomestead
-		if evm.ChainConfig().IsByzantium(evm.BlockNumber) {
-			precompiles = PrecompiledContractsByzantium
-		}
-		if precompiles[addr] == nil && evm.ChainConfig().IsEIP158(evm.BlockNumber) && value.Sign() == 0 {
+		if activePrecompile(evm.ChainConfig(), evm.BlockNumber, addr) == nil && evm.ChainConfig().IsEIP158(evm.BlockNumber) && value.Sign() == 0 {
 //调用一个不存在的帐户，不要做任何事情，只需ping跟踪程序
 			if evm.vmConfig.Debug && evm.depth == 0 {
 				evm.vmConfig.Tracer.CaptureStart(caller.Address(), addr, false, input, gas, value)
@@ -463,3 +455,8 @@ func (evm *EVM) Create2(caller ContractRef, code []byte, gas uint64, endowment *
 //chainconfig返回环境的链配置
 func (evm *EVM) ChainConfig() *params.ChainConfig { return evm.chainConfig }
 
+//IsPrecompile报告给定地址在当前块是否为活动的预编译合同（内置或自定义）。
+func (evm *EVM) IsPrecompile(addr common.Address) bool {
+	return activePrecompile(evm.chainConfig, evm.BlockNumber, addr) != nil
+}
+
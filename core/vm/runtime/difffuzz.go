@@ -0,0 +1,418 @@
+
+//<developer>
+//    <name>linapex 曹一峰</name>
+//    <email>linapex@163.com</email>
+//    <wx>superexc</wx>
+//    <qqgroup>128148617</qqgroup>
+//    <url>https://jsq.ink</url>
+//    <role>pku engineer</role>
+//    <date>2019-03-16 19:16:36</date>
+//</624450083219312640>
+
+
+package runtime
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+//FuzzContractAddress是执行测试用例代码的合同地址，与Execute使用的地址相同。
+//外部虚拟机必须在此地址执行代码，以便跟踪可比较。
+var FuzzContractAddress = common.BytesToAddress([]byte("contract"))
+
+//FuzzAccount是差分模糊测试用例中预先分配的帐户。
+type FuzzAccount struct {
+	Balance *math.HexOrDecimal256       `json:"balance"`
+	Nonce   math.HexOrDecimal64         `json:"nonce"`
+	Code    hexutil.Bytes               `json:"code,omitempty"`
+	Storage map[common.Hash]common.Hash `json:"storage,omitempty"`
+}
+
+//FuzzCase是一个完全确定的EVM执行：代码、调用数据、块环境和预状态。
+//它以JSON格式传递给外部虚拟机，以便在相同的输入上比较执行。
+//所有分叉（直至君士坦丁堡）在创世时都处于激活状态。
+type FuzzCase struct {
+	Code        hexutil.Bytes                  `json:"code"`
+	Input       hexutil.Bytes                  `json:"input"`
+	Gas         math.HexOrDecimal64            `json:"gas"`
+	Value       *math.HexOrDecimal256          `json:"value"`
+	Origin      common.Address                 `json:"origin"`
+	Coinbase    common.Address                 `json:"coinbase"`
+	BlockNumber *math.HexOrDecimal256          `json:"number"`
+	Time        *math.HexOrDecimal256          `json:"timestamp"`
+	Difficulty  *math.HexOrDecimal256          `json:"difficulty"`
+	Alloc       map[common.Address]FuzzAccount `json:"alloc"`
+}
+
+//FuzzTrace是单个测试用例执行的结构化跟踪和结果。
+type FuzzTrace struct {
+	Steps   []vm.StructLog
+	Output  []byte
+	GasUsed uint64
+	Err     string
+}
+
+//Divergence描述两个跟踪之间的第一个差异。Step是发生差异的执行
+//步骤的索引，如果差异在最终结果中，则为-1。
+type Divergence struct {
+	Step      int
+	Field     string
+	Reference string
+	Candidate string
+}
+
+//字符串实现fmt.Stringer接口。
+func (d *Divergence) String() string {
+	if d.Step < 0 {
+		return fmt.Sprintf("result %s mismatch: reference %s, candidate %s", d.Field, d.Reference, d.Candidate)
+	}
+	return fmt.Sprintf("step %d: %s mismatch: reference %s, candidate %s", d.Step, d.Field, d.Reference, d.Candidate)
+}
+
+//fuzzOps包含生成器可以发出的所有已定义操作码。
+var fuzzOps = func() []vm.OpCode {
+	var ops []vm.OpCode
+	for i := 0; i < 256; i++ {
+		op := vm.OpCode(i)
+		if op.IsPush() {
+			continue
+		}
+		if strings.HasPrefix(op.String(), "Missing opcode") {
+			continue
+		}
+		ops = append(ops, op)
+	}
+	return ops
+}()
+
+//fuzzReader从模糊输入中确定地读取字节，输入耗尽后返回零。
+type fuzzReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *fuzzReader) exhausted() bool {
+	return r.pos >= len(r.data)
+}
+
+func (r *fuzzReader) byte() byte {
+	if r.exhausted() {
+		return 0
+	}
+	b := r.data[r.pos]
+	r.pos++
+	return b
+}
+
+func (r *fuzzReader) bytes(n int) []byte {
+	out := make([]byte, n)
+	for i := range out {
+		out[i] = r.byte()
+	}
+	return out
+}
+
+//NewFuzzCase从模糊输入确定地生成结构化测试用例。生成的代码偏向于
+//有意义的程序：推送小整数、已知帐户地址和代码内的跳转目标，
+//以便执行比随机字节走得更远。
+func NewFuzzCase(data []byte) *FuzzCase {
+	r := &fuzzReader{data: data}
+
+	c := &FuzzCase{
+		Origin:      common.HexToAddress("0x00000000000000000000000000000000000000f0"),
+		Coinbase:    common.HexToAddress("0x00000000000000000000000000000000000000c0"),
+		BlockNumber: (*math.HexOrDecimal256)(big.NewInt(1)),
+		Time:        (*math.HexOrDecimal256)(big.NewInt(1000)),
+		Difficulty:  (*math.HexOrDecimal256)(big.NewInt(0x20000)),
+		Alloc:       make(map[common.Address]FuzzAccount),
+	}
+	c.Alloc[c.Origin] = FuzzAccount{Balance: (*math.HexOrDecimal256)(new(big.Int).Mul(big.NewInt(params.Ether), big.NewInt(1000)))}
+
+//生成几个带有余额、存储和小段代码的帐户
+	accounts := []common.Address{c.Origin, FuzzContractAddress}
+	for i := 0; i < 1+int(r.byte()%3); i++ {
+		addr := common.BigToAddress(big.NewInt(int64(0x1000 + i)))
+		accounts = append(accounts, addr)
+	}
+	for _, addr := range accounts[2:] {
+		acc := FuzzAccount{
+			Balance: (*math.HexOrDecimal256)(new(big.Int).SetBytes(r.bytes(4))),
+			Nonce:   math.HexOrDecimal64(r.byte() % 4),
+			Storage: make(map[common.Hash]common.Hash),
+		}
+		for j := 0; j < int(r.byte()%4); j++ {
+			acc.Storage[common.BigToHash(big.NewInt(int64(r.byte()%8)))] = common.BytesToHash(r.bytes(2))
+		}
+		acc.Code = generateFuzzCode(r, accounts, 32)
+		c.Alloc[addr] = acc
+	}
+//生成执行环境和主合同代码
+	c.Gas = math.HexOrDecimal64(100000 + uint64(r.byte())*10000)
+	c.Value = (*math.HexOrDecimal256)(big.NewInt(int64(r.byte() % 2)))
+	c.Input = r.bytes(int(r.byte() % 64))
+	c.Code = generateFuzzCode(r, accounts, 512)
+
+	return c
+}
+
+//generateFuzzCode从读取器生成最多limit字节的字节码。
+func generateFuzzCode(r *fuzzReader, accounts []common.Address, limit int) []byte {
+	var code []byte
+	for !r.exhausted() && len(code) < limit {
+		b := r.byte()
+		if b < 0x40 {
+//发出有意义的操作数，而不是随机操作码
+			switch b % 4 {
+			case 0:
+				code = append(code, byte(vm.PUSH1), r.byte()%32)
+			case 1:
+				addr := accounts[int(r.byte())%len(accounts)]
+				code = append(code, byte(vm.PUSH20))
+				code = append(code, addr[:]...)
+			case 2:
+				target := uint16(int(r.byte()) % (len(code) + 1))
+				code = append(code, byte(vm.PUSH2), byte(target>>8), byte(target))
+			case 3:
+				code = append(code, byte(vm.JUMPDEST))
+			}
+			continue
+		}
+		if b < 0x60 {
+//带有随机参数的推送
+			n := 1 + int(r.byte()%32)
+			code = append(code, byte(vm.PUSH1)+byte(n-1))
+			code = append(code, r.bytes(n)...)
+			continue
+		}
+		code = append(code, byte(fuzzOps[int(b)%len(fuzzOps)]))
+	}
+	return code
+}
+
+//config为测试用例组装运行时配置，预状态已提交，以便原始存储值
+//（君士坦丁堡的SSTORE天然气计量所需）与外部虚拟机一致。
+func (c *FuzzCase) config(tracer vm.Tracer) (*Config, error) {
+	db := state.NewDatabase(ethdb.NewMemDatabase())
+	statedb, _ := state.New(common.Hash{}, db)
+	for addr, acc := range c.Alloc {
+		statedb.CreateAccount(addr)
+		if acc.Balance != nil {
+			statedb.SetBalance(addr, (*big.Int)(acc.Balance))
+		}
+		statedb.SetNonce(addr, uint64(acc.Nonce))
+		statedb.SetCode(addr, acc.Code)
+		for key, value := range acc.Storage {
+			statedb.SetState(addr, key, value)
+		}
+	}
+	root, err := statedb.Commit(false)
+	if err != nil {
+		return nil, err
+	}
+	if statedb, err = state.New(root, db); err != nil {
+		return nil, err
+	}
+	cfg := &Config{
+		ChainConfig: params.AllEthashProtocolChanges,
+		Origin:      c.Origin,
+		Coinbase:    c.Coinbase,
+		BlockNumber: (*big.Int)(c.BlockNumber),
+		Time:        (*big.Int)(c.Time),
+		Difficulty:  (*big.Int)(c.Difficulty),
+		GasLimit:    uint64(c.Gas),
+		Value:       (*big.Int)(c.Value),
+		State:       statedb,
+	}
+	if tracer != nil {
+		cfg.EVMConfig = vm.Config{Debug: true, Tracer: tracer}
+	}
+	return cfg, nil
+}
+
+//Run使用本地解释器执行测试用例，并将执行步骤报告给给定的跟踪程序。
+func (c *FuzzCase) Run(tracer vm.Tracer) ([]byte, error) {
+	cfg, err := c.config(tracer)
+	if err != nil {
+		return nil, err
+	}
+	ret, _, err := Execute(c.Code, c.Input, cfg)
+	return ret, err
+}
+
+//referenceTracer是一个结构化记录器，它还记录调用消耗的气体。
+type referenceTracer struct {
+	*vm.StructLogger
+	gasUsed uint64
+}
+
+func (t *referenceTracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration, err error) error {
+	t.gasUsed = gasUsed
+	return t.StructLogger.CaptureEnd(output, gasUsed, d, err)
+}
+
+//Trace使用本地解释器和vm.StructLogger执行测试用例，返回结构化跟踪。
+func (c *FuzzCase) Trace() (*FuzzTrace, error) {
+	tracer := &referenceTracer{StructLogger: vm.NewStructLogger(&vm.LogConfig{DisableMemory: true, DisableStorage: true})}
+	ret, err := c.Run(tracer)
+
+	trace := &FuzzTrace{
+		Steps:   tracer.StructLogs(),
+		Output:  ret,
+		GasUsed: tracer.gasUsed,
+	}
+	if err != nil {
+		trace.Err = err.Error()
+	}
+	return trace, nil
+}
+
+//ExternalVM通过JSON跟踪协议驱动另一个EVM实现：测试用例以JSON格式写入
+//进程的标准输入，进程必须以与vm.JSONLogger相同的格式每行输出一个
+//执行步骤，并以包含output、gasUsed和error的结果行结束。
+//其他行（例如stateRoot）将被忽略。
+type ExternalVM struct {
+	Path string
+	Args []string
+}
+
+//Trace在外部虚拟机上执行测试用例并解析其跟踪。
+func (e *ExternalVM) Trace(c *FuzzCase) (*FuzzTrace, error) {
+	blob, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.Command(e.Path, e.Args...)
+	cmd.Stdin = bytes.NewReader(blob)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("external vm failed: %v: %s", err, stderr.String())
+	}
+	return ParseJSONTrace(&stdout)
+}
+
+//ParseJSONTrace解析vm.JSONLogger格式的跟踪。
+func ParseJSONTrace(r io.Reader) (*FuzzTrace, error) {
+	trace := new(FuzzTrace)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 32*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 || line[0] != '{' {
+			continue
+		}
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(line, &fields); err != nil {
+			return nil, fmt.Errorf("invalid trace line %q: %v", line, err)
+		}
+		switch {
+		case fields["pc"] != nil:
+			var step vm.StructLog
+			if err := json.Unmarshal(line, &step); err != nil {
+				return nil, fmt.Errorf("invalid trace step %q: %v", line, err)
+			}
+			trace.Steps = append(trace.Steps, step)
+
+		case fields["output"] != nil:
+			var end struct {
+				Output  string              `json:"output"`
+				GasUsed math.HexOrDecimal64 `json:"gasUsed"`
+				Err     string              `json:"error"`
+			}
+			if err := json.Unmarshal(line, &end); err != nil {
+				return nil, fmt.Errorf("invalid trace result %q: %v", line, err)
+			}
+			trace.Output = common.FromHex(end.Output)
+			trace.GasUsed = uint64(end.GasUsed)
+			trace.Err = end.Err
+		}
+	}
+	return trace, scanner.Err()
+}
+
+//CompareTraces返回两个跟踪之间的第一个差异，如果它们一致，则返回nil。
+//仅当两个跟踪都包含堆栈时才比较堆栈；错误仅比较是否存在，因为
+//不同的实现对错误的措辞不同。
+func CompareTraces(ref, cand *FuzzTrace) *Divergence {
+	for i := 0; i < len(ref.Steps) && i < len(cand.Steps); i++ {
+		want, have := &ref.Steps[i], &cand.Steps[i]
+
+		switch {
+		case want.Pc != have.Pc:
+			return &Divergence{i, "pc", fmt.Sprint(want.Pc), fmt.Sprint(have.Pc)}
+		case want.Op != have.Op:
+			return &Divergence{i, "op", want.Op.String(), have.Op.String()}
+		case want.Gas != have.Gas:
+			return &Divergence{i, "gas", fmt.Sprint(want.Gas), fmt.Sprint(have.Gas)}
+		case want.GasCost != have.GasCost:
+			return &Divergence{i, "gasCost", fmt.Sprint(want.GasCost), fmt.Sprint(have.GasCost)}
+		case want.Depth != have.Depth:
+			return &Divergence{i, "depth", fmt.Sprint(want.Depth), fmt.Sprint(have.Depth)}
+		case want.MemorySize != have.MemorySize:
+			return &Divergence{i, "memSize", fmt.Sprint(want.MemorySize), fmt.Sprint(have.MemorySize)}
+		case want.RefundCounter != have.RefundCounter:
+			return &Divergence{i, "refund", fmt.Sprint(want.RefundCounter), fmt.Sprint(have.RefundCounter)}
+		}
+		if want.Stack != nil && have.Stack != nil {
+			if len(want.Stack) != len(have.Stack) {
+				return &Divergence{i, "stack size", fmt.Sprint(len(want.Stack)), fmt.Sprint(len(have.Stack))}
+			}
+			for j := range want.Stack {
+				if want.Stack[j].Cmp(have.Stack[j]) != 0 {
+					return &Divergence{i, fmt.Sprintf("stack[%d]", j), fmt.Sprintf("%#x", want.Stack[j]), fmt.Sprintf("%#x", have.Stack[j])}
+				}
+			}
+		}
+	}
+	if len(ref.Steps) != len(cand.Steps) {
+		n := len(ref.Steps)
+		if len(cand.Steps) < n {
+			n = len(cand.Steps)
+		}
+		return &Divergence{n, "step count", fmt.Sprint(len(ref.Steps)), fmt.Sprint(len(cand.Steps))}
+	}
+	switch {
+	case !bytes.Equal(ref.Output, cand.Output):
+		return &Divergence{-1, "output", fmt.Sprintf("%#x", ref.Output), fmt.Sprintf("%#x", cand.Output)}
+	case ref.GasUsed != cand.GasUsed:
+		return &Divergence{-1, "gasUsed", fmt.Sprint(ref.GasUsed), fmt.Sprint(cand.GasUsed)}
+	case (ref.Err == "") != (cand.Err == ""):
+		return &Divergence{-1, "error", fmt.Sprintf("%q", ref.Err), fmt.Sprintf("%q", cand.Err)}
+	}
+	return nil
+}
+
+//Differential从模糊输入生成测试用例，在本地解释器和外部虚拟机上执行，
+//并返回测试用例及第一个差异（如果有）。
+func Differential(input []byte, ext *ExternalVM) (*FuzzCase, *Divergence, error) {
+	c := NewFuzzCase(input)
+
+	ref, err := c.Trace()
+	if err != nil {
+		return c, nil, err
+	}
+	cand, err := ext.Trace(c)
+	if err != nil {
+		return c, nil, err
+	}
+	return c, CompareTraces(ref, cand), nil
+}
@@ -0,0 +1,102 @@
+
+//<developer>
+//    <name>linapex 曹一峰</name>
+//    <email>linapex@163.com</email>
+//    <wx>superexc</wx>
+//    <qqgroup>128148617</qqgroup>
+//    <url>https://jsq.ink</url>
+//    <role>pku engineer</role>
+//    <date>2019-03-16 19:16:36</date>
+//</624450083252867072>
+
+
+package runtime
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+//测试生成器是否是确定的，并且测试用例是否在JSON编码后保持不变。
+func TestFuzzCaseDeterministic(t *testing.T) {
+	input := make([]byte, 1024)
+	rand.New(rand.NewSource(1)).Read(input)
+
+	a, b := NewFuzzCase(input), NewFuzzCase(input)
+	if !reflect.DeepEqual(a, b) {
+		t.Fatalf("generator not deterministic")
+	}
+	blob, err := json.Marshal(a)
+	if err != nil {
+		t.Fatalf("failed to encode case: %v", err)
+	}
+	var dec FuzzCase
+	if err := json.Unmarshal(blob, &dec); err != nil {
+		t.Fatalf("failed to decode case: %v", err)
+	}
+	if !bytes.Equal(dec.Code, a.Code) || !bytes.Equal(dec.Input, a.Input) || len(dec.Alloc) != len(a.Alloc) {
+		t.Fatalf("case mismatch after JSON round trip")
+	}
+}
+
+//测试JSONLogger生成的跟踪与StructLogger生成的跟踪是否一致，
+//即本地解释器与自身之间的差分比较没有差异。
+func TestDifferentialSelfConsistency(t *testing.T) {
+	rnd := rand.New(rand.NewSource(2))
+	for i := 0; i < 50; i++ {
+		input := make([]byte, 256)
+		rnd.Read(input)
+		c := NewFuzzCase(input)
+
+		ref, err := c.Trace()
+		if err != nil {
+			t.Fatalf("case %d: reference trace failed: %v", i, err)
+		}
+		var out bytes.Buffer
+		if _, err := c.Run(vm.NewJSONLogger(&vm.LogConfig{DisableMemory: true}, &out)); err != nil && ref.Err == "" {
+			t.Fatalf("case %d: json run failed: %v", i, err)
+		}
+		cand, err := ParseJSONTrace(&out)
+		if err != nil {
+			t.Fatalf("case %d: failed to parse json trace: %v", i, err)
+		}
+		if div := CompareTraces(ref, cand); div != nil {
+			t.Fatalf("case %d: unexpected divergence: %v", i, div)
+		}
+	}
+}
+
+//测试CompareTraces是否报告第一个不同的步骤。
+func TestCompareTracesDivergence(t *testing.T) {
+	input := make([]byte, 512)
+	rand.New(rand.NewSource(3)).Read(input)
+
+	ref, _ := NewFuzzCase(input).Trace()
+	if len(ref.Steps) < 2 {
+		t.Skip("trace too short")
+	}
+	cand := &FuzzTrace{
+		Steps:   append([]vm.StructLog{}, ref.Steps...),
+		Output:  ref.Output,
+		GasUsed: ref.GasUsed,
+		Err:     ref.Err,
+	}
+	cand.Steps[1].Gas++
+
+	div := CompareTraces(ref, cand)
+	if div == nil {
+		t.Fatalf("divergence not detected")
+	}
+	if div.Step != 1 || div.Field != "gas" {
+		t.Fatalf("wrong divergence reported: %v", div)
+	}
+	cand.Steps = cand.Steps[:1]
+	if div := CompareTraces(ref, cand); div == nil || div.Field != "step count" {
+		t.Fatalf("missing steps not detected: %v", div)
+	}
+}
@@ -14,6 +14,12 @@
 
 package runtime
 
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
 //引信是Go-Fuzz工具的基本切入点
 //
 //对于有效的可分析/不可运行代码，返回1，0
@@ -31,3 +37,21 @@ func Fuzz(input []byte) int {
 	return 1
 }
 
+//FuzzDifferential是差分模糊测试的Go-Fuzz切入点。参考虚拟机的
+//命令行取自EVM_FUZZ_REFERENCE环境变量，未设置时跳过输入。
+//
+//发现执行分歧时崩溃，以便Go-Fuzz保存导致分歧的输入。
+func FuzzDifferential(input []byte) int {
+	cmd := strings.Fields(os.Getenv("EVM_FUZZ_REFERENCE"))
+	if len(cmd) == 0 {
+		return 0
+	}
+	c, div, err := Differential(input, &ExternalVM{Path: cmd[0], Args: cmd[1:]})
+	if err != nil {
+		return 0
+	}
+	if div != nil {
+		panic(fmt.Sprintf("divergence: %v (case code %x)", div, c.Code))
+	}
+	return 1
+}
@@ -31,6 +31,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/miner"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/rpc"
@@ -151,6 +152,50 @@ func (api *PrivateMinerAPI) GetHashrate() uint64 {
 	return api.e.miner.HashRate()
 }
 
+//BuildBlockArgs是miner_buildBlock的参数。
+type BuildBlockArgs struct {
+	ParentHash   common.Hash     `json:"parentHash"`
+	Timestamp    hexutil.Uint64  `json:"timestamp"`
+	Coinbase     common.Address  `json:"coinbase"`
+	ExtraData    *hexutil.Bytes  `json:"extraData"`
+	Transactions []hexutil.Bytes `json:"transactions"`
+	NoPool       bool            `json:"noPool"`
+}
+
+//BuildBlock在给定父块上构建一个完全执行但未密封的块，首先包含
+//给定的RLP编码交易，然后用交易池填充。返回的块不会被导入或
+//广播，调用方可以自行密封它。
+func (api *PrivateMinerAPI) BuildBlock(args BuildBlockArgs) (map[string]interface{}, error) {
+	buildArgs := &miner.BuildArgs{
+		Parent:    args.ParentHash,
+		Timestamp: uint64(args.Timestamp),
+		Coinbase:  args.Coinbase,
+		NoPool:    args.NoPool,
+	}
+	if args.ExtraData != nil {
+		buildArgs.Extra = []byte(*args.ExtraData)
+	}
+	for i, encoded := range args.Transactions {
+		tx := new(types.Transaction)
+		if err := rlp.DecodeBytes(encoded, tx); err != nil {
+			return nil, fmt.Errorf("invalid transaction %d: %v", i, err)
+		}
+		buildArgs.Forced = append(buildArgs.Forced, tx)
+	}
+	built, err := api.e.Miner().BuildBlock(buildArgs)
+	if err != nil {
+		return nil, err
+	}
+	header := built.Block.Header()
+	return map[string]interface{}{
+		"header":       header,
+		"sealHash":     api.e.Engine().SealHash(header),
+		"transactions": built.Block.Transactions(),
+		"receipts":     built.Receipts,
+		"fees":         (*hexutil.Big)(built.Fees),
+	}, nil
+}
+
 //PrivateAdminAPI is the collection of Ethereum full node-related APIs
 //在私有管理终结点上公开。
 type PrivateAdminAPI struct {
@@ -185,6 +230,12 @@ func (api *PrivateAdminAPI) ExportChain(file string) (bool, error) {
 	return true, nil
 }
 
+//TxPeerStats返回每个连接的对等方发送的事务的准入、拒绝和限制统计信息，
+//按对等方ID索引。
+func (api *PrivateAdminAPI) TxPeerStats() map[string]TxPeerStats {
+	return api.eth.protocolManager.txLimiter.stats()
+}
+
 func hasAllBlocks(chain *core.BlockChain, bs []*types.Block) bool {
 	for _, b := range bs {
 		if !chain.HasBlock(b.Hash(), b.NumberU64()) {
@@ -13,13 +13,27 @@
 package eth
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/big"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
 )
 
 var dumper = spew.ConfigState{Indent: "    "}
@@ -85,3 +99,129 @@ keys     = []common.Hash{ //存储键散列
 	}
 }
 
+
+//测试余额为零的账户在不指定燃气的情况下也能跟踪调用。
+func TestTraceCallUnfundedSender(t *testing.T) {
+	var (
+		db      = ethdb.NewMemDatabase()
+		gspec   = &core.Genesis{Config: params.TestChainConfig}
+		genesis = gspec.MustCommit(db)
+	)
+	blockchain, _ := core.NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, nil)
+	defer blockchain.Stop()
+
+	chain, _ := core.GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 1, nil)
+	if _, err := blockchain.InsertChain(chain); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	api := NewPrivateDebugAPI(gspec.Config, &Ethereum{blockchain: blockchain, chainDb: db})
+
+	to := common.Address{0x02}
+	args := ethapi.CallArgs{From: common.Address{0x01}, To: &to}
+	res, err := api.TraceCall(context.Background(), args, rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber), nil)
+	if err != nil {
+		t.Fatalf("failed to trace call: %v", err)
+	}
+	result, ok := res.(*ethapi.ExecutionResult)
+	if !ok {
+		t.Fatalf("unexpected result type %T", res)
+	}
+	if result.Failed || result.Gas != params.TxGas {
+		t.Fatalf("unexpected result: failed %v, gas %d", result.Failed, result.Gas)
+	}
+}
+
+//traceChainResults通过进程内RPC订阅跟踪链段，并按顺序收集直到end的所有结果。
+func traceChainResults(t *testing.T, api *PrivateDebugAPI, start, end uint64) []json.RawMessage {
+	server := rpc.NewServer()
+	if err := server.RegisterName("debug", api); err != nil {
+		t.Fatalf("failed to register debug API: %v", err)
+	}
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	results := make(chan json.RawMessage)
+	sub, err := client.Subscribe(context.Background(), "debug", results, "traceChain", hexutil.Uint64(start), hexutil.Uint64(end), nil)
+	if err != nil {
+		t.Fatalf("failed to subscribe to chain trace: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	var traces []json.RawMessage
+	for {
+		select {
+		case res := <-results:
+			traces = append(traces, res)
+
+			var block struct {
+				Block hexutil.Uint64 `json:"block"`
+			}
+			if err := json.Unmarshal(res, &block); err != nil {
+				t.Fatalf("failed to decode trace: %v", err)
+			}
+			if uint64(block.Block) == end {
+				return traces
+			}
+		case err := <-sub.Err():
+			t.Fatalf("chain trace failed: %v", err)
+		case <-time.After(10 * time.Second):
+			t.Fatalf("chain trace timed out after %d blocks", len(traces))
+		}
+	}
+}
+
+//测试并行跟踪链段的结果与顺序重新执行的结果一致。
+func TestTraceChainParallel(t *testing.T) {
+	var (
+		key, _  = crypto.GenerateKey()
+		addr    = crypto.PubkeyToAddress(key.PublicKey)
+		gspec   = &core.Genesis{Config: params.TestChainConfig, Alloc: core.GenesisAlloc{addr: {Balance: big.NewInt(1000000000000000)}}}
+		signer  = types.HomesteadSigner{}
+		initDB  = ethdb.NewMemDatabase()
+		genesis = gspec.MustCommit(initDB)
+	)
+//每个块包含一笔转账和一个写存储的合约创建
+	blocks, _ := core.GenerateChain(gspec.Config, genesis, ethash.NewFaker(), initDB, 8, func(i int, gen *core.BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(gen.TxNonce(addr), common.Address{0x01}, big.NewInt(1000), params.TxGas, big.NewInt(1), nil), signer, key)
+		gen.AddTx(tx)
+		tx, _ = types.SignTx(types.NewContractCreation(gen.TxNonce(addr), big.NewInt(0), 100000, big.NewInt(1), []byte{0x60, byte(i + 1), 0x60, 0x00, 0x55}), signer, key)
+		gen.AddTx(tx)
+	})
+//存档节点保存所有中间状态，因此链段被并行跟踪
+	archiveDB := ethdb.NewMemDatabase()
+	gspec.MustCommit(archiveDB)
+	archive, _ := core.NewBlockChain(archiveDB, &core.CacheConfig{Disabled: true}, gspec.Config, ethash.NewFaker(), vm.Config{}, nil)
+	defer archive.Stop()
+	if _, err := archive.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	parallel := NewPrivateDebugAPI(gspec.Config, &Ethereum{blockchain: archive, chainDb: archiveDB, config: &Config{}})
+	if !parallel.parallelTraceable(archive.Genesis(), archive.CurrentBlock()) {
+		t.Fatalf("archive chain not traceable in parallel")
+	}
+//重启后的修剪节点只在磁盘上保存最近的状态，因此链段被顺序跟踪
+	prunedDB := ethdb.NewMemDatabase()
+	gspec.MustCommit(prunedDB)
+	pruned, _ := core.NewBlockChain(prunedDB, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, nil)
+	if _, err := pruned.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	pruned.Stop()
+
+	pruned, _ = core.NewBlockChain(prunedDB, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, nil)
+	defer pruned.Stop()
+	serial := NewPrivateDebugAPI(gspec.Config, &Ethereum{blockchain: pruned, chainDb: prunedDB, config: &Config{}})
+	if serial.parallelTraceable(pruned.Genesis(), pruned.CurrentBlock()) {
+		t.Fatalf("pruned chain traceable in parallel")
+	}
+	have := traceChainResults(t, parallel, 0, 8)
+	want := traceChainResults(t, serial, 0, 8)
+	if len(have) != len(want) {
+		t.Fatalf("trace count mismatch: have %d, want %d", len(have), len(want))
+	}
+	for i := range have {
+		if !bytes.Equal(have[i], want[i]) {
+			t.Errorf("trace %d mismatch:\nhave %s\nwant %s", i, have[i], want[i])
+		}
+	}
+}
@@ -26,6 +26,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
@@ -141,6 +142,12 @@ func (api *PrivateDebugAPI) traceChain(ctx context.Context, start, end *types.Bl
 	}
 	sub := notifier.CreateSubscription()
 
+//如果所有中间状态都可用（例如归档节点），则各块可以
+//独立地并行跟踪，无需顺序重新执行整个链段
+	if api.parallelTraceable(start, end) {
+		api.traceChainParallel(ctx, notifier, sub, start, end, config)
+		return sub, nil
+	}
 //在进行任何工作之前，确保我们有一个有效的启动状态
 	origin := start.NumberU64()
 database := state.NewDatabaseWithCache(api.eth.ChainDb(), 16) //链追踪可能从Genesis开始。
@@ -197,22 +204,8 @@ database := state.NewDatabaseWithCache(api.eth.ChainDb(), 16) //链追踪可能
 
 //获取并执行下一个块跟踪任务
 			for task := range tasks {
-				signer := types.MakeSigner(api.config, task.block.Number())
-
-//跟踪包含在
-				for i, tx := range task.block.Transactions() {
-					msg, _ := tx.AsMessage(signer)
-					vmctx := core.NewEVMContext(msg, task.block.Header(), api.eth.blockchain, nil)
-
-					res, err := api.traceTx(ctx, msg, vmctx, task.statedb, config)
-					if err != nil {
-						task.results[i] = &txTraceResult{Error: err.Error()}
-						log.Warn("Tracing failed", "hash", tx.Hash(), "block", task.block.NumberU64(), "err", err)
-						break
-					}
-					task.statedb.Finalise(true)
-					task.results[i] = &txTraceResult{Result: res}
-				}
+				api.traceChainTask(ctx, task, config)
+
 //将结果返回给用户或在拆卸时中止
 				select {
 				case results <- task:
@@ -345,6 +338,153 @@ database := state.NewDatabaseWithCache(api.eth.ChainDb(), 16) //链追踪可能
 	return sub, nil
 }
 
+//tracechaintask跟踪块跟踪任务中包含的所有事务，并在
+//任务的中间状态之上执行它们，将结果填充到任务中。
+func (api *PrivateDebugAPI) traceChainTask(ctx context.Context, task *blockTraceTask, config *TraceConfig) {
+	signer := types.MakeSigner(api.config, task.block.Number())
+
+	for i, tx := range task.block.Transactions() {
+		msg, _ := tx.AsMessage(signer)
+		vmctx := core.NewEVMContext(msg, task.block.Header(), api.eth.blockchain, nil)
+
+		res, err := api.traceTx(ctx, msg, vmctx, task.statedb, config)
+		if err != nil {
+			task.results[i] = &txTraceResult{Error: err.Error()}
+			log.Warn("Tracing failed", "hash", tx.Hash(), "block", task.block.NumberU64(), "err", err)
+			break
+		}
+		task.statedb.Finalise(true)
+		task.results[i] = &txTraceResult{Result: res}
+	}
+}
+
+//ParallelTraceable检查是否可以直接访问跟踪链段所需的
+//所有中间状态，在这种情况下可以并行跟踪各个块。只要有一个块
+//的父状态缺失，每个工作线程都可能需要重新执行大量的块，此时顺序跟踪更快。
+func (api *PrivateDebugAPI) parallelTraceable(start, end *types.Block) bool {
+	if api.eth.config.NoPruning {
+		return true
+	}
+	for number := start.NumberU64(); number < end.NumberU64(); number++ {
+		header := api.eth.blockchain.GetHeaderByNumber(number)
+		if header == nil || !api.eth.blockchain.HasState(header.Root) {
+			return false
+		}
+	}
+	return true
+}
+
+//tracechainparallel跟踪链段（不包括start）中的所有块，每个块
+//独立地从其父状态开始跟踪，并跨多个工作线程并发执行。
+//结果按块顺序流式传输给订阅者；正在进行和已完成但尚未
+//发送的块数受一个窗口限制，以限制内存使用。
+func (api *PrivateDebugAPI) traceChainParallel(ctx context.Context, notifier *rpc.Notifier, sub *rpc.Subscription, start, end *types.Block, config *TraceConfig) {
+	reexec := defaultTraceReexec
+	if config != nil && config.Reexec != nil {
+		reexec = *config.Reexec
+	}
+	blocks := int(end.NumberU64() - start.NumberU64())
+
+	threads := runtime.NumCPU()
+	if threads > blocks {
+		threads = blocks
+	}
+	var (
+		pend    = new(sync.WaitGroup)
+		tasks   = make(chan uint64, threads)
+		results = make(chan *blockTraceResult, threads)
+window  = make(chan struct{}, 2*threads) //正在跟踪或等待按顺序发送的块的许可
+		begin   = time.Now()
+	)
+	for th := 0; th < threads; th++ {
+		pend.Add(1)
+		go func() {
+			defer pend.Done()
+
+//获取下一个块，重建其父状态并跟踪
+			for number := range tasks {
+				result := &blockTraceResult{Block: hexutil.Uint64(number)}
+
+				block := api.eth.blockchain.GetBlockByNumber(number)
+				if block == nil {
+					result.Traces = []*txTraceResult{{Error: fmt.Sprintf("block #%d not found", number)}}
+				} else {
+					result.Hash = block.Hash()
+					task := &blockTraceTask{block: block, results: make([]*txTraceResult, len(block.Transactions()))}
+
+					parent := api.eth.blockchain.GetBlock(block.ParentHash(), number-1)
+					if parent == nil {
+						result.Traces = []*txTraceResult{{Error: fmt.Sprintf("parent %#x not found", block.ParentHash())}}
+					} else if statedb, err := api.computeStateDB(parent, reexec); err != nil {
+						result.Traces = []*txTraceResult{{Error: err.Error()}}
+					} else {
+						task.statedb = statedb
+						api.traceChainTask(ctx, task, config)
+						result.Traces = task.results
+					}
+				}
+				select {
+				case results <- result:
+				case <-notifier.Closed():
+					return
+				}
+			}
+		}()
+	}
+//将所有块编号按顺序送入跟踪程序，不超过窗口大小
+	go func() {
+		defer func() {
+			close(tasks)
+			pend.Wait()
+			close(results)
+		}()
+		for number := start.NumberU64() + 1; number <= end.NumberU64(); number++ {
+			select {
+			case window <- struct{}{}:
+			case <-notifier.Closed():
+				return
+			}
+			select {
+			case tasks <- number:
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+//继续读取跟踪结果，并按块顺序将其传输给用户
+	go func() {
+		var (
+			logged time.Time
+			traced uint64
+			done   = make(map[uint64]*blockTraceResult)
+			next   = start.NumberU64() + 1
+		)
+		for res := range results {
+			done[uint64(res.Block)] = res
+
+			for result, ok := done[next]; ok; result, ok = done[next] {
+				if len(result.Traces) > 0 || next == end.NumberU64() {
+					notifier.Notify(sub.ID, result)
+				}
+				traced += uint64(len(result.Traces))
+				delete(done, next)
+				next++
+				<-window
+			}
+//如果经过足够长的时间，则打印进度日志
+			if time.Since(logged) > 8*time.Second {
+				log.Info("Tracing chain segment", "start", start.NumberU64(), "end", end.NumberU64(), "current", next-1, "transactions", traced, "elapsed", time.Since(begin), "threads", threads)
+				logged = time.Now()
+			}
+		}
+		if next <= end.NumberU64() {
+			log.Warn("Chain tracing aborted", "start", start.NumberU64(), "end", end.NumberU64(), "abort", next, "transactions", traced, "elapsed", time.Since(begin))
+		} else {
+			log.Info("Chain tracing finished", "start", start.NumberU64(), "end", end.NumberU64(), "transactions", traced, "elapsed", time.Since(begin))
+		}
+	}()
+}
+
 //traceBlockByNumber返回在执行期间创建的结构化日志
 //EVM并将其作为JSON对象返回。
 func (api *PrivateDebugAPI) TraceBlockByNumber(ctx context.Context, number rpc.BlockNumber, config *TraceConfig) ([]*txTraceResult, error) {
@@ -703,6 +843,55 @@ func (api *PrivateDebugAPI) TraceTransaction(ctx context.Context, hash common.Ha
 	return api.traceTx(ctx, msg, vmctx, statedb, config)
 }
 
+//TraceCall允许在给定块的状态之上跟踪任意调用（不需要签名），
+//返回值取决于所请求的跟踪程序。块可以按编号（包括“latest”
+//和“pending”标签）或按哈希指定。
+func (api *PrivateDebugAPI) TraceCall(ctx context.Context, args ethapi.CallArgs, blockNrOrHash rpc.BlockNumberOrHash, config *TraceConfig) (interface{}, error) {
+//检索要在其上执行调用的块和状态
+	var (
+		block   *types.Block
+		statedb *state.StateDB
+		err     error
+	)
+	if hash, ok := blockNrOrHash.Hash(); ok {
+		block = api.eth.blockchain.GetBlockByHash(hash)
+		if block == nil {
+			return nil, fmt.Errorf("block %#x not found", hash)
+		}
+	} else if number, ok := blockNrOrHash.Number(); ok {
+		switch number {
+		case rpc.PendingBlockNumber:
+			block, statedb = api.eth.miner.Pending()
+		case rpc.LatestBlockNumber:
+			block = api.eth.blockchain.CurrentBlock()
+		default:
+			block = api.eth.blockchain.GetBlockByNumber(uint64(number))
+		}
+		if block == nil {
+			return nil, fmt.Errorf("block #%d not found", number)
+		}
+	} else {
+		return nil, errors.New("invalid arguments; neither block nor hash specified")
+	}
+	if statedb == nil {
+		reexec := defaultTraceReexec
+		if config != nil && config.Reexec != nil {
+			reexec = *config.Reexec
+		}
+		if statedb, err = api.computeStateDB(block, reexec); err != nil {
+			return nil, err
+		}
+	}
+//组装调用消息及其EVM上下文，然后跟踪
+	msg := args.ToMessage()
+	vmctx := core.NewEVMContext(msg, block.Header(), api.eth.blockchain, nil)
+
+//与eth_call一样为发送者提供足够的余额，使未注资的账户也能使用默认燃气跟踪调用
+	statedb.SetBalance(msg.From(), math.MaxBig256)
+
+	return api.traceTx(ctx, msg, vmctx, statedb, config)
+}
+
 //tracetx根据提供的配置配置配置新的跟踪程序，以及
 //在提供的环境中执行给定的消息。返回值将
 //be tracer dependent.
@@ -27,6 +27,7 @@ import (
 	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/consensus/clique"
 	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/consensus/ibft"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/bloombits"
 	"github.com/ethereum/go-ethereum/core/rawdb"
@@ -152,8 +153,11 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 			EWASMInterpreter:        config.EWASMInterpreter,
 			EVMInterpreter:          config.EVMInterpreter,
 		}
-		cacheConfig = &core.CacheConfig{Disabled: config.NoPruning, TrieCleanLimit: config.TrieCleanCache, TrieDirtyLimit: config.TrieDirtyCache, TrieTimeLimit: config.TrieTimeout}
+		cacheConfig = &core.CacheConfig{Disabled: config.NoPruning, TrieCleanLimit: config.TrieCleanCache, TrieDirtyLimit: config.TrieDirtyCache, TrieTimeLimit: config.TrieTimeout, BodyRetention: config.BodyRetention}
 	)
+	if config.SyncMode == downloader.PrunedSync && cacheConfig.BodyRetention == 0 {
+		cacheConfig.BodyRetention = downloader.DefaultBodyRetention
+	}
 	eth.blockchain, err = core.NewBlockChain(chainDb, cacheConfig, eth.chainConfig, eth.engine, vmConfig, eth.shouldPreserve)
 	if err != nil {
 		return nil, err
@@ -166,18 +170,37 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 	}
 	eth.bloomIndexer.Start(eth.blockchain)
 
+	if engine, ok := eth.engine.(*ibft.Engine); ok {
+		if err := engine.Start(eth.blockchain, eth.verifyProposal, eth.insertCommitted); err != nil {
+			return nil, err
+		}
+	}
+
 	if config.TxPool.Journal != "" {
 		config.TxPool.Journal = ctx.ResolvePath(config.TxPool.Journal)
 	}
+	if config.TxPool.Snapshot != "" {
+		config.TxPool.Snapshot = ctx.ResolvePath(config.TxPool.Snapshot)
+	}
 	eth.txPool = core.NewTxPool(config.TxPool, eth.chainConfig, eth.blockchain)
 
 	if eth.protocolManager, err = NewProtocolManager(eth.chainConfig, config.SyncMode, config.NetworkId, eth.eventMux, eth.txPool, eth.engine, eth.blockchain, chainDb, config.Whitelist); err != nil {
 		return nil, err
 	}
+	if config.SyncCheckpoint != (common.Hash{}) {
+		eth.protocolManager.downloader.SetCheckpoint(config.SyncCheckpoint)
+	}
+	eth.protocolManager.downloader.SetBodyRetention(cacheConfig.BodyRetention)
 
 	eth.miner = miner.New(eth, eth.chainConfig, eth.EventMux(), eth.engine, config.MinerRecommit, config.MinerGasFloor, config.MinerGasCeil, eth.isLocalBlock)
 	eth.miner.SetExtra(makeExtraData(config.MinerExtraData))
 
+	ordering, err := miner.NewOrdering(config.MinerOrdering)
+	if err != nil {
+		return nil, err
+	}
+	eth.miner.SetOrdering(ordering)
+
 	eth.APIBackend = &EthAPIBackend{eth, nil}
 	gpoParams := config.GPO
 	if gpoParams.Default == nil {
@@ -223,6 +246,10 @@ func CreateConsensusEngine(ctx *node.ServiceContext, chainConfig *params.ChainCo
 	if chainConfig.Clique != nil {
 		return clique.New(chainConfig.Clique, db)
 	}
+//如果要求拜占庭容错共识，请将其设置为
+	if chainConfig.IBFT != nil {
+		return ibft.New(chainConfig.IBFT)
+	}
 //否则承担工作证明
 	switch config.PowMode {
 	case ethash.ModeFake:
@@ -236,12 +263,14 @@ func CreateConsensusEngine(ctx *node.ServiceContext, chainConfig *params.ChainCo
 		return ethash.NewShared()
 	default:
 		engine := ethash.New(ethash.Config{
-			CacheDir:       ctx.ResolvePath(config.CacheDir),
-			CachesInMem:    config.CachesInMem,
-			CachesOnDisk:   config.CachesOnDisk,
-			DatasetDir:     config.DatasetDir,
-			DatasetsInMem:  config.DatasetsInMem,
-			DatasetsOnDisk: config.DatasetsOnDisk,
+			CacheDir:          ctx.ResolvePath(config.CacheDir),
+			CachesInMem:       config.CachesInMem,
+			CachesOnDisk:      config.CachesOnDisk,
+			DatasetDir:        config.DatasetDir,
+			DatasetsInMem:     config.DatasetsInMem,
+			DatasetsOnDisk:    config.DatasetsOnDisk,
+			StratumAddr:       config.StratumAddr,
+			StratumDifficulty: config.StratumDifficulty,
 		}, notify, noverify)
 engine.SetThreads(-1) //禁用CPU挖掘
 		return engine
@@ -383,6 +412,9 @@ func (s *Ethereum) shouldPreserve(block *types.Block) bool {
 	if _, ok := s.engine.(*clique.Clique); ok {
 		return false
 	}
+	if _, ok := s.engine.(*ibft.Engine); ok {
+		return false
+	}
 	return s.isLocalBlock(block)
 }
 
@@ -432,6 +464,14 @@ threads = -1 //从内部禁用矿工
 			}
 			clique.Authorize(eb, wallet.SignHash)
 		}
+		if engine, ok := s.engine.(*ibft.Engine); ok {
+			wallet, err := s.accountManager.Find(accounts.Account{Address: eb})
+			if wallet == nil || err != nil {
+				log.Error("Etherbase account unavailable locally", "err", err)
+				return fmt.Errorf("validator missing: %v", err)
+			}
+			engine.Authorize(eb, wallet.SignHash)
+		}
 //如果开始挖掘，我们可以禁用事务拒绝机制。
 //介绍速度同步时间。
 		atomic.StoreUint32(&s.protocolManager.acceptTxs, 1)
@@ -472,10 +512,44 @@ func (s *Ethereum) Downloader() *downloader.Downloader { return s.protocolManage
 //协议实现node.service，返回所有当前配置的
 //要启动的网络协议。
 func (s *Ethereum) Protocols() []p2p.Protocol {
+	protos := s.protocolManager.SubProtocols
+	if engine, ok := s.engine.(*ibft.Engine); ok {
+		protos = append(protos, engine.Protocols()...)
+	}
 	if s.lesServer == nil {
-		return s.protocolManager.SubProtocols
+		return protos
+	}
+	return append(protos, s.lesServer.Protocols()...)
+}
+
+//verifyProposal在本地状态上执行IBFT提议块，以便验证者只接受有效的块。
+func (s *Ethereum) verifyProposal(block *types.Block) error {
+	if err := s.blockchain.Validator().ValidateBody(block); err != nil {
+		return err
+	}
+	parent := s.blockchain.GetBlock(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return consensus.ErrUnknownAncestor
+	}
+	statedb, err := s.blockchain.StateAt(parent.Root())
+	if err != nil {
+		return err
+	}
+	receipts, _, usedGas, err := s.blockchain.Processor().Process(block, statedb, *s.blockchain.GetVMConfig())
+	if err != nil {
+		return err
+	}
+	return s.blockchain.Validator().ValidateState(block, parent, statedb, receipts, usedGas)
+}
+
+//insertCommitted导入其他验证者提议的已最终确定的IBFT块，并像本地挖出的块一样
+//广播它，使提议者在提交后离线时其他节点也能收到。
+func (s *Ethereum) insertCommitted(block *types.Block) error {
+	if _, err := s.blockchain.InsertChain(types.Blocks{block}); err != nil {
+		return err
 	}
-	return append(s.protocolManager.SubProtocols, s.lesServer.Protocols()...)
+	s.eventMux.Post(core.NewMinedBlockEvent{Block: block})
+	return nil
 }
 
 //start实现node.service，启动
@@ -487,6 +561,9 @@ func (s *Ethereum) Start(srvr *p2p.Server) error {
 //启动RPC服务
 	s.netRPCService = ethapi.NewPublicNetAPI(srvr, s.NetVersion())
 
+//在节点记录中宣布并保持更新分叉标识
+	s.startEthEntryUpdate(srvr.LocalNode())
+
 //根据服务器限制计算最大对等数
 	maxPeers := srvr.MaxPeers
 	if s.config.LightServ > 0 {
@@ -33,11 +33,12 @@ import (
 var DefaultConfig = Config{
 	SyncMode: downloader.FastSync,
 	Ethash: ethash.Config{
-		CacheDir:       "ethash",
-		CachesInMem:    2,
-		CachesOnDisk:   3,
-		DatasetsInMem:  1,
-		DatasetsOnDisk: 2,
+		CacheDir:          "ethash",
+		CachesInMem:       2,
+		CachesOnDisk:      3,
+		DatasetsInMem:     1,
+		DatasetsOnDisk:    2,
+		StratumDifficulty: 1 << 32,
 	},
 	NetworkId:      1,
 	LightPeers:     100,
@@ -83,6 +84,14 @@ NetworkId uint64 //Network ID to use for selecting peers to connect to
 	SyncMode  downloader.SyncMode
 	NoPruning bool
 
+//SyncCheckpoint是受信任的近期块哈希。如果设置，完整和快速同步会从它向后下载头，
+//只验证哈希链，并在该块处下载状态，而不是从创世块开始同步。
+	SyncCheckpoint common.Hash `toml:",omitempty"`
+
+//BodyRetention是保留块主体和收据的最近块数，更早的在后台删除，只保留头。
+//零表示全部保留，修剪同步模式下默认为downloader.DefaultBodyRetention。
+	BodyRetention uint64 `toml:",omitempty"`
+
 //所需块号的白名单->要接受的哈希值
 	Whitelist map[uint64]common.Hash `toml:"-"`
 
@@ -107,6 +116,7 @@ LightPeers int `toml:",omitempty"` //最大LES客户端对等数
 	MinerGasPrice  *big.Int
 	MinerRecommit  time.Duration
 	MinerNoverify  bool
+	MinerOrdering  string `toml:",omitempty"`
 
 //乙烯利选项
 	Ethash ethash.Config
@@ -0,0 +1,227 @@
+
+//<developer>
+//    <name>linapex 曹一峰</name>
+//    <email>linapex@163.com</email>
+//    <wx>superexc</wx>
+//    <qqgroup>128148617</qqgroup>
+//    <url>https://jsq.ink</url>
+//    <role>pku engineer</role>
+//    <date>2019-03-16 19:16:37</date>
+//</624450103113682952>
+
+
+package downloader
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+//检查点同步从操作员提供的受信任头哈希开始。下载程序从检查点
+//向后下载头，直到与本地链相连，只验证哈希链，然后下载检查点
+//块的主体、收据和状态，并将其作为新的头块提交。之后常规同步从
+//检查点继续。检查点之前的块只有头，没有主体和收据。
+
+//SetCheckpoint设置检查点同步使用的受信任头哈希。空哈希禁用检查点同步。
+//必须在同步开始之前调用。
+func (d *Downloader) SetCheckpoint(hash common.Hash) {
+	d.checkpoint = hash
+}
+
+//syncCheckpoint将本地链同步到受信任检查点，如果检查点块已存在则不做任何事情。
+//总难度td低于检查点的对等端还没有检查点块，返回errCheckpointUnavailable，
+//该对等端暂时无用但不会被丢弃。
+func (d *Downloader) syncCheckpoint(p *peerConnection, td *big.Int) error {
+	if header := d.lightchain.GetHeaderByHash(d.checkpoint); header != nil {
+		number := header.Number.Uint64()
+		if d.blockchain.HasBlock(d.checkpoint, number) {
+			d.checkpointNumber = number
+			return nil
+		}
+		if ctd := d.lightchain.GetTd(d.checkpoint, number); ctd != nil && td != nil && td.Cmp(ctd) < 0 {
+			p.log.Debug("Peer below checkpoint", "td", td, "checkpoint", ctd)
+			return errCheckpointUnavailable
+		}
+	}
+	log.Info("Synchronising from trusted checkpoint", "hash", d.checkpoint, "peer", p.id)
+
+	header, err := d.fetchCheckpointHeaders(p)
+	if err != nil {
+		return err
+	}
+//检查点之前的块只有头，在导入检查点块之前设置编号
+	d.checkpointNumber = header.Number.Uint64()
+	return d.fetchCheckpointBlock(p, header)
+}
+
+//fetchCheckpointHeaders从检查点向后下载头，直到遇到本地链中已有的祖先，
+//然后按升序将它们插入链中。下载的头先写入数据库，只在内存中保留每批
+//的最高头，因此内存占用与链的长度无关。
+func (d *Downloader) fetchCheckpointHeaders(p *peerConnection) (*types.Header, error) {
+	var (
+tops   []*types.Header //每批下载的头中编号最高的头
+next   = d.checkpoint  //下一个期望的头哈希
+origin uint64          //本地链中已知的共同祖先的编号
+	)
+	for done := false; !done; {
+		go p.peer.RequestHeadersByHash(next, MaxHeaderFetch, 0, true)
+
+		packet, err := d.fetchCheckpointPacket(p, d.headerCh)
+		if err != nil {
+			return nil, err
+		}
+		headers := packet.(*headerPack).headers
+		if len(headers) == 0 {
+//对等端还不知道检查点本身时只是尚未同步到检查点，缺少更早的头则是坏对等端
+			if next == d.checkpoint {
+				return nil, errCheckpointUnavailable
+			}
+			return nil, errEmptyHeaderSet
+		}
+//只验证哈希链：每个头必须是上一个头的父头
+		batch := d.stateDB.NewBatch()
+		for i, header := range headers {
+			if header.Hash() != next || (i > 0 && header.Number.Uint64()+1 != headers[i-1].Number.Uint64()) {
+				p.log.Debug("Checkpoint header chain broken", "number", header.Number, "hash", header.Hash(), "want", next)
+				return nil, errInvalidChain
+			}
+			number := header.Number.Uint64()
+			if number == 0 {
+				p.log.Warn("Checkpoint chain has a different genesis", "hash", header.Hash())
+				return nil, errInvalidChain
+			}
+			rawdb.WriteHeader(batch, header)
+			next = header.ParentHash
+
+			if d.lightchain.GetTd(header.ParentHash, number-1) != nil {
+				headers, origin, done = headers[:i+1], number-1, true
+				break
+			}
+		}
+		if err := batch.Write(); err != nil {
+			return nil, err
+		}
+		tops = append(tops, headers[0])
+
+		if len(tops) == 1 {
+			d.syncStatsLock.Lock()
+			d.syncStatsChainHeight = headers[0].Number.Uint64()
+			d.syncStatsLock.Unlock()
+		}
+		p.log.Trace("Downloaded checkpoint headers", "count", len(headers), "from", headers[len(headers)-1].Number)
+	}
+	log.Info("Downloaded checkpoint header chain", "ancestor", origin, "number", tops[0].Number)
+
+//按升序逐批从数据库读回头并插入链中
+	for i := len(tops) - 1; i >= 0; i-- {
+		select {
+		case <-d.cancelCh:
+			return nil, errCancelHeaderProcessing
+		default:
+		}
+		from := origin + 1
+		if i < len(tops)-1 {
+			from = tops[i+1].Number.Uint64() + 1
+		}
+		chunk := make([]*types.Header, tops[i].Number.Uint64()-from+1)
+		chunk[len(chunk)-1] = tops[i]
+		for j := len(chunk) - 2; j >= 0; j-- {
+			if chunk[j] = rawdb.ReadHeader(d.stateDB, chunk[j+1].ParentHash, chunk[j+1].Number.Uint64()-1); chunk[j] == nil {
+				log.Error("Missing checkpoint header", "number", chunk[j+1].Number.Uint64()-1, "hash", chunk[j+1].ParentHash)
+				return nil, errInvalidChain
+			}
+		}
+		if n, err := d.blockchain.InsertTrustedHeaderChain(chunk); err != nil {
+			log.Debug("Checkpoint header import failed", "number", chunk[n].Number, "hash", chunk[n].Hash(), "err", err)
+			return nil, err
+		}
+	}
+	return tops[0], nil
+}
+
+//fetchCheckpointBlock下载检查点块的主体、收据和状态，并将其提交为头块。
+func (d *Downloader) fetchCheckpointBlock(p *peerConnection, header *types.Header) error {
+	hash := header.Hash()
+
+	go p.peer.RequestBodies([]common.Hash{hash})
+	packet, err := d.fetchCheckpointPacket(p, d.bodyCh)
+	if err != nil {
+		return err
+	}
+	bodies := packet.(*bodyPack)
+	if len(bodies.transactions) != 1 || len(bodies.uncles) != 1 ||
+		types.DeriveSha(types.Transactions(bodies.transactions[0])) != header.TxHash ||
+		types.CalcUncleHash(bodies.uncles[0]) != header.UncleHash {
+		p.log.Debug("Invalid checkpoint block body", "number", header.Number, "hash", hash)
+		return errBadPeer
+	}
+	go p.peer.RequestReceipts([]common.Hash{hash})
+	if packet, err = d.fetchCheckpointPacket(p, d.receiptCh); err != nil {
+		return err
+	}
+	receipts := packet.(*receiptPack).receipts
+	if len(receipts) != 1 || types.DeriveSha(types.Receipts(receipts[0])) != header.ReceiptHash {
+		p.log.Debug("Invalid checkpoint block receipts", "number", header.Number, "hash", hash)
+		return errBadPeer
+	}
+//下载检查点的状态，状态同步使用所有可用的对等端
+	log.Info("Downloading checkpoint state", "number", header.Number, "hash", hash, "root", header.Root)
+	stateSync := d.syncState(header.Root)
+	defer stateSync.Cancel()
+
+	select {
+	case <-stateSync.done:
+		if stateSync.err != nil {
+			return stateSync.err
+		}
+	case <-d.cancelCh:
+		return errCancelStateFetch
+	}
+	return d.commitPivotBlock(&fetchResult{
+		Header:       header,
+		Transactions: bodies.transactions[0],
+		Uncles:       bodies.uncles[0],
+		Receipts:     receipts[0],
+	})
+}
+
+//fetchCheckpointPacket等待源对等端对检查点请求的响应，即到达ch的第一个来自该对等端的数据包。
+//其他下载通道上的越界交付被丢弃。
+func (d *Downloader) fetchCheckpointPacket(p *peerConnection, ch chan dataPack) (dataPack, error) {
+	ttl := d.requestTTL()
+	timeout := time.After(ttl)
+	for {
+		var (
+			packet dataPack
+			wanted bool
+		)
+		select {
+		case <-d.cancelCh:
+			return nil, errCancelBlockFetch
+
+		case packet = <-d.headerCh:
+			wanted = ch == d.headerCh
+		case packet = <-d.bodyCh:
+			wanted = ch == d.bodyCh
+		case packet = <-d.receiptCh:
+			wanted = ch == d.receiptCh
+
+		case <-timeout:
+			p.log.Debug("Waiting for checkpoint data timed out", "elapsed", ttl)
+			return nil, errTimeout
+		}
+//丢弃源对等机以外的任何内容以及越界交付
+		if packet.PeerId() != p.id {
+			log.Debug("Received checkpoint data from incorrect peer", "peer", packet.PeerId())
+			continue
+		}
+		if wanted {
+			return packet, nil
+		}
+	}
+}
@@ -74,6 +74,7 @@ var (
 	errNoPeers                 = errors.New("no peers to keep download active")
 	errTimeout                 = errors.New("timeout")
 	errEmptyHeaderSet          = errors.New("empty header set by peer")
+	errCheckpointUnavailable   = errors.New("peer has not reached the checkpoint")
 	errPeersUnavailable        = errors.New("no peers available or all tried for download")
 	errInvalidAncestor         = errors.New("retrieved ancestor is invalid")
 	errInvalidChain            = errors.New("retrieved hash chain is invalid")
@@ -100,6 +101,14 @@ queue   *queue   //用于选择要下载的哈希的计划程序
 peers   *peerSet //可从中继续下载的活动对等点集
 	stateDB ethdb.Database
 
+checkpoint       common.Hash //检查点同步的受信任头哈希，为空表示禁用
+checkpointNumber uint64      //检查点块的编号，检查点同步完成之前为零
+bodyRetention    uint64      //修剪同步模式下下载主体和收据的最近块数
+
+snapPeers  map[string]SnapPeer //支持snap协议的对等点
+snapLock   sync.RWMutex        //保护snap对等点集合的锁
+snapSyncer *snapSync           //当前透视点的snap同步进度，透视点变化时重置
+
 rttEstimate   uint64 //目标下载请求的往返时间
 rttConfidence uint64 //估计RTT的置信度（单位：百万分之一允许原子操作）
 
@@ -133,6 +142,7 @@ headerProcCh  chan []*types.Header //[eth/62] Channel to feed the header process
 	stateSyncStart chan *stateSync
 	trackStateReq  chan *stateReq
 stateCh        chan dataPack //[ETH/63]接收入站节点状态数据的通道
+snapCh         chan dataPack //[snap/1]接收入站状态范围的通道
 
 //取消和终止
 cancelPeer string         //当前用作主机的对等机的标识符（删除时取消）
@@ -198,6 +208,15 @@ type BlockChain interface {
 
 //InsertReceiptChain将一批收据插入本地链。
 	InsertReceiptChain(types.Blocks, []types.Receipts) (int, error)
+
+//InsertTrustedHeaderChain将一批由受信任检查点担保的头插入本地链，不进行共识验证。
+	InsertTrustedHeaderChain([]*types.Header) (int, error)
+
+//BodyTail返回本地链仍保存主体的最低块号，更早的块已被修剪，只有头。
+	BodyTail() uint64
+
+//SetBodyTail记录编号低于给定值的块只同步了头。
+	SetBodyTail(uint64)
 }
 
 //新建创建一个新的下载程序，从远程对等端获取哈希和块。
@@ -225,6 +244,8 @@ func New(mode SyncMode, stateDb ethdb.Database, mux *event.TypeMux, chain BlockC
 		headerProcCh:   make(chan []*types.Header, 1),
 		quitCh:         make(chan struct{}),
 		stateCh:        make(chan dataPack),
+		snapCh:         make(chan dataPack),
+		snapPeers:      make(map[string]SnapPeer),
 		stateSyncStart: make(chan *stateSync),
 		syncStatsState: stateSyncStats{
 			processed: rawdb.ReadFastTrieProgress(stateDb),
@@ -236,6 +257,12 @@ func New(mode SyncMode, stateDb ethdb.Database, mux *event.TypeMux, chain BlockC
 	return dl
 }
 
+//SetBodyRetention设置修剪同步模式下下载主体和收据的最近块数。零表示使用
+//DefaultBodyRetention。必须在同步开始之前调用。
+func (d *Downloader) SetBodyRetention(n uint64) {
+	d.bodyRetention = n
+}
+
 //进程检索同步边界，特别是起源。
 //同步开始于的块（可能已失败/暂停）；块
 //或头同步当前位于；以及同步目标的最新已知块。
@@ -249,12 +276,12 @@ func (d *Downloader) Progress() ethereum.SyncProgress {
 	defer d.syncStatsLock.RUnlock()
 
 	current := uint64(0)
-	switch d.mode {
-	case FullSync:
+	switch {
+	case d.mode == FullSync:
 		current = d.blockchain.CurrentBlock().NumberU64()
-	case FastSync:
+	case d.mode.FetchesState():
 		current = d.blockchain.CurrentFastBlock().NumberU64()
-	case LightSync:
+	case d.mode == LightSync:
 		current = d.lightchain.CurrentHeader().Number.Uint64()
 	}
 	return ethereum.SyncProgress{
@@ -285,6 +312,32 @@ func (d *Downloader) RegisterPeer(id string, version int, peer Peer) error {
 	return nil
 }
 
+//RegisterSnapPeer注入一个支持snap协议的对等点，用于快照同步期间下载状态范围。
+func (d *Downloader) RegisterSnapPeer(id string, peer SnapPeer) error {
+	d.snapLock.Lock()
+	defer d.snapLock.Unlock()
+
+	if _, ok := d.snapPeers[id]; ok {
+		return errAlreadyRegistered
+	}
+	log.Trace("Registering snap sync peer", "peer", id)
+	d.snapPeers[id] = peer
+	return nil
+}
+
+//UnregisterSnapPeer从snap对等点集合中删除对等点。
+func (d *Downloader) UnregisterSnapPeer(id string) error {
+	d.snapLock.Lock()
+	defer d.snapLock.Unlock()
+
+	if _, ok := d.snapPeers[id]; !ok {
+		return errNotRegistered
+	}
+	log.Trace("Unregistering snap sync peer", "peer", id)
+	delete(d.snapPeers, id)
+	return nil
+}
+
 //Regiterlightpeer注入一个轻量级客户端对等端，将其包装起来，使其看起来像一个普通对等端。
 func (d *Downloader) RegisterLightPeer(id string, version int, peer LightPeer) error {
 	return d.RegisterPeer(id, version, &lightPeerWrapper{peer})
@@ -322,10 +375,21 @@ func (d *Downloader) Synchronise(id string, head common.Hash, td *big.Int, mode
 	case nil:
 	case errBusy:
 
+	case errCheckpointUnavailable:
+		log.Debug("Peer not yet synced to checkpoint", "peer", id)
+
 	case errTimeout, errBadPeer, errStallingPeer,
 		errEmptyHeaderSet, errPeersUnavailable, errTooOld,
 		errInvalidAncestor, errInvalidChain:
 		log.Warn("Synchronisation failed, dropping peer", "peer", id, "err", err)
+		if p := d.peers.Peer(id); p != nil {
+			switch err {
+			case errTimeout, errStallingPeer:
+				p.report(SyncTimeout)
+			case errInvalidAncestor, errInvalidChain:
+				p.report(SyncInvalidChain)
+			}
+		}
 		if d.dropPeer == nil {
 //当对本地副本使用“--copydb”时，droppeer方法为nil。
 //如果压缩在错误的时间命中，则可能发生超时，并且可以忽略。
@@ -423,6 +487,13 @@ func (d *Downloader) syncWithPeer(p *peerConnection, hash common.Hash, td *big.I
 		log.Debug("Synchronisation terminated", "elapsed", time.Since(start))
 	}(time.Now())
 
+//如果配置了检查点，先从检查点同步，然后从那里继续常规同步
+	if d.checkpoint != (common.Hash{}) && d.mode != LightSync {
+		if err := d.syncCheckpoint(p, td); err != nil {
+			return err
+		}
+	}
+
 //查找同步边界：共同祖先和目标块
 	latest, err := d.fetchHeight(p)
 	if err != nil {
@@ -443,7 +514,7 @@ func (d *Downloader) syncWithPeer(p *peerConnection, hash common.Hash, td *big.I
 
 //确保我们的原点在任何快速同步轴点之下
 	pivot := uint64(0)
-	if d.mode == FastSync {
+	if d.mode.FetchesState() {
 		if height <= uint64(fsMinFullBlocks) {
 			origin = 0
 		} else {
@@ -454,23 +525,41 @@ func (d *Downloader) syncWithPeer(p *peerConnection, hash common.Hash, td *big.I
 		}
 	}
 	d.committed = 1
-	if d.mode == FastSync && pivot != 0 {
+	if d.mode.FetchesState() && pivot != 0 {
 		d.committed = 0
 	}
+//修剪同步只下载保留窗口内块的主体和收据，窗口总是包含透视块
+	var (
+		bodyFrom = origin + 1
+bodyTail uint64 //跳过主体的块之后的第一个块，零表示没有跳过
+	)
+	if d.mode == PrunedSync {
+		retention := d.bodyRetention
+		if retention == 0 {
+			retention = DefaultBodyRetention
+		}
+		if retention <= uint64(fsMinFullBlocks) {
+			retention = uint64(fsMinFullBlocks) + 1
+		}
+		if height > retention && height-retention+1 > bodyFrom {
+			bodyFrom = height - retention + 1
+			bodyTail = bodyFrom
+		}
+	}
 //使用并发头和内容检索算法启动同步
-	d.queue.Prepare(origin+1, d.mode)
+	d.queue.Prepare(bodyFrom, d.mode)
 	if d.syncInitHook != nil {
 		d.syncInitHook(origin, height)
 	}
 
 	fetchers := []func() error{
 func() error { return d.fetchHeaders(p, origin+1, pivot) }, //始终检索邮件头
-func() error { return d.fetchBodies(origin + 1) },          //在正常和快速同步期间检索主体
-func() error { return d.fetchReceipts(origin + 1) },        //在快速同步过程中检索收据
-		func() error { return d.processHeaders(origin+1, pivot, td) },
+func() error { return d.fetchBodies(bodyFrom) },            //在正常和快速同步期间检索主体
+func() error { return d.fetchReceipts(bodyFrom) },          //在快速同步过程中检索收据
+		func() error { return d.processHeaders(origin+1, bodyFrom, pivot, td) },
 	}
-	if d.mode == FastSync {
-		fetchers = append(fetchers, func() error { return d.processFastSyncContent(latest) })
+	if d.mode.FetchesState() {
+		fetchers = append(fetchers, func() error { return d.processFastSyncContent(latest, bodyTail) })
 	} else if d.mode == FullSync {
 		fetchers = append(fetchers, d.processFullSyncContent)
 	}
@@ -651,10 +740,10 @@ func (d *Downloader) findAncestor(p *peerConnection, remoteHeader *types.Header)
 		localHeight  uint64
 		remoteHeight = remoteHeader.Number.Uint64()
 	)
-	switch d.mode {
-	case FullSync:
+	switch {
+	case d.mode == FullSync:
 		localHeight = d.blockchain.CurrentBlock().NumberU64()
-	case FastSync:
+	case d.mode.FetchesState():
 		localHeight = d.blockchain.CurrentFastBlock().NumberU64()
 	default:
 		localHeight = d.lightchain.CurrentHeader().Number.Uint64()
@@ -731,16 +820,7 @@ func (d *Downloader) findAncestor(p *peerConnection, remoteHeader *types.Header)
 				h := headers[i].Hash()
 				n := headers[i].Number.Uint64()
 
-				var known bool
-				switch d.mode {
-				case FullSync:
-					known = d.blockchain.HasBlock(h, n)
-				case FastSync:
-					known = d.blockchain.HasFastBlock(h, n)
-				default:
-					known = d.lightchain.HasHeader(h, n)
-				}
-				if known {
+				if d.hasAncestor(h, n) {
 					number, hash = n, h
 					break
 				}
@@ -804,16 +884,7 @@ func (d *Downloader) findAncestor(p *peerConnection, remoteHeader *types.Header)
 				h := headers[0].Hash()
 				n := headers[0].Number.Uint64()
 
-				var known bool
-				switch d.mode {
-				case FullSync:
-					known = d.blockchain.HasBlock(h, n)
-				case FastSync:
-					known = d.blockchain.HasFastBlock(h, n)
-				default:
-					known = d.lightchain.HasHeader(h, n)
-				}
-				if !known {
+				if !d.hasAncestor(h, n) {
 					end = check
 					break
 				}
@@ -844,6 +915,21 @@ header := d.lightchain.GetHeaderByHash(h) //独立于同步模式，头文件肯
 	return start, nil
 }
 
+//hasAncestor报告在当前同步模式下本地链中是否已有给定的块。检查点同步
+//或修剪主体之后，更早的块只有头，因此只检查头是否存在。
+func (d *Downloader) hasAncestor(hash common.Hash, number uint64) bool {
+	switch {
+	case d.mode == LightSync:
+		return d.lightchain.HasHeader(hash, number)
+	case number < d.checkpointNumber || number < d.blockchain.BodyTail():
+		return d.lightchain.HasHeader(hash, number)
+	case d.mode == FullSync:
+		return d.blockchain.HasBlock(hash, number)
+	default:
+		return d.blockchain.HasFastBlock(hash, number)
+	}
+}
+
 //FetchHeaders始终从数字中同时检索头
 //请求，直到不再返回，可能会在途中限制。到
 //方便并发，但仍能防止恶意节点发送错误
@@ -991,6 +1077,7 @@ timeout := time.NewTimer(0) //转储非响应活动对等机的计时器
 //头检索超时，考虑对等机错误并丢弃
 			p.log.Debug("Header request timed out", "elapsed", ttl)
 			headerTimeoutMeter.Mark(1)
+			p.report(SyncTimeout)
 			d.dropPeer(p.id)
 
 //但是，请优雅地完成同步，而不是转储收集的数据
@@ -1152,6 +1239,9 @@ func (d *Downloader) fetchParts(errCancel error, deliveryCh chan dataPack, deliv
 				if err != errStaleDelivery {
 					setIdle(peer, accepted)
 				}
+				if err == nil && accepted > 0 {
+					peer.report(SyncGoodDelivery)
+				}
 //向用户发布日志以查看发生了什么
 				switch {
 				case err == nil && packet.Items() == 0:
@@ -1204,8 +1294,10 @@ func (d *Downloader) fetchParts(errCancel error, deliveryCh chan dataPack, deliv
 					if fails > 2 {
 						peer.log.Trace("Data delivery timed out", "type", kind)
 						setIdle(peer, 0)
+						peer.report(SyncSlowDelivery)
 					} else {
 						peer.log.Debug("Stalling delivery, dropping", "type", kind)
+						peer.report(SyncTimeout)
 						if d.dropPeer == nil {
 //当对本地副本使用“--copydb”时，droppeer方法为nil。
 //如果压缩在错误的时间命中，则可能发生超时，并且可以忽略。
@@ -1282,7 +1374,7 @@ func (d *Downloader) fetchParts(errCancel error, deliveryCh chan dataPack, deliv
 //processHeaders从输入通道获取一批检索到的头，并且
 //继续处理并将它们调度到头链和下载程序中
 //排队直到流结束或发生故障。
-func (d *Downloader) processHeaders(origin uint64, pivot uint64, td *big.Int) error {
+func (d *Downloader) processHeaders(origin uint64, bodyFrom uint64, pivot uint64, td *big.Int) error {
 //保留不确定的头数以回滚
 	rollback := []*types.Header{}
 	defer func() {
@@ -1353,7 +1445,7 @@ func (d *Downloader) processHeaders(origin uint64, pivot uint64, td *big.Int) er
 //由于块可能仍然是
 //头下载完成后排队等待处理。但是，只要
 //同行给了我们一些有用的东西，我们已经很高兴/进步了（上面的检查）。
-				if d.mode == FastSync || d.mode == LightSync {
+				if d.mode.FetchesState() || d.mode == LightSync {
 					head := d.lightchain.CurrentHeader()
 					if td.Cmp(d.lightchain.GetTd(head.Hash(), head.Number.Uint64())) > 0 {
 						return errStallingPeer
@@ -1381,7 +1473,7 @@ func (d *Downloader) processHeaders(origin uint64, pivot uint64, td *big.Int) er
 				chunk := headers[:limit]
 
 //如果只同步头，请立即验证块。
-				if d.mode == FastSync || d.mode == LightSync {
+				if d.mode.FetchesState() || d.mode == LightSync {
 //收集尚未确定的邮件头，将其标记为不确定邮件头
 					unknown := make([]*types.Header, 0, len(headers))
 					for _, header := range chunk {
@@ -1409,7 +1501,7 @@ func (d *Downloader) processHeaders(origin uint64, pivot uint64, td *big.Int) er
 					}
 				}
 //除非我们在做轻链，否则请为相关的内容检索安排标题。
-				if d.mode == FullSync || d.mode == FastSync {
+				if d.mode == FullSync || d.mode.FetchesState() {
 //如果达到了允许的挂起头的数目，请暂停一点。
 					for d.queue.PendingBlocks() >= maxQueuedHeaders || d.queue.PendingReceipts() >= maxQueuedHeaders {
 						select {
@@ -1418,11 +1510,21 @@ func (d *Downloader) processHeaders(origin uint64, pivot uint64, td *big.Int) er
 						case <-time.After(time.Second):
 						}
 					}
-//否则插入标题进行内容检索
-					inserts := d.queue.Schedule(chunk, origin)
-					if len(inserts) != len(chunk) {
-						log.Debug("Stale headers")
-						return errBadPeer
+//否则插入标题进行内容检索，跳过主体不需要下载的块
+					scheduled, from := chunk, origin
+					if origin < bodyFrom {
+						skip := bodyFrom - origin
+						if skip > uint64(len(chunk)) {
+							skip = uint64(len(chunk))
+						}
+						scheduled, from = chunk[skip:], origin+skip
+					}
+					if len(scheduled) > 0 {
+						inserts := d.queue.Schedule(scheduled, from)
+						if len(inserts) != len(scheduled) {
+							log.Debug("Stale headers")
+							return errBadPeer
+						}
 					}
 				}
 				headers = headers[limit:]
@@ -1499,8 +1601,9 @@ func (d *Downloader) importBlockResults(results []*fetchResult) error {
 }
 
 //processFastSyncContent从队列获取结果并将其写入
-//数据库。它还控制枢轴块状态节点的同步。
-func (d *Downloader) processFastSyncContent(latest *types.Header) error {
+//数据库。它还控制枢轴块状态节点的同步。bodyTail非零时，提交透视块后
+//记录更早的块只有头。
+func (d *Downloader) processFastSyncContent(latest *types.Header, bodyTail uint64) error {
 //开始同步报告的头块的状态。这应该让我们
 //透视图块的状态。
 	stateSync := d.syncState(latest.Root)
@@ -1579,6 +1682,9 @@ d.queue.Close() //唤醒结果
 				if err := d.commitPivotBlock(P); err != nil {
 					return err
 				}
+				if bodyTail > 0 {
+					d.blockchain.SetBodyTail(bodyTail)
+				}
 				oldPivot = nil
 
 			case <-time.After(time.Second):
@@ -1651,6 +1757,9 @@ func (d *Downloader) commitPivotBlock(result *fetchResult) error {
 		return err
 	}
 	atomic.StoreInt32(&d.committed, 1)
+
+//状态已完整同步，不再需要保留snap同步进度
+	d.snapSyncer = nil
 	return nil
 }
 
@@ -1675,6 +1784,21 @@ func (d *Downloader) DeliverNodeData(id string, data [][]byte) (err error) {
 	return d.deliver(id, d.stateCh, &statePack{id, data}, stateInMeter, stateDropMeter)
 }
 
+//DeliverAccountRange注入从远程节点接收到的一段连续账户及其边界证明。
+func (d *Downloader) DeliverAccountRange(id string, reqID uint64, hashes []common.Hash, accounts [][]byte, proof [][]byte) (err error) {
+	return d.deliver(id, d.snapCh, &accountRangePack{id, reqID, hashes, accounts, proof}, snapInMeter, snapDropMeter)
+}
+
+//DeliverStorageRange注入从远程节点接收到的一段连续存储槽及其边界证明。
+func (d *Downloader) DeliverStorageRange(id string, reqID uint64, hashes []common.Hash, slots [][]byte, proof [][]byte) (err error) {
+	return d.deliver(id, d.snapCh, &storageRangePack{id, reqID, hashes, slots, proof}, snapInMeter, snapDropMeter)
+}
+
+//DeliverByteCodes注入从远程节点接收到的一批合约代码。
+func (d *Downloader) DeliverByteCodes(id string, reqID uint64, codes [][]byte) (err error) {
+	return d.deliver(id, d.snapCh, &byteCodesPack{id, reqID, codes}, snapInMeter, snapDropMeter)
+}
+
 //deliver注入从远程节点接收的新批数据。
 func (d *Downloader) deliver(id string, destCh chan dataPack, packet dataPack, inMeter, dropMeter metrics.Meter) (err error) {
 //更新好交付和失败交付的交付指标
@@ -51,6 +51,7 @@ ownHeaders  map[common.Hash]*types.Header  //属于检测仪的收割台
 ownBlocks   map[common.Hash]*types.Block   //属于测试仪的块
 ownReceipts map[common.Hash]types.Receipts //属于测试人员的收据
 ownChainTd  map[common.Hash]*big.Int       //本地链中块的总困难
+ownBodyTail uint64                         //编号低于此值的块只有头
 
 	lock sync.RWMutex
 }
@@ -224,6 +225,42 @@ func (dl *downloadTester) InsertHeaderChain(headers []*types.Header, checkFreq i
 	return len(headers), nil
 }
 
+//InsertTrustedHeaderChain将一批受信任的头注入到模拟链中，不检查头是否已知。
+func (dl *downloadTester) InsertTrustedHeaderChain(headers []*types.Header) (i int, err error) {
+	dl.lock.Lock()
+	defer dl.lock.Unlock()
+
+	for i, header := range headers {
+		if _, ok := dl.ownChainTd[header.ParentHash]; !ok {
+			return i, errors.New("unknown parent")
+		}
+		if _, ok := dl.ownHeaders[header.Hash()]; !ok {
+			dl.ownHashes = append(dl.ownHashes, header.Hash())
+			dl.ownHeaders[header.Hash()] = header
+		}
+		dl.ownChainTd[header.Hash()] = new(big.Int).Add(dl.ownChainTd[header.ParentHash], header.Difficulty)
+	}
+	return len(headers), nil
+}
+
+//BodyTail返回模拟链中最早保留主体的块号。
+func (dl *downloadTester) BodyTail() uint64 {
+	dl.lock.RLock()
+	defer dl.lock.RUnlock()
+
+	return dl.ownBodyTail
+}
+
+//SetBodyTail记录模拟链中编号低于number的块只有头。
+func (dl *downloadTester) SetBodyTail(number uint64) {
+	dl.lock.Lock()
+	defer dl.lock.Unlock()
+
+	if number > dl.ownBodyTail {
+		dl.ownBodyTail = number
+	}
+}
+
 //insertchain向模拟链中注入一批新的块。
 func (dl *downloadTester) InsertChain(blocks types.Blocks) (i int, err error) {
 	dl.lock.Lock()
@@ -256,8 +293,15 @@ func (dl *downloadTester) InsertReceiptChain(blocks types.Blocks, receipts []typ
 		if _, ok := dl.ownHeaders[blocks[i].Hash()]; !ok {
 			return i, errors.New("unknown owner")
 		}
+//检查点之前和修剪同步保留窗口之前的块只有头，其他父块必须有主体
 		if _, ok := dl.ownBlocks[blocks[i].ParentHash()]; !ok {
-			return i, errors.New("unknown parent")
+			parent := blocks[i].NumberU64() - 1
+			if parent >= dl.downloader.checkpointNumber && dl.downloader.mode != PrunedSync {
+				return i, errors.New("unknown parent")
+			}
+			if _, ok := dl.ownHeaders[blocks[i].ParentHash()]; !ok {
+				return i, errors.New("unknown parent")
+			}
 		}
 		dl.ownBlocks[blocks[i].Hash()] = blocks[i]
 		dl.ownReceipts[blocks[i].Hash()] = receipts[i]
@@ -319,11 +363,12 @@ func (dlp *downloadTesterPeer) Head() (common.Hash, *big.Int) {
 //源站；与下载测试仪中的特定对等点关联。归还的人
 //函数可用于从特定的对等端检索成批的头。
 func (dlp *downloadTesterPeer) RequestHeadersByHash(origin common.Hash, amount int, skip int, reverse bool) error {
+	var result []*types.Header
 	if reverse {
-		panic("reverse header requests not supported")
+		result = dlp.chain.headersByHashReverse(origin, amount, skip)
+	} else {
+		result = dlp.chain.headersByHash(origin, amount, skip)
 	}
-
-	result := dlp.chain.headersByHash(origin, amount, skip)
 	go dlp.dl.downloader.DeliverHeaders(dlp.id, result)
 	return nil
 }
@@ -1564,3 +1609,108 @@ func TestRemoteHeaderRequestSpan(t *testing.T) {
 	}
 }
 
+
+//测试检查点同步只下载检查点之前的头，在检查点处提交状态，然后继续
+//常规同步到链头。
+func TestCheckpointSync63Full(t *testing.T) { testCheckpointSync(t, 63, FullSync) }
+func TestCheckpointSync63Fast(t *testing.T) { testCheckpointSync(t, 63, FastSync) }
+func TestCheckpointSync64Full(t *testing.T) { testCheckpointSync(t, 64, FullSync) }
+func TestCheckpointSync64Fast(t *testing.T) { testCheckpointSync(t, 64, FastSync) }
+
+func testCheckpointSync(t *testing.T, protocol int, mode SyncMode) {
+	t.Parallel()
+
+	tester := newTester()
+	defer tester.terminate()
+
+	chain := testChainBase.shorten(blockCacheItems - 15)
+	checkpoint := 500
+	tester.downloader.SetCheckpoint(chain.chain[checkpoint])
+	tester.newPeer("peer", protocol, chain)
+
+	if err := tester.sync("peer", nil, mode); err != nil {
+		t.Fatalf("failed to synchronise blocks: %v", err)
+	}
+	if hs := len(tester.ownHeaders); hs != chain.len() {
+		t.Fatalf("synchronised headers mismatch: have %v, want %v", hs, chain.len())
+	}
+	if bs := len(tester.ownBlocks); bs != chain.len()-checkpoint+1 {
+		t.Fatalf("synchronised blocks mismatch: have %v, want %v", bs, chain.len()-checkpoint+1)
+	}
+	if rs := len(tester.ownReceipts); rs != chain.len()-checkpoint+1 {
+		t.Fatalf("synchronised receipts mismatch: have %v, want %v", rs, chain.len()-checkpoint+1)
+	}
+	if tester.GetBlockByHash(chain.chain[checkpoint-1]) != nil {
+		t.Fatalf("block before checkpoint downloaded")
+	}
+	for _, hash := range chain.chain {
+		if td := tester.GetTd(hash, 0); td == nil || td.Cmp(chain.td(hash)) != 0 {
+			t.Fatalf("total difficulty mismatch for %x: have %v, want %v", hash, td, chain.td(hash))
+		}
+	}
+}
+
+//测试还不知道检查点的对等端只是暂时无用，不会被丢弃。
+func TestCheckpointSyncUnknown(t *testing.T) {
+	t.Parallel()
+
+	tester := newTester()
+	defer tester.terminate()
+
+	chain := testChainBase.shorten(MaxHeaderFetch)
+	tester.downloader.SetCheckpoint(testChainForkLightA.chain[len(testChainForkLightA.chain)-1])
+	tester.newPeer("peer", 63, chain)
+
+	head := chain.headBlock().Hash()
+	if err := tester.downloader.Synchronise("peer", head, chain.td(head), FullSync); err != errCheckpointUnavailable {
+		t.Fatalf("sync error mismatch: have %v, want %v", err, errCheckpointUnavailable)
+	}
+	if tester.downloader.peers.Peer("peer") == nil {
+		t.Fatalf("peer below checkpoint dropped")
+	}
+	assertOwnChain(t, tester, 1)
+}
+
+//测试修剪同步下载所有头，但只下载最近保留窗口内的块主体和收据。
+func TestPrunedSync63(t *testing.T) { testPrunedSync(t, 63) }
+func TestPrunedSync64(t *testing.T) { testPrunedSync(t, 64) }
+
+func testPrunedSync(t *testing.T, protocol int) {
+	t.Parallel()
+
+	tester := newTester()
+	defer tester.terminate()
+
+	chain := testChainBase.shorten(blockCacheItems - 15)
+	retention := 200
+	tester.downloader.SetBodyRetention(uint64(retention))
+	tester.newPeer("peer", protocol, chain)
+
+	if err := tester.sync("peer", nil, PrunedSync); err != nil {
+		t.Fatalf("failed to synchronise blocks: %v", err)
+	}
+	if hs := len(tester.ownHeaders); hs != chain.len() {
+		t.Fatalf("synchronised headers mismatch: have %v, want %v", hs, chain.len())
+	}
+//保留窗口内的块加上创世块
+	if bs := len(tester.ownBlocks); bs != retention+1 {
+		t.Fatalf("synchronised blocks mismatch: have %v, want %v", bs, retention+1)
+	}
+	if rs := len(tester.ownReceipts); rs != retention+1 {
+		t.Fatalf("synchronised receipts mismatch: have %v, want %v", rs, retention+1)
+	}
+	if tester.GetBlockByHash(chain.chain[chain.len()-retention-1]) != nil {
+		t.Fatalf("block outside the retention window downloaded")
+	}
+	if tester.GetBlockByHash(chain.chain[chain.len()-retention]) == nil {
+		t.Fatalf("block inside the retention window missing")
+	}
+	if tail, want := tester.BodyTail(), uint64(chain.len()-retention); tail != want {
+		t.Fatalf("body tail mismatch: have %d, want %d", tail, want)
+	}
+	for _, hash := range chain.chain {
+		if td := tester.GetTd(hash, 0); td == nil || td.Cmp(chain.td(hash)) != 0 {
+			t.Fatalf("total difficulty mismatch for %x: have %v, want %v", hash, td, chain.td(hash))
+		}
+	}
+}
@@ -18,13 +18,23 @@ import "fmt"
 type SyncMode int
 
 const (
-FullSync  SyncMode = iota //从完整块同步整个区块链历史
-FastSync                  //快速下载邮件头，仅在链头完全同步
-LightSync                 //只下载邮件头，然后终止
+FullSync   SyncMode = iota //从完整块同步整个区块链历史
+FastSync                   //快速下载邮件头，仅在链头完全同步
+LightSync                  //只下载邮件头，然后终止
+SnapSync                   //与快速同步相同，但通过snap协议按范围下载状态，然后修复trie
+PrunedSync                 //与快速同步相同，但只下载最近块的主体和收据，更早的块只有头
 )
 
+//DefaultBodyRetention是修剪同步模式下默认保留主体和收据的最近块数。
+const DefaultBodyRetention = 90000
+
+//FetchesState返回该模式是否在透视点下载状态，而不是执行透视点之前的所有块。
+func (mode SyncMode) FetchesState() bool {
+	return mode == FastSync || mode == SnapSync || mode == PrunedSync
+}
+
 func (mode SyncMode) IsValid() bool {
-	return mode >= FullSync && mode <= LightSync
+	return mode >= FullSync && mode <= PrunedSync
 }
 
 //字符串实现字符串接口。
@@ -36,6 +46,10 @@ func (mode SyncMode) String() string {
 		return "fast"
 	case LightSync:
 		return "light"
+	case SnapSync:
+		return "snap"
+	case PrunedSync:
+		return "pruned"
 	default:
 		return "unknown"
 	}
@@ -49,6 +63,10 @@ func (mode SyncMode) MarshalText() ([]byte, error) {
 		return []byte("fast"), nil
 	case LightSync:
 		return []byte("light"), nil
+	case SnapSync:
+		return []byte("snap"), nil
+	case PrunedSync:
+		return []byte("pruned"), nil
 	default:
 		return nil, fmt.Errorf("unknown sync mode %d", mode)
 	}
@@ -62,8 +80,12 @@ func (mode *SyncMode) UnmarshalText(text []byte) error {
 		*mode = FastSync
 	case "light":
 		*mode = LightSync
+	case "snap":
+		*mode = SnapSync
+	case "pruned":
+		*mode = PrunedSync
 	default:
-		return fmt.Errorf(`unknown sync mode %q, want "full", "fast" or "light"`, text)
+		return fmt.Errorf(`unknown sync mode %q, want "full", "fast", "light", "snap" or "pruned"`, text)
 	}
 	return nil
 }
@@ -108,6 +108,23 @@ func (w *lightPeerWrapper) RequestNodeData([]common.Hash) error {
 	panic("RequestNodeData not supported in light client mode sync")
 }
 
+//SyncBehaviour是下载器在同步中观察到的对等端行为。
+type SyncBehaviour int
+
+const (
+SyncGoodDelivery SyncBehaviour = iota //对等端交付了有用的数据
+SyncSlowDelivery                      //对等端的多个请求元素过期
+SyncTimeout                           //对等端的请求超时或停滞
+SyncInvalidChain                      //对等端提供了无效的链
+)
+
+//ReputationPeer是对等端可以选择实现的接口。下载器通过它报告对等端
+//在同步中的表现，使对等端的信誉在重启后仍然有效，下载器不会总是选择
+//同样缓慢的对等端。
+type ReputationPeer interface {
+	ReportSync(SyncBehaviour)
+}
+
 //NexPeRead创建了一个新的下载器对等体。
 func newPeerConnection(id string, version int, peer Peer, logger log.Logger) *peerConnection {
 	return &peerConnection{
@@ -121,6 +138,13 @@ func newPeerConnection(id string, version int, peer Peer, logger log.Logger) *pe
 	}
 }
 
+//report在对等端支持信誉记录时报告它的行为。
+func (p *peerConnection) report(b SyncBehaviour) {
+	if rp, ok := p.peer.(ReputationPeer); ok {
+		rp.ReportSync(b)
+	}
+}
+
 //重置清除对等实体的内部状态。
 func (p *peerConnection) Reset() {
 	p.lock.Lock()
@@ -0,0 +1,582 @@
+
+//<developer>
+//    <name>linapex 曹一峰</name>
+//    <email>linapex@163.com</email>
+//    <wx>superexc</wx>
+//    <qqgroup>128148617</qqgroup>
+//    <url>https://jsq.ink</url>
+//    <role>pku engineer</role>
+//    <date>2019-03-16 19:16:37</date>
+//</624450088399278080>
+
+
+package downloader
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+const (
+snapAccountChunks   = 16              //账户哈希空间被拆分成的并发下载任务数
+snapResponseBytes   = 512 * 1024      //每个范围请求的软响应大小限制
+snapCodeBatch       = 64              //每个请求的最大合约代码数
+snapCommitThreshold = 16384           //将trie刷新到磁盘之前累积的最大条目数
+snapPackBuffer      = 16              //等待snap阶段处理的最大响应数
+snapLogInterval     = 8 * time.Second //记录同步进度的时间间隔
+)
+
+var (
+	errInvalidRange = errors.New("invalid state range")
+
+//emptyCode是没有代码的账户的代码哈希
+	emptyCode = crypto.Keccak256Hash(nil)
+
+//maxHash是哈希空间中的最后一个哈希
+	maxHash = common.HexToHash("0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff")
+)
+
+//SnapPeer封装了通过snap协议下载状态范围所需的方法。
+type SnapPeer interface {
+//RequestAccountRange请求给定状态根中从origin到limit的一段连续账户。
+	RequestAccountRange(id uint64, root common.Hash, origin common.Hash, limit common.Hash, bytes uint64) error
+
+//RequestStorageRange请求给定存储根中从origin到limit的一段连续存储槽。
+	RequestStorageRange(id uint64, root common.Hash, origin common.Hash, limit common.Hash, bytes uint64) error
+
+//RequestByteCodes按哈希请求一批合约代码。
+	RequestByteCodes(id uint64, hashes []common.Hash, bytes uint64) error
+}
+
+//accountTask是账户哈希空间中一段待下载的区间。
+type accountTask struct {
+next common.Hash //下一个要请求的账户哈希
+last common.Hash //区间中的最后一个账户哈希
+	busy bool
+	done bool
+}
+
+//storageTask是单个存储trie的下载任务。
+type storageTask struct {
+root        common.Hash //存储trie的根，同时也是请求的目标
+next        common.Hash //下一个要请求的槽哈希
+trie        *trie.Trie  //正在构建的存储trie
+	busy        bool
+	uncommitted int
+}
+
+//snapRequest是发送给单个对等点的一个进行中的snap请求。
+type snapRequest struct {
+	id      uint64
+	peer    string
+	account *accountTask
+	storage *storageTask
+	codes   []common.Hash
+	timer   *time.Timer
+}
+
+//snapSync通过snap协议按连续范围下载状态并在本地重建trie。
+//每个范围都通过其边界证明和全部条目重建trie并与状态根比较，因此只有完整的范围被导入。
+//未能下载的存储和代码只来自已验证的账户，在随后的trie修复阶段中由逐节点同步补全。
+type snapSync struct {
+	d      *Downloader
+	root   common.Hash
+	triedb *trie.Database
+
+	accountTrie  *trie.Trie
+	accountTasks []*accountTask
+	storageTasks []*storageTask
+	codeTasks    map[common.Hash]bool //待下载的代码（true=正在请求）
+
+healTries []common.Hash //需要逐节点修复的存储trie
+healCodes []common.Hash //需要逐节点下载的代码
+
+	requests    map[string]*snapRequest
+	stateless   map[string]struct{} //当前状态根不可用的对等点
+	timeout     chan *snapRequest
+	reqID       uint64
+	uncommitted int
+
+	accounts, slots, codes uint64 //统计已导入的条目
+	logged                 time.Time
+}
+
+//newSnapSync为给定的状态根创建snap同步器，将账户哈希空间拆分为多个并发任务。
+func newSnapSync(d *Downloader, root common.Hash) *snapSync {
+	triedb := trie.NewDatabase(d.stateDB)
+	accountTrie, _ := trie.New(common.Hash{}, triedb)
+
+	s := &snapSync{
+		d:           d,
+		root:        root,
+		triedb:      triedb,
+		accountTrie: accountTrie,
+		codeTasks:   make(map[common.Hash]bool),
+	}
+	step := new(big.Int).Div(new(big.Int).Add(maxHash.Big(), common.Big1), big.NewInt(snapAccountChunks))
+	next := common.Hash{}
+	for i := 1; i <= snapAccountChunks; i++ {
+		last := common.BigToHash(new(big.Int).Sub(new(big.Int).Mul(step, big.NewInt(int64(i))), common.Big1))
+		if i == snapAccountChunks {
+			last = maxHash
+		}
+		s.accountTasks = append(s.accountTasks, &accountTask{next: next, last: last})
+		next = incHash(last)
+	}
+	return s
+}
+
+//snap在逐节点同步之前运行snap阶段，之后将需要修复的部分加入trie同步调度程序。
+//透视点变化时同步进度被重置，以免修复从旧状态根下载的、新状态中不可达的trie。
+func (s *stateSync) snap() error {
+//如果状态已经存在，则无需下载
+	if ok, _ := s.d.stateDB.Has(s.root[:]); ok {
+		return nil
+	}
+	syncer := s.d.snapSyncer
+	if syncer == nil || syncer.root != s.root {
+		syncer = newSnapSync(s.d, s.root)
+		s.d.snapSyncer = syncer
+	}
+	if err := syncer.run(s.snapPacks, s.cancel); err != nil {
+		return err
+	}
+	s.sched = state.NewStateSync(s.root, s.d.stateDB)
+	for _, root := range syncer.healTries {
+		s.sched.AddSubTrie(root, 64, common.Hash{}, nil)
+	}
+	for _, hash := range syncer.healCodes {
+		s.sched.AddRawEntry(hash, 64, common.Hash{})
+	}
+	log.Info("State ranges downloaded, healing trie", "accounts", syncer.accounts, "slots", syncer.slots, "codes", syncer.codes, "pending", s.sched.Pending())
+	return nil
+}
+
+//run下载状态范围，直到所有任务完成、没有可用的对等点或同步被取消。
+func (s *snapSync) run(packs chan dataPack, cancel chan struct{}) error {
+	s.requests = make(map[string]*snapRequest)
+	s.stateless = make(map[string]struct{})
+	s.timeout = make(chan *snapRequest)
+
+	quit := make(chan struct{})
+	defer func() {
+		close(quit)
+		for _, req := range s.requests {
+			req.timer.Stop()
+			s.revert(req)
+		}
+		s.requests = nil
+	}()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for !s.finished() {
+		s.assignTasks(quit)
+		if len(s.requests) == 0 && !s.available() {
+			log.Warn("No snap peers available, healing remaining state")
+			break
+		}
+		select {
+		case pack := <-packs:
+			if err := s.process(pack); err != nil {
+				return err
+			}
+		case req := <-s.timeout:
+			if s.requests[req.peer] != req {
+				continue
+			}
+			log.Debug("State range request timed out", "peer", req.peer)
+			delete(s.requests, req.peer)
+			s.revert(req)
+			s.stateless[req.peer] = struct{}{}
+
+		case <-ticker.C:
+//检查是否有新的对等点可以分配任务
+
+		case <-cancel:
+			return errCancelStateFetch
+		case <-s.d.cancelCh:
+			return errCancelStateFetch
+		}
+	}
+	return s.finish()
+}
+
+//finished返回是否所有范围都已下载完毕。
+func (s *snapSync) finished() bool {
+	for _, task := range s.accountTasks {
+		if !task.done {
+			return false
+		}
+	}
+	return len(s.storageTasks) == 0 && len(s.codeTasks) == 0 && len(s.requests) == 0
+}
+
+//available返回是否还有对当前状态根有用的snap对等点。
+func (s *snapSync) available() bool {
+	s.d.snapLock.RLock()
+	defer s.d.snapLock.RUnlock()
+
+	for id := range s.d.snapPeers {
+		if _, ok := s.stateless[id]; !ok {
+			return true
+		}
+	}
+	return false
+}
+
+//assignTasks为每个空闲的snap对等点分配一个请求，优先下载存储和代码，以限制内存中未完成的数据。
+func (s *snapSync) assignTasks(quit chan struct{}) {
+	s.d.snapLock.RLock()
+	peers := make(map[string]SnapPeer, len(s.d.snapPeers))
+	for id, peer := range s.d.snapPeers {
+		peers[id] = peer
+	}
+	s.d.snapLock.RUnlock()
+
+	for id, peer := range peers {
+		if _, ok := s.requests[id]; ok {
+			continue
+		}
+		if _, ok := s.stateless[id]; ok {
+			continue
+		}
+		s.reqID++
+		req := &snapRequest{id: s.reqID, peer: id}
+
+		var err error
+		if task := s.nextStorageTask(); task != nil {
+			task.busy, req.storage = true, task
+			err = peer.RequestStorageRange(req.id, task.root, task.next, maxHash, snapResponseBytes)
+		} else if hashes := s.nextCodes(); len(hashes) > 0 {
+			req.codes = hashes
+			err = peer.RequestByteCodes(req.id, hashes, snapResponseBytes)
+		} else if task := s.nextAccountTask(); task != nil {
+			task.busy, req.account = true, task
+			err = peer.RequestAccountRange(req.id, s.root, task.next, task.last, snapResponseBytes)
+		} else {
+			return
+		}
+		if err != nil {
+			log.Debug("Failed to request state range", "peer", id, "err", err)
+			s.revert(req)
+			s.stateless[id] = struct{}{}
+			continue
+		}
+		req.timer = time.AfterFunc(s.d.requestTTL(), func() {
+			select {
+			case s.timeout <- req:
+			case <-quit:
+			}
+		})
+		s.requests[id] = req
+	}
+}
+
+func (s *snapSync) nextStorageTask() *storageTask {
+	for _, task := range s.storageTasks {
+		if !task.busy {
+			return task
+		}
+	}
+	return nil
+}
+
+func (s *snapSync) nextAccountTask() *accountTask {
+	for _, task := range s.accountTasks {
+		if !task.busy && !task.done {
+			return task
+		}
+	}
+	return nil
+}
+
+func (s *snapSync) nextCodes() []common.Hash {
+	var hashes []common.Hash
+	for hash, busy := range s.codeTasks {
+		if len(hashes) >= snapCodeBatch {
+			break
+		}
+		if !busy {
+			s.codeTasks[hash] = true
+			hashes = append(hashes, hash)
+		}
+	}
+	return hashes
+}
+
+//revert将未完成请求的任务放回队列。
+func (s *snapSync) revert(req *snapRequest) {
+	if req.account != nil {
+		req.account.busy = false
+	}
+	if req.storage != nil {
+		req.storage.busy = false
+	}
+	for _, hash := range req.codes {
+		if _, ok := s.codeTasks[hash]; ok {
+			s.codeTasks[hash] = false
+		}
+	}
+}
+
+//process处理一个snap响应，丢弃不属于当前请求的响应。
+func (s *snapSync) process(pack dataPack) error {
+	req := s.requests[pack.PeerId()]
+	if req == nil {
+		log.Debug("Unrequested state range", "peer", pack.PeerId(), "len", pack.Items())
+		return nil
+	}
+	var id uint64
+	switch pack := pack.(type) {
+	case *accountRangePack:
+		id = pack.id
+	case *storageRangePack:
+		id = pack.id
+	case *byteCodesPack:
+		id = pack.id
+	}
+	if id != req.id {
+		log.Debug("Stale state range", "peer", pack.PeerId(), "id", id, "want", req.id)
+		return nil
+	}
+	req.timer.Stop()
+	delete(s.requests, req.peer)
+
+	var err error
+	switch pack := pack.(type) {
+	case *accountRangePack:
+		if req.account == nil {
+			break
+		}
+		err = s.processAccounts(req, pack)
+	case *storageRangePack:
+		if req.storage == nil {
+			break
+		}
+		err = s.processStorage(req, pack)
+	case *byteCodesPack:
+		s.processCodes(req, pack)
+	}
+	if err != nil && err != errInvalidRange {
+		return err
+	}
+	if err == errInvalidRange {
+		log.Debug("Invalid state range, ignoring peer", "peer", req.peer)
+		s.stateless[req.peer] = struct{}{}
+	}
+	s.revert(req)
+	s.report(false)
+	return nil
+}
+
+//processAccounts将一段已验证的账户插入账户trie，并为其存储和代码创建下载任务。
+func (s *snapSync) processAccounts(req *snapRequest, pack *accountRangePack) error {
+	task := req.account
+
+//没有证明的空响应表示对等点没有这个状态
+	if len(pack.hashes) == 0 && len(pack.proof) == 0 {
+		return errInvalidRange
+	}
+	more, err := verifyRange(s.root, task.next, pack.hashes, pack.accounts, pack.proof)
+	if err != nil {
+		return errInvalidRange
+	}
+	accounts := make([]state.Account, len(pack.accounts))
+	for i, blob := range pack.accounts {
+		if err := rlp.DecodeBytes(blob, &accounts[i]); err != nil {
+			return errInvalidRange
+		}
+	}
+	for i, hash := range pack.hashes {
+		if bytes.Compare(hash[:], task.last[:]) > 0 {
+			break
+		}
+		if err := s.accountTrie.TryUpdate(hash[:], pack.accounts[i]); err != nil {
+			return err
+		}
+		s.accounts++
+		s.uncommitted++
+
+		if accounts[i].Root != types.EmptyRootHash {
+			storage, _ := trie.New(common.Hash{}, s.triedb)
+			s.storageTasks = append(s.storageTasks, &storageTask{root: accounts[i].Root, trie: storage})
+		}
+		if code := common.BytesToHash(accounts[i].CodeHash); code != emptyCode {
+			if ok, _ := s.d.stateDB.Has(code[:]); !ok {
+				if _, ok := s.codeTasks[code]; !ok {
+					s.codeTasks[code] = false
+				}
+			}
+		}
+	}
+	if !more || bytes.Compare(pack.hashes[len(pack.hashes)-1][:], task.last[:]) >= 0 {
+		task.done = true
+	} else {
+		task.next = incHash(pack.hashes[len(pack.hashes)-1])
+	}
+	if s.uncommitted >= snapCommitThreshold {
+		if _, err := s.commit(s.accountTrie); err != nil {
+			return err
+		}
+		s.uncommitted = 0
+	}
+	return nil
+}
+
+//processStorage将一段已验证的存储槽插入存储trie。没有证明的空响应表示对等点
+//不再有这个存储trie，此时将其交给修复阶段，而不惩罚对等点。
+func (s *snapSync) processStorage(req *snapRequest, pack *storageRangePack) error {
+	task := req.storage
+
+	if len(pack.hashes) == 0 && len(pack.proof) == 0 {
+		s.healTries = append(s.healTries, task.root)
+		s.removeStorageTask(task)
+		return nil
+	}
+	more, err := verifyRange(task.root, task.next, pack.hashes, pack.slots, pack.proof)
+	if err != nil {
+		return errInvalidRange
+	}
+	for i, hash := range pack.hashes {
+		if err := task.trie.TryUpdate(hash[:], pack.slots[i]); err != nil {
+			return err
+		}
+		s.slots++
+		task.uncommitted++
+	}
+	if more {
+		task.next = incHash(pack.hashes[len(pack.hashes)-1])
+		if task.uncommitted >= snapCommitThreshold {
+			if _, err := s.commit(task.trie); err != nil {
+				return err
+			}
+			task.uncommitted = 0
+		}
+		return nil
+	}
+//存储trie已完整下载，根不匹配时交给修复阶段
+	root, err := s.commit(task.trie)
+	if err != nil {
+		return err
+	}
+	if root != task.root {
+		log.Debug("Storage range inconsistent, scheduling heal", "root", task.root, "have", root)
+		s.healTries = append(s.healTries, task.root)
+	}
+	s.removeStorageTask(task)
+	return nil
+}
+
+func (s *snapSync) removeStorageTask(task *storageTask) {
+	for i, t := range s.storageTasks {
+		if t == task {
+			s.storageTasks = append(s.storageTasks[:i], s.storageTasks[i+1:]...)
+			return
+		}
+	}
+}
+
+//processCodes写入与请求的哈希匹配的代码。没有返回任何代码的对等点不再被使用。
+func (s *snapSync) processCodes(req *snapRequest, pack *byteCodesPack) {
+	requested := make(map[common.Hash]struct{}, len(req.codes))
+	for _, hash := range req.codes {
+		requested[hash] = struct{}{}
+	}
+	delivered := 0
+	for _, code := range pack.codes {
+		hash := crypto.Keccak256Hash(code)
+		if _, ok := requested[hash]; !ok {
+			continue
+		}
+		s.d.stateDB.Put(hash[:], code)
+		delete(s.codeTasks, hash)
+		s.codes++
+		delivered++
+	}
+	if delivered == 0 {
+		s.stateless[req.peer] = struct{}{}
+	}
+}
+
+//commit将trie提交到trie数据库并刷新到磁盘。
+func (s *snapSync) commit(t *trie.Trie) (common.Hash, error) {
+	root, err := t.Commit(nil)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if err := s.triedb.Commit(root, false); err != nil {
+		return common.Hash{}, err
+	}
+	return root, nil
+}
+
+//finish提交账户trie，并将未完成的存储和代码交给修复阶段。
+func (s *snapSync) finish() error {
+	for _, task := range s.storageTasks {
+		s.healTries = append(s.healTries, task.root)
+	}
+	s.storageTasks = nil
+
+	for hash := range s.codeTasks {
+		s.healCodes = append(s.healCodes, hash)
+	}
+	s.codeTasks = make(map[common.Hash]bool)
+
+	root, err := s.commit(s.accountTrie)
+	if err != nil {
+		return err
+	}
+	s.uncommitted = 0
+	if root != s.root {
+		log.Debug("State ranges inconsistent with root, healing", "root", s.root, "have", root)
+	}
+	s.report(true)
+	return nil
+}
+
+//report定期记录snap阶段的进度。
+func (s *snapSync) report(force bool) {
+	if !force && time.Since(s.logged) < snapLogInterval {
+		return
+	}
+	s.logged = time.Now()
+
+	done := 0
+	for _, task := range s.accountTasks {
+		if task.done {
+			done++
+		}
+	}
+	log.Info("Imported state ranges", "accounts", s.accounts, "slots", s.slots, "codes", s.codes, "chunks", done, "total", len(s.accountTasks), "storage", len(s.storageTasks))
+}
+
+//verifyRange检查范围响应是否正是状态根中从origin开始到最后一个条目为止的全部条目，
+//并返回之后是否还有更多条目。空响应必须证明origin之后没有任何条目。
+func verifyRange(root common.Hash, origin common.Hash, hashes []common.Hash, values [][]byte, proof [][]byte) (bool, error) {
+	proofdb := ethdb.NewMemDatabase()
+	for _, node := range proof {
+		proofdb.Put(crypto.Keccak256(node), node)
+	}
+	keys := make([][]byte, len(hashes))
+	for i, hash := range hashes {
+		keys[i] = common.CopyBytes(hash[:])
+	}
+	return trie.VerifyRangeProof(root, origin[:], keys, values, proofdb)
+}
+
+//incHash返回哈希空间中的下一个哈希。
+func incHash(h common.Hash) common.Hash {
+	return common.BigToHash(new(big.Int).Add(h.Big(), common.Big1))
+}
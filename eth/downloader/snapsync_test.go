@@ -0,0 +1,188 @@
+
+//<developer>
+//    <name>linapex 曹一峰</name>
+//    <email>linapex@163.com</email>
+//    <wx>superexc</wx>
+//    <qqgroup>128148617</qqgroup>
+//    <url>https://jsq.ink</url>
+//    <role>pku engineer</role>
+//    <date>2019-03-16 19:16:37</date>
+//</624450088399278081>
+
+
+package downloader
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+//snapTestPeer从本地状态数据库提供状态范围，每个响应最多包含几个条目，
+//以便测试分段下载。与真实的服务端一样，limit之后的第一个条目也被返回。
+type snapTestPeer struct {
+	id      string
+	db      ethdb.Database
+	triedb  *trie.Database
+	packs   chan dataPack
+missing bool //对等点没有任何状态
+gapped  bool //对等点在响应中遗漏范围中间的条目
+}
+
+func (p *snapTestPeer) serve(root common.Hash, origin common.Hash, limit common.Hash) ([]common.Hash, [][]byte, [][]byte) {
+	tr, err := trie.New(root, p.triedb)
+	if p.missing || err != nil {
+		return nil, nil, nil
+	}
+	var (
+		hashes []common.Hash
+		values [][]byte
+	)
+	it := trie.NewIterator(tr.NodeIterator(origin[:]))
+	for len(hashes) < 3 && it.Next() {
+		hash := common.BytesToHash(it.Key)
+		hashes = append(hashes, hash)
+		values = append(values, common.CopyBytes(it.Value))
+
+		if bytes.Compare(hash[:], limit[:]) >= 0 {
+			break
+		}
+	}
+	if p.gapped && len(hashes) == 3 {
+		hashes, values = append(hashes[:1], hashes[2]), append(values[:1], values[2])
+	}
+	proofdb := ethdb.NewMemDatabase()
+	tr.Prove(origin[:], 0, proofdb)
+	if len(hashes) > 0 {
+		tr.Prove(hashes[len(hashes)-1][:], 0, proofdb)
+	}
+	var proof [][]byte
+	for _, key := range proofdb.Keys() {
+		node, _ := proofdb.Get(key)
+		proof = append(proof, node)
+	}
+	return hashes, values, proof
+}
+
+func (p *snapTestPeer) RequestAccountRange(id uint64, root common.Hash, origin common.Hash, limit common.Hash, bytes uint64) error {
+	hashes, accounts, proof := p.serve(root, origin, limit)
+	p.packs <- &accountRangePack{peerID: p.id, id: id, hashes: hashes, accounts: accounts, proof: proof}
+	return nil
+}
+
+func (p *snapTestPeer) RequestStorageRange(id uint64, root common.Hash, origin common.Hash, limit common.Hash, bytes uint64) error {
+	hashes, slots, proof := p.serve(root, origin, limit)
+	p.packs <- &storageRangePack{peerID: p.id, id: id, hashes: hashes, slots: slots, proof: proof}
+	return nil
+}
+
+func (p *snapTestPeer) RequestByteCodes(id uint64, hashes []common.Hash, bytes uint64) error {
+	var codes [][]byte
+	if !p.missing {
+		for _, hash := range hashes {
+			if code, err := p.db.Get(hash[:]); err == nil {
+				codes = append(codes, code)
+			}
+		}
+	}
+	p.packs <- &byteCodesPack{peerID: p.id, id: id, codes: codes}
+	return nil
+}
+
+//测试snap同步从对等点下载完整状态，并忽略没有该状态的对等点。
+func TestSnapSync(t *testing.T) {
+//创建包含余额、存储和代码的源状态
+	srcdb := ethdb.NewMemDatabase()
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(srcdb))
+	for i := 0; i < 64; i++ {
+		addr := common.BigToAddress(big.NewInt(int64(i + 1)))
+		statedb.AddBalance(addr, big.NewInt(int64(i+1)))
+		if i%4 == 0 {
+			for j := 0; j < 10; j++ {
+				statedb.SetState(addr, common.BigToHash(big.NewInt(int64(j))), common.BigToHash(big.NewInt(int64(i*j+1))))
+			}
+		}
+		if i%8 == 0 {
+			statedb.SetCode(addr, []byte{byte(i), 0x60, 0x00})
+		}
+	}
+	root, err := statedb.Commit(false)
+	if err != nil {
+		t.Fatalf("failed to commit source state: %v", err)
+	}
+	if err := statedb.Database().TrieDB().Commit(root, false); err != nil {
+		t.Fatalf("failed to flush source state: %v", err)
+	}
+//没有状态的对等点被忽略，同步在没有可用对等点时停止
+	dstdb := ethdb.NewMemDatabase()
+	d := New(SnapSync, dstdb, new(event.TypeMux), nil, nil, nil)
+	defer d.Terminate()
+
+	packs := make(chan dataPack, 16)
+	srctrie := trie.NewDatabase(srcdb)
+	d.RegisterSnapPeer("empty", &snapTestPeer{id: "empty", db: srcdb, triedb: srctrie, packs: packs, missing: true})
+
+	syncer := newSnapSync(d, root)
+	if err := syncer.run(packs, make(chan struct{})); err != nil {
+		t.Fatalf("snap sync failed: %v", err)
+	}
+	if _, ok := syncer.stateless["empty"]; !ok {
+		t.Errorf("peer without state not marked stateless")
+	}
+	if syncer.accounts != 0 {
+		t.Errorf("imported accounts from peer without state: %d", syncer.accounts)
+	}
+//边界证明正确但遗漏条目的对等点也被忽略
+	d.UnregisterSnapPeer("empty")
+	d.RegisterSnapPeer("gapped", &snapTestPeer{id: "gapped", db: srcdb, triedb: srctrie, packs: packs, gapped: true})
+
+	if err := syncer.run(packs, make(chan struct{})); err != nil {
+		t.Fatalf("snap sync failed: %v", err)
+	}
+	if _, ok := syncer.stateless["gapped"]; !ok {
+		t.Errorf("peer with gapped ranges not marked stateless")
+	}
+//有状态的对等点完成同步，不需要任何修复
+	d.UnregisterSnapPeer("gapped")
+	d.RegisterSnapPeer("good", &snapTestPeer{id: "good", db: srcdb, triedb: srctrie, packs: packs})
+
+	if err := syncer.run(packs, make(chan struct{})); err != nil {
+		t.Fatalf("snap sync failed: %v", err)
+	}
+	if len(syncer.healTries) != 0 || len(syncer.healCodes) != 0 {
+		t.Errorf("unexpected heal tasks: tries %d, codes %d", len(syncer.healTries), len(syncer.healCodes))
+	}
+	if syncer.accounts != 64 {
+		t.Errorf("imported account count mismatch: have %d, want %d", syncer.accounts, 64)
+	}
+//重建的状态必须与源状态一致
+	synced, err := state.New(root, state.NewDatabase(dstdb))
+	if err != nil {
+		t.Fatalf("failed to open synced state: %v", err)
+	}
+	for i := 0; i < 64; i++ {
+		addr := common.BigToAddress(big.NewInt(int64(i + 1)))
+		if have, want := synced.GetBalance(addr), statedb.GetBalance(addr); have.Cmp(want) != 0 {
+			t.Errorf("account %d: balance mismatch: have %v, want %v", i, have, want)
+		}
+		if have, want := synced.GetCode(addr), statedb.GetCode(addr); !bytes.Equal(have, want) {
+			t.Errorf("account %d: code mismatch: have %x, want %x", i, have, want)
+		}
+		for j := 0; j < 10; j++ {
+			key := common.BigToHash(big.NewInt(int64(j)))
+			if have, want := synced.GetState(addr, key), statedb.GetState(addr, key); have != want {
+				t.Errorf("account %d slot %d: mismatch: have %x, want %x", i, j, have, want)
+			}
+		}
+	}
+	if ok, _ := dstdb.Has(crypto.Keccak256([]byte{0, 0x60, 0x00})); !ok {
+		t.Errorf("contract code missing from synced database")
+	}
+}
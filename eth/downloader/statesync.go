@@ -76,6 +76,8 @@ func (d *Downloader) stateFetcher() {
 			}
 		case <-d.stateCh:
 //不运行同步时忽略状态响应。
+		case <-d.snapCh:
+//不运行同步时忽略状态范围响应。
 		case <-d.quitCh:
 			return
 		}
@@ -148,6 +150,14 @@ timeout  = make(chan *stateReq)       //活动请求超时
 			finished = append(finished, req)
 			delete(active, pack.PeerId())
 
+//将状态范围响应转交给snap阶段，不在snap阶段时丢弃
+		case pack := <-d.snapCh:
+			select {
+			case s.snapPacks <- pack:
+			default:
+				log.Debug("Unrequested state range", "peer", pack.PeerId(), "len", pack.Items())
+			}
+
 //处理掉的对等连接：
 		case p := <-peerDrop:
 //Skip if no request is currently pending
@@ -208,7 +218,8 @@ timeout  = make(chan *stateReq)       //活动请求超时
 //stateSync schedules requests for downloading a particular state trie defined
 //通过给定的状态根。
 type stateSync struct {
-d *Downloader //用于访问和管理当前对等集的下载程序实例
+d    *Downloader //用于访问和管理当前对等集的下载程序实例
+root common.Hash //要同步的状态根
 
 sched  *trie.Sync                 //State trie sync scheduler defining the tasks
 keccak hash.Hash                  //KECCAK256哈希验证交付
@@ -218,6 +229,7 @@ tasks  map[common.Hash]*stateTask //当前排队等待检索的任务集
 	bytesUncommitted int
 
 deliver    chan *stateReq //传递通道多路复用对等响应
+snapPacks  chan dataPack  //snap阶段的状态范围响应
 cancel     chan struct{}  //发送终止请求信号的通道
 cancelOnce sync.Once      //确保Cancel只被调用一次
 done       chan struct{}  //通道到信号终止完成
@@ -234,13 +246,15 @@ type stateTask struct {
 //开始同步。用户需要调用run来启动。
 func newStateSync(d *Downloader, root common.Hash) *stateSync {
 	return &stateSync{
-		d:       d,
-		sched:   state.NewStateSync(root, d.stateDB),
-		keccak:  sha3.NewLegacyKeccak256(),
-		tasks:   make(map[common.Hash]*stateTask),
-		deliver: make(chan *stateReq),
-		cancel:  make(chan struct{}),
-		done:    make(chan struct{}),
+		d:         d,
+		root:      root,
+		sched:     state.NewStateSync(root, d.stateDB),
+		keccak:    sha3.NewLegacyKeccak256(),
+		tasks:     make(map[common.Hash]*stateTask),
+		deliver:   make(chan *stateReq),
+		snapPacks: make(chan dataPack, snapPackBuffer),
+		cancel:    make(chan struct{}),
+		done:      make(chan struct{}),
 	}
 }
 
@@ -248,7 +262,12 @@ func newStateSync(d *Downloader, root common.Hash) *stateSync {
 //它结束，并最终通知等待循环的任何Goroutines
 //完成。
 func (s *stateSync) run() {
-	s.err = s.loop()
+	if s.d.mode == SnapSync {
+		s.err = s.snap()
+	}
+	if s.err == nil {
+		s.err = s.loop()
+	}
 	close(s.done)
 }
 
@@ -172,6 +172,21 @@ func (tc *testChain) headersByHash(origin common.Hash, amount int, skip int) []*
 	return tc.headersByNumber(num, amount, skip)
 }
 
+//headersByHashReverse从给定哈希按降序返回头。
+func (tc *testChain) headersByHashReverse(origin common.Hash, amount int, skip int) []*types.Header {
+	num, ok := tc.hashToNumber(origin)
+	if !ok {
+		return nil
+	}
+	result := make([]*types.Header, 0, amount)
+	for n := int(num); n >= 0 && len(result) < amount; n -= skip + 1 {
+		if header, ok := tc.headerm[tc.chain[n]]; ok {
+			result = append(result, header)
+		}
+	}
+	return result
+}
+
 //HeadersByNumber从给定的数字以升序返回标题。
 func (tc *testChain) headersByNumber(origin uint64, amount int, skip int) []*types.Header {
 	result := make([]*types.Header, 0, amount)
@@ -15,6 +15,7 @@ package downloader
 import (
 	"fmt"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 )
 
@@ -74,3 +75,39 @@ func (p *statePack) PeerId() string { return p.peerID }
 func (p *statePack) Items() int     { return len(p.states) }
 func (p *statePack) Stats() string  { return fmt.Sprintf("%d", len(p.states)) }
 
+//accountRangePack是对等机通过snap协议返回的一段连续账户。
+type accountRangePack struct {
+	peerID   string
+	id       uint64
+	hashes   []common.Hash
+	accounts [][]byte
+	proof    [][]byte
+}
+
+func (p *accountRangePack) PeerId() string { return p.peerID }
+func (p *accountRangePack) Items() int     { return len(p.accounts) }
+func (p *accountRangePack) Stats() string  { return fmt.Sprintf("%d", len(p.accounts)) }
+
+//storageRangePack是对等机通过snap协议返回的一段连续存储槽。
+type storageRangePack struct {
+	peerID string
+	id     uint64
+	hashes []common.Hash
+	slots  [][]byte
+	proof  [][]byte
+}
+
+func (p *storageRangePack) PeerId() string { return p.peerID }
+func (p *storageRangePack) Items() int     { return len(p.slots) }
+func (p *storageRangePack) Stats() string  { return fmt.Sprintf("%d", len(p.slots)) }
+
+//byteCodesPack是对等机通过snap协议返回的一批合约代码。
+type byteCodesPack struct {
+	peerID string
+	id     uint64
+	codes  [][]byte
+}
+
+func (p *byteCodesPack) PeerId() string { return p.peerID }
+func (p *byteCodesPack) Items() int     { return len(p.codes) }
+func (p *byteCodesPack) Stats() string  { return fmt.Sprintf("%d", len(p.codes)) }
@@ -0,0 +1,71 @@
+
+//<developer>
+//    <name>linapex 曹一峰</name>
+//    <email>linapex@163.com</email>
+//    <wx>superexc</wx>
+//    <qqgroup>128148617</qqgroup>
+//    <url>https://jsq.ink</url>
+//    <role>pku engineer</role>
+//    <date>2019-03-16 19:16:37</date>
+//</624450088294420481>
+
+
+package eth
+
+import (
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/forkid"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+//ethEntry是节点记录中的“eth”条目，宣布节点所在链的分叉标识。
+type ethEntry struct {
+	ForkID forkid.ID
+
+//忽略额外的字段，以便向前兼容
+	Rest []rlp.RawValue `rlp:"tail"`
+}
+
+//ENRKey实现enr.Entry。
+func (e ethEntry) ENRKey() string {
+	return "eth"
+}
+
+//currentEthEntry为链的当前头构造“eth”条目。
+func currentEthEntry(chain forkid.Blockchain) *ethEntry {
+	return &ethEntry{ForkID: forkid.NewID(chain)}
+}
+
+//startEthEntryUpdate在每个新的链头之后更新本地节点记录中的“eth”条目，
+//使分叉标识在经过分叉块时保持最新。
+func (s *Ethereum) startEthEntryUpdate(ln *enode.LocalNode) {
+	newHead := make(chan core.ChainHeadEvent, 10)
+	sub := s.blockchain.SubscribeChainHeadEvent(newHead)
+
+	go func() {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case <-newHead:
+				ln.Set(currentEthEntry(s.blockchain))
+			case <-sub.Err():
+				return
+			case <-s.shutdownChan:
+				return
+			}
+		}
+	}()
+}
+
+//newNodeFilter创建一个拨号过滤器，跳过节点记录宣布了不兼容分叉标识的节点。
+//没有“eth”条目的节点（例如旧版本）不被过滤，由握手进一步检查。
+func newNodeFilter(forkFilter forkid.Filter) func(*enode.Node) bool {
+	return func(n *enode.Node) bool {
+		var entry ethEntry
+		if err := n.Load(&entry); err != nil {
+			return true
+		}
+		return forkFilter(entry.ForkID) == nil
+	}
+}
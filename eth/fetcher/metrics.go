@@ -36,5 +36,14 @@ var (
 	headerFilterOutMeter = metrics.NewRegisteredMeter("eth/fetcher/filter/headers/out", nil)
 	bodyFilterInMeter    = metrics.NewRegisteredMeter("eth/fetcher/filter/bodies/in", nil)
 	bodyFilterOutMeter   = metrics.NewRegisteredMeter("eth/fetcher/filter/bodies/out", nil)
+
+	txAnnounceInMeter    = metrics.NewRegisteredMeter("eth/fetcher/tx/announces/in", nil)
+	txAnnounceKnownMeter = metrics.NewRegisteredMeter("eth/fetcher/tx/announces/known", nil)
+	txAnnounceDOSMeter   = metrics.NewRegisteredMeter("eth/fetcher/tx/announces/dos", nil)
+
+	txBroadcastInMeter  = metrics.NewRegisteredMeter("eth/fetcher/tx/broadcasts/in", nil)
+	txReplyInMeter      = metrics.NewRegisteredMeter("eth/fetcher/tx/replies/in", nil)
+	txFetchMeter        = metrics.NewRegisteredMeter("eth/fetcher/tx/fetch", nil)
+	txFetchTimeoutMeter = metrics.NewRegisteredMeter("eth/fetcher/tx/fetch/timeout", nil)
 )
 
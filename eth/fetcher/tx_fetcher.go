@@ -0,0 +1,338 @@
+
+//<developer>
+//    <name>linapex 曹一峰</name>
+//    <email>linapex@163.com</email>
+//    <wx>superexc</wx>
+//    <qqgroup>128148617</qqgroup>
+//    <url>https://jsq.ink</url>
+//    <role>pku engineer</role>
+//    <date>2019-03-16 19:16:37</date>
+//</624450087149375489>
+
+
+package fetcher
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const (
+txArriveTimeout = 500 * time.Millisecond //请求公告交易之前等待其广播到达的时间
+txFetchTimeout  = 5 * time.Second        //返回显式请求交易的最长分配时间
+maxTxAnnounces  = 4096                   //对等点可能已宣布且尚未交付的交易的最大数目
+maxTxRetrievals = 256                    //单个请求中检索的最大交易数
+)
+
+//txHasFn是用于检查交易是否已在本地池中的回调类型。
+type txHasFn func(common.Hash) bool
+
+//txAddFn是用于将一批交易加入本地池的回调类型。
+type txAddFn func([]*types.Transaction) []error
+
+//txRequesterFn是用于向对等点发送交易检索请求的回调类型。
+type txRequesterFn func(peer string, hashes []common.Hash) error
+
+//txAnnounce是一批交易哈希通知。
+type txAnnounce struct {
+	origin string
+	hashes []common.Hash
+}
+
+//txDelivery是一批已到达的交易，可能是广播也可能是请求的答复。
+type txDelivery struct {
+	origin string
+	hashes []common.Hash
+	direct bool
+}
+
+//txRequest是发送给单个对等点的一个进行中的交易检索请求。
+type txRequest struct {
+	hashes []common.Hash
+	time   time.Time
+}
+
+//TxFetcher负责收集不同对等点的交易通知并安排检索。
+//每个哈希先等待一小段时间，以便完整交易通过广播到达，之后只从一个
+//宣布者请求；超时或断开的对等点的请求转给其他宣布者。
+type TxFetcher struct {
+	notify  chan *txAnnounce
+	cleanup chan *txDelivery
+	drop    chan string
+	quit    chan struct{}
+
+waitlist  map[common.Hash]time.Time           //等待广播到达的哈希及其首次公告时间
+announces map[string]map[common.Hash]struct{} //每个对等点宣布且尚未交付的哈希
+announced map[common.Hash]map[string]struct{} //每个哈希的宣布者
+fetching  map[common.Hash]string              //正在检索的哈希及其被请求的对等点
+requests  map[string]*txRequest               //每个对等点进行中的请求
+
+hasTx    txHasFn       //检查交易是否已知
+addTxs   txAddFn       //将交易加入交易池
+fetchTxs txRequesterFn //从对等点检索交易
+
+//测试钩
+fetchingHook func(string, []common.Hash) //发起交易检索时调用的方法
+}
+
+//NewTxFetcher创建一个交易获取器，以基于哈希通知检索交易。
+func NewTxFetcher(hasTx txHasFn, addTxs txAddFn, fetchTxs txRequesterFn) *TxFetcher {
+	return &TxFetcher{
+		notify:    make(chan *txAnnounce),
+		cleanup:   make(chan *txDelivery),
+		drop:      make(chan string),
+		quit:      make(chan struct{}),
+		waitlist:  make(map[common.Hash]time.Time),
+		announces: make(map[string]map[common.Hash]struct{}),
+		announced: make(map[common.Hash]map[string]struct{}),
+		fetching:  make(map[common.Hash]string),
+		requests:  make(map[string]*txRequest),
+		hasTx:     hasTx,
+		addTxs:    addTxs,
+		fetchTxs:  fetchTxs,
+	}
+}
+
+//Start启动交易获取器，处理通知和交付，直到请求终止。
+func (f *TxFetcher) Start() {
+	go f.loop()
+}
+
+//Stop终止交易获取器，取消所有挂起的操作。
+func (f *TxFetcher) Stop() {
+	close(f.quit)
+}
+
+//Notify通知获取器网络中新交易的潜在可用性。
+func (f *TxFetcher) Notify(peer string, hashes []common.Hash) error {
+	txAnnounceInMeter.Mark(int64(len(hashes)))
+
+//过滤掉已经在池中的交易，无需在事件循环中处理
+	unknown := make([]common.Hash, 0, len(hashes))
+	for _, hash := range hashes {
+		if !f.hasTx(hash) {
+			unknown = append(unknown, hash)
+		}
+	}
+	txAnnounceKnownMeter.Mark(int64(len(hashes) - len(unknown)))
+	if len(unknown) == 0 {
+		return nil
+	}
+	select {
+	case f.notify <- &txAnnounce{origin: peer, hashes: unknown}:
+		return nil
+	case <-f.quit:
+		return errTerminated
+	}
+}
+
+//Enqueue将广播或请求返回的交易导入交易池，并停止跟踪它们的通知。
+//返回交易池对每个交易的导入结果。
+func (f *TxFetcher) Enqueue(peer string, txs []*types.Transaction, direct bool) []error {
+	if direct {
+		txReplyInMeter.Mark(int64(len(txs)))
+	} else {
+		txBroadcastInMeter.Mark(int64(len(txs)))
+	}
+	errs := f.addTxs(txs)
+
+	hashes := make([]common.Hash, len(txs))
+	for i, tx := range txs {
+		hashes[i] = tx.Hash()
+	}
+	select {
+	case f.cleanup <- &txDelivery{origin: peer, hashes: hashes, direct: direct}:
+	case <-f.quit:
+	}
+	return errs
+}
+
+//Drop删除对等点的所有通知，并将其进行中的请求转给其他宣布者。
+func (f *TxFetcher) Drop(peer string) error {
+	select {
+	case f.drop <- peer:
+		return nil
+	case <-f.quit:
+		return errTerminated
+	}
+}
+
+//loop是交易获取器的主事件循环。
+func (f *TxFetcher) loop() {
+	timer := time.NewTimer(0)
+	<-timer.C
+	defer timer.Stop()
+
+	for {
+		select {
+		case ann := <-f.notify:
+			now := time.Now()
+			for i, hash := range ann.hashes {
+//超过未完成通知限制的哈希被丢弃，以防内存耗尽
+				if len(f.announces[ann.origin]) >= maxTxAnnounces {
+					txAnnounceDOSMeter.Mark(int64(len(ann.hashes) - i))
+					log.Debug("Peer exceeded outstanding announces", "peer", ann.origin, "limit", maxTxAnnounces)
+					break
+				}
+				if _, ok := f.announced[hash]; !ok {
+					f.announced[hash] = make(map[string]struct{})
+					f.waitlist[hash] = now
+				}
+				f.announced[hash][ann.origin] = struct{}{}
+
+				if f.announces[ann.origin] == nil {
+					f.announces[ann.origin] = make(map[common.Hash]struct{})
+				}
+				f.announces[ann.origin][hash] = struct{}{}
+			}
+
+		case delivery := <-f.cleanup:
+			for _, hash := range delivery.hashes {
+				f.forgetHash(hash)
+			}
+//请求的答复结束了该请求，未返回的交易对等点没有，转给其他宣布者
+			if req := f.requests[delivery.origin]; delivery.direct && req != nil {
+				delete(f.requests, delivery.origin)
+				for _, hash := range req.hashes {
+					if f.fetching[hash] == delivery.origin {
+						delete(f.fetching, hash)
+						f.forgetAnnounce(delivery.origin, hash)
+					}
+				}
+			}
+
+		case peer := <-f.drop:
+			if req := f.requests[peer]; req != nil {
+				delete(f.requests, peer)
+				for _, hash := range req.hashes {
+					if f.fetching[hash] == peer {
+						delete(f.fetching, hash)
+					}
+				}
+			}
+			for hash := range f.announces[peer] {
+				f.forgetAnnounce(peer, hash)
+			}
+
+		case <-timer.C:
+			now := time.Now()
+			for hash, arrived := range f.waitlist {
+				if now.Sub(arrived) >= txArriveTimeout {
+					delete(f.waitlist, hash)
+				}
+			}
+			for peer, req := range f.requests {
+				if now.Sub(req.time) < txFetchTimeout {
+					continue
+				}
+//对等点没有及时答复，不再从它检索这些交易
+				txFetchTimeoutMeter.Mark(int64(len(req.hashes)))
+				delete(f.requests, peer)
+				for _, hash := range req.hashes {
+					if f.fetching[hash] == peer {
+						delete(f.fetching, hash)
+						f.forgetAnnounce(peer, hash)
+					}
+				}
+			}
+
+		case <-f.quit:
+			return
+		}
+		f.schedule()
+		f.rescheduleTimer(timer)
+	}
+}
+
+//schedule为每个空闲的对等点分配其已宣布、等待期已过且未在检索中的交易。
+func (f *TxFetcher) schedule() {
+	for peer, hashes := range f.announces {
+		if _, ok := f.requests[peer]; ok {
+			continue
+		}
+		var request []common.Hash
+		for hash := range hashes {
+			if len(request) >= maxTxRetrievals {
+				break
+			}
+			if _, ok := f.waitlist[hash]; ok {
+				continue
+			}
+			if _, ok := f.fetching[hash]; ok {
+				continue
+			}
+			request = append(request, hash)
+		}
+		if len(request) == 0 {
+			continue
+		}
+		for _, hash := range request {
+			f.fetching[hash] = peer
+		}
+		f.requests[peer] = &txRequest{hashes: request, time: time.Now()}
+		txFetchMeter.Mark(int64(len(request)))
+
+		if f.fetchingHook != nil {
+			f.fetchingHook(peer, request)
+		}
+		go func(peer string, hashes []common.Hash) {
+			if err := f.fetchTxs(peer, hashes); err != nil {
+				log.Debug("Failed to request transactions", "peer", peer, "err", err)
+			}
+		}(peer, request)
+	}
+}
+
+//rescheduleTimer将定时器重置为下一个等待期结束或请求超时的时间。
+func (f *TxFetcher) rescheduleTimer(timer *time.Timer) {
+	var earliest time.Time
+	for _, arrived := range f.waitlist {
+		if deadline := arrived.Add(txArriveTimeout); earliest.IsZero() || deadline.Before(earliest) {
+			earliest = deadline
+		}
+	}
+	for _, req := range f.requests {
+		if deadline := req.time.Add(txFetchTimeout); earliest.IsZero() || deadline.Before(earliest) {
+			earliest = deadline
+		}
+	}
+	if earliest.IsZero() {
+		return
+	}
+	if !timer.Stop() {
+		select {
+		case <-timer.C:
+		default:
+		}
+	}
+	timer.Reset(time.Until(earliest))
+}
+
+//forgetHash停止跟踪已到达的交易的所有通知。
+func (f *TxFetcher) forgetHash(hash common.Hash) {
+	for peer := range f.announced[hash] {
+		delete(f.announces[peer], hash)
+		if len(f.announces[peer]) == 0 {
+			delete(f.announces, peer)
+		}
+	}
+	delete(f.announced, hash)
+	delete(f.waitlist, hash)
+	delete(f.fetching, hash)
+}
+
+//forgetAnnounce删除单个对等点对交易的通知，如果没有其他宣布者则停止跟踪该交易。
+func (f *TxFetcher) forgetAnnounce(peer string, hash common.Hash) {
+	delete(f.announces[peer], hash)
+	if len(f.announces[peer]) == 0 {
+		delete(f.announces, peer)
+	}
+	delete(f.announced[hash], peer)
+	if len(f.announced[hash]) == 0 {
+		delete(f.announced, hash)
+		delete(f.waitlist, hash)
+	}
+}
@@ -0,0 +1,146 @@
+
+//<developer>
+//    <name>linapex 曹一峰</name>
+//    <email>linapex@163.com</email>
+//    <wx>superexc</wx>
+//    <qqgroup>128148617</qqgroup>
+//    <url>https://jsq.ink</url>
+//    <role>pku engineer</role>
+//    <date>2019-03-16 19:16:37</date>
+//</624450087149375490>
+
+
+package fetcher
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+//txFetchRequest是测试获取器发出的一个交易检索请求。
+type txFetchRequest struct {
+	peer   string
+	hashes []common.Hash
+}
+
+//txFetcherTester是模拟交易池和对等点的测试模拟器。
+type txFetcherTester struct {
+	fetcher  *TxFetcher
+	requests chan *txFetchRequest
+}
+
+func newTxFetcherTester() *txFetcherTester {
+	tester := &txFetcherTester{requests: make(chan *txFetchRequest, 16)}
+	tester.fetcher = NewTxFetcher(
+		func(common.Hash) bool { return false },
+		func(txs []*types.Transaction) []error { return make([]error, len(txs)) },
+		func(peer string, hashes []common.Hash) error {
+			tester.requests <- &txFetchRequest{peer: peer, hashes: hashes}
+			return nil
+		},
+	)
+	tester.fetcher.Start()
+	return tester
+}
+
+//expectRequest等待下一个检索请求并返回它。
+func (tester *txFetcherTester) expectRequest(t *testing.T) *txFetchRequest {
+	select {
+	case req := <-tester.requests:
+		return req
+	case <-time.After(txArriveTimeout + time.Second):
+		t.Fatalf("transaction retrieval not requested")
+	}
+	return nil
+}
+
+//expectNoRequest验证在等待期内没有发出检索请求。
+func (tester *txFetcherTester) expectNoRequest(t *testing.T) {
+	select {
+	case req := <-tester.requests:
+		t.Fatalf("unexpected retrieval from %s: %x", req.peer, req.hashes)
+	case <-time.After(txArriveTimeout + 200*time.Millisecond):
+	}
+}
+
+func newTestTxs(n int) []*types.Transaction {
+	txs := make([]*types.Transaction, n)
+	for i := range txs {
+		txs[i] = types.NewTransaction(uint64(i), common.Address{}, big.NewInt(0), 21000, big.NewInt(1), nil)
+	}
+	return txs
+}
+
+func txHashes(txs []*types.Transaction) []common.Hash {
+	hashes := make([]common.Hash, len(txs))
+	for i, tx := range txs {
+		hashes[i] = tx.Hash()
+	}
+	return hashes
+}
+
+//测试在等待期内通过广播到达的交易不会被检索。
+func TestTxFetcherWaitsForBroadcast(t *testing.T) {
+	tester := newTxFetcherTester()
+	defer tester.fetcher.Stop()
+
+	txs := newTestTxs(2)
+	tester.fetcher.Notify("A", txHashes(txs))
+	tester.fetcher.Enqueue("B", txs, false)
+
+	tester.expectNoRequest(t)
+}
+
+//测试多个对等点宣布的交易只被检索一次，答复后不再请求。
+func TestTxFetcherDeduplication(t *testing.T) {
+	tester := newTxFetcherTester()
+	defer tester.fetcher.Stop()
+
+	txs := newTestTxs(3)
+	tester.fetcher.Notify("A", txHashes(txs))
+	tester.fetcher.Notify("B", txHashes(txs))
+
+	req := tester.expectRequest(t)
+	if len(req.hashes) != len(txs) {
+		t.Fatalf("requested hash count mismatch: have %d, want %d", len(req.hashes), len(txs))
+	}
+	tester.fetcher.Enqueue(req.peer, txs, true)
+	tester.expectNoRequest(t)
+}
+
+//测试断开的对等点的请求以及对等点未返回的交易转给其他宣布者。
+func TestTxFetcherRescheduling(t *testing.T) {
+	tester := newTxFetcherTester()
+	defer tester.fetcher.Stop()
+
+	txs := newTestTxs(2)
+	tester.fetcher.Notify("A", txHashes(txs))
+	tester.fetcher.Notify("B", txHashes(txs))
+	tester.fetcher.Notify("C", txHashes(txs))
+
+//第一个对等点断开，其请求必须转给另一个宣布者
+	first := tester.expectRequest(t)
+	tester.fetcher.Drop(first.peer)
+
+	second := tester.expectRequest(t)
+	if second.peer == first.peer {
+		t.Fatalf("retrieval rescheduled to dropped peer %s", first.peer)
+	}
+	if len(second.hashes) != len(txs) {
+		t.Fatalf("rescheduled hash count mismatch: have %d, want %d", len(second.hashes), len(txs))
+	}
+//第二个对等点只返回一个交易，另一个必须从剩下的宣布者检索
+	tester.fetcher.Enqueue(second.peer, txs[:1], true)
+
+	third := tester.expectRequest(t)
+	if third.peer == first.peer || third.peer == second.peer {
+		t.Fatalf("retrieval rescheduled to used peer %s", third.peer)
+	}
+	if len(third.hashes) != 1 || third.hashes[0] != txs[1].Hash() {
+		t.Fatalf("rescheduled hashes mismatch: have %x, want %x", third.hashes, txs[1].Hash())
+	}
+}
@@ -26,6 +26,7 @@ import (
 	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/consensus/misc"
 	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/forkid"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/eth/downloader"
 	"github.com/ethereum/go-ethereum/eth/fetcher"
@@ -34,6 +35,7 @@ import (
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rlp"
 )
@@ -65,17 +67,22 @@ func errResp(code errCode, format string, v ...interface{}) error {
 type ProtocolManager struct {
 	networkID uint64
 
-fastSync  uint32 //标记是否启用快速同步（如果已经有块，则禁用）
-acceptTxs uint32 //标记是否被认为是同步的（启用事务处理）
+fastSync   uint32 //标记是否启用快速同步（如果已经有块，则禁用）
+snapSync   uint32 //标记快速同步是否通过snap协议按范围下载状态
+prunedSync uint32 //标记快速同步是否只下载最近块的主体和收据
+acceptTxs  uint32 //标记是否被认为是同步的（启用事务处理）
 
 	txpool      txPool
 	blockchain  *core.BlockChain
 	chainconfig *params.ChainConfig
+	forkFilter  forkid.Filter
 	maxPeers    int
 
 	downloader *downloader.Downloader
 	fetcher    *fetcher.Fetcher
+	txFetcher  *fetcher.TxFetcher
 	peers      *peerSet
+	txLimiter  *txLimiter
 
 	SubProtocols []p2p.Protocol
 
@@ -107,26 +114,35 @@ func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, ne
 		txpool:      txpool,
 		blockchain:  blockchain,
 		chainconfig: config,
+		forkFilter:  forkid.NewFilter(blockchain),
 		peers:       newPeerSet(),
+		txLimiter:   newTxLimiter(txPeerRate, txPeerBurst),
 		whitelist:   whitelist,
 		newPeerCh:   make(chan *peer),
 		noMorePeers: make(chan struct{}),
 		txsyncCh:    make(chan *txsync),
 		quitSync:    make(chan struct{}),
 	}
+//修剪模式在链非空时也被记录，以便重新启用快速同步时仍然只下载最近的主体
+	if mode == downloader.PrunedSync {
+		manager.prunedSync = uint32(1)
+	}
 //确定是否允许快速同步
-	if mode == downloader.FastSync && blockchain.CurrentBlock().NumberU64() > 0 {
+	if mode.FetchesState() && blockchain.CurrentBlock().NumberU64() > 0 {
 		log.Warn("Blockchain not empty, fast sync disabled")
 		mode = downloader.FullSync
 	}
-	if mode == downloader.FastSync {
+	if mode.FetchesState() {
 		manager.fastSync = uint32(1)
 	}
+	if mode == downloader.SnapSync {
+		manager.snapSync = uint32(1)
+	}
 //为我们能处理的每个实现版本启动一个子协议
 	manager.SubProtocols = make([]p2p.Protocol, 0, len(ProtocolVersions))
 	for i, version := range ProtocolVersions {
 //如果与操作模式不兼容，则跳过协议版本
-		if mode == downloader.FastSync && version < eth63 {
+		if mode.FetchesState() && version < eth63 {
 			continue
 		}
 //兼容；初始化子协议
@@ -155,11 +171,24 @@ version := version //跑步结束
 				}
 				return nil
 			},
+			Attributes: []enr.Entry{currentEthEntry(blockchain)},
+			DialFilter: newNodeFilter(manager.forkFilter),
 		})
 	}
 	if len(manager.SubProtocols) == 0 {
 		return nil, errIncompatibleConfig
 	}
+//snap协议作为eth的附属协议运行，为快照同步提供状态范围
+	manager.SubProtocols = append(manager.SubProtocols, p2p.Protocol{
+		Name:    snapProtocolName,
+		Version: snap1,
+		Length:  snapProtocolLength,
+		Run: func(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+			manager.wg.Add(1)
+			defer manager.wg.Done()
+			return manager.handleSnap(newSnapPeer(p, rw))
+		},
+	})
 //构建不同的同步机制
 	manager.downloader = downloader.New(mode, chaindb, manager.eventMux, blockchain, nil, manager.removePeer)
 
@@ -178,7 +207,19 @@ version := version //跑步结束
 atomic.StoreUint32(&manager.acceptTxs, 1) //在任何获取器导入上标记初始同步完成
 		return manager.blockchain.InsertChain(blocks)
 	}
-	manager.fetcher = fetcher.New(blockchain.GetBlockByHash, validator, manager.BroadcastBlock, heighter, inserter, manager.removePeer)
+	manager.fetcher = fetcher.New(blockchain.GetBlockByHash, validator, manager.BroadcastBlock, heighter, inserter, manager.dropInvalidPeer)
+
+	hasTx := func(hash common.Hash) bool {
+		return txpool.Get(hash) != nil
+	}
+	fetchTxs := func(id string, hashes []common.Hash) error {
+		p := manager.peers.Peer(id)
+		if p == nil {
+			return errNotRegistered
+		}
+		return p.RequestTxs(hashes)
+	}
+	manager.txFetcher = fetcher.NewTxFetcher(hasTx, txpool.AddRemotes, fetchTxs)
 
 	return manager, nil
 }
@@ -193,6 +234,7 @@ func (pm *ProtocolManager) removePeer(id string) {
 
 //从下载程序和以太坊对等集注销对等。
 	pm.downloader.UnregisterPeer(id)
+	pm.txFetcher.Drop(id)
 	if err := pm.peers.Unregister(id); err != nil {
 		log.Error("Peer removal failed", "peer", id, "err", err)
 	}
@@ -202,6 +244,14 @@ func (pm *ProtocolManager) removePeer(id string) {
 	}
 }
 
+//dropInvalidPeer在对等端传播了无效区块时降低其信誉并断开连接。
+func (pm *ProtocolManager) dropInvalidPeer(id string) {
+	if peer := pm.peers.Peer(id); peer != nil {
+		peer.Report(p2p.PeerInvalidBlock)
+	}
+	pm.removePeer(id)
+}
+
 func (pm *ProtocolManager) Start(maxPeers int) {
 	pm.maxPeers = maxPeers
 
@@ -265,7 +315,7 @@ func (pm *ProtocolManager) handle(p *peer) error {
 		number  = head.Number.Uint64()
 		td      = pm.blockchain.GetTd(hash, number)
 	)
-	if err := p.Handshake(pm.networkID, td, hash, genesis.Hash()); err != nil {
+	if err := p.Handshake(pm.networkID, td, hash, genesis.Hash(), forkid.NewID(pm.blockchain), pm.forkFilter); err != nil {
 		p.Log().Debug("Ethereum handshake failed", "err", err)
 		return err
 	}
@@ -279,6 +329,9 @@ func (pm *ProtocolManager) handle(p *peer) error {
 	}
 	defer pm.removePeer(p.id)
 
+	pm.txLimiter.register(p.id)
+	defer pm.txLimiter.unregister(p.id)
+
 //在下载程序中注册对等点。如果下载者认为它是被禁止的，我们会断开
 	if err := pm.downloader.RegisterPeer(p.id, p.version, p); err != nil {
 		return err
@@ -512,6 +565,8 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			if data := pm.blockchain.GetBodyRLP(hash); len(data) != 0 {
 				bodies = append(bodies, data)
 				bytes += len(data)
+			} else if header := pm.blockchain.GetHeaderByHash(hash); header != nil && pm.blockchain.IsBodyPruned(header.Number.Uint64()) {
+				return errResp(ErrBodyPruned, "block #%d", header.Number)
 			}
 		}
 		return p.SendBlockBodiesRLP(bodies)
@@ -602,7 +657,11 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 //检索请求块的收据，如果我们不知道，则跳过
 			results := pm.blockchain.GetReceiptsByHash(hash)
 			if results == nil {
-				if header := pm.blockchain.GetHeaderByHash(hash); header == nil || header.ReceiptHash != types.EmptyRootHash {
+				header := pm.blockchain.GetHeaderByHash(hash)
+				if header != nil && header.ReceiptHash != types.EmptyRootHash && pm.blockchain.IsBodyPruned(header.Number.Uint64()) {
+					return errResp(ErrBodyPruned, "receipts of block #%d", header.Number)
+				}
+				if header == nil || header.ReceiptHash != types.EmptyRootHash {
 					continue
 				}
 			}
@@ -679,7 +738,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			}
 		}
 
-	case msg.Code == TxMsg:
+	case msg.Code == TxMsg || (p.version >= eth65 && msg.Code == PooledTransactionsMsg):
 //交易已到达，请确保我们有一个有效且新鲜的链来处理它们。
 		if atomic.LoadUint32(&pm.acceptTxs) == 0 {
 			break
@@ -696,7 +755,71 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			}
 			p.MarkTransaction(tx.Hash())
 		}
-		pm.txpool.AddRemotes(txs)
+//限制泛滥的对等方，并断开主要发送被限制或无效事务的对等方
+		if allowed := pm.txLimiter.allow(p.id, len(txs)); allowed < len(txs) {
+			p.Log().Debug("Throttling transaction flood", "received", len(txs), "allowed", allowed)
+			txs = txs[:allowed]
+		}
+		var errs []error
+		if len(txs) > 0 {
+			errs = pm.txFetcher.Enqueue(p.id, txs, msg.Code == PooledTransactionsMsg)
+		}
+		if pm.txLimiter.account(p.id, errs) {
+			return errResp(ErrTxSpam, "too many rejected transactions")
+		}
+
+	case p.version >= eth65 && msg.Code == NewPooledTransactionHashesMsg:
+//交易通知已到达，只有在同步后才检索交易
+		if atomic.LoadUint32(&pm.acceptTxs) == 0 {
+			break
+		}
+		var hashes []common.Hash
+		if err := msg.Decode(&hashes); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		for _, hash := range hashes {
+			p.MarkTransaction(hash)
+		}
+		pm.txFetcher.Notify(p.id, hashes)
+
+	case p.version >= eth65 && msg.Code == GetPooledTransactionsMsg:
+//解码检索消息
+		msgStream := rlp.NewStream(msg.Payload, uint64(msg.Size))
+		if _, err := msgStream.List(); err != nil {
+			return err
+		}
+//收集交易，直到达到提取或网络限制
+		var (
+			hash   common.Hash
+			bytes  int
+			hashes []common.Hash
+			txs    []rlp.RawValue
+		)
+		for bytes < softResponseLimit {
+//检索下一个请求的哈希
+			if err := msgStream.Decode(&hash); err == rlp.EOL {
+				break
+			} else if err != nil {
+				return errResp(ErrDecode, "msg %v: %v", msg, err)
+			}
+//只返回池中的公开交易，未知和私有的交易被跳过
+			if pm.txpool.IsPrivate(hash) {
+				continue
+			}
+			tx := pm.txpool.Get(hash)
+			if tx == nil {
+				continue
+			}
+			encoded, err := rlp.EncodeToBytes(tx)
+			if err != nil {
+				log.Error("Failed to encode transaction", "err", err)
+				continue
+			}
+			hashes = append(hashes, hash)
+			txs = append(txs, encoded)
+			bytes += len(encoded)
+		}
+		return p.SendPooledTransactionsRLP(hashes, txs)
 
 	default:
 		return errResp(ErrInvalidMsgCode, "%v", msg.Code)
@@ -745,22 +868,45 @@ func (pm *ProtocolManager) BroadcastBlock(block *types.Block, propagate bool) {
 }
 
 //BroadcastTXS将把一批事务传播到所有未知的对等端
-//已经有给定的事务。
+//已经有给定的事务。支持ETH/65的对等端中只有平方根数量的对等端
+//收到完整事务，其余的只收到哈希通知，需要时自行检索。
 func (pm *ProtocolManager) BroadcastTxs(txs types.Transactions) {
-	var txset = make(map[*peer]types.Transactions)
-
+	var (
+		txset  = make(map[*peer]types.Transactions)
+		annset = make(map[*peer][]common.Hash)
+	)
 //将事务广播给一批不知道它的对等方
 	for _, tx := range txs {
+//私有事务只在本地挖掘，从不广播
+		if pm.txpool.IsPrivate(tx.Hash()) {
+			continue
+		}
 		peers := pm.peers.PeersWithoutTx(tx.Hash())
+
+		var announcers []*peer
 		for _, peer := range peers {
-			txset[peer] = append(txset[peer], tx)
+			if peer.version >= eth65 {
+				announcers = append(announcers, peer)
+			} else {
+				txset[peer] = append(txset[peer], tx)
+			}
+		}
+		direct := int(math.Sqrt(float64(len(announcers))))
+		for i, peer := range announcers {
+			if i < direct {
+				txset[peer] = append(txset[peer], tx)
+			} else {
+				annset[peer] = append(annset[peer], tx.Hash())
+			}
 		}
-		log.Trace("Broadcast transaction", "hash", tx.Hash(), "recipients", len(peers))
+		log.Trace("Broadcast transaction", "hash", tx.Hash(), "recipients", len(peers), "announced", len(announcers)-direct)
 	}
-//fixme再次包括：peers=peers[：int（math.sqrt（float64（len（peers）））]
 	for peer, txs := range txset {
 		peer.AsyncSendTransactions(txs)
 	}
+	for peer, hashes := range annset {
+		peer.AsyncSendPooledTransactionHashes(hashes)
+	}
 }
 
 //地雷广播回路
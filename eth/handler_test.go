@@ -23,6 +23,7 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/consensus/ethash"
 	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
@@ -445,6 +446,48 @@ func testGetReceipt(t *testing.T, protocol int) {
 	}
 }
 
+//测试请求已修剪的块主体或收据时返回明确的错误，而不是静默地省略它们。
+func TestGetPrunedBodies63(t *testing.T) { testGetPrunedBodies(t, 63) }
+
+func testGetPrunedBodies(t *testing.T, protocol int) {
+	generator := func(i int, block *core.BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(block.TxNonce(testBank), common.Address{0x01}, big.NewInt(1000), params.TxGas, nil, nil), types.HomesteadSigner{}, testBankKey)
+		block.AddTx(tx)
+	}
+	tests := []struct {
+		code uint64
+		want error
+	}{
+		{GetBlockBodiesMsg, errResp(ErrBodyPruned, "block #1")},
+		{GetReceiptsMsg, errResp(ErrBodyPruned, "receipts of block #1")},
+	}
+	for i, tt := range tests {
+		pm, db := newTestProtocolManagerMust(t, downloader.FullSync, 4, generator, nil)
+
+//修剪前两个块的主体和收据
+		for number := uint64(1); number < 3; number++ {
+			hash := rawdb.ReadCanonicalHash(db, number)
+			rawdb.DeleteBody(db, hash, number)
+			rawdb.DeleteReceipts(db, hash, number)
+		}
+		pm.blockchain.SetBodyTail(3)
+
+		peer, errc := newTestPeer("peer", protocol, pm, true)
+		go p2p.Send(peer.app, tt.code, []common.Hash{pm.blockchain.GetBlockByNumber(3).Hash(), rawdb.ReadCanonicalHash(db, 1)})
+
+		select {
+		case err := <-errc:
+			if err == nil || err.Error() != tt.want.Error() {
+				t.Errorf("test %d: error mismatch: have %v, want %v", i, err, tt.want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Errorf("test %d: protocol did not shut down within 2 seconds", i)
+		}
+		peer.close()
+		pm.Stop()
+	}
+}
+
 //发布ETH协议握手的测试，启用DAO分叉的客户端也会执行
 //一个DAO“挑战”验证彼此的DAO分叉头，以确保它们处于打开状态
 //兼容的链条。
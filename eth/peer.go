@@ -21,7 +21,9 @@ import (
 
 	mapset "github.com/deckarep/golang-set"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/forkid"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/downloader"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/rlp"
 )
@@ -41,6 +43,10 @@ maxKnownBlocks = 1024  //要保留在已知列表中的最大块哈希数（防
 //包含一个或数千个事务。
 	maxQueuedTxs = 128
 
+//maxQueuedTxAnns是在丢弃广播之前排队的交易通知列表的最大数目。
+//通知只包含哈希，因此与完整交易使用相同的限制。
+	maxQueuedTxAnns = 128
+
 //MaxQueuedProps是在之前排队的最大块传播数
 //正在删除广播。排队的陈旧街区没什么意义，所以有一些
 //这可能足以覆盖叔叔们。
@@ -81,26 +87,28 @@ forkDrop *time.Timer //如果未及时验证分叉，则为定时连接滴管
 	td   *big.Int
 	lock sync.RWMutex
 
-knownTxs    mapset.Set                //此对等方已知的事务哈希集
-knownBlocks mapset.Set                //此对等方已知的块哈希集
-queuedTxs   chan []*types.Transaction //要广播到对等机的事务队列
-queuedProps chan *propEvent           //向对等机广播的块队列
-queuedAnns  chan *types.Block         //向对等机宣布的块队列
-term        chan struct{}             //终止频道以停止广播
+knownTxs     mapset.Set                //此对等方已知的事务哈希集
+knownBlocks  mapset.Set                //此对等方已知的块哈希集
+queuedTxs    chan []*types.Transaction //要广播到对等机的事务队列
+queuedTxAnns chan []common.Hash        //要向对等机宣布的事务哈希队列
+queuedProps  chan *propEvent           //向对等机广播的块队列
+queuedAnns   chan *types.Block         //向对等机宣布的块队列
+term         chan struct{}             //终止频道以停止广播
 }
 
 func newPeer(version int, p *p2p.Peer, rw p2p.MsgReadWriter) *peer {
 	return &peer{
-		Peer:        p,
-		rw:          rw,
-		version:     version,
-		id:          fmt.Sprintf("%x", p.ID().Bytes()[:8]),
-		knownTxs:    mapset.NewSet(),
-		knownBlocks: mapset.NewSet(),
-		queuedTxs:   make(chan []*types.Transaction, maxQueuedTxs),
-		queuedProps: make(chan *propEvent, maxQueuedProps),
-		queuedAnns:  make(chan *types.Block, maxQueuedAnns),
-		term:        make(chan struct{}),
+		Peer:         p,
+		rw:           rw,
+		version:      version,
+		id:           fmt.Sprintf("%x", p.ID().Bytes()[:8]),
+		knownTxs:     mapset.NewSet(),
+		knownBlocks:  mapset.NewSet(),
+		queuedTxs:    make(chan []*types.Transaction, maxQueuedTxs),
+		queuedTxAnns: make(chan []common.Hash, maxQueuedTxAnns),
+		queuedProps:  make(chan *propEvent, maxQueuedProps),
+		queuedAnns:   make(chan *types.Block, maxQueuedAnns),
+		term:         make(chan struct{}),
 	}
 }
 
@@ -116,6 +124,12 @@ func (p *peer) broadcast() {
 			}
 			p.Log().Trace("Broadcast transactions", "count", len(txs))
 
+		case hashes := <-p.queuedTxAnns:
+			if err := p.SendPooledTransactionHashes(hashes); err != nil {
+				return
+			}
+			p.Log().Trace("Announced transactions", "count", len(hashes))
+
 		case prop := <-p.queuedProps:
 			if err := p.SendNewBlock(prop.block, prop.td); err != nil {
 				return
@@ -211,6 +225,37 @@ func (p *peer) AsyncSendTransactions(txs []*types.Transaction) {
 	}
 }
 
+//SendPooledTransactionHashes向对等端宣布一批交易哈希，并将哈希
+//包括在其事务哈希集中，以供将来参考。
+func (p *peer) SendPooledTransactionHashes(hashes []common.Hash) error {
+	for _, hash := range hashes {
+		p.knownTxs.Add(hash)
+	}
+	return p2p.Send(p.rw, NewPooledTransactionHashesMsg, hashes)
+}
+
+//AsyncSendPooledTransactionHashes将交易哈希排队，以便向远程对等端宣布。
+//如果对等方的广播队列已满，则会静默地删除事件。
+func (p *peer) AsyncSendPooledTransactionHashes(hashes []common.Hash) {
+	select {
+	case p.queuedTxAnns <- hashes:
+		for _, hash := range hashes {
+			p.knownTxs.Add(hash)
+		}
+	default:
+		p.Log().Debug("Dropping transaction announcement", "count", len(hashes))
+	}
+}
+
+//SendPooledTransactionsRLP从已经RLP编码的格式向远程对等端发送
+//请求的交易。
+func (p *peer) SendPooledTransactionsRLP(hashes []common.Hash, txs []rlp.RawValue) error {
+	for _, hash := range hashes {
+		p.knownTxs.Add(hash)
+	}
+	return p2p.Send(p.rw, PooledTransactionsMsg, txs)
+}
+
 //sendNewBlockHashes宣布通过
 //哈希通知。
 func (p *peer) SendNewBlockHashes(hashes []common.Hash, numbers []uint64) error {
@@ -282,6 +327,12 @@ func (p *peer) SendReceiptsRLP(receipts []rlp.RawValue) error {
 	return p2p.Send(p.rw, ReceiptsMsg, receipts)
 }
 
+//RequestTxs从远程对等端获取一批已宣布的交易。
+func (p *peer) RequestTxs(hashes []common.Hash) error {
+	p.Log().Debug("Fetching batch of transactions", "count", len(hashes))
+	return p2p.Send(p.rw, GetPooledTransactionsMsg, hashes)
+}
+
 //RequestOneHeader是一个包装器，它围绕头查询函数来获取
 //单头。它仅由取纸器使用。
 func (p *peer) RequestOneHeader(hash common.Hash) error {
@@ -325,22 +376,42 @@ func (p *peer) RequestReceipts(hashes []common.Hash) error {
 
 //握手执行ETH协议握手，协商版本号，
 //网络ID，困难，头和创世块。
-func (p *peer) Handshake(network uint64, td *big.Int, head common.Hash, genesis common.Hash) error {
+func (p *peer) Handshake(network uint64, td *big.Int, head common.Hash, genesis common.Hash, forkID forkid.ID, forkFilter forkid.Filter) error {
 //在新线程中发送自己的握手
 	errc := make(chan error, 2)
-var status statusData //从errc收到两个值后可以安全读取
 
+	var (
+status   statusData   //从errc收到两个值后可以安全读取
+status64 statusData64 //从errc收到两个值后可以安全读取
+	)
 	go func() {
-		errc <- p2p.Send(p.rw, StatusMsg, &statusData{
-			ProtocolVersion: uint32(p.version),
-			NetworkId:       network,
-			TD:              td,
-			CurrentBlock:    head,
-			GenesisBlock:    genesis,
-		})
+		switch {
+		case p.version >= eth64:
+			errc <- p2p.Send(p.rw, StatusMsg, &statusData64{
+				ProtocolVersion: uint32(p.version),
+				NetworkID:       network,
+				TD:              td,
+				Head:            head,
+				Genesis:         genesis,
+				ForkID:          forkID,
+			})
+		default:
+			errc <- p2p.Send(p.rw, StatusMsg, &statusData{
+				ProtocolVersion: uint32(p.version),
+				NetworkId:       network,
+				TD:              td,
+				CurrentBlock:    head,
+				GenesisBlock:    genesis,
+			})
+		}
 	}()
 	go func() {
-		errc <- p.readStatus(network, &status, genesis)
+		switch {
+		case p.version >= eth64:
+			errc <- p.readStatus64(network, &status64, genesis, forkFilter)
+		default:
+			errc <- p.readStatus(network, &status, genesis)
+		}
 	}()
 	timeout := time.NewTimer(handshakeTimeout)
 	defer timeout.Stop()
@@ -354,10 +425,35 @@ var status statusData //从errc收到两个值后可以安全读取
 			return p2p.DiscReadTimeout
 		}
 	}
-	p.td, p.head = status.TD, status.CurrentBlock
+	switch {
+	case p.version >= eth64:
+		p.td, p.head = status64.TD, status64.Head
+	default:
+		p.td, p.head = status.TD, status.CurrentBlock
+	}
 	return nil
 }
 
+//useless报告对等端对本节点没有用处（例如在另一条链上），并返回err。
+func (p *peer) useless(err error) error {
+	p.Report(p2p.PeerUseless)
+	return err
+}
+
+//ReportSync实现downloader.ReputationPeer，把下载器观察到的行为转给p2p层的信誉记录。
+func (p *peer) ReportSync(b downloader.SyncBehaviour) {
+	switch b {
+	case downloader.SyncGoodDelivery:
+		p.Report(p2p.PeerGoodResponse)
+	case downloader.SyncSlowDelivery:
+		p.Report(p2p.PeerSlowResponse)
+	case downloader.SyncTimeout:
+		p.Report(p2p.PeerTimeout)
+	case downloader.SyncInvalidChain:
+		p.Report(p2p.PeerInvalidBlock)
+	}
+}
+
 func (p *peer) readStatus(network uint64, status *statusData, genesis common.Hash) (err error) {
 	msg, err := p.rw.ReadMsg()
 	if err != nil {
@@ -374,13 +470,44 @@ func (p *peer) readStatus(network uint64, status *statusData, genesis common.Has
 		return errResp(ErrDecode, "msg %v: %v", msg, err)
 	}
 	if status.GenesisBlock != genesis {
-		return errResp(ErrGenesisBlockMismatch, "%x (!= %x)", status.GenesisBlock[:8], genesis[:8])
+		return p.useless(errResp(ErrGenesisBlockMismatch, "%x (!= %x)", status.GenesisBlock[:8], genesis[:8]))
 	}
 	if status.NetworkId != network {
-		return errResp(ErrNetworkIdMismatch, "%d (!= %d)", status.NetworkId, network)
+		return p.useless(errResp(ErrNetworkIdMismatch, "%d (!= %d)", status.NetworkId, network))
+	}
+	if int(status.ProtocolVersion) != p.version {
+		return p.useless(errResp(ErrProtocolVersionMismatch, "%d (!= %d)", status.ProtocolVersion, p.version))
+	}
+	return nil
+}
+
+//readStatus64读取ETH/64及以后版本的状态消息，并用分叉过滤器验证远程的分叉标识。
+func (p *peer) readStatus64(network uint64, status *statusData64, genesis common.Hash, forkFilter forkid.Filter) error {
+	msg, err := p.rw.ReadMsg()
+	if err != nil {
+		return err
+	}
+	if msg.Code != StatusMsg {
+		return errResp(ErrNoStatusMsg, "first msg has code %x (!= %x)", msg.Code, StatusMsg)
+	}
+	if msg.Size > ProtocolMaxMsgSize {
+		return errResp(ErrMsgTooLarge, "%v > %v", msg.Size, ProtocolMaxMsgSize)
+	}
+//解码握手并确保所有内容都匹配
+	if err := msg.Decode(&status); err != nil {
+		return errResp(ErrDecode, "msg %v: %v", msg, err)
+	}
+	if status.NetworkID != network {
+		return p.useless(errResp(ErrNetworkIdMismatch, "%d (!= %d)", status.NetworkID, network))
 	}
 	if int(status.ProtocolVersion) != p.version {
-		return errResp(ErrProtocolVersionMismatch, "%d (!= %d)", status.ProtocolVersion, p.version)
+		return p.useless(errResp(ErrProtocolVersionMismatch, "%d (!= %d)", status.ProtocolVersion, p.version))
+	}
+	if status.Genesis != genesis {
+		return p.useless(errResp(ErrGenesisBlockMismatch, "%x (!= %x)", status.Genesis[:8], genesis[:8]))
+	}
+	if err := forkFilter(status.ForkID); err != nil {
+		return p.useless(errResp(ErrForkIDRejected, "%v", err))
 	}
 	return nil
 }
@@ -19,6 +19,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/forkid"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/rlp"
@@ -28,16 +29,18 @@ import (
 const (
 	eth62 = 62
 	eth63 = 63
+	eth64 = 64
+	eth65 = 65
 )
 
 //ProtocolName是在能力协商期间使用的协议的官方简称。
 var ProtocolName = "eth"
 
 //协议版本是受支持的ETH协议版本（第一个是主协议）。
-var ProtocolVersions = []uint{eth63, eth62}
+var ProtocolVersions = []uint{eth65, eth64, eth63, eth62}
 
 //Protocollength是对应于不同协议版本的已实现消息数。
-var ProtocolLengths = []uint64{17, 8}
+var ProtocolLengths = []uint64{17, 17, 17, 8}
 
 const ProtocolMaxMsgSize = 10 * 1024 * 1024 //协议消息大小的最大上限
 
@@ -53,6 +56,11 @@ const (
 	BlockBodiesMsg     = 0x06
 	NewBlockMsg        = 0x07
 
+//属于ETH/65的协议消息
+	NewPooledTransactionHashesMsg = 0x08
+	GetPooledTransactionsMsg      = 0x09
+	PooledTransactionsMsg         = 0x0a
+
 //属于ETH/63的协议消息
 	GetNodeDataMsg = 0x0d
 	NodeDataMsg    = 0x0e
@@ -72,6 +80,9 @@ const (
 	ErrNoStatusMsg
 	ErrExtraStatusMsg
 	ErrSuspendedPeer
+	ErrTxSpam
+	ErrForkIDRejected
+	ErrBodyPruned
 )
 
 func (e errCode) String() string {
@@ -89,16 +100,25 @@ var errorToString = map[int]string{
 	ErrNoStatusMsg:             "No status message",
 	ErrExtraStatusMsg:          "Extra status message",
 	ErrSuspendedPeer:           "Suspended peer",
+	ErrTxSpam:                  "Transaction spam",
+	ErrForkIDRejected:          "Fork ID rejected",
+	ErrBodyPruned:              "Requested block body pruned",
 }
 
 type txPool interface {
 //AddRemotes应该将给定的事务添加到池中。
 	AddRemotes([]*types.Transaction) []error
 
+//Get应返回池中具有给定哈希的事务，如果不存在则返回nil。
+	Get(hash common.Hash) *types.Transaction
+
 //挂起应返回挂起的事务。
 //该切片应由调用方可修改。
 	Pending() (map[common.Address]types.Transactions, error)
 
+//IsPrivate应报告事务是否不得传播给对等方。
+	IsPrivate(hash common.Hash) bool
+
 //subscribenewtxsevent应返回的事件订阅
 //newtxSevent并将事件发送到给定的通道。
 	SubscribeNewTxsEvent(chan<- core.NewTxsEvent) event.Subscription
@@ -113,6 +133,16 @@ type statusData struct {
 	GenesisBlock    common.Hash
 }
 
+//statusData64是ETH/64及以后版本状态消息的网络包，增加了EIP-2124分叉标识。
+type statusData64 struct {
+	ProtocolVersion uint32
+	NetworkID       uint64
+	TD              *big.Int
+	Head            common.Hash
+	Genesis         common.Hash
+	ForkID          forkid.ID
+}
+
 //newblockhashesdata是块通知的网络包。
 type newBlockHashesData []struct {
 Hash   common.Hash //正在公布的一个特定块的哈希
@@ -19,6 +19,7 @@ import (
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/forkid"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/eth/downloader"
@@ -88,9 +89,67 @@ func testStatusMsgErrors(t *testing.T, protocol int) {
 	}
 }
 
+func TestStatusMsgErrors64(t *testing.T) {
+	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, nil)
+	var (
+		genesis = pm.blockchain.Genesis()
+		head    = pm.blockchain.CurrentHeader()
+		td      = pm.blockchain.GetTd(head.Hash(), head.Number.Uint64())
+		forkID  = forkid.NewID(pm.blockchain)
+	)
+	defer pm.Stop()
+
+	tests := []struct {
+		code      uint64
+		data      interface{}
+		wantError error
+	}{
+		{
+			code: TxMsg, data: []interface{}{},
+			wantError: errResp(ErrNoStatusMsg, "first msg has code 2 (!= 0)"),
+		},
+		{
+			code: StatusMsg, data: statusData64{10, DefaultConfig.NetworkId, td, head.Hash(), genesis.Hash(), forkID},
+			wantError: errResp(ErrProtocolVersionMismatch, "10 (!= %d)", eth64),
+		},
+		{
+			code: StatusMsg, data: statusData64{eth64, 999, td, head.Hash(), genesis.Hash(), forkID},
+			wantError: errResp(ErrNetworkIdMismatch, "999 (!= 1)"),
+		},
+		{
+			code: StatusMsg, data: statusData64{eth64, DefaultConfig.NetworkId, td, head.Hash(), common.Hash{3}, forkID},
+			wantError: errResp(ErrGenesisBlockMismatch, "0300000000000000 (!= %x)", genesis.Hash().Bytes()[:8]),
+		},
+		{
+			code: StatusMsg, data: statusData64{eth64, DefaultConfig.NetworkId, td, head.Hash(), genesis.Hash(), forkid.ID{Hash: [4]byte{0x00, 0x01, 0x02, 0x03}}},
+			wantError: errResp(ErrForkIDRejected, "%v", forkid.ErrLocalIncompatibleOrStale),
+		},
+	}
+	for i, test := range tests {
+		p, errc := newTestPeer("peer", eth64, pm, false)
+//在重置之前，发送呼叫可能挂起，因为
+//协议可能无法读取有效负载。
+		go p2p.Send(p.app, test.code, test.data)
+
+		select {
+		case err := <-errc:
+			if err == nil {
+				t.Errorf("test %d: protocol returned nil error, want %q", i, test.wantError)
+			} else if err.Error() != test.wantError.Error() {
+				t.Errorf("test %d: wrong error: got %q, want %q", i, err, test.wantError)
+			}
+		case <-time.After(2 * time.Second):
+			t.Errorf("protocol did not shut down within 2 seconds")
+		}
+		p.close()
+	}
+}
+
 //此测试检查接收到的事务是否添加到本地池。
 func TestRecvTransactions62(t *testing.T) { testRecvTransactions(t, 62) }
 func TestRecvTransactions63(t *testing.T) { testRecvTransactions(t, 63) }
+func TestRecvTransactions64(t *testing.T) { testRecvTransactions(t, 64) }
+func TestRecvTransactions65(t *testing.T) { testRecvTransactions(t, 65) }
 
 func testRecvTransactions(t *testing.T, protocol int) {
 	txAdded := make(chan []*types.Transaction)
@@ -175,6 +234,129 @@ func testSendTransactions(t *testing.T, protocol int) {
 }
 
 //测试自定义联合字段编码器和解码器是否正常工作。
+//测试交易通知在等待广播后被检索，返回的交易被加入池中。
+func TestTransactionAnnounce65(t *testing.T) {
+	txAdded := make(chan []*types.Transaction)
+	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, txAdded)
+pm.acceptTxs = 1 //标记为同步以接受交易记录
+	p, _ := newTestPeer("peer", eth65, pm, true)
+	defer pm.Stop()
+	defer p.close()
+
+	tx := newTestTransaction(testAccount, 0, 0)
+	if err := p2p.Send(p.app, NewPooledTransactionHashesMsg, []common.Hash{tx.Hash()}); err != nil {
+		t.Fatalf("send error: %v", err)
+	}
+//节点必须请求已宣布的交易
+	msg, err := p.app.ReadMsg()
+	if err != nil {
+		t.Fatalf("read error: %v", err)
+	}
+	if msg.Code != GetPooledTransactionsMsg {
+		t.Fatalf("got code %d, want GetPooledTransactionsMsg", msg.Code)
+	}
+	var hashes []common.Hash
+	if err := msg.Decode(&hashes); err != nil {
+		t.Fatalf("failed to decode request: %v", err)
+	}
+	if len(hashes) != 1 || hashes[0] != tx.Hash() {
+		t.Fatalf("requested hashes mismatch: have %x, want %x", hashes, tx.Hash())
+	}
+	if err := p2p.Send(p.app, PooledTransactionsMsg, []*types.Transaction{tx}); err != nil {
+		t.Fatalf("send error: %v", err)
+	}
+	select {
+	case added := <-txAdded:
+		if len(added) != 1 || added[0].Hash() != tx.Hash() {
+			t.Errorf("added transactions mismatch: have %v, want %x", added, tx.Hash())
+		}
+	case <-time.After(2 * time.Second):
+		t.Errorf("no transaction added within 2 seconds")
+	}
+}
+
+//测试池中的交易按请求返回，未知的交易被跳过。
+func TestGetPooledTransactions65(t *testing.T) {
+	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, nil)
+	defer pm.Stop()
+
+	tx := newTestTransaction(testAccount, 0, 0)
+	pm.txpool.AddRemotes([]*types.Transaction{tx})
+
+	p, _ := newTestPeer("peer", eth65, pm, true)
+	defer p.close()
+
+	if err := p2p.Send(p.app, GetPooledTransactionsMsg, []common.Hash{{0x01}, tx.Hash()}); err != nil {
+		t.Fatalf("send error: %v", err)
+	}
+//跳过连接时发送的交易通知
+	for {
+		msg, err := p.app.ReadMsg()
+		if err != nil {
+			t.Fatalf("read error: %v", err)
+		}
+		if msg.Code == NewPooledTransactionHashesMsg {
+			msg.Discard()
+			continue
+		}
+		if msg.Code != PooledTransactionsMsg {
+			t.Fatalf("got code %d, want PooledTransactionsMsg", msg.Code)
+		}
+		var txs []*types.Transaction
+		if err := msg.Decode(&txs); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if len(txs) != 1 || txs[0].Hash() != tx.Hash() {
+			t.Errorf("pooled transactions mismatch: have %v, want %x", txs, tx.Hash())
+		}
+		return
+	}
+}
+
+//测试私有交易即使被请求也不会返回给对等方。
+func TestGetPooledPrivateTransactions65(t *testing.T) {
+	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, nil)
+	defer pm.Stop()
+
+	public := newTestTransaction(testAccount, 0, 0)
+	private := newTestTransaction(testAccount, 1, 0)
+
+	pool := pm.txpool.(*testTxPool)
+	pool.lock.Lock()
+	pool.private = map[common.Hash]bool{private.Hash(): true}
+	pool.lock.Unlock()
+	pool.AddRemotes([]*types.Transaction{public, private})
+
+	p, _ := newTestPeer("peer", eth65, pm, true)
+	defer p.close()
+
+	if err := p2p.Send(p.app, GetPooledTransactionsMsg, []common.Hash{private.Hash(), public.Hash()}); err != nil {
+		t.Fatalf("send error: %v", err)
+	}
+//跳过连接时发送的交易通知
+	for {
+		msg, err := p.app.ReadMsg()
+		if err != nil {
+			t.Fatalf("read error: %v", err)
+		}
+		if msg.Code == NewPooledTransactionHashesMsg {
+			msg.Discard()
+			continue
+		}
+		if msg.Code != PooledTransactionsMsg {
+			t.Fatalf("got code %d, want PooledTransactionsMsg", msg.Code)
+		}
+		var txs []*types.Transaction
+		if err := msg.Decode(&txs); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if len(txs) != 1 || txs[0].Hash() != public.Hash() {
+			t.Errorf("pooled transactions mismatch: have %v, want %x", txs, public.Hash())
+		}
+		return
+	}
+}
+
 func TestGetBlockHeadersDataEncodeDecode(t *testing.T) {
 //为测试创建“随机”哈希
 	var hash common.Hash
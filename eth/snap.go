@@ -0,0 +1,277 @@
+
+//<developer>
+//    <name>linapex 曹一峰</name>
+//    <email>linapex@163.com</email>
+//    <wx>superexc</wx>
+//    <qqgroup>128148617</qqgroup>
+//    <url>https://jsq.ink</url>
+//    <role>pku engineer</role>
+//    <date>2019-03-16 19:16:37</date>
+//</624450088294420480>
+
+
+package eth
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+//用于匹配snap协议版本和消息的常量
+const (
+	snap1 = 1
+)
+
+//snapProtocolName是snap附属协议在能力协商期间使用的名称。
+var snapProtocolName = "snap"
+
+//snapProtocolLength是snap协议实现的消息数。
+const snapProtocolLength = 6
+
+const (
+maxRangeRequestBytes = softResponseLimit //单个范围响应的最大字节数
+maxCodeLookups       = 1024              //单个请求中查找的最大代码数
+)
+
+//snap协议报文代码
+const (
+	GetAccountRangeMsg = 0x00
+	AccountRangeMsg    = 0x01
+	GetStorageRangeMsg = 0x02
+	StorageRangeMsg    = 0x03
+	GetByteCodesMsg    = 0x04
+	ByteCodesMsg       = 0x05
+)
+
+//getRangeData表示账户或存储范围查询。
+type getRangeData struct {
+ID     uint64      //请求标识，用于匹配响应
+Root   common.Hash //账户或存储trie的根
+Origin common.Hash //范围中的第一个哈希
+Limit  common.Hash //范围中的最后一个哈希
+Bytes  uint64      //响应的软大小限制
+}
+
+//rangeEntry是范围响应中的单个trie叶子。
+type rangeEntry struct {
+	Hash common.Hash
+	Body []byte
+}
+
+//rangeData是对范围查询的响应，包括第一个和最后一个条目的merkle证明。
+type rangeData struct {
+	ID      uint64
+	Entries []rangeEntry
+	Proof   [][]byte
+}
+
+//unpack将范围响应拆分为哈希和值。
+func (d *rangeData) unpack() ([]common.Hash, [][]byte) {
+	hashes := make([]common.Hash, len(d.Entries))
+	values := make([][]byte, len(d.Entries))
+	for i, entry := range d.Entries {
+		hashes[i], values[i] = entry.Hash, entry.Body
+	}
+	return hashes, values
+}
+
+//getByteCodesData表示按哈希的合约代码查询。
+type getByteCodesData struct {
+	ID     uint64
+	Hashes []common.Hash
+	Bytes  uint64
+}
+
+//byteCodesData是对合约代码查询的响应。
+type byteCodesData struct {
+	ID    uint64
+	Codes [][]byte
+}
+
+//snapPeer是运行snap协议的远程对等点。
+type snapPeer struct {
+	*p2p.Peer
+
+	id string
+	rw p2p.MsgReadWriter
+}
+
+func newSnapPeer(p *p2p.Peer, rw p2p.MsgReadWriter) *snapPeer {
+	return &snapPeer{
+		Peer: p,
+		id:   fmt.Sprintf("%x", p.ID().Bytes()[:8]),
+		rw:   rw,
+	}
+}
+
+//RequestAccountRange从远程对等点获取给定状态根中的一段连续账户。
+func (p *snapPeer) RequestAccountRange(id uint64, root common.Hash, origin common.Hash, limit common.Hash, bytes uint64) error {
+	p.Log().Debug("Fetching range of accounts", "root", root, "origin", origin, "limit", limit)
+	return p2p.Send(p.rw, GetAccountRangeMsg, &getRangeData{ID: id, Root: root, Origin: origin, Limit: limit, Bytes: bytes})
+}
+
+//RequestStorageRange从远程对等点获取给定存储根中的一段连续存储槽。
+func (p *snapPeer) RequestStorageRange(id uint64, root common.Hash, origin common.Hash, limit common.Hash, bytes uint64) error {
+	p.Log().Debug("Fetching range of storage slots", "root", root, "origin", origin, "limit", limit)
+	return p2p.Send(p.rw, GetStorageRangeMsg, &getRangeData{ID: id, Root: root, Origin: origin, Limit: limit, Bytes: bytes})
+}
+
+//RequestByteCodes从远程对等点获取一批合约代码。
+func (p *snapPeer) RequestByteCodes(id uint64, hashes []common.Hash, bytes uint64) error {
+	p.Log().Debug("Fetching batch of byte codes", "count", len(hashes))
+	return p2p.Send(p.rw, GetByteCodesMsg, &getByteCodesData{ID: id, Hashes: hashes, Bytes: bytes})
+}
+
+//handleSnap是为管理snap对等点的生命周期而调用的回调。
+func (pm *ProtocolManager) handleSnap(p *snapPeer) error {
+	p.Log().Debug("Snap peer connected", "name", p.Name())
+
+	if err := pm.downloader.RegisterSnapPeer(p.id, p); err != nil {
+		return err
+	}
+	defer pm.downloader.UnregisterSnapPeer(p.id)
+
+	for {
+		if err := pm.handleSnapMsg(p); err != nil {
+			p.Log().Debug("Snap message handling failed", "err", err)
+			return err
+		}
+	}
+}
+
+//handleSnapMsg处理来自snap对等点的单个入站消息。
+func (pm *ProtocolManager) handleSnapMsg(p *snapPeer) error {
+	msg, err := p.rw.ReadMsg()
+	if err != nil {
+		return err
+	}
+	if msg.Size > ProtocolMaxMsgSize {
+		return errResp(ErrMsgTooLarge, "%v > %v", msg.Size, ProtocolMaxMsgSize)
+	}
+	defer msg.Discard()
+
+	switch msg.Code {
+	case GetAccountRangeMsg:
+		var req getRangeData
+		if err := msg.Decode(&req); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		res := &rangeData{ID: req.ID}
+		if tr, err := trie.New(req.Root, pm.blockchain.StateCache().TrieDB()); err == nil {
+			res.Entries, res.Proof = serveRange(tr, req.Origin, req.Limit, req.Bytes)
+		}
+		return p2p.Send(p.rw, AccountRangeMsg, res)
+
+	case GetStorageRangeMsg:
+		var req getRangeData
+		if err := msg.Decode(&req); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		res := &rangeData{ID: req.ID}
+		if tr, err := trie.New(req.Root, pm.blockchain.StateCache().TrieDB()); err == nil {
+			res.Entries, res.Proof = serveRange(tr, req.Origin, req.Limit, req.Bytes)
+		}
+		return p2p.Send(p.rw, StorageRangeMsg, res)
+
+	case GetByteCodesMsg:
+		var req getByteCodesData
+		if err := msg.Decode(&req); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		limit := req.Bytes
+		if limit > maxRangeRequestBytes {
+			limit = maxRangeRequestBytes
+		}
+		var (
+			codes [][]byte
+			size  uint64
+		)
+		for i, hash := range req.Hashes {
+			if i >= maxCodeLookups || size >= limit {
+				break
+			}
+			if code, err := pm.blockchain.StateCache().ContractCode(common.Hash{}, hash); err == nil {
+				codes = append(codes, code)
+				size += uint64(len(code))
+			}
+		}
+		return p2p.Send(p.rw, ByteCodesMsg, &byteCodesData{ID: req.ID, Codes: codes})
+
+	case AccountRangeMsg:
+		var res rangeData
+		if err := msg.Decode(&res); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		hashes, accounts := res.unpack()
+		if err := pm.downloader.DeliverAccountRange(p.id, res.ID, hashes, accounts, res.Proof); err != nil {
+			p.Log().Debug("Failed to deliver account range", "err", err)
+		}
+
+	case StorageRangeMsg:
+		var res rangeData
+		if err := msg.Decode(&res); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		hashes, slots := res.unpack()
+		if err := pm.downloader.DeliverStorageRange(p.id, res.ID, hashes, slots, res.Proof); err != nil {
+			p.Log().Debug("Failed to deliver storage range", "err", err)
+		}
+
+	case ByteCodesMsg:
+		var res byteCodesData
+		if err := msg.Decode(&res); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		if err := pm.downloader.DeliverByteCodes(p.id, res.ID, res.Codes); err != nil {
+			p.Log().Debug("Failed to deliver byte codes", "err", err)
+		}
+
+	default:
+		return errResp(ErrInvalidMsgCode, "%v", msg.Code)
+	}
+	return nil
+}
+
+//serveRange收集trie中从origin开始、不超过limit的叶子，直到达到大小限制，
+//并附上origin和最后一个返回条目的merkle证明。limit之后的第一个叶子也被返回，
+//以证明origin和limit之间没有更多的叶子。
+func serveRange(tr *trie.Trie, origin common.Hash, limit common.Hash, budget uint64) ([]rangeEntry, [][]byte) {
+	if budget > maxRangeRequestBytes {
+		budget = maxRangeRequestBytes
+	}
+	var (
+		entries []rangeEntry
+		size    uint64
+	)
+	it := trie.NewIterator(tr.NodeIterator(origin[:]))
+	for size < budget && it.Next() {
+		hash := common.BytesToHash(it.Key)
+		entries = append(entries, rangeEntry{Hash: hash, Body: common.CopyBytes(it.Value)})
+		size += uint64(common.HashLength + len(it.Value))
+
+		if bytes.Compare(hash[:], limit[:]) >= 0 {
+			break
+		}
+	}
+//证明范围的两个边界，客户端据此验证响应属于请求的根
+	proofdb := ethdb.NewMemDatabase()
+	if err := tr.Prove(origin[:], 0, proofdb); err != nil {
+		return nil, nil
+	}
+	if len(entries) > 0 {
+		if err := tr.Prove(entries[len(entries)-1].Hash[:], 0, proofdb); err != nil {
+			return nil, nil
+		}
+	}
+	proof := make([][]byte, 0, proofdb.Len())
+	for _, key := range proofdb.Keys() {
+		node, _ := proofdb.Get(key)
+		proof = append(proof, node)
+	}
+	return entries, proof
+}
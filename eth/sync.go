@@ -43,7 +43,11 @@ func (pm *ProtocolManager) syncTransactions(p *peer) {
 	var txs types.Transactions
 	pending, _ := pm.txpool.Pending()
 	for _, batch := range pending {
-		txs = append(txs, batch...)
+		for _, tx := range batch {
+			if !pm.txpool.IsPrivate(tx.Hash()) {
+				txs = append(txs, tx)
+			}
+		}
 	}
 	if len(txs) == 0 {
 		return
@@ -74,7 +78,11 @@ done    = make(chan error, 1) //发送的结果
 		pack.txs = pack.txs[:0]
 		for i := 0; i < len(s.txs) && size < txsyncPackSize; i++ {
 			pack.txs = append(pack.txs, s.txs[i])
-			size += s.txs[i].Size()
+			if s.p.version >= eth65 {
+				size += common.HashLength
+			} else {
+				size += s.txs[i].Size()
+			}
 		}
 //删除将发送的事务。
 		s.txs = s.txs[:copy(s.txs, s.txs[len(pack.txs):])]
@@ -84,7 +92,16 @@ done    = make(chan error, 1) //发送的结果
 //在后台发送包。
 		s.p.Log().Trace("Sending batch of transactions", "count", len(pack.txs), "bytes", size)
 		sending = true
-		go func() { done <- pack.p.SendTransactions(pack.txs) }()
+		if pack.p.version >= eth65 {
+//支持ETH/65的对等端只接收哈希，需要时自行检索交易
+			hashes := make([]common.Hash, len(pack.txs))
+			for i, tx := range pack.txs {
+				hashes[i] = tx.Hash()
+			}
+			go func() { done <- pack.p.SendPooledTransactionHashes(hashes) }()
+		} else {
+			go func() { done <- pack.p.SendTransactions(pack.txs) }()
+		}
 	}
 
 //选择下一个挂起的同步。
@@ -131,6 +148,8 @@ func (pm *ProtocolManager) syncer() {
 //启动并确保清除同步机制
 	pm.fetcher.Start()
 	defer pm.fetcher.Stop()
+	pm.txFetcher.Start()
+	defer pm.txFetcher.Stop()
 	defer pm.downloader.Terminate()
 
 //等待不同事件触发同步操作
@@ -175,6 +194,12 @@ func (pm *ProtocolManager) synchronise(peer *peer) {
 	if atomic.LoadUint32(&pm.fastSync) == 1 {
 //已显式请求并授予快速同步
 		mode = downloader.FastSync
+		if atomic.LoadUint32(&pm.snapSync) == 1 {
+			mode = downloader.SnapSync
+		}
+		if atomic.LoadUint32(&pm.prunedSync) == 1 {
+			mode = downloader.PrunedSync
+		}
 	} else if currentBlock.NumberU64() == 0 && pm.blockchain.CurrentFastBlock().NumberU64() > 0 {
 //数据库似乎是空的，因为当前块是Genesis。然而快速
 //块在前面，因此在某个点为该节点启用了快速同步。
@@ -183,9 +208,12 @@ func (pm *ProtocolManager) synchronise(peer *peer) {
 //但是重新启用快速同步是安全的。
 		atomic.StoreUint32(&pm.fastSync, 1)
 		mode = downloader.FastSync
+		if atomic.LoadUint32(&pm.prunedSync) == 1 {
+			mode = downloader.PrunedSync
+		}
 	}
 
-	if mode == downloader.FastSync {
+	if mode.FetchesState() {
 //确保我们正在同步的对等机的总难度更高。
 		if pm.blockchain.GetTdByHash(pm.blockchain.CurrentFastBlock().Hash()).Cmp(pTd) >= 0 {
 			return
@@ -199,6 +227,7 @@ func (pm *ProtocolManager) synchronise(peer *peer) {
 	if atomic.LoadUint32(&pm.fastSync) == 1 {
 		log.Info("Fast sync complete, auto disabling")
 		atomic.StoreUint32(&pm.fastSync, 0)
+		atomic.StoreUint32(&pm.snapSync, 0)
 	}
 atomic.StoreUint32(&pm.acceptTxs, 1) //标记初始同步完成
 	if head := pm.blockchain.CurrentBlock(); head.NumberU64() > 0 {
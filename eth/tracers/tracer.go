@@ -286,6 +286,8 @@ memoryWrapper   *memoryWrapper   //包装虚拟机内存
 contractWrapper *contractWrapper //包装合同对象
 dbWrapper       *dbWrapper       //包装虚拟机环境
 
+env *vm.EVM //当前正在跟踪的EVM，用于检查预编译合同
+
 pcValue     *uint   //由日志访问器包装的可交换PC值
 gasValue    *uint   //由日志访问器包装的可交换气体值
 costValue   *uint   //日志访问器包装的可交换成本值
@@ -386,7 +388,14 @@ func New(code string) (*Tracer, error) {
 		return 1
 	})
 	tracer.vm.PushGlobalGoFunction("isPrecompiled", func(ctx *duktape.Context) int {
-		_, ok := vm.PrecompiledContractsByzantium[common.BytesToAddress(popSlice(ctx))]
+		addr := common.BytesToAddress(popSlice(ctx))
+
+		var ok bool
+		if tracer.env != nil {
+			ok = tracer.env.IsPrecompile(addr)
+		} else {
+			_, ok = vm.PrecompiledContractsByzantium[addr]
+		}
 		ctx.PushBoolean(ok)
 		return 1
 	})
@@ -545,6 +554,7 @@ func (jst *Tracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost
 		jst.memoryWrapper.memory = memory
 		jst.contractWrapper.contract = contract
 		jst.dbWrapper.db = env.StateDB
+		jst.env = env
 
 		*jst.pcValue = uint(pc)
 		*jst.gasValue = uint(gas)
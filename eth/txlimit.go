@@ -0,0 +1,162 @@
+
+//<developer>
+//    <name>linapex 曹一峰</name>
+//    <email>linapex@163.com</email>
+//    <wx>superexc</wx>
+//    <qqgroup>128148617</qqgroup>
+//    <url>https://jsq.ink</url>
+//    <role>pku engineer</role>
+//    <date>2019-03-16 19:16:37</date>
+//</624450089196195840>
+
+
+package eth
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core"
+)
+
+const (
+txPeerRate        = 256  //每个对等方每秒允许进入池的平均事务数
+txPeerBurst       = 4096 //对等方可以一次发送的最大事务数（允许初始同步）
+txRejectWindow    = 1024 //评估对等方拒绝率之前的事务样本数
+txRejectThreshold = 0.75 //超过此拒绝率的对等方将被断开
+)
+
+//TxPeerStats是从单个对等方接收的事务的统计信息。
+type TxPeerStats struct {
+Admitted  uint64 `json:"admitted"`  //被池接受的交易
+Ignored   uint64 `json:"ignored"`   //取决于本地状态而被拒绝的交易（已知、nonce、价格或余额），通常是无害的
+Rejected  uint64 `json:"rejected"`  //任何节点都不会接受的无效交易
+Throttled uint64 `json:"throttled"` //因超出对等方或发送者速率限制而未处理的交易
+}
+
+//txPeerLimit是单个对等方的令牌桶和拒绝窗口。
+type txPeerLimit struct {
+	stats TxPeerStats
+
+tokens  float64   //当前允许的事务数
+updated time.Time //上次补充令牌的时间
+
+windowTotal    uint64 //当前窗口中处理的事务
+windowRejected uint64 //当前窗口中被限制或无效的事务
+}
+
+//txLimiter跟踪每个对等方的事务准入率和拒绝率，限制泛滥的对等方，
+//并标记主要发送被限制或无效事务的对等方。
+type txLimiter struct {
+	rate  float64
+	burst float64
+	peers map[string]*txPeerLimit
+	lock  sync.Mutex
+}
+
+//newTxLimiter创建具有给定速率（每秒事务）和突发的限制器。
+func newTxLimiter(rate, burst float64) *txLimiter {
+	return &txLimiter{
+		rate:  rate,
+		burst: burst,
+		peers: make(map[string]*txPeerLimit),
+	}
+}
+
+//register开始跟踪对等方，以满桶开始。
+func (l *txLimiter) register(id string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.peers[id] = &txPeerLimit{tokens: l.burst, updated: time.Now()}
+}
+
+//unregister停止跟踪对等方。
+func (l *txLimiter) unregister(id string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	delete(l.peers, id)
+}
+
+//allow返回对等方的count个事务中现在允许处理的数目，并将其余的
+//计为受限制的。
+func (l *txLimiter) allow(id string, count int) int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	peer := l.peers[id]
+	if peer == nil {
+		return count
+	}
+//根据经过的时间补充令牌
+	now := time.Now()
+	peer.tokens += now.Sub(peer.updated).Seconds() * l.rate
+	if peer.tokens > l.burst {
+		peer.tokens = l.burst
+	}
+	peer.updated = now
+
+	allowed := count
+	if float64(allowed) > peer.tokens {
+		allowed = int(peer.tokens)
+	}
+	peer.tokens -= float64(allowed)
+
+	if throttled := count - allowed; throttled > 0 {
+		peer.stats.Throttled += uint64(throttled)
+		peer.windowTotal += uint64(throttled)
+		peer.windowRejected += uint64(throttled)
+		txThrottledMeter.Mark(int64(throttled))
+	}
+	return allowed
+}
+
+//account记录池对对等方事务的处理结果，如果对等方在最后一个完整
+//窗口中的拒绝率超过阈值，则返回true。只有速率限制的命中和无效事务计入拒绝，
+//因余额、nonce或价格而被拒绝的事务取决于本地状态，诚实的对等方也会转发它们。
+func (l *txLimiter) account(id string, errs []error) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	peer := l.peers[id]
+	if peer == nil {
+		return false
+	}
+	for _, err := range errs {
+		switch err {
+		case nil:
+			peer.stats.Admitted++
+		case core.ErrSenderThrottled:
+			peer.stats.Throttled++
+			peer.windowRejected++
+			txThrottledMeter.Mark(1)
+		case core.ErrInvalidSender, core.ErrIntrinsicGas, core.ErrNegativeValue, core.ErrOversizedData:
+			peer.stats.Rejected++
+			peer.windowRejected++
+			txRejectedMeter.Mark(1)
+		default:
+			peer.stats.Ignored++
+		}
+		peer.windowTotal++
+	}
+	if peer.windowTotal < txRejectWindow {
+		return false
+	}
+	abusive := float64(peer.windowRejected)/float64(peer.windowTotal) > txRejectThreshold
+	peer.windowTotal, peer.windowRejected = 0, 0
+
+	return abusive
+}
+
+//stats返回所有跟踪对等方的事务统计信息的副本。
+func (l *txLimiter) stats() map[string]TxPeerStats {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	stats := make(map[string]TxPeerStats, len(l.peers))
+	for id, peer := range l.peers {
+		stats[id] = peer.stats
+	}
+	return stats
+}
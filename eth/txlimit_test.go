@@ -0,0 +1,101 @@
+
+//<developer>
+//    <name>linapex 曹一峰</name>
+//    <email>linapex@163.com</email>
+//    <wx>superexc</wx>
+//    <qqgroup>128148617</qqgroup>
+//    <url>https://jsq.ink</url>
+//    <role>pku engineer</role>
+//    <date>2019-03-16 19:16:37</date>
+//</624450089250721792>
+
+
+package eth
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core"
+)
+
+//测试对等方在突发用尽后被限制，而未注册的对等方不受限制。
+func TestTxLimiterThrottling(t *testing.T) {
+	limiter := newTxLimiter(0, 10)
+	limiter.register("peer")
+
+	if allowed := limiter.allow("peer", 8); allowed != 8 {
+		t.Fatalf("first batch: allowed %d, want %d", allowed, 8)
+	}
+	if allowed := limiter.allow("peer", 8); allowed != 2 {
+		t.Fatalf("second batch: allowed %d, want %d", allowed, 2)
+	}
+	if allowed := limiter.allow("unknown", 100); allowed != 100 {
+		t.Fatalf("unregistered peer: allowed %d, want %d", allowed, 100)
+	}
+	if stats := limiter.stats()["peer"]; stats.Throttled != 6 {
+		t.Fatalf("throttled count mismatch: have %d, want %d", stats.Throttled, 6)
+	}
+	limiter.unregister("peer")
+	if _, ok := limiter.stats()["peer"]; ok {
+		t.Fatalf("unregistered peer still tracked")
+	}
+}
+
+//测试只有主要发送无效事务的对等方才会被标记为滥用，而已知、定价过低、
+//余额不足或nonce过低的事务不计入拒绝。
+func TestTxLimiterRejectRatio(t *testing.T) {
+	limiter := newTxLimiter(txPeerRate, txPeerBurst)
+	limiter.register("honest")
+	limiter.register("spammer")
+
+	ignored := []error{core.ErrAlreadyKnown, core.ErrUnderpriced, core.ErrInsufficientFunds, core.ErrNonceTooLow}
+
+	benign := make([]error, txRejectWindow)
+	for i := range benign {
+		benign[i] = ignored[i%len(ignored)]
+	}
+	if limiter.account("honest", benign) {
+		t.Errorf("honest peer flagged as abusive")
+	}
+	invalid := make([]error, txRejectWindow)
+	for i := range invalid {
+		invalid[i] = core.ErrIntrinsicGas
+	}
+	if limiter.account("spammer", invalid[:txRejectWindow/2]) {
+		t.Errorf("peer flagged before the window filled")
+	}
+	if !limiter.account("spammer", invalid[txRejectWindow/2:]) {
+		t.Errorf("spamming peer not flagged")
+	}
+	stats := limiter.stats()
+	if stats["honest"].Ignored != txRejectWindow || stats["honest"].Rejected != 0 {
+		t.Errorf("honest stats mismatch: %+v", stats["honest"])
+	}
+	if stats["spammer"].Rejected != txRejectWindow {
+		t.Errorf("spammer stats mismatch: %+v", stats["spammer"])
+	}
+}
+
+//测试速率限制的命中计入拒绝率，因此持续泛滥的对等方被标记为滥用。
+func TestTxLimiterThrottleRatio(t *testing.T) {
+	limiter := newTxLimiter(0, 0)
+	limiter.register("flooder")
+	limiter.register("sender")
+
+	if allowed := limiter.allow("flooder", txRejectWindow); allowed != 0 {
+		t.Fatalf("allowed %d, want %d", allowed, 0)
+	}
+	if !limiter.account("flooder", nil) {
+		t.Errorf("flooding peer not flagged")
+	}
+	throttled := make([]error, txRejectWindow)
+	for i := range throttled {
+		throttled[i] = core.ErrSenderThrottled
+	}
+	if !limiter.account("sender", throttled) {
+		t.Errorf("peer relaying throttled senders not flagged")
+	}
+	if stats := limiter.stats()["sender"]; stats.Throttled != txRejectWindow || stats.Ignored != 0 {
+		t.Errorf("stats mismatch: %+v", stats)
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"math/big"
 
 	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
@@ -451,7 +452,7 @@ func (ec *Client) CallContract(ctx context.Context, msg ethereum.CallMsg, blockN
 	var hex hexutil.Bytes
 	err := ec.c.CallContext(ctx, &hex, "eth_call", toCallArg(msg), toBlockNumArg(blockNumber))
 	if err != nil {
-		return nil, err
+		return nil, toRevertError(err)
 	}
 	return hex, nil
 }
@@ -462,7 +463,7 @@ func (ec *Client) PendingCallContract(ctx context.Context, msg ethereum.CallMsg)
 	var hex hexutil.Bytes
 	err := ec.c.CallContext(ctx, &hex, "eth_call", toCallArg(msg), "pending")
 	if err != nil {
-		return nil, err
+		return nil, toRevertError(err)
 	}
 	return hex, nil
 }
@@ -485,7 +486,7 @@ func (ec *Client) EstimateGas(ctx context.Context, msg ethereum.CallMsg) (uint64
 	var hex hexutil.Uint64
 	err := ec.c.CallContext(ctx, &hex, "eth_estimateGas", toCallArg(msg))
 	if err != nil {
-		return 0, err
+		return 0, toRevertError(err)
 	}
 	return uint64(hex), nil
 }
@@ -502,6 +503,45 @@ func (ec *Client) SendTransaction(ctx context.Context, tx *types.Transaction) er
 	return ec.c.CallContext(ctx, nil, "eth_sendRawTransaction", common.ToHex(data))
 }
 
+//revertErrorCode是节点在执行被还原时返回的JSON-RPC错误代码。
+const revertErrorCode = 3
+
+//RevertError是合同执行被还原时由CallContract、PendingCallContract
+//和EstimateGas返回的错误。
+type RevertError struct {
+Reason string //解码的Error(string)还原原因，如果没有则为空
+Data   []byte //合同返回的原始还原数据
+
+	message string
+}
+
+//错误返回节点报告的错误消息。
+func (e *RevertError) Error() string {
+	return e.message
+}
+
+//toRevertError将节点返回的还原错误转换为RevertError，
+//其他错误按原样返回。
+func toRevertError(err error) error {
+	if ec, ok := err.(rpc.Error); !ok || ec.ErrorCode() != revertErrorCode {
+		return err
+	}
+	de, ok := err.(rpc.DataError)
+	if !ok {
+		return err
+	}
+	hexdata, ok := de.ErrorData().(string)
+	if !ok {
+		return err
+	}
+	data, derr := hexutil.Decode(hexdata)
+	if derr != nil {
+		return err
+	}
+	reason, _ := abi.UnpackRevert(data)
+	return &RevertError{Reason: reason, Data: data, message: err.Error()}
+}
+
 func toCallArg(msg ethereum.CallMsg) interface{} {
 	arg := map[string]interface{}{
 		"from": msg.From,
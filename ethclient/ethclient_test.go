@@ -20,6 +20,7 @@ import (
 
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 )
 
 //验证客户端是否实现了以太坊接口。
@@ -147,3 +148,37 @@ func TestToFilterArg(t *testing.T) {
 	}
 }
 
+//testRPCError模拟rpc客户端返回的带数据的错误。
+type testRPCError struct {
+	code int
+	data interface{}
+}
+
+func (e *testRPCError) Error() string          { return "execution reverted: revert reason" }
+func (e *testRPCError) ErrorCode() int         { return e.code }
+func (e *testRPCError) ErrorData() interface{} { return e.data }
+
+func TestToRevertError(t *testing.T) {
+	data := common.Hex2Bytes("08c379a00000000000000000000000000000000000000000000000000000000000000020000000000000000000000000000000000000000000000000000000000000000d72657665727420726561736f6e00000000000000000000000000000000000000")
+
+//未知错误代码保持不变
+	plain := &testRPCError{code: -32000, data: hexutil.Encode(data)}
+	if err := toRevertError(plain); err != plain {
+		t.Fatalf("non-revert error converted: %v", err)
+	}
+//还原错误将转换并解码
+	err := toRevertError(&testRPCError{code: revertErrorCode, data: hexutil.Encode(data)})
+	revert, ok := err.(*RevertError)
+	if !ok {
+		t.Fatalf("expected *RevertError, got %T", err)
+	}
+	if revert.Reason != "revert reason" {
+		t.Errorf("reason mismatch: have %q, want %q", revert.Reason, "revert reason")
+	}
+	if !reflect.DeepEqual(revert.Data, data) {
+		t.Errorf("data mismatch: have %x, want %x", revert.Data, data)
+	}
+	if revert.Error() != "execution reverted: revert reason" {
+		t.Errorf("message mismatch: have %q", revert.Error())
+	}
+}
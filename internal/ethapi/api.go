@@ -23,6 +23,7 @@ import (
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
@@ -138,6 +139,37 @@ func (s *PublicTxPoolAPI) Status() map[string]hexutil.Uint {
 	}
 }
 
+//RPCDroppedTransaction描述从事务池中删除的事务以及删除的原因。
+type RPCDroppedTransaction struct {
+	Hash       common.Hash    `json:"hash"`
+	From       common.Address `json:"from"`
+	Nonce      hexutil.Uint64 `json:"nonce"`
+	Reason     string         `json:"reason"`
+	ReplacedBy *common.Hash   `json:"replacedBy,omitempty"`
+	Time       hexutil.Uint64 `json:"time"`
+}
+
+//Dropped返回事务从池中删除的原因，如果事务仍在池中或
+//不在最近的删除历史记录中，则返回nil。
+func (s *PublicTxPoolAPI) Dropped(hash common.Hash) *RPCDroppedTransaction {
+	record := s.b.TxPoolDropped(hash)
+	if record == nil {
+		return nil
+	}
+	result := &RPCDroppedTransaction{
+		Hash:   record.Hash,
+		From:   record.From,
+		Nonce:  hexutil.Uint64(record.Nonce),
+		Reason: record.Reason.String(),
+		Time:   hexutil.Uint64(record.Time.Unix()),
+	}
+	if record.ReplacedBy != (common.Hash{}) {
+		replacement := record.ReplacedBy
+		result.ReplacedBy = &replacement
+	}
+	return result
+}
+
 //inspect检索事务池的内容并将其扁平化为
 //易于检查的列表。
 func (s *PublicTxPoolAPI) Inspect() map[string]map[string]map[string]string {
@@ -679,6 +711,19 @@ type CallArgs struct {
 	Data     hexutil.Bytes   `json:"data"`
 }
 
+//ToMessage将调用参数转换为可由EVM执行的消息，
+//未设置的天然气和天然气价格将使用默认值填充。
+func (args *CallArgs) ToMessage() types.Message {
+	gas, gasPrice := uint64(args.Gas), args.GasPrice.ToInt()
+	if gas == 0 {
+		gas = math.MaxUint64 / 2
+	}
+	if gasPrice.Sign() == 0 {
+		gasPrice = new(big.Int).SetUint64(defaultGasPrice)
+	}
+	return types.NewMessage(args.From, args.To, 0, args.Value.ToInt(), gas, gasPrice, args.Data, false)
+}
+
 func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber, timeout time.Duration) ([]byte, uint64, bool, error) {
 	defer func(start time.Time) { log.Debug("Executing EVM call finished", "runtime", time.Since(start)) }(time.Now())
 
@@ -687,25 +732,15 @@ func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr
 		return nil, 0, false, err
 	}
 //Set sender address or use a default if none specified
-	addr := args.From
-	if addr == (common.Address{}) {
+	if args.From == (common.Address{}) {
 		if wallets := s.b.AccountManager().Wallets(); len(wallets) > 0 {
 			if accounts := wallets[0].Accounts(); len(accounts) > 0 {
-				addr = accounts[0].Address
+				args.From = accounts[0].Address
 			}
 		}
 	}
-//如果未设置，则设置默认的天然气和天然气价格
-	gas, gasPrice := uint64(args.Gas), args.GasPrice.ToInt()
-	if gas == 0 {
-		gas = math.MaxUint64 / 2
-	}
-	if gasPrice.Sign() == 0 {
-		gasPrice = new(big.Int).SetUint64(defaultGasPrice)
-	}
-
-//创建新的呼叫消息
-	msg := types.NewMessage(addr, args.To, 0, args.Value.ToInt(), gas, gasPrice, args.Data, false)
+//创建新的呼叫消息，如果未设置，则使用默认的天然气和天然气价格
+	msg := args.ToMessage()
 
 //设置上下文，以便取消调用
 //或者，对于未计量的气体，设置一个超时上下文。
@@ -741,11 +776,62 @@ func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr
 	return res, gas, failed, err
 }
 
+//revertError是执行被还原时eth_call和eth_estimateGas返回的错误，
+//它携带十六进制编码的原始还原数据作为JSON-RPC错误数据。
+type revertError struct {
+	error
+reason string //十六进制编码的还原数据
+}
+
+//ErrorCode返回还原错误的JSON-RPC错误代码。
+func (e *revertError) ErrorCode() int {
+	return 3
+}
+
+//ErrorData返回十六进制编码的还原数据。
+func (e *revertError) ErrorData() interface{} {
+	return e.reason
+}
+
+//newRevertError根据EVM返回的还原数据创建revertError，
+//如果数据是Error(string)编码的，则将原因附加到错误消息中。
+func newRevertError(ret []byte) *revertError {
+	err := errors.New("execution reverted")
+	if reason, errUnpack := abi.UnpackRevert(ret); errUnpack == nil {
+		err = fmt.Errorf("execution reverted: %v", reason)
+	}
+	return &revertError{
+		error:  err,
+		reason: hexutil.Encode(ret),
+	}
+}
+
+//isRevert报告失败的调用是否因还原而失败。只有REVERT会在失败时
+//返回数据，但合约创建在代码过大或存储代码气体不足时也会
+//返回代码，因此此时仅接受可以解码的还原原因。
+func isRevert(args CallArgs, failed bool, ret []byte) bool {
+	if !failed || len(ret) == 0 {
+		return false
+	}
+	if args.To == nil {
+		_, err := abi.UnpackRevert(ret)
+		return err == nil
+	}
+	return true
+}
+
 //调用对给定块号的状态执行给定事务。
 //它不会在状态/区块链中进行更改，并且对执行和检索值很有用。
 func (s *PublicBlockChainAPI) Call(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber) (hexutil.Bytes, error) {
-	result, _, _, err := s.doCall(ctx, args, blockNr, 5*time.Second)
-	return (hexutil.Bytes)(result), err
+	result, _, failed, err := s.doCall(ctx, args, blockNr, 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+//如果执行被还原，则将还原原因返回给调用方
+	if isRevert(args, failed, result) {
+		return nil, newRevertError(result)
+	}
+	return (hexutil.Bytes)(result), nil
 }
 
 //EstimateGas返回执行
@@ -770,19 +856,20 @@ func (s *PublicBlockChainAPI) EstimateGas(ctx context.Context, args CallArgs) (h
 	cap = hi
 
 //创建一个助手以检查气体限额是否导致可执行事务
-	executable := func(gas uint64) bool {
+//失败时还返回执行结果，以便提取还原原因
+	executable := func(gas uint64) (bool, []byte, bool) {
 		args.Gas = hexutil.Uint64(gas)
 
-		_, _, failed, err := s.doCall(ctx, args, rpc.PendingBlockNumber, 0)
+		res, _, failed, err := s.doCall(ctx, args, rpc.PendingBlockNumber, 0)
 		if err != nil || failed {
-			return false
+			return false, res, failed
 		}
-		return true
+		return true, nil, false
 	}
 //执行二进制搜索并按可执行的气体限值接通。
 	for lo+1 < hi {
 		mid := (hi + lo) / 2
-		if !executable(mid) {
+		if ok, _, _ := executable(mid); !ok {
 			lo = mid
 		} else {
 			hi = mid
@@ -790,7 +877,10 @@ func (s *PublicBlockChainAPI) EstimateGas(ctx context.Context, args CallArgs) (h
 	}
 //如果交易仍以最高限额失败，则将其视为无效拒绝交易
 	if hi == cap {
-		if !executable(hi) {
+		if ok, res, failed := executable(hi); !ok {
+			if isRevert(args, failed, res) {
+				return 0, newRevertError(res)
+			}
 			return 0, fmt.Errorf("gas required exceeds allowance or always failing transaction")
 		}
 	}
@@ -1318,6 +1408,28 @@ func (s *PublicTransactionPoolAPI) SendRawTransaction(ctx context.Context, encod
 	return submitTransaction(ctx, s.b, tx)
 }
 
+//SendPrivateRawTransaction将签名的事务添加到本地事务池中，但不会
+//将其广播给对等方，因此它只能被包含在本地挖掘的块中。如果指定了
+//maxBlock，事务在该区块之后仍未被包括时将被删除。
+func (s *PublicTransactionPoolAPI) SendPrivateRawTransaction(ctx context.Context, encodedTx hexutil.Bytes, maxBlock *hexutil.Uint64) (common.Hash, error) {
+	tx := new(types.Transaction)
+	if err := rlp.DecodeBytes(encodedTx, tx); err != nil {
+		return common.Hash{}, err
+	}
+	var deadline uint64
+	if maxBlock != nil {
+		deadline = uint64(*maxBlock)
+		if current := s.b.CurrentBlock().NumberU64(); deadline <= current {
+			return common.Hash{}, fmt.Errorf("deadline block %d already passed (head %d)", deadline, current)
+		}
+	}
+	if err := s.b.SendPrivateTx(ctx, tx, deadline); err != nil {
+		return common.Hash{}, err
+	}
+	log.Info("Submitted private transaction", "fullhash", tx.Hash().Hex(), "deadline", deadline)
+	return tx.Hash(), nil
+}
+
 //sign为以下项计算ECDSA签名：
 //keccack256（“\x19ethereum签名消息：\n”+len（消息）+消息）。
 //
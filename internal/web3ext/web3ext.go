@@ -19,6 +19,7 @@ var Modules = map[string]string{
 	"chequebook": Chequebook_JS,
 	"clique":     Clique_JS,
 	"ethash":     Ethash_JS,
+	"ibft":       IBFT_JS,
 	"debug":      Debug_JS,
 	"eth":        Eth_JS,
 	"miner":      Miner_JS,
@@ -97,6 +98,12 @@ web3._extend({
 			call: 'clique_discard',
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'status',
+			call: 'clique_status',
+			params: 1,
+			inputFormatter: [null]
+		}),
 	],
 	properties: [
 		new web3._extend.Property({
@@ -107,6 +114,30 @@ web3._extend({
 });
 `
 
+const IBFT_JS = `
+web3._extend({
+	property: 'ibft',
+	methods: [
+		new web3._extend.Method({
+			name: 'getValidators',
+			call: 'ibft_getValidators'
+		}),
+		new web3._extend.Method({
+			name: 'getCommitters',
+			call: 'ibft_getCommitters',
+			params: 1,
+			inputFormatter: [null]
+		}),
+	],
+	properties: [
+		new web3._extend.Property({
+			name: 'status',
+			getter: 'ibft_status'
+		}),
+	]
+});
+`
+
 const Ethash_JS = `
 web3._extend({
 	property: 'ethash',
@@ -209,6 +240,10 @@ web3._extend({
 			name: 'datadir',
 			getter: 'admin_datadir'
 		}),
+		new web3._extend.Property({
+			name: 'txPeerStats',
+			getter: 'admin_txPeerStats'
+		}),
 	]
 });
 `
@@ -411,6 +446,12 @@ web3._extend({
 			params: 2,
 			inputFormatter: [null, null]
 		}),
+		new web3._extend.Method({
+			name: 'traceCall',
+			call: 'debug_traceCall',
+			params: 3,
+			inputFormatter: [null, null, null]
+		}),
 		new web3._extend.Method({
 			name: 'preimage',
 			call: 'debug_preimage',
@@ -471,6 +512,12 @@ web3._extend({
 			params: 1,
 			inputFormatter: [web3._extend.formatters.inputTransactionFormatter]
 		}),
+		new web3._extend.Method({
+			name: 'sendPrivateRawTransaction',
+			call: 'eth_sendPrivateRawTransaction',
+			params: 2,
+			inputFormatter: [null, null]
+		}),
 		new web3._extend.Method({
 			name: 'submitTransaction',
 			call: 'eth_submitTransaction',
@@ -554,6 +601,11 @@ web3._extend({
 			name: 'getHashrate',
 			call: 'miner_getHashrate'
 		}),
+		new web3._extend.Method({
+			name: 'buildBlock',
+			call: 'miner_buildBlock',
+			params: 1
+		}),
 	],
 	properties: []
 });
@@ -678,7 +730,13 @@ web3._extend({
 const TxPool_JS = `
 web3._extend({
 	property: 'txpool',
-	methods: [],
+	methods: [
+		new web3._extend.Method({
+			name: 'dropped',
+			call: 'txpool_dropped',
+			params: 1
+		}),
+	],
 	properties:
 	[
 		new web3._extend.Property({
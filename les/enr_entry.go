@@ -0,0 +1,47 @@
+
+//<developer>
+//    <name>linapex 曹一峰</name>
+//    <email>linapex@163.com</email>
+//    <wx>superexc</wx>
+//    <qqgroup>128148617</qqgroup>
+//    <url>https://jsq.ink</url>
+//    <role>pku engineer</role>
+//    <date>2019-03-16 19:16:39</date>
+//</624450096095825949>
+
+
+package les
+
+import (
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+//lesEntry是节点记录中的“les”条目。LES服务器在其中宣告所服务的主题，
+//客户端通过v5.1发现查找带有匹配主题的节点。
+type lesEntry struct {
+	Topics []string
+//忽略其他列表元素以便向前兼容。
+	Rest []rlp.RawValue `rlp:"tail"`
+}
+
+//ENRKey实现enr.Entry。
+func (e lesEntry) ENRKey() string {
+	return "les"
+}
+
+//serverFilter返回一个过滤器，它只接受宣告了给定主题的LES服务器。
+func serverFilter(topic string) func(*enode.Node) bool {
+	return func(n *enode.Node) bool {
+		var entry lesEntry
+		if err := n.Load(&entry); err != nil {
+			return false
+		}
+		for _, t := range entry.Topics {
+			if t == topic {
+				return true
+			}
+		}
+		return false
+	}
+}
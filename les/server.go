@@ -40,7 +40,7 @@ type LesServer struct {
 fcManager   *flowcontrol.ClientManager //如果我们的节点只是客户端，则为零
 	fcCostStats *requestCostStats
 	defParams   *flowcontrol.ServerParams
-	lesTopics   []discv5.Topic
+	lesTopics   []string
 	privateKey  *ecdsa.PrivateKey
 	quitSync    chan struct{}
 }
@@ -52,7 +52,7 @@ func NewLesServer(eth *eth.Ethereum, config *eth.Config) (*LesServer, error) {
 		return nil, err
 	}
 
-	lesTopics := make([]discv5.Topic, len(AdvertiseProtocolVersions))
+	lesTopics := make([]string, len(AdvertiseProtocolVersions))
 	for i, pv := range AdvertiseProtocolVersions {
 		lesTopics[i] = lesTopic(eth.BlockChain().Genesis().Hash(), pv)
 	}
@@ -112,7 +112,7 @@ func (s *LesServer) Start(srvr *p2p.Server) {
 	s.protocolManager.Start(s.config.LightPeers)
 	if srvr.DiscV5 != nil {
 		for _, topic := range s.lesTopics {
-			topic := topic
+			topic := discv5.Topic(topic)
 			go func() {
 				logger := log.New("topic", topic)
 				logger.Info("Starting topic registration")
@@ -122,6 +122,9 @@ func (s *LesServer) Start(srvr *p2p.Server) {
 			}()
 		}
 	}
+//在节点记录中宣告所服务的主题，v5.1发现的客户端据此找到本服务器。
+	srvr.LocalNode().Set(lesEntry{Topics: s.lesTopics})
+	log.Info("Advertising LES topics in node record", "topics", s.lesTopics)
 	s.privateKey = srvr.PrivateKey
 	s.protocolManager.blockLoop()
 }
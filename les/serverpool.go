@@ -116,7 +116,7 @@ type serverPool struct {
 	wg     *sync.WaitGroup
 	connWg sync.WaitGroup
 
-	topic discv5.Topic
+	topic string
 
 	discSetPeriod chan time.Duration
 	discNodes     chan *enode.Node
@@ -158,28 +158,32 @@ func newServerPool(db ethdb.Database, quit chan struct{}, wg *sync.WaitGroup) *s
 	return pool
 }
 
-func (pool *serverPool) start(server *p2p.Server, topic discv5.Topic) {
+func (pool *serverPool) start(server *p2p.Server, topic string) {
 	pool.server = server
 	pool.topic = topic
 	pool.dbKey = append([]byte("serverPool/"), []byte(topic)...)
 	pool.wg.Add(1)
 	pool.loadNodes()
 
-	if pool.server.DiscV5 != nil {
+	if pool.server.DiscV51 != nil || pool.server.DiscV5 != nil {
 		pool.discSetPeriod = make(chan time.Duration, 1)
 		pool.discNodes = make(chan *enode.Node, 100)
 		pool.discLookups = make(chan bool, 100)
-		go pool.discoverNodes()
+		if pool.server.DiscV51 != nil {
+			go pool.discoverNodes()
+		} else {
+			go pool.searchTopic()
+		}
 	}
 	pool.checkDial()
 	go pool.eventLoop()
 }
 
-//discovernodes包装搜索主题，将结果节点转换为enode.node。
-func (pool *serverPool) discoverNodes() {
+//searchTopic在v5主题发现中搜索主题，将结果节点转换为enode.node。
+func (pool *serverPool) searchTopic() {
 	ch := make(chan *discv5.Node)
 	go func() {
-		pool.server.DiscV5.SearchTopic(pool.topic, pool.discSetPeriod, ch, pool.discLookups)
+		pool.server.DiscV5.SearchTopic(discv5.Topic(pool.topic), pool.discSetPeriod, ch, pool.discLookups)
 		close(ch)
 	}()
 	for n := range ch {
@@ -191,6 +195,42 @@ func (pool *serverPool) discoverNodes() {
 	}
 }
 
+//discoverNodes按discSetPeriod设置的间隔执行v5.1查找，
+//并把记录中宣告了本主题的节点交给事件循环。discSetPeriod关闭时返回。
+func (pool *serverPool) discoverNodes() {
+	filter := serverFilter(pool.topic)
+	period, ok := <-pool.discSetPeriod
+	if !ok {
+		return
+	}
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case p, ok := <-pool.discSetPeriod:
+			if !ok {
+				return
+			}
+			period = p
+			continue
+		case <-timer.C:
+		}
+		for _, n := range pool.server.DiscV51.LookupFilter(filter) {
+			select {
+			case pool.discNodes <- n:
+			case <-pool.quit:
+				return
+			}
+		}
+		select {
+		case pool.discLookups <- true:
+		case <-pool.quit:
+			return
+		}
+		timer.Reset(period)
+	}
+}
+
 //任何传入连接都应调用Connect。如果连接
 //最近由服务器池拨号，返回相应的池条目。
 //否则，应拒绝连接。
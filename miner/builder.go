@@ -0,0 +1,201 @@
+
+//<developer>
+//    <name>linapex 曹一峰</name>
+//    <email>linapex@163.com</email>
+//    <wx>superexc</wx>
+//    <qqgroup>128148617</qqgroup>
+//    <url>https://jsq.ink</url>
+//    <role>pku engineer</role>
+//    <date>2019-03-16 19:16:40</date>
+//</624450100520816640>
+
+
+package miner
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/misc"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+var (
+//当请求的父块未知时，返回errUnknownParent。
+	errUnknownParent = errors.New("unknown parent block")
+
+//如果请求的时间戳不晚于父块，则返回errInvalidTimestamp。
+	errInvalidTimestamp = errors.New("timestamp not after parent")
+
+//如果请求的额外数据超过允许的最大长度，则返回errExtraTooLong。
+	errExtraTooLong = errors.New("extra data too long")
+)
+
+//BuildArgs是构建未密封块的参数。
+type BuildArgs struct {
+Parent    common.Hash        //要在其上构建的父块的哈希
+Timestamp uint64             //新块的时间戳，必须晚于父块
+Coinbase  common.Address     //接收块奖励和费用的地址
+Extra     []byte             //块头中的额外数据，nil表示使用矿工的默认值
+Forced    types.Transactions //必须按顺序首先包含的交易
+NoPool    bool               //仅包含强制交易，不从交易池中填充
+}
+
+//BuiltBlock是完全构建但未密封的块及其执行结果。
+type BuiltBlock struct {
+Block    *types.Block   //未密封的块，头中没有密封字段
+Receipts types.Receipts //块中每个交易的收据
+Fees     *big.Int       //块中所有交易支付给coinbase的费用总额
+}
+
+//BuildBlock根据给定参数构建一个完全执行但未密封的块，而不会
+//影响当前的挖掘工作。强制交易首先被应用，其中任何一个失败都会
+//中止构建，随后用交易池中的可执行交易填充块。
+func (self *Miner) BuildBlock(args *BuildArgs) (*BuiltBlock, error) {
+	return self.worker.buildBlock(args)
+}
+
+//buildBlock在独立的环境中构建未密封的块，不修改工人的当前状态。
+func (w *worker) buildBlock(args *BuildArgs) (*BuiltBlock, error) {
+	w.mu.RLock()
+	extra, gasFloor, gasCeil, ordering := w.extra, w.gasFloor, w.gasCeil, w.ordering
+	w.mu.RUnlock()
+
+	if args.Extra != nil {
+		extra = args.Extra
+	}
+	if uint64(len(extra)) > params.MaximumExtraDataSize {
+		return nil, errExtraTooLong
+	}
+	parent := w.chain.GetBlockByHash(args.Parent)
+	if parent == nil {
+		return nil, errUnknownParent
+	}
+	if parent.Time().Uint64() >= args.Timestamp {
+		return nil, errInvalidTimestamp
+	}
+	num := parent.Number()
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		Number:     num.Add(num, common.Big1),
+		GasLimit:   core.CalcGasLimit(parent, gasFloor, gasCeil),
+		Extra:      common.CopyBytes(extra),
+		Time:       new(big.Int).SetUint64(args.Timestamp),
+		Coinbase:   args.Coinbase,
+	}
+	if err := w.engine.Prepare(w.chain, header); err != nil {
+		return nil, err
+	}
+//如果我们关心DAO硬分叉，请检查是否覆盖额外的数据
+	if daoBlock := w.config.DAOForkBlock; daoBlock != nil {
+		limit := new(big.Int).Add(daoBlock, params.DAOForkExtraRange)
+		if header.Number.Cmp(daoBlock) >= 0 && header.Number.Cmp(limit) < 0 {
+			if w.config.DAOForkSupport {
+				header.Extra = common.CopyBytes(params.DAOForkBlockExtra)
+			} else if bytes.Equal(header.Extra, params.DAOForkBlockExtra) {
+				header.Extra = []byte{}
+			}
+		}
+	}
+	state, err := w.chain.StateAt(parent.Root())
+	if err != nil {
+		return nil, err
+	}
+	env := &environment{
+		signer:  types.NewEIP155Signer(w.config.ChainID),
+		state:   state,
+		header:  header,
+		gasPool: new(core.GasPool).AddGas(header.GasLimit),
+	}
+	if w.config.DAOForkSupport && w.config.DAOForkBlock != nil && w.config.DAOForkBlock.Cmp(header.Number) == 0 {
+		misc.ApplyDAOHardFork(env.state)
+	}
+//首先应用强制交易，任何失败都会使构建无效
+	for i, tx := range args.Forced {
+		if err := w.applyTransaction(env, tx, args.Coinbase); err != nil {
+			return nil, fmt.Errorf("forced transaction %d (%x) failed: %v", i, tx.Hash(), err)
+		}
+	}
+//用池中的交易填充块的剩余部分，本地交易优先
+	if !args.NoPool {
+		pending, err := w.eth.TxPool().Pending()
+		if err != nil {
+			return nil, err
+		}
+		localTxs, remoteTxs := make(map[common.Address]types.Transactions), pending
+		for _, account := range w.eth.TxPool().Locals() {
+			if txs := remoteTxs[account]; len(txs) > 0 {
+				delete(remoteTxs, account)
+				localTxs[account] = txs
+			}
+		}
+		if len(localTxs) > 0 {
+			w.fillTransactions(env, ordering.Order(env.signer, localTxs), args.Coinbase)
+		}
+		if len(remoteTxs) > 0 {
+			w.fillTransactions(env, ordering.Order(env.signer, remoteTxs), args.Coinbase)
+		}
+	}
+	block, err := w.engine.Finalize(w.chain, env.header, env.state, env.txs, nil, env.receipts)
+	if err != nil {
+		return nil, err
+	}
+	fees := new(big.Int)
+	for i, tx := range env.txs {
+		fees.Add(fees, new(big.Int).Mul(new(big.Int).SetUint64(env.receipts[i].GasUsed), tx.GasPrice()))
+	}
+	return &BuiltBlock{Block: block, Receipts: env.receipts, Fees: fees}, nil
+}
+
+//applyTransaction在给定环境中执行单个交易，失败时还原状态。
+func (w *worker) applyTransaction(env *environment, tx *types.Transaction, coinbase common.Address) error {
+	if tx.Protected() && !w.config.IsEIP155(env.header.Number) {
+		return errors.New("replay protected transaction before EIP155")
+	}
+	env.state.Prepare(tx.Hash(), common.Hash{}, env.tcount)
+
+	snap := env.state.Snapshot()
+	receipt, _, err := core.ApplyTransaction(w.config, w.chain, &coinbase, env.gasPool, env.state, env.header, tx, &env.header.GasUsed, *w.chain.GetVMConfig())
+	if err != nil {
+		env.state.RevertToSnapshot(snap)
+		return err
+	}
+	env.txs = append(env.txs, tx)
+	env.receipts = append(env.receipts, receipt)
+	env.tcount++
+
+	return nil
+}
+
+//fillTransactions将事务集中的交易尽可能多地应用到给定环境中，
+//跳过失败的交易，与commitTransactions相同但不可中断。
+func (w *worker) fillTransactions(env *environment, txs TxSet, coinbase common.Address) {
+	for env.gasPool.Gas() >= params.TxGas {
+		tx := txs.Peek()
+		if tx == nil {
+			break
+		}
+		from, _ := types.Sender(env.signer, tx)
+		if tx.Protected() && !w.config.IsEIP155(env.header.Number) {
+			txs.Pop()
+			continue
+		}
+		switch err := w.applyTransaction(env, tx, coinbase); err {
+		case core.ErrGasLimitReached, core.ErrNonceTooHigh:
+			txs.Pop()
+
+		case core.ErrNonceTooLow, nil:
+			txs.Shift()
+
+		default:
+			log.Debug("Transaction failed, account skipped", "sender", from, "hash", tx.Hash(), "err", err)
+			txs.Shift()
+		}
+	}
+}
@@ -0,0 +1,74 @@
+
+//<developer>
+//    <name>linapex 曹一峰</name>
+//    <email>linapex@163.com</email>
+//    <wx>superexc</wx>
+//    <qqgroup>128148617</qqgroup>
+//    <url>https://jsq.ink</url>
+//    <role>pku engineer</role>
+//    <date>2019-03-16 19:16:40</date>
+//</624450100541788160>
+
+
+package miner
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+//测试构建未密封的块时首先包含强制交易，并从池中填充剩余部分。
+func TestBuildBlock(t *testing.T) {
+	engine := ethash.NewFaker()
+	defer engine.Close()
+
+	w, b := newTestWorker(t, ethashChainConfig, engine, 0)
+	defer w.close()
+
+	parent := b.chain.CurrentBlock()
+	coinbase := common.Address{0x01}
+
+//仅从池中构建
+	built, err := w.buildBlock(&BuildArgs{Parent: parent.Hash(), Timestamp: parent.Time().Uint64() + 1, Coinbase: coinbase})
+	if err != nil {
+		t.Fatalf("failed to build block: %v", err)
+	}
+	if txs := built.Block.Transactions(); len(txs) != 1 || txs[0].Hash() != pendingTxs[0].Hash() {
+		t.Fatalf("pool transactions mismatch: have %d txs", len(txs))
+	}
+	if built.Block.Coinbase() != coinbase || built.Block.NumberU64() != parent.NumberU64()+1 {
+		t.Errorf("header mismatch: coinbase %x, number %d", built.Block.Coinbase(), built.Block.NumberU64())
+	}
+//强制交易必须优先于池中相同nonce的交易
+	forced, _ := types.SignTx(types.NewTransaction(0, coinbase, big.NewInt(1), params.TxGas, big.NewInt(1), nil), types.HomesteadSigner{}, testBankKey)
+	built, err = w.buildBlock(&BuildArgs{Parent: parent.Hash(), Timestamp: parent.Time().Uint64() + 1, Coinbase: coinbase, Forced: types.Transactions{forced}})
+	if err != nil {
+		t.Fatalf("failed to build block with forced transaction: %v", err)
+	}
+	if txs := built.Block.Transactions(); len(txs) != 1 || txs[0].Hash() != forced.Hash() {
+		t.Fatalf("forced transaction not included first")
+	}
+	if len(built.Receipts) != 1 || built.Fees.Cmp(big.NewInt(int64(params.TxGas))) != 0 {
+		t.Errorf("fees mismatch: have %v, want %d", built.Fees, params.TxGas)
+	}
+//无效的强制交易和参数必须中止构建
+	invalid, _ := types.SignTx(types.NewTransaction(5, coinbase, big.NewInt(1), params.TxGas, nil, nil), types.HomesteadSigner{}, testBankKey)
+	if _, err := w.buildBlock(&BuildArgs{Parent: parent.Hash(), Timestamp: parent.Time().Uint64() + 1, Forced: types.Transactions{invalid}}); err == nil {
+		t.Errorf("invalid forced transaction accepted")
+	}
+	if _, err := w.buildBlock(&BuildArgs{Parent: common.Hash{0xff}, Timestamp: 1}); err != errUnknownParent {
+		t.Errorf("unknown parent error mismatch: have %v, want %v", err, errUnknownParent)
+	}
+	if _, err := w.buildBlock(&BuildArgs{Parent: parent.Hash(), Timestamp: parent.Time().Uint64()}); err != errInvalidTimestamp {
+		t.Errorf("timestamp error mismatch: have %v, want %v", err, errInvalidTimestamp)
+	}
+//构建不能影响工人的当前状态
+	if w.current != nil && w.current.header.Coinbase == coinbase {
+		t.Errorf("worker environment modified by block building")
+	}
+}
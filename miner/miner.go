@@ -141,6 +141,11 @@ func (self *Miner) SetExtra(extra []byte) error {
 	return nil
 }
 
+//SetOrdering设置构建块时选择和排序事务的策略。
+func (self *Miner) SetOrdering(ordering TxOrdering) {
+	self.worker.setOrdering(ordering)
+}
+
 //setrecommittinterval设置密封工作重新提交的间隔。
 func (self *Miner) SetRecommitInterval(interval time.Duration) {
 	self.worker.setRecommitInterval(interval)
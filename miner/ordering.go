@@ -0,0 +1,229 @@
+
+//<developer>
+//    <name>linapex 曹一峰</name>
+//    <email>linapex@163.com</email>
+//    <wx>superexc</wx>
+//    <qqgroup>128148617</qqgroup>
+//    <url>https://jsq.ink</url>
+//    <role>pku engineer</role>
+//    <date>2019-03-16 19:16:40</date>
+//</624450100474679296>
+
+
+package miner
+
+import (
+	"container/heap"
+	"fmt"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+//TxSet是一组按策略排序的可执行事务，工人从中选择要包括在块中
+//的事务。同一帐户的事务总是按nonce顺序返回。
+type TxSet interface {
+//Peek返回下一个要包含的事务，如果没有剩余事务，则返回nil。
+	Peek() *types.Transaction
+
+//Shift将当前事务替换为同一帐户的下一个事务。
+	Shift()
+
+//Pop删除当前事务以及同一帐户的所有后续事务。
+	Pop()
+}
+
+//TxOrdering是块构建期间的事务选择策略。
+type TxOrdering interface {
+//Order从每个帐户的nonce排序事务列表创建事务集。
+//
+//注意，输入映射归事务集所有，调用方不应再使用它。
+	Order(signer types.Signer, txs map[common.Address]types.Transactions) TxSet
+}
+
+//内置的事务排序策略名称。
+const (
+	OrderingPriceAndNonce = "price"
+	OrderingFirstSeen     = "fifo"
+	OrderingFairness      = "fair"
+)
+
+//NewOrdering按名称返回内置的事务排序策略，空名称表示默认的
+//价格排序。
+func NewOrdering(name string) (TxOrdering, error) {
+	switch name {
+	case "", OrderingPriceAndNonce:
+		return PriceAndNonceOrdering{}, nil
+	case OrderingFirstSeen:
+		return FirstSeenOrdering{}, nil
+	case OrderingFairness:
+		return FairnessOrdering{}, nil
+	}
+	return nil, fmt.Errorf("unknown transaction ordering %q", name)
+}
+
+//PriceAndNonceOrdering优先选择天然气价格最高的交易，这是默认策略。
+type PriceAndNonceOrdering struct{}
+
+//Order实现TxOrdering。
+func (PriceAndNonceOrdering) Order(signer types.Signer, txs map[common.Address]types.Transactions) TxSet {
+	return types.NewTransactionsByPriceAndNonce(signer, txs)
+}
+
+//FirstSeenOrdering按本地首次看到的时间（先进先出）选择交易，不考虑
+//天然气价格。
+type FirstSeenOrdering struct{}
+
+//Order实现TxOrdering。
+func (FirstSeenOrdering) Order(signer types.Signer, txs map[common.Address]types.Transactions) TxSet {
+	return newHeadsSet(signer, txs, func(a, b *types.Transaction) bool {
+		if ta, tb := a.FirstSeen(), b.FirstSeen(); !ta.Equal(tb) {
+			return ta.Before(tb)
+		}
+//同时看到的交易按价格排序，以保持确定性
+		return a.GasPrice().Cmp(b.GasPrice()) > 0
+	})
+}
+
+//FairnessOrdering以循环方式从每个帐户中选择一个交易，这样单个
+//发送者不能用大量交易占满区块。每轮中的帐户按其下一笔交易
+//的价格排序。
+type FairnessOrdering struct{}
+
+//Order实现TxOrdering。
+func (FairnessOrdering) Order(signer types.Signer, txs map[common.Address]types.Transactions) TxSet {
+	set := &roundRobinSet{txs: make(map[common.Address]types.Transactions, len(txs))}
+	for _, accTxs := range txs {
+		if len(accTxs) == 0 {
+			continue
+		}
+//确保发件人地址来自签名者
+		acc, _ := types.Sender(signer, accTxs[0])
+		set.txs[acc] = accTxs
+		set.queue = append(set.queue, acc)
+	}
+	sort.Slice(set.queue, func(i, j int) bool {
+		a, b := set.txs[set.queue[i]][0], set.txs[set.queue[j]][0]
+		if cmp := a.GasPrice().Cmp(b.GasPrice()); cmp != 0 {
+			return cmp > 0
+		}
+		return a.FirstSeen().Before(b.FirstSeen())
+	})
+	return set
+}
+
+//txHeads是按任意比较函数排序的帐户头事务堆。
+type txHeads struct {
+	txs  []*types.Transaction
+	less func(a, b *types.Transaction) bool
+}
+
+func (h *txHeads) Len() int           { return len(h.txs) }
+func (h *txHeads) Less(i, j int) bool { return h.less(h.txs[i], h.txs[j]) }
+func (h *txHeads) Swap(i, j int)      { h.txs[i], h.txs[j] = h.txs[j], h.txs[i] }
+
+func (h *txHeads) Push(x interface{}) {
+	h.txs = append(h.txs, x.(*types.Transaction))
+}
+
+func (h *txHeads) Pop() interface{} {
+	old := h.txs
+	n := len(old)
+	x := old[n-1]
+	h.txs = old[0 : n-1]
+	return x
+}
+
+//headsSet与types.TransactionsByPriceAndNonce相同，但是帐户头事务
+//按给定的比较函数而不是价格排序。
+type headsSet struct {
+txs    map[common.Address]types.Transactions //按科目当前排序的交易记录列表
+heads  *txHeads                              //每个唯一帐户的下一个事务
+signer types.Signer                          //事务集的签名者
+}
+
+func newHeadsSet(signer types.Signer, txs map[common.Address]types.Transactions, less func(a, b *types.Transaction) bool) *headsSet {
+	heads := &txHeads{
+		txs:  make([]*types.Transaction, 0, len(txs)),
+		less: less,
+	}
+	for from, accTxs := range txs {
+		if len(accTxs) == 0 {
+			delete(txs, from)
+			continue
+		}
+		heads.txs = append(heads.txs, accTxs[0])
+//确保发件人地址来自签名者
+		acc, _ := types.Sender(signer, accTxs[0])
+		txs[acc] = accTxs[1:]
+		if from != acc {
+			delete(txs, from)
+		}
+	}
+	heap.Init(heads)
+
+	return &headsSet{
+		txs:    txs,
+		heads:  heads,
+		signer: signer,
+	}
+}
+
+//Peek实现TxSet。
+func (s *headsSet) Peek() *types.Transaction {
+	if len(s.heads.txs) == 0 {
+		return nil
+	}
+	return s.heads.txs[0]
+}
+
+//Shift实现TxSet。
+func (s *headsSet) Shift() {
+	acc, _ := types.Sender(s.signer, s.heads.txs[0])
+	if txs, ok := s.txs[acc]; ok && len(txs) > 0 {
+		s.heads.txs[0], s.txs[acc] = txs[0], txs[1:]
+		heap.Fix(s.heads, 0)
+	} else {
+		heap.Pop(s.heads)
+	}
+}
+
+//Pop实现TxSet。
+func (s *headsSet) Pop() {
+	heap.Pop(s.heads)
+}
+
+//roundRobinSet每次从队列前面的帐户中取一个事务，然后把该帐户移到
+//队列末尾。
+type roundRobinSet struct {
+txs   map[common.Address]types.Transactions //每个帐户剩余的事务
+queue []common.Address                      //帐户的轮换顺序
+}
+
+//Peek实现TxSet。
+func (s *roundRobinSet) Peek() *types.Transaction {
+	if len(s.queue) == 0 {
+		return nil
+	}
+	return s.txs[s.queue[0]][0]
+}
+
+//Shift实现TxSet。
+func (s *roundRobinSet) Shift() {
+	acc := s.queue[0]
+	s.queue = s.queue[1:]
+
+	if txs := s.txs[acc][1:]; len(txs) > 0 {
+		s.txs[acc] = txs
+		s.queue = append(s.queue, acc)
+	} else {
+		delete(s.txs, acc)
+	}
+}
+
+//Pop实现TxSet。
+func (s *roundRobinSet) Pop() {
+	delete(s.txs, s.queue[0])
+	s.queue = s.queue[1:]
+}
@@ -0,0 +1,111 @@
+
+//<developer>
+//    <name>linapex 曹一峰</name>
+//    <email>linapex@163.com</email>
+//    <wx>superexc</wx>
+//    <qqgroup>128148617</qqgroup>
+//    <url>https://jsq.ink</url>
+//    <role>pku engineer</role>
+//    <date>2019-03-16 19:16:40</date>
+//</624450100495650816>
+
+
+package miner
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+//orderingTx创建一个用给定密钥签名的测试事务。
+func orderingTx(signer types.Signer, key *ecdsa.PrivateKey, nonce uint64, price int64) *types.Transaction {
+	tx, _ := types.SignTx(types.NewTransaction(nonce, common.Address{}, big.NewInt(1), 21000, big.NewInt(price), nil), signer, key)
+	return tx
+}
+
+//drainTxSet按顺序返回事务集中的所有事务。
+func drainTxSet(set TxSet) []*types.Transaction {
+	var txs []*types.Transaction
+	for tx := set.Peek(); tx != nil; tx = set.Peek() {
+		txs = append(txs, tx)
+		set.Shift()
+	}
+	return txs
+}
+
+func checkOrder(t *testing.T, have, want []*types.Transaction) {
+	if len(have) != len(want) {
+		t.Fatalf("transaction count mismatch: have %d, want %d", len(have), len(want))
+	}
+	for i := range want {
+		if have[i].Hash() != want[i].Hash() {
+			t.Errorf("transaction %d: have nonce %d price %v, want nonce %d price %v", i, have[i].Nonce(), have[i].GasPrice(), want[i].Nonce(), want[i].GasPrice())
+		}
+	}
+}
+
+//测试先进先出排序按首次看到的时间选择交易，而不考虑价格。
+func TestFirstSeenOrdering(t *testing.T) {
+	signer := types.HomesteadSigner{}
+	key1, _ := crypto.GenerateKey()
+	key2, _ := crypto.GenerateKey()
+
+	var (
+		txs  []*types.Transaction
+		base = time.Now()
+	)
+	for i, key := range []*ecdsa.PrivateKey{key1, key2, key1, key2} {
+		tx := orderingTx(signer, key, uint64(i/2), int64(1+i))
+		tx.SetFirstSeen(base.Add(time.Duration(i) * time.Second))
+		txs = append(txs, tx)
+	}
+	pending := map[common.Address]types.Transactions{
+		crypto.PubkeyToAddress(key1.PublicKey): {txs[0], txs[2]},
+		crypto.PubkeyToAddress(key2.PublicKey): {txs[1], txs[3]},
+	}
+	checkOrder(t, drainTxSet(FirstSeenOrdering{}.Order(signer, pending)), txs)
+}
+
+//测试公平排序在每个发送者之间轮换，从最高价开始。
+func TestFairnessOrdering(t *testing.T) {
+	signer := types.HomesteadSigner{}
+	key1, _ := crypto.GenerateKey()
+	key2, _ := crypto.GenerateKey()
+
+	var (
+		spam  = types.Transactions{orderingTx(signer, key1, 0, 10), orderingTx(signer, key1, 1, 10), orderingTx(signer, key1, 2, 10)}
+		other = types.Transactions{orderingTx(signer, key2, 0, 1), orderingTx(signer, key2, 1, 1)}
+	)
+	pending := map[common.Address]types.Transactions{
+		crypto.PubkeyToAddress(key1.PublicKey): spam,
+		crypto.PubkeyToAddress(key2.PublicKey): other,
+	}
+	want := []*types.Transaction{spam[0], other[0], spam[1], other[1], spam[2]}
+	checkOrder(t, drainTxSet(FairnessOrdering{}.Order(signer, pending)), want)
+
+//弹出帐户时，必须丢弃其所有剩余交易
+	pending = map[common.Address]types.Transactions{
+		crypto.PubkeyToAddress(key1.PublicKey): spam,
+		crypto.PubkeyToAddress(key2.PublicKey): other,
+	}
+	set := FairnessOrdering{}.Order(signer, pending)
+	set.Pop()
+	checkOrder(t, drainTxSet(set), other)
+}
+
+func TestNewOrdering(t *testing.T) {
+	for _, name := range []string{"", OrderingPriceAndNonce, OrderingFirstSeen, OrderingFairness} {
+		if _, err := NewOrdering(name); err != nil {
+			t.Errorf("ordering %q: unexpected error: %v", name, err)
+		}
+	}
+	if _, err := NewOrdering("random"); err == nil {
+		t.Errorf("unknown ordering accepted")
+	}
+}
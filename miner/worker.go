@@ -149,9 +149,10 @@ localUncles  map[common.Hash]*types.Block //局部生成的一组边块，作为
 remoteUncles map[common.Hash]*types.Block //一组侧块作为可能的叔叔块。
 unconfirmed  *unconfirmedBlocks           //一组本地挖掘的块，等待规范性确认。
 
-mu       sync.RWMutex //用于保护coinbase和额外字段的锁
+mu       sync.RWMutex //用于保护coinbase、额外字段和排序策略的锁
 	coinbase common.Address
 	extra    []byte
+ordering TxOrdering //选择块中事务的策略
 
 	pendingMu    sync.RWMutex
 	pendingTasks map[common.Hash]*task
@@ -184,6 +185,7 @@ func newWorker(config *params.ChainConfig, engine consensus.Engine, eth Backend,
 		gasFloor:           gasFloor,
 		gasCeil:            gasCeil,
 		isLocalBlock:       isLocalBlock,
+		ordering:           PriceAndNonceOrdering{},
 		localUncles:        make(map[common.Hash]*types.Block),
 		remoteUncles:       make(map[common.Hash]*types.Block),
 		unconfirmed:        newUnconfirmedBlocks(eth.BlockChain(), miningLogAtDepth),
@@ -236,6 +238,13 @@ func (w *worker) setExtra(extra []byte) {
 	w.extra = extra
 }
 
+//setOrdering设置构建块时使用的事务排序策略。
+func (w *worker) setOrdering(ordering TxOrdering) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.ordering = ordering
+}
+
 //setrecommittinterval更新矿工密封工作重新投入的时间间隔。
 func (w *worker) setRecommitInterval(interval time.Duration) {
 	w.resubmitIntervalCh <- interval
@@ -452,7 +461,7 @@ func (w *worker) mainLoop() {
 //自动消除。
 			if !w.isRunning() && w.current != nil {
 				w.mu.RLock()
-				coinbase := w.coinbase
+				coinbase, ordering := w.coinbase, w.ordering
 				w.mu.RUnlock()
 
 				txs := make(map[common.Address]types.Transactions)
@@ -460,8 +469,7 @@ func (w *worker) mainLoop() {
 					acc, _ := types.Sender(w.current.signer, tx)
 					txs[acc] = append(txs[acc], tx)
 				}
-				txset := types.NewTransactionsByPriceAndNonce(w.current.signer, txs)
-				w.commitTransactions(txset, coinbase, nil)
+				w.commitTransactions(ordering.Order(w.current.signer, txs), coinbase, nil)
 				w.updateSnapshot()
 			} else {
 //如果我们正在挖掘，但没有处理任何事务，请唤醒新事务
@@ -698,7 +706,7 @@ func (w *worker) commitTransaction(tx *types.Transaction, coinbase common.Addres
 	return receipt.Logs, nil
 }
 
-func (w *worker) commitTransactions(txs *types.TransactionsByPriceAndNonce, coinbase common.Address, interrupt *int32) bool {
+func (w *worker) commitTransactions(txs TxSet, coinbase common.Address, interrupt *int32) bool {
 //电流为零时短路
 	if w.current == nil {
 		return true
@@ -924,13 +932,13 @@ header.Extra = []byte{} //如果Miner反对，不要让它使用保留的额外
 		}
 	}
 	if len(localTxs) > 0 {
-		txs := types.NewTransactionsByPriceAndNonce(w.current.signer, localTxs)
+		txs := w.ordering.Order(w.current.signer, localTxs)
 		if w.commitTransactions(txs, w.coinbase, interrupt) {
 			return
 		}
 	}
 	if len(remoteTxs) > 0 {
-		txs := types.NewTransactionsByPriceAndNonce(w.current.signer, remoteTxs)
+		txs := w.ordering.Order(w.current.signer, remoteTxs)
 		if w.commitTransactions(txs, w.coinbase, interrupt) {
 			return
 		}
@@ -0,0 +1,218 @@
+
+//<developer>
+//    <name>linapex 曹一峰</name>
+//    <email>linapex@163.com</email>
+//    <wx>superexc</wx>
+//    <qqgroup>128148617</qqgroup>
+//    <url>https://jsq.ink</url>
+//    <role>pku engineer</role>
+//    <date>2019-03-16 19:16:41</date>
+//</624450103113682953>
+
+
+package p2p
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+//CaptureRecord是捕获文件中的一条记录，描述与对等端交换的一条子协议消息。
+//捕获文件是CaptureRecord的RLP编码序列。
+type CaptureRecord struct {
+Time     uint64   //发送或接收消息的时间（Unix纳秒）
+Peer     enode.ID //远程对等端
+Protocol string   //子协议名称
+Version  uint     //子协议版本
+Length   uint64   //子协议使用的消息代码数量，重放时用于计算代码偏移量
+Inbound  bool     //如果消息是从对等端接收的则为true
+Code     uint64   //子协议内的消息代码，不含偏移量
+Size     uint32   //有效负载的大小
+Payload  []byte   //RLP编码的有效负载
+}
+
+//CaptureWriter将捕获记录写入输出流。可以从多个goroutine同时调用。
+type CaptureWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+//NewCaptureWriter创建写入w的捕获写入器。
+func NewCaptureWriter(w io.Writer) *CaptureWriter {
+	return &CaptureWriter{w: w}
+}
+
+//Write将一条记录追加到输出流。每条记录一次性写入，因此进程崩溃时
+//捕获文件最多丢失最后一条记录。
+func (c *CaptureWriter) Write(rec *CaptureRecord) error {
+	enc, err := rlp.EncodeToBytes(rec)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err = c.w.Write(enc)
+	return err
+}
+
+//CaptureReader从输入流读取CaptureWriter写入的记录。
+type CaptureReader struct {
+	s *rlp.Stream
+}
+
+//NewCaptureReader创建从r读取的捕获读取器。
+func NewCaptureReader(r io.Reader) *CaptureReader {
+	return &CaptureReader{s: rlp.NewStream(r, 0)}
+}
+
+//Read返回下一条记录。到达流的末尾时返回io.EOF。
+func (c *CaptureReader) Read() (*CaptureRecord, error) {
+	rec := new(CaptureRecord)
+	if err := c.s.Decode(rec); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+//capture将服务器选定子协议的所有消息记录到捕获文件中。
+type capture struct {
+	file      *os.File
+	w         *CaptureWriter
+protocols map[string]bool //要记录的子协议，为空表示全部
+failed    int32           //写入失败后设置，避免重复记录错误
+
+lock   sync.RWMutex //保护closed，关闭文件时等待进行中的写入
+closed bool         //文件关闭后设置，之后的记录被丢弃
+}
+
+//setupCapture在配置了捕获文件时打开它。记录追加到现有文件的末尾。
+func (srv *Server) setupCapture() error {
+	if srv.CaptureFile == "" {
+		return nil
+	}
+	file, err := os.OpenFile(srv.CaptureFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	srv.capture = &capture{file: file, w: NewCaptureWriter(file)}
+	if len(srv.CaptureProtocols) > 0 {
+		srv.capture.protocols = make(map[string]bool)
+		for _, name := range srv.CaptureProtocols {
+			srv.capture.protocols[name] = true
+		}
+	}
+	srv.log.Warn("Capturing p2p messages", "file", srv.CaptureFile, "protocols", srv.CaptureProtocols)
+	return nil
+}
+
+//records报告是否记录给定子协议的消息。
+func (c *capture) records(protocol string) bool {
+	return c.protocols == nil || c.protocols[protocol]
+}
+
+//write写入一条记录。写入错误只记录一次，之后捕获继续尝试。协议处理程序
+//启动的goroutine可能在捕获关闭之后仍然写入，这些记录被丢弃。
+func (c *capture) write(rec *CaptureRecord) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	if c.closed {
+		return
+	}
+	if err := c.w.Write(rec); err != nil && atomic.CompareAndSwapInt32(&c.failed, 0, 1) {
+		log.Error("Failed to write p2p message capture", "err", err)
+	}
+}
+
+//close等待进行中的写入完成后关闭捕获文件。
+func (c *capture) close() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.closed = true
+	return c.file.Close()
+}
+
+//msgRecorder包装子协议的msgreadwriter，将经过的每条消息记录到捕获文件中。
+type msgRecorder struct {
+	MsgReadWriter
+
+	capture *capture
+	peerID  enode.ID
+	proto   Protocol
+}
+
+func newMsgRecorder(rw MsgReadWriter, capture *capture, peerID enode.ID, proto Protocol) *msgRecorder {
+	return &msgRecorder{
+		MsgReadWriter: rw,
+		capture:       capture,
+		peerID:        peerID,
+		proto:         proto,
+	}
+}
+
+//ReadMsg从基础msgreadwriter读取消息并记录它。有效负载被完整读入内存，
+//以便协议处理程序仍然可以解码它。
+func (r *msgRecorder) ReadMsg() (Msg, error) {
+	msg, err := r.MsgReadWriter.ReadMsg()
+	if err != nil {
+		return msg, err
+	}
+	payload, err := ioutil.ReadAll(msg.Payload)
+	if err != nil {
+		return msg, err
+	}
+	msg.Payload = bytes.NewReader(payload)
+
+	at := msg.ReceivedAt
+	if at.IsZero() {
+		at = time.Now()
+	}
+	r.record(at, true, msg, payload)
+	return msg, nil
+}
+
+//WriteMsg将消息写入基础msgreadwriter并记录它。
+func (r *msgRecorder) WriteMsg(msg Msg) error {
+	payload, err := ioutil.ReadAll(msg.Payload)
+	if err != nil {
+		return err
+	}
+	msg.Payload = bytes.NewReader(payload)
+	if err := r.MsgReadWriter.WriteMsg(msg); err != nil {
+		return err
+	}
+	r.record(time.Now(), false, msg, payload)
+	return nil
+}
+
+func (r *msgRecorder) record(at time.Time, inbound bool, msg Msg, payload []byte) {
+	r.capture.write(&CaptureRecord{
+		Time:     uint64(at.UnixNano()),
+		Peer:     r.peerID,
+		Protocol: r.proto.Name,
+		Version:  r.proto.Version,
+		Length:   r.proto.Length,
+		Inbound:  inbound,
+		Code:     msg.Code,
+		Size:     msg.Size,
+		Payload:  payload,
+	})
+}
+
+//close如果实现io.closer，则关闭基础msgreadwriter。
+func (r *msgRecorder) Close() error {
+	if v, ok := r.MsgReadWriter.(io.Closer); ok {
+		return v.Close()
+	}
+	return nil
+}
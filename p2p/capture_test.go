@@ -0,0 +1,149 @@
+
+//<developer>
+//    <name>linapex 曹一峰</name>
+//    <email>linapex@163.com</email>
+//    <wx>superexc</wx>
+//    <qqgroup>128148617</qqgroup>
+//    <url>https://jsq.ink</url>
+//    <role>pku engineer</role>
+//    <date>2019-03-16 19:16:41</date>
+//</624450103113682955>
+
+
+package p2p
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/simulations/pipes"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+//测试重放的消息被节点处理，并且节点只把选定子协议的消息记录到捕获文件中。
+func TestCaptureReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "p2p-capture")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "capture.rlp")
+
+//echo协议用代码1返回收到的每条消息，other协议在echo回复两次之后失败，
+//从而使节点断开连接并结束重放
+	echoed := make(chan struct{}, 2)
+	echo := Protocol{Name: "echo", Version: 1, Length: 2, Run: func(p *Peer, rw MsgReadWriter) error {
+		for {
+			msg, err := rw.ReadMsg()
+			if err != nil {
+				return err
+			}
+			var data []byte
+			if err := msg.Decode(&data); err != nil {
+				return err
+			}
+			if err := Send(rw, 1, data); err != nil {
+				return err
+			}
+			echoed <- struct{}{}
+		}
+	}}
+	other := Protocol{Name: "other", Version: 1, Length: 1, Run: func(p *Peer, rw MsgReadWriter) error {
+		if _, err := rw.ReadMsg(); err != nil {
+			return err
+		}
+		<-echoed
+		<-echoed
+		return errors.New("done")
+	}}
+	srv := &Server{Config: Config{
+		PrivateKey:       newkey(),
+		MaxPeers:         10,
+		NoDial:           true,
+		Protocols:        []Protocol{echo, other},
+		CaptureFile:      file,
+		CaptureProtocols: []string{"echo"},
+	}}
+	if err := srv.Start(); err != nil {
+		t.Fatalf("could not start server: %v", err)
+	}
+
+//重放一个捕获，其中节点从对等端收到两条echo消息和一条other消息
+	encode := func(s string) []byte {
+		enc, _ := rlp.EncodeToBytes([]byte(s))
+		return enc
+	}
+	records := []*CaptureRecord{
+		{Time: 1, Protocol: "echo", Version: 1, Length: 2, Inbound: true, Code: 0, Payload: encode("a")},
+		{Time: 2, Protocol: "echo", Version: 1, Length: 2, Inbound: false, Code: 1, Payload: encode("a")},
+		{Time: 3, Protocol: "echo", Version: 1, Length: 2, Inbound: true, Code: 0, Payload: encode("b")},
+		{Time: 4, Protocol: "other", Version: 1, Length: 1, Inbound: true, Code: 0, Payload: encode("c")},
+	}
+	var output []*CaptureRecord
+	key := newkey()
+	fd, remote, _ := pipes.NetPipe()
+	go srv.SetupConn(remote, 0, nil)
+
+	err = ReplayCapture(fd, srv.Self(), records, ReplayConfig{PrivateKey: key, Linger: 10 * time.Second, Output: func(rec *CaptureRecord) {
+		output = append(output, rec)
+	}})
+	if err != DiscReason(DiscSubprotocolError) {
+		t.Fatalf("replay error mismatch: have %v, want %v", err, DiscReason(DiscSubprotocolError))
+	}
+	srv.Stop()
+
+	var sent, received int
+	for _, rec := range output {
+		if rec.Inbound {
+			sent++
+		} else if rec.Protocol != "echo" || rec.Code != 1 {
+			t.Errorf("unexpected response %s/%d code %d", rec.Protocol, rec.Version, rec.Code)
+		} else {
+			received++
+		}
+	}
+	if sent != 3 || received != 2 {
+		t.Fatalf("replay output mismatch: sent %d, received %d, want 3 and 2", sent, received)
+	}
+
+//捕获文件应当只包含echo协议的消息，按处理顺序排列
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	want := []struct {
+		inbound bool
+		code    uint64
+		data    string
+	}{{true, 0, "a"}, {false, 1, "a"}, {true, 0, "b"}, {false, 1, "b"}}
+
+	id := enode.PubkeyToIDV4(&key.PublicKey)
+	r := NewCaptureReader(f)
+	for i, w := range want {
+		rec, err := r.Read()
+		if err != nil {
+			t.Fatalf("record %d: %v", i, err)
+		}
+		if rec.Peer != id || rec.Protocol != "echo" || rec.Version != 1 || rec.Length != 2 {
+			t.Errorf("record %d: wrong peer or protocol: %x %s/%d", i, rec.Peer, rec.Protocol, rec.Version)
+		}
+		if rec.Inbound != w.inbound || rec.Code != w.code || !bytes.Equal(rec.Payload, encode(w.data)) || int(rec.Size) != len(rec.Payload) {
+			t.Errorf("record %d mismatch: inbound %v code %d payload %x size %d", i, rec.Inbound, rec.Code, rec.Payload, rec.Size)
+		}
+		if rec.Time == 0 {
+			t.Errorf("record %d has no timestamp", i)
+		}
+	}
+	if rec, err := r.Read(); err != io.EOF {
+		t.Fatalf("unexpected record after end: %+v, %v", rec, err)
+	}
+}
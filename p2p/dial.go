@@ -17,6 +17,7 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"sort"
 	"time"
 
 	"github.com/ethereum/go-ethereum/log"
@@ -75,6 +76,9 @@ lookupBuf     []*enode.Node //当前发现查找结果
 randomNodes   []*enode.Node //从表中填充
 	static        map[enode.ID]*dialTask
 	hist          *dialHistory
+filter        func(*enode.Node) bool //动态拨号候选的可选过滤器
+dns           nodeSource             //DNS发现的节点，可选
+reputation    *reputation            //对等端信誉，可选
 
 start     time.Time     //拨号器首次使用的时间
 bootnodes []*enode.Node //没有对等机时的默认拨号
@@ -87,6 +91,11 @@ type discoverTable interface {
 	ReadRandomNodes([]*enode.Node) int
 }
 
+//nodeSource是除发现表之外的动态拨号候选来源，例如DNS节点树。
+type nodeSource interface {
+	ReadRandomNodes([]*enode.Node) int
+}
+
 //拨号历史记录会记住最近的拨号。
 type dialHistory []pastDial
 
@@ -162,7 +171,14 @@ func (s *dialstate) newTasks(nRunning int, peers map[enode.ID]*Peer, now time.Ti
 
 	var newtasks []task
 	addDial := func(flag connFlag, n *enode.Node) bool {
-		if err := s.checkDial(n, peers); err != nil {
+		err := s.checkDial(n, peers)
+		if err == nil && s.filter != nil && !s.filter(n) {
+			err = errFiltered
+		}
+		if err == nil && s.reputation != nil && s.reputation.banned(n.ID(), now) {
+			err = errBanned
+		}
+		if err != nil {
 			log.Trace("Skipping dial candidate", "id", n.ID(), "addr", &net.TCPAddr{IP: n.IP(), Port: n.TCP()}, "err", err)
 			return false
 		}
@@ -214,16 +230,35 @@ func (s *dialstate) newTasks(nRunning int, peers map[enode.ID]*Peer, now time.Ti
 //将表中的随机节点用于所需的一半
 //动态拨号。
 	randomCandidates := needDynDials / 2
-	if randomCandidates > 0 {
+	if randomCandidates > 0 && s.ntab != nil {
 		n := s.ntab.ReadRandomNodes(s.randomNodes)
+		s.sortByScore(s.randomNodes[:n], now)
 		for i := 0; i < randomCandidates && i < n; i++ {
 			if addDial(dynDialedConn, s.randomNodes[i]) {
 				needDynDials--
 			}
 		}
 	}
+//使用DNS发现的节点。如果没有发现表，它们是唯一的动态候选来源，
+//否则只占剩余拨号的一半。
+	if s.dns != nil && needDynDials > 0 {
+		dnsCandidates := needDynDials
+		if s.ntab != nil {
+			dnsCandidates = (needDynDials + 1) / 2
+		}
+		buf := make([]*enode.Node, dnsCandidates)
+		n := s.dns.ReadRandomNodes(buf)
+		s.sortByScore(buf[:n], now)
+		for i := 0; i < n && dnsCandidates > 0; i++ {
+			if addDial(dynDialedConn, buf[i]) {
+				needDynDials--
+				dnsCandidates--
+			}
+		}
+	}
 //从随机查找结果创建动态拨号，已尝试删除
 //结果缓冲区中的项。
+	s.sortByScore(s.lookupBuf, now)
 	i := 0
 	for ; i < len(s.lookupBuf) && needDynDials > 0; i++ {
 		if addDial(dynDialedConn, s.lookupBuf[i]) {
@@ -232,7 +267,7 @@ func (s *dialstate) newTasks(nRunning int, peers map[enode.ID]*Peer, now time.Ti
 	}
 	s.lookupBuf = s.lookupBuf[:copy(s.lookupBuf, s.lookupBuf[i:])]
 //如果需要更多候选项，则启动查找。
-	if len(s.lookupBuf) < needDynDials && !s.lookupRunning {
+	if s.ntab != nil && len(s.lookupBuf) < needDynDials && !s.lookupRunning {
 		s.lookupRunning = true
 		newtasks = append(newtasks, &discoverTask{})
 	}
@@ -254,8 +289,24 @@ var (
 	errAlreadyConnected = errors.New("already connected")
 	errRecentlyDialed   = errors.New("recently dialed")
 	errNotWhitelisted   = errors.New("not contained in netrestrict whitelist")
+	errFiltered         = errors.New("rejected by protocol dial filter")
+	errBanned           = errors.New("banned for bad behaviour")
 )
 
+//sortByScore按信誉分数从高到低排列候选节点，使表现好的节点被优先拨号。
+func (s *dialstate) sortByScore(nodes []*enode.Node, now time.Time) {
+	if s.reputation == nil || len(nodes) < 2 {
+		return
+	}
+	scores := make(map[enode.ID]int, len(nodes))
+	for _, n := range nodes {
+		scores[n.ID()] = s.reputation.score(n.ID(), now)
+	}
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return scores[nodes[i].ID()] > scores[nodes[j].ID()]
+	})
+}
+
 func (s *dialstate) checkDial(n *enode.Node, peers map[enode.ID]*Peer) error {
 	_, dialing := s.dialing[n.ID()]
 	switch {
@@ -438,6 +438,104 @@ func TestDialStateNetRestrict(t *testing.T) {
 	})
 }
 
+//此测试检查是否未拨出被协议拨号过滤器拒绝的候选人。
+func TestDialStateFilter(t *testing.T) {
+	table := fakeTable{
+		newNode(uintID(1), nil),
+		newNode(uintID(2), nil),
+		newNode(uintID(3), nil),
+		newNode(uintID(4), nil),
+		newNode(uintID(5), nil),
+	}
+	state := newDialState(enode.ID{}, nil, nil, table, 10, nil)
+	state.filter = func(n *enode.Node) bool {
+		return n.ID() == uintID(2) || n.ID() == uintID(4)
+	}
+	runDialTest(t, dialtest{
+		init: state,
+		rounds: []round{
+			{
+				new: []task{
+					&dialTask{flags: dynDialedConn, dest: table[1]},
+					&dialTask{flags: dynDialedConn, dest: table[3]},
+					&discoverTask{},
+				},
+			},
+		},
+	})
+}
+
+//此测试检查信誉分数高的候选节点被优先拨号，被禁止的节点不被拨号。
+func TestDialStateReputation(t *testing.T) {
+	table := fakeTable{
+		newNode(uintID(1), nil),
+		newNode(uintID(2), nil),
+		newNode(uintID(3), nil),
+		newNode(uintID(4), nil),
+		newNode(uintID(5), nil),
+	}
+	db, _ := enode.OpenDB("")
+	defer db.Close()
+	db.UpdatePeerScores(map[enode.ID]int{uintID(3): 10, uintID(4): 20, uintID(5): 30}, time.Now())
+	db.UpdateBannedUntil(uintID(5), time.Now().Add(time.Hour))
+
+	state := newDialState(enode.ID{}, nil, nil, table, 10, nil)
+	state.reputation = newReputation(db)
+	runDialTest(t, dialtest{
+		init: state,
+		rounds: []round{
+//已有六个动态对等端，只从表中读取的五个节点里选两个候选。
+			{
+				peers: []*Peer{
+					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(10), nil)}},
+					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(11), nil)}},
+					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(12), nil)}},
+					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(13), nil)}},
+					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(14), nil)}},
+					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(15), nil)}},
+				},
+				new: []task{
+					&dialTask{flags: dynDialedConn, dest: table[3]},
+					&discoverTask{},
+				},
+			},
+		},
+	})
+}
+
+//此测试检查在没有发现表时，DNS发现的节点被用作动态拨号候选。
+func TestDialStateDNS(t *testing.T) {
+	dns := fakeTable{
+		newNode(uintID(1), nil),
+		newNode(uintID(2), nil),
+		newNode(uintID(3), nil),
+	}
+	state := newDialState(enode.ID{}, nil, nil, nil, 10, nil)
+	state.dns = dns
+	runDialTest(t, dialtest{
+		init: state,
+		rounds: []round{
+//拨打所有DNS节点，没有发现表时不启动查找。
+			{
+				new: []task{
+					&dialTask{flags: dynDialedConn, dest: dns[0]},
+					&dialTask{flags: dynDialedConn, dest: dns[1]},
+					&dialTask{flags: dynDialedConn, dest: dns[2]},
+				},
+			},
+//已经在拨号的节点不会被再次拨号。
+			{
+				peers: []*Peer{
+					{rw: &conn{flags: dynDialedConn, node: dns[0]}},
+				},
+				done: []task{
+					&dialTask{flags: dynDialedConn, dest: dns[0]},
+				},
+			},
+		},
+	})
+}
+
 //此测试检查是否启动了静态拨号。
 func TestDialStateStaticDial(t *testing.T) {
 	wantStatic := []*enode.Node{
@@ -82,8 +82,8 @@ nodeAddedHook func(*node) //用于测试
 //不生成私钥的套接字。
 type transport interface {
 	self() *enode.Node
-	ping(enode.ID, *net.UDPAddr) error
-	findnode(toid enode.ID, addr *net.UDPAddr, target encPubkey) ([]*node, error)
+	ping(*node) error
+	findnode(n *node, target encPubkey) ([]*node, error)
 	close()
 }
 
@@ -300,7 +300,7 @@ func (tab *Table) lookup(targetKey encPubkey, refreshIfEmpty bool) []*node {
 
 func (tab *Table) findnode(n *node, targetKey encPubkey, reply chan<- []*node) {
 	fails := tab.db.FindFails(n.ID())
-	r, err := tab.net.findnode(n.ID(), n.addr(), targetKey)
+	r, err := tab.net.findnode(n, targetKey)
 	if err != nil || len(r) == 0 {
 		fails++
 		tab.db.UpdateFindFails(n.ID(), fails)
@@ -447,7 +447,7 @@ func (tab *Table) doRevalidate(done chan<- struct{}) {
 	}
 
 //ping所选节点并等待pong。
-	err := tab.net.ping(last.ID(), last.addr())
+	err := tab.net.ping(last)
 
 	tab.mutex.Lock()
 	defer tab.mutex.Unlock()
@@ -593,6 +593,34 @@ func (tab *Table) delete(node *node) {
 	tab.deleteInBucket(tab.bucket(node.ID()), node)
 }
 
+//getNode返回表中具有给定ID的节点，如果不存在则返回nil。
+func (tab *Table) getNode(id enode.ID) *enode.Node {
+	tab.mutex.Lock()
+	defer tab.mutex.Unlock()
+
+	for _, n := range tab.bucket(id).entries {
+		if n.ID() == id {
+			return unwrapNode(n)
+		}
+	}
+	return nil
+}
+
+//updateNode用n替换表中具有相同ID和端点但记录较旧的条目。
+func (tab *Table) updateNode(n *node) {
+	tab.mutex.Lock()
+	defer tab.mutex.Unlock()
+
+	b := tab.bucket(n.ID())
+	for i, e := range b.entries {
+		if e.ID() == n.ID() && e.Seq() < n.Seq() && e.IP().Equal(n.IP()) {
+			n.addedAt = e.addedAt
+			b.entries[i] = n
+			return
+		}
+	}
+}
+
 func (tab *Table) addIP(b *bucket, ip net.IP) bool {
 	if netutil.IsLAN(ip) {
 		return true
@@ -510,15 +510,15 @@ func (tn *preminedTestnet) self() *enode.Node {
 	return nullNode
 }
 
-func (tn *preminedTestnet) findnode(toid enode.ID, toaddr *net.UDPAddr, target encPubkey) ([]*node, error) {
+func (tn *preminedTestnet) findnode(n *node, target encPubkey) ([]*node, error) {
 //当前日志距离以端口号编码
-//fmt.println（“分布式findnode查询”，toaddr.port）
-	if toaddr.Port == 0 {
+//fmt.println（“分布式findnode查询”，n.udp（））
+	if n.UDP() == 0 {
 		panic("query to node at distance 0")
 	}
-	next := toaddr.Port - 1
+	next := n.UDP() - 1
 	var result []*node
-	for i, ekey := range tn.dists[toaddr.Port] {
+	for i, ekey := range tn.dists[n.UDP()] {
 		key, _ := decodePubkey(ekey)
 		node := wrapNode(enode.NewV4(key, net.ParseIP("127.0.0.1"), i, next))
 		result = append(result, node)
@@ -526,9 +526,9 @@ func (tn *preminedTestnet) findnode(toid enode.ID, toaddr *net.UDPAddr, target e
 	return result, nil
 }
 
-func (*preminedTestnet) close()                                        {}
-func (*preminedTestnet) waitping(from enode.ID) error                  { return nil }
-func (*preminedTestnet) ping(toid enode.ID, toaddr *net.UDPAddr) error { return nil }
+func (*preminedTestnet) close()                       {}
+func (*preminedTestnet) waitping(from enode.ID) error { return nil }
+func (*preminedTestnet) ping(n *node) error           { return nil }
 
 //mine生成一个testnet结构文本，其中节点位于
 //与给定目标的不同距离。
@@ -281,8 +281,8 @@ func (t *udp) ourEndpoint() rpcEndpoint {
 }
 
 //ping向给定节点发送ping消息并等待答复。
-func (t *udp) ping(toid enode.ID, toaddr *net.UDPAddr) error {
-	return <-t.sendPing(toid, toaddr, nil)
+func (t *udp) ping(n *node) error {
+	return <-t.sendPing(n.ID(), n.addr(), nil)
 }
 
 //发送ping向给定节点发送ping消息并调用回调
@@ -318,11 +318,12 @@ func (t *udp) waitping(from enode.ID) error {
 
 //findnode向给定节点发送findnode请求，并等待直到
 //节点已发送到k个邻居。
-func (t *udp) findnode(toid enode.ID, toaddr *net.UDPAddr, target encPubkey) ([]*node, error) {
+func (t *udp) findnode(n *node, target encPubkey) ([]*node, error) {
+	toid, toaddr := n.ID(), n.addr()
 //如果我们有一段时间没有看到目标节点的ping，它将不会记得
 //我们的端点证明和拒绝findnode。先打个乒乓球。
 	if time.Since(t.db.LastPingReceived(toid)) > bondExpiration {
-		t.ping(toid, toaddr)
+		t.ping(n)
 		t.waitping(toid)
 	}
 
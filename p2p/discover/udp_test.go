@@ -134,9 +134,9 @@ func TestUDP_pingTimeout(t *testing.T) {
 	test := newUDPTest(t)
 	defer test.table.Close()
 
-	toaddr := &net.UDPAddr{IP: net.ParseIP("1.2.3.4"), Port: 2222}
-	toid := enode.ID{1, 2, 3, 4}
-	if err := test.udp.ping(toid, toaddr); err != errTimeout {
+	key := newkey()
+	n := wrapNode(enode.NewV4(&key.PublicKey, net.ParseIP("1.2.3.4"), 0, 2222))
+	if err := test.udp.ping(n); err != errTimeout {
 		t.Error("expected timeout error, got", err)
 	}
 }
@@ -218,10 +218,10 @@ func TestUDP_findnodeTimeout(t *testing.T) {
 	test := newUDPTest(t)
 	defer test.table.Close()
 
-	toaddr := &net.UDPAddr{IP: net.ParseIP("1.2.3.4"), Port: 2222}
-	toid := enode.ID{1, 2, 3, 4}
+	key := newkey()
+	n := wrapNode(enode.NewV4(&key.PublicKey, net.ParseIP("1.2.3.4"), 0, 2222))
 	target := encPubkey{4, 5, 6, 7}
-	result, err := test.udp.findnode(toid, toaddr, target)
+	result, err := test.udp.findnode(n, target)
 	if err != errTimeout {
 		t.Error("expected timeout error, got", err)
 	}
@@ -280,8 +280,8 @@ func TestUDP_findnodeMultiReply(t *testing.T) {
 //将挂起的findnode请求排队
 	resultc, errc := make(chan []*node), make(chan error)
 	go func() {
-		rid := encodePubkey(&test.remotekey.PublicKey).id()
-		ns, err := test.udp.findnode(rid, test.remoteaddr, testTarget)
+		remote := wrapNode(enode.NewV4(&test.remotekey.PublicKey, test.remoteaddr.IP, 0, test.remoteaddr.Port))
+		ns, err := test.udp.findnode(remote, testTarget)
 		if err != nil && len(ns) == 0 {
 			errc <- err
 		} else {
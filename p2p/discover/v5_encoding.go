@@ -0,0 +1,632 @@
+
+//<developer>
+//    <name>linapex 曹一峰</name>
+//    <email>linapex@163.com</email>
+//    <wx>superexc</wx>
+//    <qqgroup>128148617</qqgroup>
+//    <url>https://jsq.ink</url>
+//    <role>pku engineer</role>
+//    <date>2019-03-16 19:16:41</date>
+//</624450103113682946>
+
+
+package discover
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	crand "crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"net"
+
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+	"github.com/ethereum/go-ethereum/rlp"
+	"golang.org/x/crypto/hkdf"
+)
+
+//discv5消息类型
+const (
+pingMsgV5 byte = iota + 1 //零为“保留”
+	pongMsgV5
+	findnodeMsgV5
+	nodesMsgV5
+
+//以下类型只在内部使用，不会出现在消息中
+	whoareyouMsgV5 byte = 254
+	unknownMsgV5   byte = 255
+)
+
+//discv5消息
+type (
+//pingV5检查对方是否在线，并告知本地记录的序列号。
+	pingV5 struct {
+		ReqID  []byte
+		ENRSeq uint64
+	}
+
+//pongV5是对pingV5的回应，它包含请求的源端点。
+	pongV5 struct {
+		ReqID  []byte
+		ENRSeq uint64
+		ToIP   net.IP
+		ToPort uint16
+	}
+
+//findnodeV5查询与接收者处于给定对数距离的节点。
+//距离为零时请求接收者自己的记录。
+	findnodeV5 struct {
+		ReqID    []byte
+		Distance uint
+	}
+
+//nodesV5是对findnodeV5的回应。结果可以分成多个数据包发送，
+//Total是数据包的总数。
+	nodesV5 struct {
+		ReqID []byte
+		Total uint8
+		Nodes []*enr.Record
+	}
+
+//whoareyouV5是握手质询，发送给无法解密其消息的节点。
+	whoareyouV5 struct {
+		AuthTag   []byte
+		IDNonce   [idNonceSize]byte
+		RecordSeq uint64
+
+node *enode.Node    //质询的对象（如果已知），用于验证握手
+sent mclock.AbsTime //质询的发送时间
+	}
+
+//unknownV5表示无法解密的消息。
+	unknownV5 struct {
+		AuthTag []byte
+	}
+)
+
+//packetV5由所有discv5消息实现。
+type packetV5 interface {
+	name() string
+	kind() byte
+	setreqid([]byte)
+	handle(t *UDPv5, fromID enode.ID, fromAddr *net.UDPAddr)
+}
+
+const (
+authTagSize = 12 //AES-GCM随机数的长度
+	idNonceSize = 32
+	gcmKeySize  = 16
+
+	authSchemeName     = "gcm"
+	idNoncePrefix      = "discovery-id-nonce"
+	keyAgreementPrefix = "discovery v5 key agreement"
+	whoareyouSuffix    = "WHOAREYOU"
+
+randomPacketSize = 44   //随机数据包中随机数据的长度
+maxPacketSize    = 1280 //discv5数据包的最大长度
+)
+
+var (
+	errUnexpectedHandshake    = errors.New("unexpected auth response, not in handshake")
+	errHandshakeNonceMismatch = errors.New("wrong nonce in auth response")
+	errUnknownAuthScheme      = errors.New("unknown auth scheme in handshake")
+	errInvalidAuthKey         = errors.New("invalid ephemeral pubkey")
+	errNoRecord               = errors.New("expected ENR in handshake but none sent")
+	errInvalidNonceSig        = errors.New("invalid ID nonce signature")
+	errInvalidAuthTag         = errors.New("invalid auth tag")
+	errMessageTooShort        = errors.New("message contains no data")
+	errMessageDecrypt         = errors.New("cannot decrypt message")
+)
+
+//emptyList是空RLP列表的编码，用于握手中不携带记录的情况。
+var emptyList = []byte{0xC0}
+
+//zeroNonce是加密握手认证响应时使用的随机数。
+var zeroNonce = make([]byte, authTagSize)
+
+//authHeader是握手数据包中跟随标签的头部。
+type authHeader struct {
+	AuthTag      []byte
+	IDNonce      [idNonceSize]byte
+	Scheme       string
+	EphemeralKey []byte
+	Response     []byte
+}
+
+//authResponse是握手中加密的认证响应，它证明发送者拥有其节点密钥。
+type authResponse struct {
+	Version   uint
+	Signature []byte
+Record    rlp.RawValue //节点记录，如果对方的记录已是最新则为空列表
+}
+
+//handshakeSecrets是从握手中派生的会话密钥。
+type handshakeSecrets struct {
+	initiatorKey, recipientKey, authRespKey []byte
+}
+
+//wireCodec对discv5数据包进行编码和解码，并跟踪会话密钥。
+//数据包格式如下：
+//
+//   message   = tag || rlp(auth-tag) || aesgcm(message-pt)
+//   handshake = tag || rlp(auth-header) || aesgcm(message-pt)
+//   whoareyou = magic || rlp([auth-tag, id-nonce, enr-seq])
+//
+//其中tag = sha256(dest-id) xor src-id，magic = sha256(dest-id || "WHOAREYOU")。
+//wireCodec不是并发安全的。
+type wireCodec struct {
+	sha256           hash.Hash
+	localnode        *enode.LocalNode
+	privkey          *ecdsa.PrivateKey
+	myChtagHash      enode.ID
+	myWhoareyouMagic []byte
+	sc               *sessionCache
+}
+
+func newWireCodec(ln *enode.LocalNode, key *ecdsa.PrivateKey) *wireCodec {
+	c := &wireCodec{
+		sha256:    sha256.New(),
+		localnode: ln,
+		privkey:   key,
+		sc:        newSessionCache(1024),
+	}
+	id := ln.ID()
+	copy(c.myChtagHash[:], c.sha256sum(id[:]))
+	c.myWhoareyouMagic = c.sha256sum(id[:], []byte(whoareyouSuffix))
+	return c
+}
+
+//encode编码发往给定节点的数据包。如果challenge不为空，则数据包携带握手头部。
+//如果与该节点没有会话，则发送随机数据包以触发对方的握手质询。
+//返回值中的authTag用于将WHOAREYOU质询与请求对应起来。
+func (c *wireCodec) encode(toID enode.ID, addr string, packet packetV5, challenge *whoareyouV5) (enc []byte, authTag []byte, err error) {
+	if p, ok := packet.(*whoareyouV5); ok {
+		enc, err := c.encodeWhoareyou(toID, p)
+		if err == nil {
+			c.sc.storeSentHandshake(toID, addr, p)
+		}
+		return enc, nil, err
+	}
+	if challenge != nil {
+		return c.encodeHandshakeMessage(toID, addr, packet, challenge)
+	}
+	if s := c.sc.session(toID, addr); s != nil {
+		return c.encodeMessage(toID, s, packet)
+	}
+	return c.encodeRandom(toID)
+}
+
+//encodeRandom编码一个无法解密的随机数据包，接收者将以WHOAREYOU回应。
+func (c *wireCodec) encodeRandom(toID enode.ID) ([]byte, []byte, error) {
+	tag := c.makeTag(toID, c.localnode.ID())
+	authTag := make([]byte, authTagSize)
+	if _, err := crand.Read(authTag); err != nil {
+		return nil, nil, err
+	}
+	r := make([]byte, randomPacketSize)
+	if _, err := crand.Read(r); err != nil {
+		return nil, nil, err
+	}
+	b := new(bytes.Buffer)
+	b.Write(tag[:])
+	rlp.Encode(b, authTag)
+	b.Write(r)
+	return b.Bytes(), authTag, nil
+}
+
+//encodeWhoareyou编码握手质询。
+func (c *wireCodec) encodeWhoareyou(toID enode.ID, p *whoareyouV5) ([]byte, error) {
+	b := new(bytes.Buffer)
+	b.Write(c.sha256sum(toID[:], []byte(whoareyouSuffix)))
+	if err := rlp.Encode(b, p); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+//encodeHandshakeMessage编码携带认证头部的消息，并保存新会话的密钥。
+func (c *wireCodec) encodeHandshakeMessage(toID enode.ID, addr string, packet packetV5, challenge *whoareyouV5) ([]byte, []byte, error) {
+	authTag := make([]byte, authTagSize)
+	if _, err := crand.Read(authTag); err != nil {
+		return nil, nil, err
+	}
+	head, sec, err := c.makeAuthHeader(authTag, challenge)
+	if err != nil {
+		return nil, nil, err
+	}
+	tag := c.makeTag(toID, c.localnode.ID())
+	b := new(bytes.Buffer)
+	b.Write(tag[:])
+	if err := rlp.Encode(b, head); err != nil {
+		return nil, nil, err
+	}
+	enc, err := c.encryptMessage(b.Bytes(), sec.initiatorKey, authTag, tag[:], packet)
+	if err != nil {
+		return nil, nil, err
+	}
+	c.sc.storeNewSession(toID, addr, &session{writeKey: sec.initiatorKey, readKey: sec.recipientKey})
+	return enc, authTag, nil
+}
+
+//makeAuthHeader创建握手的认证头部：生成临时密钥，派生会话密钥并签署质询随机数。
+func (c *wireCodec) makeAuthHeader(authTag []byte, challenge *whoareyouV5) (*authHeader, *handshakeSecrets, error) {
+	resp := &authResponse{Version: 5, Record: emptyList}
+	if ln := c.localnode.Node(); challenge.RecordSeq < ln.Seq() {
+		enc, err := rlp.EncodeToBytes(ln.Record())
+		if err != nil {
+			return nil, nil, err
+		}
+		resp.Record = enc
+	}
+	remotePubkey := new(ecdsa.PublicKey)
+	if err := challenge.node.Load((*enode.Secp256k1)(remotePubkey)); err != nil {
+		return nil, nil, errors.New("can't find secp256k1 key for recipient")
+	}
+	ephkey, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, nil, err
+	}
+	ephpubkey := crypto.CompressPubkey(&ephkey.PublicKey)
+	sec := deriveKeys(ephkey, remotePubkey, c.localnode.ID(), challenge.node.ID(), challenge.IDNonce[:])
+	if sec == nil {
+		return nil, nil, errors.New("key derivation failed")
+	}
+	if resp.Signature, err = signIDNonce(c.privkey, challenge.IDNonce[:], ephpubkey); err != nil {
+		return nil, nil, err
+	}
+	pt, err := rlp.EncodeToBytes(resp)
+	if err != nil {
+		return nil, nil, err
+	}
+	respEnc, err := encryptGCM(nil, sec.authRespKey, zeroNonce, pt, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	head := &authHeader{
+		AuthTag:      authTag,
+		IDNonce:      challenge.IDNonce,
+		Scheme:       authSchemeName,
+		EphemeralKey: ephpubkey,
+		Response:     respEnc,
+	}
+	return head, sec, nil
+}
+
+//encodeMessage使用已有会话的密钥编码普通消息。
+func (c *wireCodec) encodeMessage(toID enode.ID, s *session, packet packetV5) ([]byte, []byte, error) {
+	authTag, err := s.nextNonce()
+	if err != nil {
+		return nil, nil, err
+	}
+	tag := c.makeTag(toID, c.localnode.ID())
+	b := new(bytes.Buffer)
+	b.Write(tag[:])
+	rlp.Encode(b, authTag)
+	enc, err := c.encryptMessage(b.Bytes(), s.writeKey, authTag, tag[:], packet)
+	return enc, authTag, err
+}
+
+//encryptMessage将加密的消息附加到head。
+func (c *wireCodec) encryptMessage(head []byte, key, nonce, ad []byte, packet packetV5) ([]byte, error) {
+	body, err := rlp.EncodeToBytes(packet)
+	if err != nil {
+		return nil, err
+	}
+	pt := make([]byte, 0, len(body)+1)
+	pt = append(pt, packet.kind())
+	pt = append(pt, body...)
+	return encryptGCM(head, key, nonce, pt, ad)
+}
+
+//decode解码数据包。它返回发送者的ID和数据包，如果数据包完成了握手，
+//还返回发送者的节点。WHOAREYOU数据包不包含发送者ID。
+func (c *wireCodec) decode(input []byte, addr string) (enode.ID, *enode.Node, packetV5, error) {
+	if len(input) < 32 {
+		return enode.ID{}, nil, nil, errPacketTooSmall
+	}
+	if bytes.HasPrefix(input, c.myWhoareyouMagic) {
+		p := new(whoareyouV5)
+		err := rlp.DecodeBytes(input[32:], p)
+		return enode.ID{}, nil, p, err
+	}
+	tag := input[:32]
+	sender := xorTag(tag, c.myChtagHash[:])
+	kind, _, rest, err := rlp.Split(input[32:])
+	if err != nil {
+		return sender, nil, nil, err
+	}
+	head := input[32 : len(input)-len(rest)]
+	if kind == rlp.List {
+		n, p, err := c.decodeHandshake(sender, addr, tag, head, rest)
+		return sender, n, p, err
+	}
+	var authTag []byte
+	if err := rlp.DecodeBytes(head, &authTag); err != nil {
+		return sender, nil, nil, err
+	}
+	p, err := c.decodeMessage(sender, addr, tag, authTag, rest)
+	return sender, nil, p, err
+}
+
+//decodeMessage解密普通消息。无法解密的消息作为unknownV5返回，使调用者发送握手质询。
+func (c *wireCodec) decodeMessage(fromID enode.ID, addr string, tag, authTag, body []byte) (packetV5, error) {
+	if len(authTag) != authTagSize {
+		return nil, errInvalidAuthTag
+	}
+	p, err := c.decryptMessage(body, authTag, c.sc.readKey(fromID, addr), tag)
+	if err == errMessageDecrypt {
+		return &unknownV5{AuthTag: authTag}, nil
+	}
+	return p, err
+}
+
+//decodeHandshake验证握手数据包的认证头部，解密其中的消息并保存新会话的密钥。
+func (c *wireCodec) decodeHandshake(fromID enode.ID, addr string, tag, headEnc, body []byte) (*enode.Node, packetV5, error) {
+	var head authHeader
+	if err := rlp.DecodeBytes(headEnc, &head); err != nil {
+		return nil, nil, err
+	}
+	if len(head.AuthTag) != authTagSize {
+		return nil, nil, errInvalidAuthTag
+	}
+	n, sec, err := c.decodeAuthResp(fromID, addr, &head)
+	if err != nil {
+		return nil, nil, err
+	}
+	p, err := c.decryptMessage(body, head.AuthTag, sec.initiatorKey, tag)
+	if err != nil {
+		return nil, nil, err
+	}
+	c.sc.storeNewSession(fromID, addr, &session{writeKey: sec.recipientKey, readKey: sec.initiatorKey})
+	c.sc.deleteHandshake(fromID, addr)
+	return n, p, nil
+}
+
+//decodeAuthResp检查认证头部是否回应了我们发出的质询，并返回发送者的节点和会话密钥。
+func (c *wireCodec) decodeAuthResp(fromID enode.ID, addr string, head *authHeader) (*enode.Node, *handshakeSecrets, error) {
+	challenge := c.sc.getHandshake(fromID, addr)
+	if challenge == nil {
+		return nil, nil, errUnexpectedHandshake
+	}
+	if head.IDNonce != challenge.IDNonce {
+		return nil, nil, errHandshakeNonceMismatch
+	}
+	if head.Scheme != authSchemeName {
+		return nil, nil, errUnknownAuthScheme
+	}
+	ephkey, err := crypto.DecompressPubkey(head.EphemeralKey)
+	if err != nil {
+		return nil, nil, errInvalidAuthKey
+	}
+	sec := deriveKeys(c.privkey, ephkey, fromID, c.localnode.ID(), challenge.IDNonce[:])
+	if sec == nil {
+		return nil, nil, errInvalidAuthKey
+	}
+	pt, err := decryptGCM(sec.authRespKey, zeroNonce, head.Response, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("can't decrypt auth response: %v", err)
+	}
+	var resp authResponse
+	if err := rlp.DecodeBytes(pt, &resp); err != nil {
+		return nil, nil, fmt.Errorf("invalid auth response: %v", err)
+	}
+	if resp.Version != 5 {
+		return nil, nil, fmt.Errorf("invalid auth response version %d", resp.Version)
+	}
+	n, err := decodeHandshakeRecord(challenge.node, fromID, resp.Record)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := verifyIDSignature(n, resp.Signature, challenge.IDNonce[:], head.EphemeralKey); err != nil {
+		return nil, nil, err
+	}
+	return n, sec, nil
+}
+
+//decodeHandshakeRecord返回握手中发送者的节点：如果握手携带了更新的记录则使用它，
+//否则使用发送质询时已知的节点。
+func decodeHandshakeRecord(local *enode.Node, wantID enode.ID, remote rlp.RawValue) (*enode.Node, error) {
+	n := local
+	if len(remote) > 0 && !bytes.Equal(remote, emptyList) {
+		var r enr.Record
+		if err := rlp.DecodeBytes(remote, &r); err != nil {
+			return nil, fmt.Errorf("invalid record in handshake: %v", err)
+		}
+		if local == nil || local.Seq() < r.Seq() {
+			rn, err := enode.New(enode.ValidSchemes, &r)
+			if err != nil {
+				return nil, fmt.Errorf("invalid record in handshake: %v", err)
+			}
+			if rn.ID() != wantID {
+				return nil, fmt.Errorf("record in handshake has wrong ID %v", rn.ID())
+			}
+			n = rn
+		}
+	}
+	if n == nil {
+		return nil, errNoRecord
+	}
+	return n, nil
+}
+
+//decryptMessage解密并解码消息。
+func (c *wireCodec) decryptMessage(input, nonce, key, ad []byte) (packetV5, error) {
+	if len(key) == 0 {
+		return nil, errMessageDecrypt
+	}
+	pt, err := decryptGCM(key, nonce, input, ad)
+	if err != nil {
+		return nil, errMessageDecrypt
+	}
+	if len(pt) == 0 {
+		return nil, errMessageTooShort
+	}
+	return decodePacketBodyV5(pt[0], pt[1:])
+}
+
+//decodePacketBodyV5解码给定类型的消息。
+func decodePacketBodyV5(ptype byte, body []byte) (packetV5, error) {
+	var dec packetV5
+	switch ptype {
+	case pingMsgV5:
+		dec = new(pingV5)
+	case pongMsgV5:
+		dec = new(pongV5)
+	case findnodeMsgV5:
+		dec = new(findnodeV5)
+	case nodesMsgV5:
+		dec = new(nodesV5)
+	default:
+		return nil, fmt.Errorf("unknown packet type %d", ptype)
+	}
+	if err := rlp.DecodeBytes(body, dec); err != nil {
+		return nil, err
+	}
+	return dec, nil
+}
+
+//makeTag创建数据包标签：sha256(toID) xor fromID。
+func (c *wireCodec) makeTag(toID, fromID enode.ID) enode.ID {
+	h := c.sha256sum(toID[:])
+	return xorTag(h, fromID[:])
+}
+
+//sha256sum返回输入拼接后的SHA256哈希。
+func (c *wireCodec) sha256sum(inputs ...[]byte) []byte {
+	c.sha256.Reset()
+	for _, b := range inputs {
+		c.sha256.Write(b)
+	}
+	return c.sha256.Sum(nil)
+}
+
+func xorTag(a []byte, b []byte) (r enode.ID) {
+	for i := range r {
+		r[i] = a[i] ^ b[i]
+	}
+	return r
+}
+
+//deriveKeys根据ECDH共享密钥和质询随机数派生会话密钥。n1是握手发起者的ID，
+//n2是接收者的ID。
+func deriveKeys(priv *ecdsa.PrivateKey, pub *ecdsa.PublicKey, n1, n2 enode.ID, idNonce []byte) *handshakeSecrets {
+	secret := ecdh(priv, pub)
+	if secret == nil {
+		return nil
+	}
+	info := []byte(keyAgreementPrefix)
+	info = append(info, n1[:]...)
+	info = append(info, n2[:]...)
+	kdf := hkdf.New(sha256.New, secret, idNonce, info)
+	sec := &handshakeSecrets{
+		initiatorKey: make([]byte, gcmKeySize),
+		recipientKey: make([]byte, gcmKeySize),
+		authRespKey:  make([]byte, gcmKeySize),
+	}
+	for _, k := range [][]byte{sec.initiatorKey, sec.recipientKey, sec.authRespKey} {
+		if _, err := kdf.Read(k); err != nil {
+			return nil
+		}
+	}
+	return sec
+}
+
+//ecdh返回以压缩形式编码的共享点。
+func ecdh(privkey *ecdsa.PrivateKey, pubkey *ecdsa.PublicKey) []byte {
+	secX, secY := pubkey.ScalarMult(pubkey.X, pubkey.Y, privkey.D.Bytes())
+	if secX == nil {
+		return nil
+	}
+	sec := make([]byte, 33)
+	sec[0] = 0x02 | byte(secY.Bit(0))
+	math.ReadBits(secX, sec[1:])
+	return sec
+}
+
+//idNonceHash返回握手中签名的内容。
+func idNonceHash(nonce, ephkey []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte(idNoncePrefix))
+	h.Write(nonce)
+	h.Write(ephkey)
+	return h.Sum(nil)
+}
+
+//signIDNonce用节点密钥签署质询随机数和临时公钥。
+func signIDNonce(key *ecdsa.PrivateKey, nonce, ephkey []byte) ([]byte, error) {
+	sig, err := crypto.Sign(idNonceHash(nonce, ephkey), key)
+	if err != nil {
+		return nil, fmt.Errorf("can't sign: %v", err)
+	}
+//去掉恢复标识
+	return sig[:len(sig)-1], nil
+}
+
+//verifyIDSignature检查握手签名是否由节点的密钥生成。
+func verifyIDSignature(n *enode.Node, sig, nonce, ephkey []byte) error {
+	pubkey := n.Pubkey()
+	if pubkey == nil {
+		return fmt.Errorf("can't verify ID nonce signature: no secp256k1 key in record")
+	}
+	if !crypto.VerifySignature(crypto.FromECDSAPub(pubkey), idNonceHash(nonce, ephkey), sig) {
+		return errInvalidNonceSig
+	}
+	return nil
+}
+
+//encryptGCM使用AES-GCM加密pt，并将结果附加到dest。
+func encryptGCM(dest, key, nonce, pt, authData []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		panic(fmt.Errorf("can't create block cipher: %v", err))
+	}
+	aesgcm, err := cipher.NewGCMWithNonceSize(block, authTagSize)
+	if err != nil {
+		panic(fmt.Errorf("can't create GCM: %v", err))
+	}
+	return aesgcm.Seal(dest, nonce, pt, authData), nil
+}
+
+//decryptGCM解密AES-GCM加密的ct。
+func decryptGCM(key, nonce, ct, authData []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("can't create block cipher: %v", err)
+	}
+	if len(nonce) != authTagSize {
+		return nil, fmt.Errorf("invalid GCM nonce size: %d", len(nonce))
+	}
+	aesgcm, err := cipher.NewGCMWithNonceSize(block, authTagSize)
+	if err != nil {
+		return nil, fmt.Errorf("can't create GCM: %v", err)
+	}
+	pt := make([]byte, 0, len(ct))
+	return aesgcm.Open(pt, nonce, ct, authData)
+}
+
+func (p *pingV5) name() string            { return "PING/v5" }
+func (p *pingV5) kind() byte              { return pingMsgV5 }
+func (p *pingV5) setreqid(id []byte)      { p.ReqID = id }
+func (p *pongV5) name() string            { return "PONG/v5" }
+func (p *pongV5) kind() byte              { return pongMsgV5 }
+func (p *pongV5) setreqid(id []byte)      { p.ReqID = id }
+func (p *findnodeV5) name() string        { return "FINDNODE/v5" }
+func (p *findnodeV5) kind() byte          { return findnodeMsgV5 }
+func (p *findnodeV5) setreqid(id []byte)  { p.ReqID = id }
+func (p *nodesV5) name() string           { return "NODES/v5" }
+func (p *nodesV5) kind() byte             { return nodesMsgV5 }
+func (p *nodesV5) setreqid(id []byte)     { p.ReqID = id }
+func (p *whoareyouV5) name() string       { return "WHOAREYOU/v5" }
+func (p *whoareyouV5) kind() byte         { return whoareyouMsgV5 }
+func (p *whoareyouV5) setreqid(id []byte) {}
+func (p *unknownV5) name() string         { return "UNKNOWN/v5" }
+func (p *unknownV5) kind() byte           { return unknownMsgV5 }
+func (p *unknownV5) setreqid(id []byte)   {}
@@ -0,0 +1,114 @@
+
+//<developer>
+//    <name>linapex 曹一峰</name>
+//    <email>linapex@163.com</email>
+//    <wx>superexc</wx>
+//    <qqgroup>128148617</qqgroup>
+//    <url>https://jsq.ink</url>
+//    <role>pku engineer</role>
+//    <date>2019-03-16 19:16:41</date>
+//</624450103113682947>
+
+
+package discover
+
+import (
+	crand "crypto/rand"
+	"encoding/binary"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/hashicorp/golang-lru"
+)
+
+//handshakeTimeout是发出的握手质询保持有效的时间。
+const handshakeTimeout = time.Second
+
+//sessionCache保存与其他节点之间的会话密钥和未完成的握手质询。
+//会话以节点ID和端点为键，因此对方更换地址后需要重新握手。
+type sessionCache struct {
+	sessions   *lru.Cache
+	handshakes map[sessionID]*whoareyouV5
+}
+
+//sessionID标识与某个端点上的节点之间的会话。
+type sessionID struct {
+	id   enode.ID
+	addr string
+}
+
+//session包含一个会话的密钥。
+type session struct {
+	writeKey     []byte
+	readKey      []byte
+nonceCounter uint32 //用于构造不重复的AES-GCM随机数
+}
+
+func newSessionCache(maxItems int) *sessionCache {
+	cache, err := lru.New(maxItems)
+	if err != nil {
+		panic("can't create session cache")
+	}
+	return &sessionCache{
+		sessions:   cache,
+		handshakes: make(map[sessionID]*whoareyouV5),
+	}
+}
+
+//nextNonce为下一条消息创建随机数：四字节计数器后跟随机字节。
+func (s *session) nextNonce() ([]byte, error) {
+	s.nonceCounter++
+	nonce := make([]byte, authTagSize)
+	binary.BigEndian.PutUint32(nonce, s.nonceCounter)
+	_, err := crand.Read(nonce[4:])
+	return nonce, err
+}
+
+//session返回与给定节点之间的当前会话。
+func (sc *sessionCache) session(id enode.ID, addr string) *session {
+	item, ok := sc.sessions.Get(sessionID{id, addr})
+	if !ok {
+		return nil
+	}
+	return item.(*session)
+}
+
+//readKey返回用于解密来自给定节点的消息的密钥。
+func (sc *sessionCache) readKey(id enode.ID, addr string) []byte {
+	if s := sc.session(id, addr); s != nil {
+		return s.readKey
+	}
+	return nil
+}
+
+//storeNewSession保存与给定节点之间的会话，替换已有的会话。
+func (sc *sessionCache) storeNewSession(id enode.ID, addr string, s *session) {
+	sc.sessions.Add(sessionID{id, addr}, s)
+}
+
+//getHandshake返回发给给定节点的未过期的握手质询。
+func (sc *sessionCache) getHandshake(id enode.ID, addr string) *whoareyouV5 {
+	return sc.handshakes[sessionID{id, addr}]
+}
+
+//storeSentHandshake保存发给给定节点的握手质询。
+func (sc *sessionCache) storeSentHandshake(id enode.ID, addr string, challenge *whoareyouV5) {
+	challenge.sent = mclock.Now()
+	sc.handshakes[sessionID{id, addr}] = challenge
+}
+
+//deleteHandshake删除握手质询。
+func (sc *sessionCache) deleteHandshake(id enode.ID, addr string) {
+	delete(sc.handshakes, sessionID{id, addr})
+}
+
+//handshakeGC删除超时的握手质询。
+func (sc *sessionCache) handshakeGC() {
+	deadline := mclock.Now().Add(-handshakeTimeout)
+	for key, challenge := range sc.handshakes {
+		if challenge.sent < deadline {
+			delete(sc.handshakes, key)
+		}
+	}
+}
@@ -0,0 +1,649 @@
+
+//<developer>
+//    <name>linapex 曹一峰</name>
+//    <email>linapex@163.com</email>
+//    <wx>superexc</wx>
+//    <qqgroup>128148617</qqgroup>
+//    <url>https://jsq.ink</url>
+//    <role>pku engineer</role>
+//    <date>2019-03-16 19:16:41</date>
+//</624450103113682948>
+
+
+package discover
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	crand "crypto/rand"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+	"github.com/ethereum/go-ethereum/p2p/netutil"
+)
+
+const (
+respTimeoutV5           = 700 * time.Millisecond //等待每个回应数据包的时间
+findnodeResultLimit     = 16                     //FINDNODE回应中的最大节点数
+totalNodesResponseLimit = 5                      //接受的NODES数据包总数上限
+nodesResponseItemLimit  = 3                      //每个NODES数据包中的最大记录数
+)
+
+var (
+	errChallengeNoCall = errors.New("no matching call")
+	errChallengeTwice  = errors.New("second handshake")
+	errLowPort         = errors.New("low port")
+)
+
+//UDPv5实现discv5有线协议。它与v4共用节点表的实现，
+//但节点表中的条目总是带有完整的节点记录，因此可以按记录中的条目
+//（例如宣告的协议）查找节点。
+type UDPv5 struct {
+//静态字段
+	conn        conn
+	tab         *Table
+	netrestrict *netutil.Netlist
+	priv        *ecdsa.PrivateKey
+	localNode   *enode.LocalNode
+	db          *enode.DB
+
+//调度循环的通道
+	packetInCh    chan ReadPacket
+	readNextCh    chan struct{}
+	callCh        chan *callV5
+	callDoneCh    chan *callV5
+	respTimeoutCh chan *callTimeout
+
+//调度循环的状态
+	codec            *wireCodec
+	activeCallByNode map[enode.ID]*callV5
+	activeCallByAuth map[string]*callV5
+	callQueue        map[enode.ID][]*callV5
+
+	closeOnce sync.Once
+	closing   chan struct{}
+	wg        sync.WaitGroup
+}
+
+//callV5表示对某个节点的一个请求。每个节点同时只有一个活跃的请求，
+//其他请求在队列中等待。
+type callV5 struct {
+	node         *enode.Node
+	packet       packetV5
+responseType byte //期望的回应类型
+	reqid        []byte
+ch           chan packetV5 //回应发送到这里
+err          chan error    //错误发送到这里
+
+//以下字段只对活跃的请求有效
+authTag        []byte       //请求数据包的认证标签
+handshakeCount int          //为此请求进行握手的次数
+challenge      *whoareyouV5 //最后收到的握手质询
+	timeout        *time.Timer
+}
+
+//callTimeout是活跃请求的回应超时。
+type callTimeout struct {
+	c     *callV5
+	timer *time.Timer
+}
+
+//ListenV5在给定连接上启动discv5协议。
+func ListenV5(c conn, ln *enode.LocalNode, cfg Config) (*UDPv5, error) {
+	t := &UDPv5{
+		conn:             c,
+		netrestrict:      cfg.NetRestrict,
+		priv:             cfg.PrivateKey,
+		localNode:        ln,
+		db:               ln.Database(),
+		packetInCh:       make(chan ReadPacket, 1),
+		readNextCh:       make(chan struct{}, 1),
+		callCh:           make(chan *callV5),
+		callDoneCh:       make(chan *callV5),
+		respTimeoutCh:    make(chan *callTimeout),
+		codec:            newWireCodec(ln, cfg.PrivateKey),
+		activeCallByNode: make(map[enode.ID]*callV5),
+		activeCallByAuth: make(map[string]*callV5),
+		callQueue:        make(map[enode.ID][]*callV5),
+		closing:          make(chan struct{}),
+	}
+	tab, err := newTable(t, t.db, cfg.Bootnodes)
+	if err != nil {
+		return nil, err
+	}
+	t.tab = tab
+
+	t.wg.Add(2)
+	go t.readLoop()
+	go t.dispatch()
+	return t, nil
+}
+
+//Self返回本地节点。
+func (t *UDPv5) Self() *enode.Node {
+	return t.localNode.Node()
+}
+
+//Close关闭套接字并停止节点表的维护。
+func (t *UDPv5) Close() {
+	t.tab.Close()
+}
+
+//Ping向给定节点发送ping消息并等待回应。
+func (t *UDPv5) Ping(n *enode.Node) error {
+	_, err := t.pingSeq(n)
+	return err
+}
+
+//Resolve搜索具有给定ID的节点的最新记录。如果找不到节点，则返回n。
+func (t *UDPv5) Resolve(n *enode.Node) *enode.Node {
+	if rn, err := t.RequestENR(n); err == nil && rn.Seq() > n.Seq() {
+		n = rn
+	}
+	if rn := t.tab.Resolve(n); rn != nil && rn.Seq() > n.Seq() {
+		n = rn
+	}
+	return n
+}
+
+//RequestENR向给定节点请求其当前的节点记录。
+func (t *UDPv5) RequestENR(n *enode.Node) (*enode.Node, error) {
+	nodes, err := t.findnodeDist(n, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) != 1 {
+		return nil, fmt.Errorf("%d nodes in response for distance zero", len(nodes))
+	}
+	return nodes[0], nil
+}
+
+//LookupRandom查找网络中的随机节点。
+func (t *UDPv5) LookupRandom() []*enode.Node {
+	return t.tab.LookupRandom()
+}
+
+//ReadRandomNodes用节点表中的随机节点填充给定切片。
+func (t *UDPv5) ReadRandomNodes(buf []*enode.Node) int {
+	return t.tab.ReadRandomNodes(buf)
+}
+
+//LookupFilter执行一次随机查找，并返回节点表和查找结果中满足filter的节点。
+//它用于按能力查找节点，例如只查找在记录中宣告了某个协议的节点。
+func (t *UDPv5) LookupFilter(filter func(*enode.Node) bool) []*enode.Node {
+	found := t.LookupRandom()
+
+	t.tab.mutex.Lock()
+	for _, b := range &t.tab.buckets {
+		found = append(found, unwrapNodes(b.entries)...)
+	}
+	t.tab.mutex.Unlock()
+
+	var (
+		result []*enode.Node
+		seen   = make(map[enode.ID]bool)
+	)
+	for _, n := range found {
+		if !seen[n.ID()] && filter(n) {
+			seen[n.ID()] = true
+			result = append(result, n)
+		}
+	}
+	return result
+}
+
+//self实现transport。
+func (t *UDPv5) self() *enode.Node {
+	return t.Self()
+}
+
+//ping实现transport。如果对方的记录比表中的更新，则请求新记录并更新节点表。
+func (t *UDPv5) ping(n *node) error {
+	seq, err := t.pingSeq(&n.Node)
+	if err != nil {
+		return err
+	}
+	if seq > n.Seq() {
+		if rn, err := t.RequestENR(&n.Node); err == nil {
+			t.tab.updateNode(wrapNode(rn))
+		}
+	}
+	return nil
+}
+
+//findnode实现transport。它请求目标所在距离上的节点。
+func (t *UDPv5) findnode(n *node, target encPubkey) ([]*node, error) {
+	nodes, err := t.findnodeDist(&n.Node, enode.LogDist(target.id(), n.ID()))
+	return wrapNodes(nodes), err
+}
+
+//close实现transport。
+func (t *UDPv5) close() {
+	t.closeOnce.Do(func() {
+		close(t.closing)
+		t.conn.Close()
+		t.wg.Wait()
+	})
+}
+
+//pingSeq发送ping消息并返回对方记录的序列号。
+func (t *UDPv5) pingSeq(n *enode.Node) (uint64, error) {
+	c := t.call(n, pongMsgV5, &pingV5{ENRSeq: t.localNode.Node().Seq()})
+	defer t.callDone(c)
+
+	select {
+	case resp := <-c.ch:
+		return resp.(*pongV5).ENRSeq, nil
+	case err := <-c.err:
+		return 0, err
+	}
+}
+
+//findnodeDist请求与n处于给定距离的节点，并等待所有回应数据包。
+func (t *UDPv5) findnodeDist(n *enode.Node, distance int) ([]*enode.Node, error) {
+	c := t.call(n, nodesMsgV5, &findnodeV5{Distance: uint(distance)})
+	defer t.callDone(c)
+
+	var (
+		nodes           []*enode.Node
+		seen            = make(map[enode.ID]struct{})
+		received, total = 0, -1
+	)
+	for {
+		select {
+		case resp := <-c.ch:
+			p := resp.(*nodesV5)
+			for _, r := range p.Nodes {
+				rn, err := t.verifyResponseNode(c, r, distance, seen)
+				if err != nil {
+					log.Debug("Invalid record in "+p.name(), "id", c.node.ID(), "err", err)
+					continue
+				}
+				nodes = append(nodes, rn)
+			}
+			if total == -1 {
+				total = int(p.Total)
+				if total > totalNodesResponseLimit {
+					total = totalNodesResponseLimit
+				}
+			}
+			if received++; received >= total {
+				return nodes, nil
+			}
+		case err := <-c.err:
+			return nodes, err
+		}
+	}
+}
+
+//verifyResponseNode检查NODES回应中的记录是否有效并处于请求的距离。
+func (t *UDPv5) verifyResponseNode(c *callV5, r *enr.Record, distance int, seen map[enode.ID]struct{}) (*enode.Node, error) {
+	n, err := enode.New(enode.ValidSchemes, r)
+	if err != nil {
+		return nil, err
+	}
+	if err := n.ValidateComplete(); err != nil {
+		return nil, err
+	}
+	if err := netutil.CheckRelayIP(c.node.IP(), n.IP()); err != nil {
+		return nil, err
+	}
+	if t.netrestrict != nil && !t.netrestrict.Contains(n.IP()) {
+		return nil, errors.New("not contained in netrestrict whitelist")
+	}
+	if n.UDP() <= 1024 {
+		return nil, errLowPort
+	}
+	if d := enode.LogDist(c.node.ID(), n.ID()); d != distance {
+		return nil, fmt.Errorf("wrong distance %d, want %d", d, distance)
+	}
+	if _, ok := seen[n.ID()]; ok {
+		return nil, errors.New("duplicate record")
+	}
+	seen[n.ID()] = struct{}{}
+	return n, nil
+}
+
+//call将请求交给调度循环。调用者必须在完成后调用callDone。
+func (t *UDPv5) call(n *enode.Node, responseType byte, packet packetV5) *callV5 {
+	c := &callV5{
+		node:         n,
+		packet:       packet,
+		responseType: responseType,
+		reqid:        make([]byte, 8),
+		ch:           make(chan packetV5, 1),
+		err:          make(chan error, 1),
+	}
+	crand.Read(c.reqid)
+	packet.setreqid(c.reqid)
+
+	select {
+	case t.callCh <- c:
+	case <-t.closing:
+		c.err <- errClosed
+	}
+	return c
+}
+
+//callDone通知调度循环请求已完成。
+func (t *UDPv5) callDone(c *callV5) {
+	for {
+		select {
+//丢弃迟到的回应，避免阻塞调度循环
+		case <-c.ch:
+		case <-c.err:
+		case t.callDoneCh <- c:
+			return
+		case <-t.closing:
+			return
+		}
+	}
+}
+
+//dispatch在自己的goroutine中运行。它处理传入的数据包并管理请求的状态。
+func (t *UDPv5) dispatch() {
+	defer t.wg.Done()
+
+	gc := time.NewTicker(handshakeTimeout)
+	defer gc.Stop()
+
+//允许第一次读取
+	t.readNextCh <- struct{}{}
+
+	for {
+		select {
+		case c := <-t.callCh:
+			id := c.node.ID()
+			t.callQueue[id] = append(t.callQueue[id], c)
+			t.sendNextCall(id)
+
+		case ct := <-t.respTimeoutCh:
+			active := t.activeCallByNode[ct.c.node.ID()]
+			if ct.c == active && ct.timer == active.timeout {
+				ct.c.err <- errTimeout
+			}
+
+		case c := <-t.callDoneCh:
+			id := c.node.ID()
+			if t.activeCallByNode[id] != c {
+				panic("BUG: callDone for inactive call")
+			}
+			c.timeout.Stop()
+			delete(t.activeCallByAuth, string(c.authTag))
+			delete(t.activeCallByNode, id)
+			t.sendNextCall(id)
+
+		case p := <-t.packetInCh:
+			t.handlePacket(p.Data, p.Addr)
+//允许下一次读取
+			t.readNextCh <- struct{}{}
+
+		case <-gc.C:
+			t.codec.sc.handshakeGC()
+
+		case <-t.closing:
+			close(t.readNextCh)
+			for id, queue := range t.callQueue {
+				for _, c := range queue {
+					c.err <- errClosed
+				}
+				delete(t.callQueue, id)
+			}
+			for id, c := range t.activeCallByNode {
+				c.timeout.Stop()
+				select {
+				case c.err <- errClosed:
+				default:
+				}
+				delete(t.activeCallByNode, id)
+			}
+			return
+		}
+	}
+}
+
+//sendNextCall在没有活跃请求时发送队列中的下一个请求。
+func (t *UDPv5) sendNextCall(id enode.ID) {
+	queue := t.callQueue[id]
+	if len(queue) == 0 || t.activeCallByNode[id] != nil {
+		return
+	}
+	t.activeCallByNode[id] = queue[0]
+	t.sendCall(queue[0])
+	if len(queue) == 1 {
+		delete(t.callQueue, id)
+	} else {
+		copy(queue, queue[1:])
+		t.callQueue[id] = queue[:len(queue)-1]
+	}
+}
+
+//sendCall编码并发送请求数据包，并开始等待回应。
+func (t *UDPv5) sendCall(c *callV5) {
+	if len(c.authTag) > 0 {
+		delete(t.activeCallByAuth, string(c.authTag))
+	}
+	addr := &net.UDPAddr{IP: c.node.IP(), Port: c.node.UDP()}
+	c.authTag, _ = t.send(c.node.ID(), addr, c.packet, c.challenge)
+	t.activeCallByAuth[string(c.authTag)] = c
+	t.startResponseTimeout(c)
+}
+
+//startResponseTimeout为请求的下一个回应数据包重新设置超时。
+func (t *UDPv5) startResponseTimeout(c *callV5) {
+	if c.timeout != nil {
+		c.timeout.Stop()
+	}
+	ct := &callTimeout{c: c}
+	ct.timer = time.AfterFunc(respTimeoutV5, func() {
+		select {
+		case t.respTimeoutCh <- ct:
+		case <-t.closing:
+		}
+	})
+	c.timeout = ct.timer
+}
+
+//sendResponse向给定节点发送回应数据包。
+func (t *UDPv5) sendResponse(toID enode.ID, toAddr *net.UDPAddr, packet packetV5) error {
+	_, err := t.send(toID, toAddr, packet, nil)
+	return err
+}
+
+//send编码并发送数据包，返回数据包的认证标签。
+func (t *UDPv5) send(toID enode.ID, toAddr *net.UDPAddr, packet packetV5, challenge *whoareyouV5) ([]byte, error) {
+	addr := toAddr.String()
+	enc, authTag, err := t.codec.encode(toID, addr, packet, challenge)
+	if err != nil {
+		log.Warn(">> "+packet.name(), "id", toID, "addr", addr, "err", err)
+		return authTag, err
+	}
+	_, err = t.conn.WriteToUDP(enc, toAddr)
+	log.Trace(">> "+packet.name(), "id", toID, "addr", addr, "err", err)
+	return authTag, err
+}
+
+//readLoop在自己的goroutine中运行。它读取数据包并交给调度循环处理，
+//每次读取都要等待调度循环允许，因此读取缓冲区可以重复使用。
+func (t *UDPv5) readLoop() {
+	defer t.wg.Done()
+
+	buf := make([]byte, maxPacketSize)
+	for range t.readNextCh {
+		nbytes, from, err := t.conn.ReadFromUDP(buf)
+		for netutil.IsTemporaryError(err) {
+//忽略临时读取错误。
+			log.Debug("Temporary UDP read error", "err", err)
+			nbytes, from, err = t.conn.ReadFromUDP(buf)
+		}
+		if err != nil {
+//关闭永久错误循环。
+			log.Debug("UDP read error", "err", err)
+			return
+		}
+		select {
+		case t.packetInCh <- ReadPacket{buf[:nbytes], from}:
+		case <-t.closing:
+			return
+		}
+	}
+}
+
+//handlePacket解码并处理传入的数据包。
+func (t *UDPv5) handlePacket(rawpacket []byte, fromAddr *net.UDPAddr) error {
+	addr := fromAddr.String()
+	fromID, fromNode, packet, err := t.codec.decode(rawpacket, addr)
+	if err != nil {
+		log.Debug("Bad discv5 packet", "id", fromID, "addr", addr, "err", err)
+		return err
+	}
+	if fromNode != nil {
+//握手完成，只有当记录中的端点与数据包来源一致时才加入节点表
+		if fromNode.IP().Equal(fromAddr.IP) && fromNode.UDP() == fromAddr.Port {
+			n := wrapNode(fromNode)
+			t.tab.addThroughPing(n)
+			t.tab.updateNode(n)
+		}
+	}
+	if packet.kind() != whoareyouMsgV5 {
+		log.Trace("<< "+packet.name(), "id", fromID, "addr", addr)
+	}
+	packet.handle(t, fromID, fromAddr)
+	return nil
+}
+
+//handleCallResponse将回应交给等待它的请求。如果回应不属于活跃请求则返回false。
+func (t *UDPv5) handleCallResponse(fromID enode.ID, fromAddr *net.UDPAddr, reqid []byte, p packetV5) bool {
+	ac := t.activeCallByNode[fromID]
+	if ac == nil || !bytes.Equal(reqid, ac.reqid) {
+		log.Debug(fmt.Sprintf("Unsolicited/late %s response", p.name()), "id", fromID, "addr", fromAddr)
+		return false
+	}
+	if !fromAddr.IP.Equal(ac.node.IP()) || fromAddr.Port != ac.node.UDP() {
+		log.Debug(fmt.Sprintf("%s from wrong endpoint", p.name()), "id", fromID, "addr", fromAddr)
+		return false
+	}
+	if p.kind() != ac.responseType {
+		log.Debug(fmt.Sprintf("Wrong discv5 response type %s", p.name()), "id", fromID, "addr", fromAddr)
+		return false
+	}
+	t.startResponseTimeout(ac)
+	ac.ch <- p
+	return true
+}
+
+//getNode在节点表和数据库中查找具有给定ID的节点。
+func (t *UDPv5) getNode(id enode.ID) *enode.Node {
+	if n := t.tab.getNode(id); n != nil {
+		return n
+	}
+	return t.db.Node(id)
+}
+
+//matchWithCall返回认证标签与握手质询一致的活跃请求。
+func (t *UDPv5) matchWithCall(fromAddr *net.UDPAddr, authTag []byte) (*callV5, error) {
+	c := t.activeCallByAuth[string(authTag)]
+	if c == nil {
+		return nil, errChallengeNoCall
+	}
+	if c.handshakeCount > 0 {
+		return nil, errChallengeTwice
+	}
+	if !fromAddr.IP.Equal(c.node.IP()) || fromAddr.Port != c.node.UDP() {
+		return nil, errChallengeNoCall
+	}
+	return c, nil
+}
+
+//handle处理无法解密的消息：向发送者发出握手质询。
+func (p *unknownV5) handle(t *UDPv5, fromID enode.ID, fromAddr *net.UDPAddr) {
+	challenge := &whoareyouV5{AuthTag: p.AuthTag}
+	crand.Read(challenge.IDNonce[:])
+	if n := t.getNode(fromID); n != nil {
+		challenge.node = n
+		challenge.RecordSeq = n.Seq()
+	}
+	t.sendResponse(fromID, fromAddr, challenge)
+}
+
+//handle处理握手质询：使用握手重新发送被质询的请求。
+func (p *whoareyouV5) handle(t *UDPv5, fromID enode.ID, fromAddr *net.UDPAddr) {
+	c, err := t.matchWithCall(fromAddr, p.AuthTag)
+	if err != nil {
+		log.Debug("Invalid "+p.name(), "addr", fromAddr, "err", err)
+		return
+	}
+	log.Trace("<< "+p.name(), "id", c.node.ID(), "addr", fromAddr)
+	c.handshakeCount++
+	c.challenge = p
+	p.node = c.node
+	t.sendCall(c)
+}
+
+func (p *pingV5) handle(t *UDPv5, fromID enode.ID, fromAddr *net.UDPAddr) {
+	t.sendResponse(fromID, fromAddr, &pongV5{
+		ReqID:  p.ReqID,
+		ToIP:   fromAddr.IP,
+		ToPort: uint16(fromAddr.Port),
+		ENRSeq: t.localNode.Node().Seq(),
+	})
+}
+
+func (p *pongV5) handle(t *UDPv5, fromID enode.ID, fromAddr *net.UDPAddr) {
+	if t.handleCallResponse(fromID, fromAddr, p.ReqID, p) {
+		t.localNode.UDPEndpointStatement(fromAddr, &net.UDPAddr{IP: p.ToIP, Port: int(p.ToPort)})
+	}
+}
+
+//handle回应与本地节点处于请求距离的节点。只有带签名记录的节点才会被发送。
+func (p *findnodeV5) handle(t *UDPv5, fromID enode.ID, fromAddr *net.UDPAddr) {
+	if p.Distance == 0 {
+		t.sendNodes(fromID, fromAddr, p.ReqID, []*enode.Node{t.Self()})
+		return
+	}
+	var nodes []*enode.Node
+	self := t.Self().ID()
+	t.tab.mutex.Lock()
+	for _, b := range &t.tab.buckets {
+		for _, n := range b.entries {
+			if len(nodes) >= findnodeResultLimit {
+				break
+			}
+			if uint(enode.LogDist(self, n.ID())) != p.Distance || n.Record().IdentityScheme() == "" {
+				continue
+			}
+			if netutil.CheckRelayIP(fromAddr.IP, n.IP()) != nil {
+				continue
+			}
+			nodes = append(nodes, unwrapNode(n))
+		}
+	}
+	t.tab.mutex.Unlock()
+	t.sendNodes(fromID, fromAddr, p.ReqID, nodes)
+}
+
+//sendNodes将节点分成多个NODES数据包发送。即使没有节点也至少发送一个数据包。
+func (t *UDPv5) sendNodes(toID enode.ID, toAddr *net.UDPAddr, reqid []byte, nodes []*enode.Node) {
+	total := (len(nodes) + nodesResponseItemLimit - 1) / nodesResponseItemLimit
+	if total == 0 {
+		total = 1
+	}
+	resp := &nodesV5{ReqID: reqid, Total: uint8(total)}
+	for i := 0; i < total; i++ {
+		resp.Nodes = resp.Nodes[:0]
+		for j := i * nodesResponseItemLimit; j < len(nodes) && j < (i+1)*nodesResponseItemLimit; j++ {
+			resp.Nodes = append(resp.Nodes, nodes[j].Record())
+		}
+		t.sendResponse(toID, toAddr, resp)
+	}
+}
+
+func (p *nodesV5) handle(t *UDPv5, fromID enode.ID, fromAddr *net.UDPAddr) {
+	t.handleCallResponse(fromID, fromAddr, p.ReqID, p)
+}
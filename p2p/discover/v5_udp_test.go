@@ -0,0 +1,176 @@
+
+//<developer>
+//    <name>linapex 曹一峰</name>
+//    <email>linapex@163.com</email>
+//    <wx>superexc</wx>
+//    <qqgroup>128148617</qqgroup>
+//    <url>https://jsq.ink</url>
+//    <role>pku engineer</role>
+//    <date>2019-03-16 19:16:41</date>
+//</624450103234531329>
+
+
+package discover
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+)
+
+//startLocalhostV5在本地回环地址上启动discv5节点。
+func startLocalhostV5(t *testing.T, cfg Config) *UDPv5 {
+	cfg.PrivateKey = newkey()
+	db, _ := enode.OpenDB("")
+	ln := enode.NewLocalNode(db, cfg.PrivateKey)
+
+	socket, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IP{127, 0, 0, 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	realaddr := socket.LocalAddr().(*net.UDPAddr)
+	ln.SetStaticIP(realaddr.IP)
+	ln.SetFallbackUDP(realaddr.Port)
+	udp, err := ListenV5(socket, ln, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return udp
+}
+
+//这个测试检查两个节点能否完成握手并交换消息。
+func TestUDPv5_pingHandshake(t *testing.T) {
+	t.Parallel()
+	node0 := startLocalhostV5(t, Config{})
+	node1 := startLocalhostV5(t, Config{})
+	defer node0.Close()
+	defer node1.Close()
+
+//第一次ping需要握手，第二次使用已建立的会话。
+	for i := 0; i < 2; i++ {
+		if err := node0.Ping(node1.Self()); err != nil {
+			t.Fatalf("ping %d failed: %v", i, err)
+		}
+	}
+//对方也可以使用握手时建立的会话。
+	if err := node1.Ping(node0.Self()); err != nil {
+		t.Fatal("reverse ping failed:", err)
+	}
+}
+
+//这个测试检查RequestENR返回对方的最新记录。
+func TestUDPv5_requestENR(t *testing.T) {
+	t.Parallel()
+	node0 := startLocalhostV5(t, Config{})
+	node1 := startLocalhostV5(t, Config{})
+	defer node0.Close()
+	defer node1.Close()
+
+	node1.localNode.Set(enr.WithEntry("foo", uint(1)))
+	n, err := node0.RequestENR(node1.Self())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.ID() != node1.Self().ID() {
+		t.Fatalf("wrong node ID %v", n.ID())
+	}
+	if n.Seq() != node1.Self().Seq() {
+		t.Fatalf("wrong record seq %d, want %d", n.Seq(), node1.Self().Seq())
+	}
+}
+
+//这个测试检查FINDNODE回应包含请求距离上的节点。
+func TestUDPv5_findnode(t *testing.T) {
+	t.Parallel()
+	node0 := startLocalhostV5(t, Config{})
+	node1 := startLocalhostV5(t, Config{})
+	node2 := startLocalhostV5(t, Config{})
+	defer node0.Close()
+	defer node1.Close()
+	defer node2.Close()
+
+	node1.tab.stuff([]*node{wrapNode(node2.Self())})
+	dist := enode.LogDist(node1.Self().ID(), node2.Self().ID())
+	nodes, err := node0.findnodeDist(node1.Self(), dist)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 1 || nodes[0].ID() != node2.Self().ID() {
+		t.Fatalf("wrong nodes in response: %v", nodes)
+	}
+
+//其他距离上没有节点。
+	nodes, err = node0.findnodeDist(node1.Self(), dist-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 0 {
+		t.Fatalf("unexpected nodes in response: %v", nodes)
+	}
+}
+
+//这个测试检查LookupFilter按记录条目找到节点。
+func TestUDPv5_lookupFilter(t *testing.T) {
+	t.Parallel()
+	node1 := startLocalhostV5(t, Config{})
+	node2 := startLocalhostV5(t, Config{})
+	defer node1.Close()
+	defer node2.Close()
+	node2.localNode.Set(enr.WithEntry("foo", uint(1)))
+	node1.tab.stuff([]*node{wrapNode(node2.Self())})
+
+	node0 := startLocalhostV5(t, Config{Bootnodes: []*enode.Node{node1.Self()}})
+	defer node0.Close()
+	node0.tab.stuff([]*node{wrapNode(node1.Self()), wrapNode(node2.Self())})
+
+	result := node0.LookupFilter(func(n *enode.Node) bool {
+		var v uint
+		return n.Load(enr.WithEntry("foo", &v)) == nil
+	})
+	if len(result) != 1 || result[0].ID() != node2.Self().ID() {
+		t.Fatalf("wrong lookup result: %v", result)
+	}
+}
+
+//这个测试检查篡改过的数据包无法解密，而是触发新的握手质询。
+func TestUDPv5_codecTamper(t *testing.T) {
+	t.Parallel()
+	node0 := startLocalhostV5(t, Config{})
+	node1 := startLocalhostV5(t, Config{})
+	defer node0.Close()
+	defer node1.Close()
+
+	if err := node0.Ping(node1.Self()); err != nil {
+		t.Fatal(err)
+	}
+	var (
+		addr0 = &net.UDPAddr{IP: node0.Self().IP(), Port: node0.Self().UDP()}
+		addr1 = &net.UDPAddr{IP: node1.Self().IP(), Port: node1.Self().UDP()}
+	)
+	c0, c1 := newWireCodec(node0.localNode, node0.priv), newWireCodec(node1.localNode, node1.priv)
+	c0.sc, c1.sc = node0.codec.sc, node1.codec.sc
+
+	enc, _, err := c0.encode(node1.Self().ID(), addr1.String(), &pingV5{ReqID: []byte{1}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, p, err := c1.decode(enc, addr0.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ping, ok := p.(*pingV5); !ok || !bytes.Equal(ping.ReqID, []byte{1}) {
+		t.Fatalf("wrong packet %v", p)
+	}
+
+	enc[len(enc)-1] ^= 0xFF
+	_, _, p, err = c1.decode(enc, addr0.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := p.(*unknownV5); !ok {
+		t.Fatalf("tampered packet decoded as %v", p.name())
+	}
+}
@@ -209,6 +209,12 @@ data       interface{} //rpc结构之一
 	rawData    []byte
 }
 
+//IsPacket报告data是否以本协议数据包的版本前缀开头。与其他发现协议
+//共用UDP端口时用于区分数据包。
+func IsPacket(data []byte) bool {
+	return bytes.HasPrefix(data, versionPrefix)
+}
+
 type conn interface {
 	ReadFromUDP(b []byte) (n int, addr *net.UDPAddr, err error)
 	WriteToUDP(b []byte, addr *net.UDPAddr) (n int, err error)
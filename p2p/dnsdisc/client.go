@@ -0,0 +1,375 @@
+
+//<developer>
+//    <name>linapex 曹一峰</name>
+//    <email>linapex@163.com</email>
+//    <wx>superexc</wx>
+//    <qqgroup>128148617</qqgroup>
+//    <url>https://jsq.ink</url>
+//    <role>pku engineer</role>
+//    <date>2019-03-16 19:16:41</date>
+//</624450102517286272>
+
+
+//包dnsdisc实现基于DNS的节点发现（EIP-1459）客户端。
+package dnsdisc
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+//Client通过DNS发现节点树中的节点。
+type Client struct {
+	cfg   Config
+	cache *lru.Cache
+	links []*linkEntry
+
+	lock  sync.RWMutex
+	trees map[string]*clientTree //已同步的树，按链接字符串索引
+	nodes []*enode.Node          //所有树中节点的并集
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+//Config保存DNS发现客户端的配置。
+type Config struct {
+Timeout         time.Duration      //单次DNS查找的超时，默认5秒
+RecheckInterval time.Duration      //检查树根更新的时间间隔，默认30分钟
+CacheLimit      int                //缓存的最大树条目数，默认1000
+ValidSchemes    enr.IdentityScheme //可接受的节点记录身份方案，默认enode.ValidSchemes
+Resolver        Resolver           //使用的DNS解析器，默认系统解析器
+Logger          log.Logger         //日志记录器，默认log.Root()
+}
+
+//Resolver是客户端使用的DNS解析器。
+type Resolver interface {
+	LookupTXT(ctx context.Context, domain string) ([]string, error)
+}
+
+func (cfg Config) withDefaults() Config {
+	const (
+		defaultTimeout = 5 * time.Second
+		defaultRecheck = 30 * time.Minute
+		defaultCache   = 1000
+	)
+	if cfg.Timeout == 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if cfg.RecheckInterval == 0 {
+		cfg.RecheckInterval = defaultRecheck
+	}
+	if cfg.CacheLimit == 0 {
+		cfg.CacheLimit = defaultCache
+	}
+	if cfg.ValidSchemes == nil {
+		cfg.ValidSchemes = enode.ValidSchemes
+	}
+	if cfg.Resolver == nil {
+		cfg.Resolver = new(net.Resolver)
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = log.Root()
+	}
+	return cfg
+}
+
+//clientTree是客户端已同步的一棵树。
+type clientTree struct {
+	root  rootEntry
+	nodes []*enode.Node
+	links []*linkEntry
+}
+
+//NewClient创建一个客户端，从给定的enrtree链接中发现节点。
+func NewClient(cfg Config, urls ...string) (*Client, error) {
+	c := &Client{
+		cfg:   cfg.withDefaults(),
+		trees: make(map[string]*clientTree),
+		quit:  make(chan struct{}),
+	}
+	var err error
+	if c.cache, err = lru.New(c.cfg.CacheLimit); err != nil {
+		return nil, err
+	}
+	for _, url := range urls {
+		le, err := parseLink(url)
+		if err != nil {
+			return nil, fmt.Errorf("invalid enrtree URL %q: %v", url, err)
+		}
+		c.links = append(c.links, le)
+	}
+	return c, nil
+}
+
+//Start启动后台同步循环，定期重新解析所有树。
+func (c *Client) Start() {
+	c.wg.Add(1)
+	go c.loop()
+}
+
+//Close停止后台同步循环。
+func (c *Client) Close() {
+	close(c.quit)
+	c.wg.Wait()
+}
+
+//ReadRandomNodes用已发现节点的随机样本填充给定切片，
+//并返回写入的节点数。
+func (c *Client) ReadRandomNodes(buf []*enode.Node) int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	perm := rand.Perm(len(c.nodes))
+	n := 0
+	for ; n < len(buf) && n < len(perm); n++ {
+		buf[n] = c.nodes[perm[n]]
+	}
+	return n
+}
+
+//SyncTree下载给定链接处的完整节点树。
+func (c *Client) SyncTree(url string) (*Tree, error) {
+	le, err := parseLink(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid enrtree URL: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-c.quit:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return c.syncTree(ctx, le)
+}
+
+//循环在启动时以及每个重新检查间隔同步所有树。
+func (c *Client) loop() {
+	defer c.wg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-c.quit
+		cancel()
+	}()
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			c.refresh(ctx)
+			timer.Reset(c.cfg.RecheckInterval)
+		case <-c.quit:
+			return
+		}
+	}
+}
+
+//refresh检查所有已知树（包括链接到的树）的根，并重新同步已更改的树。
+func (c *Client) refresh(ctx context.Context) {
+	var (
+		queue = append([]*linkEntry{}, c.links...)
+		seen  = make(map[string]bool)
+		trees = make(map[string]*clientTree)
+	)
+	for len(queue) > 0 {
+		le := queue[0]
+		queue = queue[1:]
+		if seen[le.String()] {
+			continue
+		}
+		seen[le.String()] = true
+
+		c.lock.RLock()
+		prev := c.trees[le.String()]
+		c.lock.RUnlock()
+
+		tree, err := c.updateTree(ctx, le, prev)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			c.cfg.Logger.Debug("DNS discovery sync failed", "tree", le.domain, "err", err)
+			if prev == nil {
+				continue
+			}
+//保留旧的树，直到下次成功同步
+			tree = prev
+		}
+		trees[le.String()] = tree
+		queue = append(queue, tree.links...)
+	}
+//用新的结果集替换节点集
+	var nodes []*enode.Node
+	dedup := make(map[enode.ID]bool)
+	for _, tree := range trees {
+		for _, n := range tree.nodes {
+			if !dedup[n.ID()] {
+				dedup[n.ID()] = true
+				nodes = append(nodes, n)
+			}
+		}
+	}
+	c.lock.Lock()
+	c.trees, c.nodes = trees, nodes
+	c.lock.Unlock()
+
+	c.cfg.Logger.Debug("DNS discovery refreshed", "trees", len(trees), "nodes", len(nodes))
+}
+
+//updateTree在树根发生变化时重新同步树，否则返回先前的结果。
+func (c *Client) updateTree(ctx context.Context, le *linkEntry, prev *clientTree) (*clientTree, error) {
+	root, err := c.resolveRoot(ctx, le)
+	if err != nil {
+		return nil, err
+	}
+	if prev != nil && prev.root.seq == root.seq && prev.root.eroot == root.eroot && prev.root.lroot == root.lroot {
+		return prev, nil
+	}
+	tree, err := c.syncEntries(ctx, le, root)
+	if err != nil {
+		return nil, err
+	}
+	ct := &clientTree{root: root, nodes: tree.Nodes()}
+	for _, e := range tree.entries {
+		if link, ok := e.(*linkEntry); ok {
+			ct.links = append(ct.links, link)
+		}
+	}
+	c.cfg.Logger.Debug("Synced DNS discovery tree", "tree", le.domain, "seq", root.seq, "nodes", len(ct.nodes), "links", len(ct.links))
+	return ct, nil
+}
+
+//syncTree下载给定链接处的树根和所有条目。
+func (c *Client) syncTree(ctx context.Context, le *linkEntry) (*Tree, error) {
+	root, err := c.resolveRoot(ctx, le)
+	if err != nil {
+		return nil, err
+	}
+	return c.syncEntries(ctx, le, root)
+}
+
+//syncEntries下载根引用的所有条目。
+func (c *Client) syncEntries(ctx context.Context, le *linkEntry, root rootEntry) (*Tree, error) {
+	tree := &Tree{root: &root, entries: make(map[string]entry)}
+	if err := c.collect(ctx, le.domain, root.lroot, true, tree); err != nil {
+		return nil, err
+	}
+	if err := c.collect(ctx, le.domain, root.eroot, false, tree); err != nil {
+		return nil, err
+	}
+	return tree, nil
+}
+
+//collect递归地解析给定哈希下的所有条目并将它们添加到树中。
+//链接子树中只允许出现链接，节点子树中只允许出现节点记录。
+func (c *Client) collect(ctx context.Context, domain, hash string, link bool, tree *Tree) error {
+	if _, ok := tree.entries[hash]; ok {
+		return nil
+	}
+	e, err := c.resolveEntry(ctx, domain, hash)
+	if err != nil {
+		return err
+	}
+	tree.entries[hash] = e
+
+	switch e := e.(type) {
+	case *branchEntry:
+		for _, child := range e.children {
+			if err := c.collect(ctx, domain, child, link, tree); err != nil {
+				return err
+			}
+		}
+	case *enrEntry:
+		if link {
+			return nameError{hash + "." + domain, errENRInLinkTree}
+		}
+	case *linkEntry:
+		if !link {
+			return nameError{hash + "." + domain, errLinkInENRTree}
+		}
+	}
+	return nil
+}
+
+//resolveRoot检索树根，并验证其签名。
+func (c *Client) resolveRoot(ctx context.Context, le *linkEntry) (rootEntry, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
+	defer cancel()
+
+	txts, err := c.cfg.Resolver.LookupTXT(ctx, le.domain)
+	c.cfg.Logger.Trace("Updating DNS discovery root", "tree", le.domain, "err", err)
+	if err != nil {
+		return rootEntry{}, err
+	}
+	for _, txt := range txts {
+		if strings.HasPrefix(txt, rootPrefix) {
+			e, err := parseRoot(txt)
+			if err != nil {
+				return e, nameError{le.domain, err}
+			}
+			if !e.verifySignature(le.pubkey) {
+				return e, nameError{le.domain, entryError{typ: "root", err: errInvalidSig}}
+			}
+			return e, nil
+		}
+	}
+	return rootEntry{}, nameError{le.domain, errNoRoot}
+}
+
+//resolveEntry检索树条目，并检查其内容与哈希是否一致。
+//条目是内容寻址的，因此解析的结果会被缓存。
+func (c *Client) resolveEntry(ctx context.Context, domain, hash string) (entry, error) {
+	cacheKey := truncateHash(hash)
+	if e, ok := c.cache.Get(cacheKey); ok {
+		return e.(entry), nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
+	defer cancel()
+
+	name := hash + "." + domain
+	txts, err := c.cfg.Resolver.LookupTXT(ctx, name)
+	c.cfg.Logger.Trace("DNS discovery lookup", "name", name, "err", err)
+	if err != nil {
+		return nil, err
+	}
+	for _, txt := range txts {
+		e, err := parseEntry(txt, c.cfg.ValidSchemes)
+		if err == errUnknownEntry {
+			continue
+		}
+		if err != nil {
+			return nil, nameError{name, err}
+		}
+		if !strings.HasPrefix(subdomain(e), hash) {
+			return nil, nameError{name, errHashMismatch}
+		}
+		c.cache.Add(cacheKey, e)
+		return e, nil
+	}
+	return nil, nameError{name, errNoEntry}
+}
+
+//truncateHash将base32哈希截断到最小长度，用作缓存键。
+func truncateHash(hash string) string {
+	maxLen := b32format.EncodedLen(minHashLength)
+	if len(hash) < maxLen {
+		return hash
+	}
+	return hash[:maxLen]
+}
@@ -0,0 +1,183 @@
+
+//<developer>
+//    <name>linapex 曹一峰</name>
+//    <email>linapex@163.com</email>
+//    <wx>superexc</wx>
+//    <qqgroup>128148617</qqgroup>
+//    <url>https://jsq.ink</url>
+//    <role>pku engineer</role>
+//    <date>2019-03-16 19:16:41</date>
+//</624450102517286275>
+
+
+package dnsdisc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+)
+
+//测试完整的树同步能够检索所有节点和链接。
+func TestClientSyncTree(t *testing.T) {
+	nodes := testNodes(40)
+	link := (&linkEntry{"nodes.example.org", &testKey(2).PublicKey}).String()
+	tree, url := makeTestTree("n", nodes, []string{link})
+
+	c, _ := NewClient(Config{Resolver: newMapResolver(tree.ToTXT("n"))}, url)
+	stree, err := c.SyncTree(url)
+	if err != nil {
+		t.Fatal("sync error:", err)
+	}
+	if !reflect.DeepEqual(sortByID(stree.Nodes()), sortByID(nodes)) {
+		t.Errorf("wrong nodes in synced tree: have %d, want %d", len(stree.Nodes()), len(nodes))
+	}
+	if !reflect.DeepEqual(stree.Links(), []string{link}) {
+		t.Errorf("wrong links in synced tree: %v", stree.Links())
+	}
+	if stree.Signature() != tree.Signature() {
+		t.Errorf("wrong signature in synced tree")
+	}
+}
+
+//测试签名错误的树根会被拒绝。
+func TestClientSyncTreeBadSignature(t *testing.T) {
+	tree, _ := makeTestTree("n", testNodes(3), nil)
+	otherKey, _ := crypto.GenerateKey()
+	url := (&linkEntry{"n", &otherKey.PublicKey}).String()
+
+	c, _ := NewClient(Config{Resolver: newMapResolver(tree.ToTXT("n"))}, url)
+	if _, err := c.SyncTree(url); err == nil {
+		t.Fatal("expected error for tree signed by wrong key")
+	}
+}
+
+//测试内容与哈希不符的条目会被拒绝。
+func TestClientSyncTreeHashMismatch(t *testing.T) {
+	tree, url := makeTestTree("n", testNodes(3), nil)
+	records := tree.ToTXT("n")
+
+//用另一个有效节点替换其中一个节点记录
+	other := testNodes(1)[0]
+	for name, txt := range records {
+		if len(txt) > 4 && txt[:4] == "enr:" {
+			records[name] = NodeText(other)
+			break
+		}
+	}
+	c, _ := NewClient(Config{Resolver: newMapResolver(records)}, url)
+	if _, err := c.SyncTree(url); err == nil {
+		t.Fatal("expected error for tampered entry")
+	}
+}
+
+//测试后台循环跟随链接并提供来自所有树的节点。
+func TestClientFollowsLinks(t *testing.T) {
+	var (
+		nodes1   = testNodes(5)
+		nodes2   = testNodes(7)
+		t2, url2 = makeTestTree("t2", nodes2, nil)
+		t1, url1 = makeTestTree("t1", nodes1, []string{url2})
+		records  = make(map[string]string)
+	)
+	for name, txt := range t1.ToTXT("t1") {
+		records[name] = txt
+	}
+	for name, txt := range t2.ToTXT("t2") {
+		records[name] = txt
+	}
+	c, _ := NewClient(Config{Resolver: newMapResolver(records)}, url1)
+	c.refresh(context.Background())
+
+	buf := make([]*enode.Node, 20)
+	n := c.ReadRandomNodes(buf)
+	if n != len(nodes1)+len(nodes2) {
+		t.Fatalf("wrong number of nodes: have %d, want %d", n, len(nodes1)+len(nodes2))
+	}
+	want := append(append([]*enode.Node{}, nodes1...), nodes2...)
+	if !reflect.DeepEqual(sortByID(buf[:n]), sortByID(want)) {
+		t.Errorf("wrong nodes returned")
+	}
+}
+
+//测试树根未变化时不重新下载条目。
+func TestClientRefreshUnchanged(t *testing.T) {
+	tree, url := makeTestTree("n", testNodes(10), nil)
+	resolver := newMapResolver(tree.ToTXT("n"))
+
+	c, _ := NewClient(Config{Resolver: resolver, Timeout: time.Second}, url)
+	c.refresh(context.Background())
+	first := resolver.lookups
+
+	c.refresh(context.Background())
+	if extra := resolver.lookups - first; extra != 1 {
+		t.Errorf("wrong number of lookups on unchanged tree: have %d, want 1 (root only)", extra)
+	}
+}
+
+func makeTestTree(domain string, nodes []*enode.Node, links []string) (*Tree, string) {
+	tree, err := MakeTree(1, nodes, links)
+	if err != nil {
+		panic(err)
+	}
+	url, err := tree.Sign(testKey(signingKeySeed), domain)
+	if err != nil {
+		panic(err)
+	}
+	return tree, url
+}
+
+const signingKeySeed = 0x111111
+
+func testKey(seed int64) *ecdsa.PrivateKey {
+	key, err := crypto.HexToECDSA(fmt.Sprintf("%064x", seed))
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+func testNodes(n int) []*enode.Node {
+	nodes := make([]*enode.Node, n)
+	for i := range nodes {
+		key, _ := crypto.GenerateKey()
+		var r enr.Record
+		r.Set(enr.IP{127, 0, 0, 1})
+		r.Set(enr.TCP(30303))
+		r.SetSeq(uint64(i))
+		if err := enode.SignV4(&r, key); err != nil {
+			panic(err)
+		}
+		node, err := enode.New(enode.ValidSchemes, &r)
+		if err != nil {
+			panic(err)
+		}
+		nodes[i] = node
+	}
+	return nodes
+}
+
+//mapResolver是从映射中应答TXT查询的解析器。
+type mapResolver struct {
+	records map[string]string
+	lookups int
+}
+
+func newMapResolver(records map[string]string) *mapResolver {
+	return &mapResolver{records: records}
+}
+
+func (mr *mapResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	mr.lookups++
+	if record, ok := mr.records[name]; ok {
+		return []string{record}, nil
+	}
+	return nil, fmt.Errorf("no such name %q", name)
+}
@@ -43,6 +43,10 @@ dbItemPrefix = "n:"      //为节点条目加前缀的标识符
 	dbDiscoverFindFails = dbDiscoverRoot + ":findfail"
 	dbLocalRoot         = ":local"
 	dbLocalSeq          = dbLocalRoot + ":seq"
+	dbPeerRoot          = ":peer"
+	dbPeerScore         = dbPeerRoot + ":score"
+	dbPeerScoreTime     = dbPeerRoot + ":scoretime"
+	dbPeerBanned        = dbPeerRoot + ":banned"
 )
 
 var (
@@ -153,9 +157,13 @@ func (db *DB) fetchInt64(key []byte) int64 {
 
 //storeInt64在给定的键中存储一个整数。
 func (db *DB) storeInt64(key []byte, n int64) error {
+	return db.lvl.Put(key, encodeInt64(n), nil)
+}
+
+//encodeInt64将整数编码为存储在数据库中的形式。
+func encodeInt64(n int64) []byte {
 	blob := make([]byte, binary.MaxVarintLen64)
-	blob = blob[:binary.PutVarint(blob, n)]
-	return db.lvl.Put(key, blob, nil)
+	return blob[:binary.PutVarint(blob, n)]
 }
 
 //fetchuint64检索与特定键关联的整数。
@@ -322,6 +330,33 @@ func (db *DB) UpdateFindFails(id ID, fails int) error {
 	return db.storeInt64(makeKey(id, dbDiscoverFindFails), int64(fails))
 }
 
+//PeerScore检索节点作为对等端的信誉分数及其写入时间。
+func (db *DB) PeerScore(id ID) (int, time.Time) {
+	score := int(db.fetchInt64(makeKey(id, dbPeerScore)))
+	return score, time.Unix(db.fetchInt64(makeKey(id, dbPeerScoreTime)), 0)
+}
+
+//UpdatePeerScores在一个批次中更新多个节点作为对等端的信誉分数，
+//updated是这些分数的时间。
+func (db *DB) UpdatePeerScores(scores map[ID]int, updated time.Time) error {
+	batch := new(leveldb.Batch)
+	for id, score := range scores {
+		batch.Put(makeKey(id, dbPeerScore), encodeInt64(int64(score)))
+		batch.Put(makeKey(id, dbPeerScoreTime), encodeInt64(updated.Unix()))
+	}
+	return db.lvl.Write(batch, nil)
+}
+
+//BannedUntil检索节点被禁止连接的截止时间。
+func (db *DB) BannedUntil(id ID) time.Time {
+	return time.Unix(db.fetchInt64(makeKey(id, dbPeerBanned)), 0)
+}
+
+//UpdateBannedUntil更新节点被禁止连接的截止时间。
+func (db *DB) UpdateBannedUntil(id ID, instance time.Time) error {
+	return db.storeInt64(makeKey(id, dbPeerBanned), instance.Unix())
+}
+
 //localseq检索本地记录序列计数器。
 func (db *DB) localSeq(id ID) uint64 {
 	return db.fetchUint64(makeKey(id, dbLocalSeq))
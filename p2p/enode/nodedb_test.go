@@ -138,6 +138,26 @@ func TestDBFetchStore(t *testing.T) {
 	if stored := db.FindFails(node.ID()); stored != num {
 		t.Errorf("find-node fails: value mismatch: have %v, want %v", stored, num)
 	}
+//检查节点信誉分数对象上的获取/存储操作
+	if stored, updated := db.PeerScore(node.ID()); stored != 0 || updated.Unix() != 0 {
+		t.Errorf("peer score: non-existing object: %v at %v", stored, updated)
+	}
+	if err := db.UpdatePeerScores(map[ID]int{node.ID(): -num}, inst); err != nil {
+		t.Errorf("peer score: failed to update: %v", err)
+	}
+	if stored, updated := db.PeerScore(node.ID()); stored != -num || updated.Unix() != inst.Unix() {
+		t.Errorf("peer score: value mismatch: have %v at %v, want %v at %v", stored, updated, -num, inst)
+	}
+//检查节点禁止时间对象上的获取/存储操作
+	if stored := db.BannedUntil(node.ID()); stored.Unix() != 0 {
+		t.Errorf("ban: non-existing object: %v", stored)
+	}
+	if err := db.UpdateBannedUntil(node.ID(), inst); err != nil {
+		t.Errorf("ban: failed to update: %v", err)
+	}
+	if stored := db.BannedUntil(node.ID()); stored.Unix() != inst.Unix() {
+		t.Errorf("ban: value mismatch: have %v, want %v", stored, inst)
+	}
 //检查实际节点对象上的获取/存储操作
 	if stored := db.Node(node.ID()); stored != nil {
 		t.Errorf("node: non-existing object: %v", stored)
@@ -109,6 +109,12 @@ type Peer struct {
 
 //事件接收消息发送/接收事件（如果设置）
 	events *event.Feed
+
+//reputation记录Report报告的行为（如果设置）
+	reputation *reputation
+
+//capture将子协议消息记录到捕获文件中（如果设置）
+	capture *capture
 }
 
 //newpeer返回用于测试目的的对等机。
@@ -349,6 +355,9 @@ func (p *Peer) startProtocols(writeStart <-chan struct{}, writeErr chan<- error)
 		proto.wstart = writeStart
 		proto.werr = writeErr
 		var rw MsgReadWriter = proto
+		if p.capture != nil && p.capture.records(proto.Name) {
+			rw = newMsgRecorder(rw, p.capture, p.ID(), proto.Protocol)
+		}
 		if p.events != nil {
 			rw = newMsgEventer(rw, p.events, p.ID(), proto.Name)
 		}
@@ -431,6 +440,8 @@ RemoteAddress string `json:"remoteAddress"` //TCP数据连接的远程终结点
 		Inbound       bool   `json:"inbound"`
 		Trusted       bool   `json:"trusted"`
 		Static        bool   `json:"static"`
+Subnet        string `json:"subnet,omitempty"`      //远程地址所在的子网
+SubnetPeers   int    `json:"subnetPeers,omitempty"` //同一子网中已连接的对等端数量
 	} `json:"network"`
 Protocols map[string]interface{} `json:"protocols"` //子协议特定的元数据字段
 }
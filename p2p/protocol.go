@@ -52,6 +52,10 @@ type Protocol struct {
 
 //属性包含节点记录的协议特定信息。
 	Attributes []enr.Entry
+
+//DialFilter是一个可选的帮助器方法，用于在动态拨号之前检查发现的节点。
+//返回false的节点不会被拨号，静态节点不受影响。
+	DialFilter func(*enode.Node) bool
 }
 
 func (p Protocol) cap() Cap {
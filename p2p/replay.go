@@ -0,0 +1,225 @@
+
+//<developer>
+//    <name>linapex 曹一峰</name>
+//    <email>linapex@163.com</email>
+//    <wx>superexc</wx>
+//    <qqgroup>128148617</qqgroup>
+//    <url>https://jsq.ink</url>
+//    <role>pku engineer</role>
+//    <date>2019-03-16 19:16:41</date>
+//</624450103113682954>
+
+
+package p2p
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+//ReplayConfig配置捕获的重放。
+type ReplayConfig struct {
+//PrivateKey是模拟对等端的节点密钥。如果为空则生成随机密钥。
+	PrivateKey *ecdsa.PrivateKey
+
+//Name是在协议握手中宣告的客户端名称。
+	Name string
+
+//如果设置了Timing，则保持捕获中消息之间的原始时间间隔，否则尽快发送。
+	Timing bool
+
+//Linger是发送最后一条消息后等待节点响应的时间。
+	Linger time.Duration
+
+//如果设置了Output，则对发送和接收的每条消息按顺序调用它。记录的方向与
+//捕获相同：Inbound表示被重放的节点接收的消息。
+	Output func(*CaptureRecord)
+}
+
+//ReplayCapture通过fd扮演捕获中的远程对等端，与节点dest进行加密和协议握手，
+//然后按顺序将records中节点原来接收到的消息发送给它。节点原来发送的消息被跳过，
+//节点实际的响应通过cfg.Output报告。records应当只包含一个对等端的记录。
+//
+//如果节点在重放期间断开连接，则返回断开原因。
+func ReplayCapture(fd net.Conn, dest *enode.Node, records []*CaptureRecord, cfg ReplayConfig) error {
+	if len(records) == 0 {
+		return errors.New("empty capture")
+	}
+	key := cfg.PrivateKey
+	if key == nil {
+		var err error
+		if key, err = crypto.GenerateKey(); err != nil {
+			return err
+		}
+	}
+	t := newRLPX(fd)
+	if _, err := t.doEncHandshake(key, dest.Pubkey()); err != nil {
+		t.close(err)
+		return fmt.Errorf("encryption handshake failed: %v", err)
+	}
+//宣告捕获中出现的所有子协议
+	protos := replayProtocols(records)
+	pubkey := crypto.FromECDSAPub(&key.PublicKey)
+	our := &protoHandshake{Version: baseProtocolVersion, Name: cfg.Name, ID: pubkey[1:]}
+	for _, proto := range protos {
+		our.Caps = append(our.Caps, proto.cap())
+	}
+	their, err := t.doProtoHandshake(our)
+	if err != nil {
+		t.close(err)
+		return fmt.Errorf("protocol handshake failed: %v", err)
+	}
+	running := matchProtocols(protos, their.Caps, t)
+	if len(running) == 0 {
+		t.close(DiscUselessPeer)
+		return DiscUselessPeer
+	}
+	r := &replayer{t: t, running: running, cfg: cfg, peer: records[0].Peer, errc: make(chan error, 1)}
+	go r.readLoop()
+
+	err = r.send(records)
+	if err == nil {
+		select {
+		case err = <-r.errc:
+		case <-time.After(cfg.Linger):
+		}
+	}
+	if err != nil {
+		fd.Close()
+		return err
+	}
+	t.close(DiscQuitting)
+	return nil
+}
+
+//replayProtocols返回捕获中出现的子协议。
+func replayProtocols(records []*CaptureRecord) []Protocol {
+	seen := make(map[Cap]bool)
+	var protos []Protocol
+	for _, rec := range records {
+		cap := Cap{Name: rec.Protocol, Version: rec.Version}
+		if !seen[cap] {
+			seen[cap] = true
+			protos = append(protos, Protocol{Name: rec.Protocol, Version: rec.Version, Length: rec.Length})
+		}
+	}
+	sort.Slice(protos, func(i, j int) bool {
+		return protos[i].Name < protos[j].Name || (protos[i].Name == protos[j].Name && protos[i].Version < protos[j].Version)
+	})
+	return protos
+}
+
+//replayer在重放期间保存连接状态。
+type replayer struct {
+	t       transport
+running map[string]*protoRW //与节点协商的子协议及其代码偏移量
+	cfg     ReplayConfig
+peer    enode.ID   //捕获中被模拟的对等端
+errc    chan error //读取循环的终止错误
+
+outputMu sync.Mutex //保证Output按顺序调用
+}
+
+//send按顺序发送节点原来接收到的消息。
+func (r *replayer) send(records []*CaptureRecord) error {
+	var last uint64
+	for _, rec := range records {
+		if !rec.Inbound {
+			continue
+		}
+		proto, ok := r.running[rec.Protocol]
+		if !ok || proto.Version != rec.Version {
+			return fmt.Errorf("protocol %s/%d not supported by node", rec.Protocol, rec.Version)
+		}
+		if r.cfg.Timing && last != 0 && rec.Time > last {
+			select {
+			case err := <-r.errc:
+				return err
+			case <-time.After(time.Duration(rec.Time - last)):
+			}
+		}
+		last = rec.Time
+
+//在写入之前报告消息，使节点的响应总是排在请求之后
+		r.output(rec.Protocol, rec.Version, rec.Length, true, rec.Code, rec.Payload)
+		msg := Msg{Code: rec.Code + proto.offset, Size: uint32(len(rec.Payload)), Payload: bytes.NewReader(rec.Payload)}
+		if err := r.t.WriteMsg(msg); err != nil {
+			return err
+		}
+
+		select {
+		case err := <-r.errc:
+			return err
+		default:
+		}
+	}
+	return nil
+}
+
+//readLoop读取节点发送的消息，直到连接关闭，并在r.errc上报告终止原因。
+func (r *replayer) readLoop() {
+	for {
+		msg, err := r.t.ReadMsg()
+		if err != nil {
+			r.errc <- err
+			return
+		}
+		switch {
+		case msg.Code == pingMsg:
+			msg.Discard()
+			go SendItems(r.t, pongMsg)
+		case msg.Code == discMsg:
+			var reason [1]DiscReason
+			if err := msg.Decode(&reason); err != nil {
+				r.errc <- err
+				return
+			}
+			r.errc <- reason[0]
+			return
+		case msg.Code < baseProtocolLength:
+			msg.Discard()
+		default:
+			payload, err := ioutil.ReadAll(msg.Payload)
+			if err != nil {
+				r.errc <- err
+				return
+			}
+			for _, proto := range r.running {
+				if msg.Code >= proto.offset && msg.Code < proto.offset+proto.Length {
+					r.output(proto.Name, proto.Version, proto.Length, false, msg.Code-proto.offset, payload)
+					break
+				}
+			}
+		}
+	}
+}
+
+func (r *replayer) output(protocol string, version uint, length uint64, inbound bool, code uint64, payload []byte) {
+	if r.cfg.Output == nil {
+		return
+	}
+	r.outputMu.Lock()
+	defer r.outputMu.Unlock()
+
+	r.cfg.Output(&CaptureRecord{
+		Time:     uint64(time.Now().UnixNano()),
+		Peer:     r.peer,
+		Protocol: protocol,
+		Version:  version,
+		Length:   length,
+		Inbound:  inbound,
+		Code:     code,
+		Size:     uint32(len(payload)),
+		Payload:  payload,
+	})
+}
@@ -0,0 +1,196 @@
+
+//<developer>
+//    <name>linapex 曹一峰</name>
+//    <email>linapex@163.com</email>
+//    <wx>superexc</wx>
+//    <qqgroup>128148617</qqgroup>
+//    <url>https://jsq.ink</url>
+//    <role>pku engineer</role>
+//    <date>2019-03-16 19:16:41</date>
+//</624450103113682950>
+
+
+package p2p
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+//PeerBehaviour是协议观察到的对等端行为。服务器据此调整对等端的信誉分数，
+//分数保存在节点数据库中，因此在重启后仍然有效。
+type PeerBehaviour int
+
+const (
+PeerGoodResponse PeerBehaviour = iota //对等端及时提供了有用的数据
+PeerSlowResponse                      //对等端响应缓慢
+PeerTimeout                           //对等端的请求超时
+PeerUseless                           //对等端对本节点没有用处，例如在另一条链上
+PeerInvalidBlock                      //对等端发送了无效的区块
+)
+
+//peerBehaviourScores是每种行为对信誉分数的调整量。
+var peerBehaviourScores = [...]int{
+	PeerGoodResponse: 1,
+	PeerSlowResponse: -2,
+	PeerTimeout:      -10,
+	PeerUseless:      -25,
+	PeerInvalidBlock: -100,
+}
+
+var peerBehaviourNames = [...]string{
+	PeerGoodResponse: "good response",
+	PeerSlowResponse: "slow response",
+	PeerTimeout:      "timeout",
+	PeerUseless:      "useless peer",
+	PeerInvalidBlock: "invalid block",
+}
+
+const (
+maxPeerScore           = 100              //信誉分数的上限，避免老节点积累过多信用
+minPeerScore           = -200             //信誉分数的下限
+peerBanScore           = -100             //分数降到此值时对等端被禁止
+peerBanDuration        = 30 * time.Minute //禁止的持续时间
+peerScoreHalfLife      = time.Hour        //分数向零衰减的半衰期，过去的行为逐渐被遗忘
+peerScoreFlushInterval = 30 * time.Second //报告的分数最多每隔这么久批量写入数据库一次
+)
+
+func (b PeerBehaviour) String() string {
+	if b < 0 || int(b) >= len(peerBehaviourNames) {
+		return fmt.Sprintf("unknown behaviour %d", int(b))
+	}
+	return peerBehaviourNames[b]
+}
+
+//Report报告对等端的行为。如果对等端的分数降到禁止阈值，它会被断开，
+//并且在一段时间内既不会被拨号也不会被接受。可信对等端不会被禁止。
+//可以从任何goroutine调用。
+func (p *Peer) Report(b PeerBehaviour) {
+	if p.reputation == nil {
+		return
+	}
+	if p.reputation.report(p.ID(), b, p.rw.is(trustedConn)) {
+		p.log.Debug("Banning peer", "behaviour", b, "duration", peerBanDuration)
+		p.Disconnect(DiscUselessPeer)
+	}
+}
+
+//reputation在节点数据库中维护对等端的信誉分数。报告的分数先保存在内存中，
+//按peerScoreFlushInterval批量写入数据库，避免每个报告都写一次LevelDB。
+type reputation struct {
+	mu        sync.Mutex
+	db        *enode.DB
+scores    map[enode.ID]*peerScore //尚未写入数据库的分数
+lastFlush time.Time               //上次写入数据库的时间
+}
+
+//peerScore是内存中的信誉分数。
+type peerScore struct {
+value   float64   //updated时的分数
+updated time.Time //分数的时间，之后分数随时间衰减
+}
+
+//newReputation创建在db中保存分数的信誉记录。
+func newReputation(db *enode.DB) *reputation {
+	return &reputation{
+		db:        db,
+		scores:    make(map[enode.ID]*peerScore),
+		lastFlush: time.Now(),
+	}
+}
+
+//decayScore返回经过elapsed之后的分数。分数以peerScoreHalfLife为半衰期向零衰减，
+//因此好的和坏的行为都会随时间被淡忘。
+func decayScore(score float64, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return score
+	}
+	return score * math.Pow(0.5, float64(elapsed)/float64(peerScoreHalfLife))
+}
+
+//report按行为调整节点的分数。如果节点因此被禁止则返回true。
+func (r *reputation) report(id enode.ID, b PeerBehaviour, trusted bool) bool {
+	return r.reportAt(id, b, trusted, time.Now())
+}
+
+func (r *reputation) reportAt(id enode.ID, b PeerBehaviour, trusted bool, now time.Time) bool {
+	if b < 0 || int(b) >= len(peerBehaviourScores) {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	score := r.current(id, now) + float64(peerBehaviourScores[b])
+	if score > maxPeerScore {
+		score = maxPeerScore
+	}
+	if score < minPeerScore {
+		score = minPeerScore
+	}
+	banned := score <= peerBanScore && !trusted
+	if banned {
+//禁止期满后节点从较低的分数重新开始，使其拨号优先级仍然较低。
+//禁止很少发生，立即写入数据库。
+		r.db.UpdateBannedUntil(id, now.Add(peerBanDuration))
+		score = peerBanScore / 2
+	}
+	r.scores[id] = &peerScore{value: score, updated: now}
+	if now.Sub(r.lastFlush) >= peerScoreFlushInterval {
+		r.flushLocked(now)
+	}
+	return banned
+}
+
+//current返回节点在now时衰减后的分数。
+//
+//注意，此方法假定r.mu被保持！
+func (r *reputation) current(id enode.ID, now time.Time) float64 {
+	if s, ok := r.scores[id]; ok {
+		return decayScore(s.value, now.Sub(s.updated))
+	}
+	score, updated := r.db.PeerScore(id)
+	return decayScore(float64(score), now.Sub(updated))
+}
+
+//flush将内存中的分数写入数据库。
+func (r *reputation) flush() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.flushLocked(time.Now())
+}
+
+func (r *reputation) flushLocked(now time.Time) {
+	r.lastFlush = now
+	if len(r.scores) == 0 {
+		return
+	}
+	scores := make(map[enode.ID]int, len(r.scores))
+	for id, s := range r.scores {
+		scores[id] = int(math.Round(decayScore(s.value, now.Sub(s.updated))))
+	}
+	if err := r.db.UpdatePeerScores(scores, now); err != nil {
+		log.Warn("Failed to store peer scores", "err", err)
+		return
+	}
+	r.scores = make(map[enode.ID]*peerScore)
+}
+
+//banned报告节点当前是否被禁止。
+func (r *reputation) banned(id enode.ID, now time.Time) bool {
+	until := r.db.BannedUntil(id)
+	return until.Unix() != 0 && until.After(now)
+}
+
+//score返回节点在now时的信誉分数。
+func (r *reputation) score(id enode.ID, now time.Time) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return int(math.Round(r.current(id, now)))
+}
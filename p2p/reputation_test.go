@@ -0,0 +1,136 @@
+
+//<developer>
+//    <name>linapex 曹一峰</name>
+//    <email>linapex@163.com</email>
+//    <wx>superexc</wx>
+//    <qqgroup>128148617</qqgroup>
+//    <url>https://jsq.ink</url>
+//    <role>pku engineer</role>
+//    <date>2019-03-16 19:16:41</date>
+//</624450103113682951>
+
+
+package p2p
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+func TestReputationBan(t *testing.T) {
+	db, _ := enode.OpenDB("")
+	defer db.Close()
+	r := newReputation(db)
+	id := uintID(1)
+	now := time.Now()
+
+//分数有上限。
+	for i := 0; i < 2*maxPeerScore; i++ {
+		r.reportAt(id, PeerGoodResponse, false, now)
+	}
+	if s := r.score(id, now); s != maxPeerScore {
+		t.Fatalf("score not capped: got %d, want %d", s, maxPeerScore)
+	}
+//超时会降低分数，但不会立即导致禁止。
+	for i := 0; i < 5; i++ {
+		if r.reportAt(id, PeerTimeout, false, now) {
+			t.Fatalf("banned after %d timeouts", i+1)
+		}
+	}
+	if s := r.score(id, now); s != maxPeerScore-50 {
+		t.Fatalf("wrong score after timeouts: got %d, want %d", s, maxPeerScore-50)
+	}
+//无效区块使分数降到禁止阈值。
+	r.reportAt(id, PeerInvalidBlock, false, now)
+	if !r.reportAt(id, PeerInvalidBlock, false, now) {
+		t.Fatal("peer not banned after invalid blocks")
+	}
+	if !r.banned(id, now) {
+		t.Fatal("ban not recorded")
+	}
+	if r.banned(id, now.Add(peerBanDuration+time.Second)) {
+		t.Fatal("ban doesn't expire")
+	}
+	if s := r.score(id, now); s != peerBanScore/2 {
+		t.Fatalf("wrong score after ban: got %d, want %d", s, peerBanScore/2)
+	}
+}
+
+func TestReputationTrusted(t *testing.T) {
+	db, _ := enode.OpenDB("")
+	defer db.Close()
+	r := newReputation(db)
+	id := uintID(1)
+	now := time.Now()
+
+	for i := 0; i < 5; i++ {
+		if r.reportAt(id, PeerInvalidBlock, true, now) {
+			t.Fatal("trusted peer banned")
+		}
+	}
+	if r.banned(id, now) {
+		t.Fatal("ban recorded for trusted peer")
+	}
+	if s := r.score(id, now); s != minPeerScore {
+		t.Fatalf("score not capped: got %d, want %d", s, minPeerScore)
+	}
+}
+
+//测试分数随时间以半衰期向零衰减。
+func TestReputationDecay(t *testing.T) {
+	db, _ := enode.OpenDB("")
+	defer db.Close()
+	r := newReputation(db)
+	good, bad := uintID(1), uintID(2)
+	now := time.Now()
+
+	for i := 0; i < maxPeerScore; i++ {
+		r.reportAt(good, PeerGoodResponse, false, now)
+	}
+	for i := 0; i < 8; i++ {
+		r.reportAt(bad, PeerTimeout, false, now)
+	}
+	if s := r.score(good, now.Add(peerScoreHalfLife)); s != maxPeerScore/2 {
+		t.Errorf("good score after one half-life: got %d, want %d", s, maxPeerScore/2)
+	}
+	if s := r.score(bad, now.Add(2*peerScoreHalfLife)); s != -20 {
+		t.Errorf("bad score after two half-lives: got %d, want %d", s, -20)
+	}
+//衰减之后的报告从衰减后的分数开始计算
+	r.reportAt(bad, PeerTimeout, false, now.Add(peerScoreHalfLife))
+	if s := r.score(bad, now.Add(peerScoreHalfLife)); s != -50 {
+		t.Errorf("bad score after decayed report: got %d, want %d", s, -50)
+	}
+}
+
+//测试分数只按写入间隔批量写入数据库，衰减在重新加载后继续。
+func TestReputationFlush(t *testing.T) {
+	db, _ := enode.OpenDB("")
+	defer db.Close()
+	r := newReputation(db)
+	id := uintID(1)
+	now := r.lastFlush
+
+	for i := 0; i < 10; i++ {
+		r.reportAt(id, PeerTimeout, false, now)
+	}
+	if s, _ := db.PeerScore(id); s != 0 {
+		t.Fatalf("score written before flush interval: %d", s)
+	}
+	r.reportAt(id, PeerGoodResponse, false, now.Add(peerScoreFlushInterval))
+	if s, _ := db.PeerScore(id); s >= 0 {
+		t.Fatalf("score not written after flush interval: %d", s)
+	}
+	if len(r.scores) != 0 {
+		t.Fatalf("flushed scores still cached: %d", len(r.scores))
+	}
+//新的实例从数据库加载分数，并从写入时间开始衰减
+	stored, updated := db.PeerScore(id)
+	r = newReputation(db)
+	if s := r.score(id, updated.Add(peerScoreHalfLife)); s != int(math.Round(float64(stored)/2)) {
+		t.Errorf("reloaded score mismatch: got %d, want %d", s, int(math.Round(float64(stored)/2)))
+	}
+}
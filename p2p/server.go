@@ -31,6 +31,7 @@ import (
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/p2p/discover"
 	"github.com/ethereum/go-ethereum/p2p/discv5"
+	"github.com/ethereum/go-ethereum/p2p/dnsdisc"
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethereum/go-ethereum/p2p/enr"
 	"github.com/ethereum/go-ethereum/p2p/nat"
@@ -46,6 +47,10 @@ const (
 	defaultMaxPendingPeers = 50
 	defaultDialRatio       = 3
 
+//每个子网的入站对等端限制的默认值。IPv6地址总是按/48划分子网。
+	defaultInboundSubnetBits = 24
+	inboundSubnetBitsIPv6     = 48
+
 //读取完整邮件所允许的最长时间。
 //这实际上是连接可以空闲的时间量。
 	frameReadTimeout = 30 * time.Second
@@ -75,6 +80,18 @@ type Config struct {
 //将DialRatio设置为零将默认为3。
 	DialRatio int `toml:",omitempty"`
 
+//MinOutboundPeers是为拨出连接保留的最小连接数。如果按DialRatio计算的
+//拨出连接数小于此值，则使用此值，入站连接不能占用这些位置。
+	MinOutboundPeers int `toml:",omitempty"`
+
+//InboundSubnetLimit是来自同一IP子网的入站对等端的最大数量，用于防止
+//单个托管商的地址范围占满所有入站连接。零或负值表示不限制（默认）。
+//局域网地址和可信节点不受此限制。
+	InboundSubnetLimit int `toml:",omitempty"`
+
+//InboundSubnetBits是用于划分IPv4子网的前缀位数。零默认为24。
+	InboundSubnetBits uint `toml:",omitempty"`
+
 //nodiscovery可用于禁用对等发现机制。
 //禁用对于协议调试（手动拓扑）很有用。
 	NoDiscovery bool
@@ -83,6 +100,15 @@ type Config struct {
 //是否启动协议。
 	DiscoveryV5 bool `toml:",omitempty"`
 
+//DiscoveryV51指定是否启动v5.1发现协议。它与v4共用同一个UDP端口，
+//交换节点记录，因此可以按记录中宣告的能力查找节点。它的线路格式与
+//DiscoveryV5的主题发现不兼容，因此使用单独的版本和引导节点。
+	DiscoveryV51 bool `toml:",omitempty"`
+
+//DiscoveryDNS是EIP-1459节点树的enrtree链接列表。从这些树中解析的
+//节点用作动态拨号候选，即使UDP发现被禁用也可以使用。
+	DiscoveryDNS []string `toml:",omitempty"`
+
 //名称设置此服务器的节点名称。
 //使用common.makename创建遵循现有约定的名称。
 	Name string `toml:"-"`
@@ -96,6 +122,10 @@ type Config struct {
 //协议。
 	BootstrapNodesV5 []*discv5.Node `toml:",omitempty"`
 
+//BootstrapNodesV51用于建立v5.1发现的连接。为空时使用BootstrapNodes，
+//运行v5.1的节点在与v4相同的端口上应答。
+	BootstrapNodesV51 []*enode.Node `toml:",omitempty"`
+
 //静态节点用作预先配置的连接，这些连接总是
 //在断开时保持并重新连接。
 	StaticNodes []*enode.Node
@@ -142,6 +172,13 @@ type Config struct {
 //无论何时向对等端发送或从对等端接收消息
 	EnableMsgEvents bool
 
+//如果设置了CaptureFile，服务器将与对等端交换的子协议消息（包括完整的有效负载）
+//追加到该文件，可以用p2pcapture工具查看和重放。
+	CaptureFile string `toml:",omitempty"`
+
+//CaptureProtocols限制记录哪些子协议的消息。为空表示记录所有子协议。
+	CaptureProtocols []string `toml:",omitempty"`
+
 //logger是用于p2p.server的自定义记录器。
 	Logger log.Logger `toml:",omitempty"`
 }
@@ -160,12 +197,17 @@ lock    sync.Mutex //保护运行
 	running bool
 
 	nodedb       *enode.DB
+	reputation   *reputation
+	capture      *capture
 	localnode    *enode.LocalNode
 	ntab         discoverTable
 	listener     net.Listener
 	ourHandshake *protoHandshake
 	lastLookup   time.Time
 	DiscV5       *discv5.Network
+	DiscV51      *discover.UDPv5
+	dnsdisc      *dnsdisc.Client
+discConn     *net.UDPConn //只有v5和v5.1共用套接字而v4被禁用时由服务器读取和关闭
 
 //这些是为对等机，对等机计数（而不是其他任何东西）。
 	peerOp     chan peerOpFunc
@@ -357,6 +399,14 @@ func (srv *Server) Self() *enode.Node {
 	return ln.Node()
 }
 
+//LocalNode返回本地节点记录，子协议可以用它更新自己的条目。
+func (srv *Server) LocalNode() *enode.LocalNode {
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+
+	return srv.localnode
+}
+
 //stop终止服务器和所有活动的对等连接。
 //它会一直阻塞，直到关闭所有活动连接。
 func (srv *Server) Stop() {
@@ -382,7 +432,7 @@ type sharedUDPConn struct {
 	unhandled chan discover.ReadPacket
 }
 
-//readfromudp实现discv5.conn
+//readfromudp实现discover.conn
 func (s *sharedUDPConn) ReadFromUDP(b []byte) (n int, addr *net.UDPAddr, err error) {
 	packet, ok := <-s.unhandled
 	if !ok {
@@ -396,7 +446,7 @@ func (s *sharedUDPConn) ReadFromUDP(b []byte) (n int, addr *net.UDPAddr, err err
 	return l, packet.Addr, nil
 }
 
-//关闭机具discv5.conn
+//关闭机具discover.conn
 func (s *sharedUDPConn) Close() error {
 	return nil
 }
@@ -450,14 +500,46 @@ func (srv *Server) Start() (err error) {
 	if err := srv.setupDiscovery(); err != nil {
 		return err
 	}
+	if err := srv.setupDNSDiscovery(); err != nil {
+		return err
+	}
+	if err := srv.setupCapture(); err != nil {
+		return err
+	}
 
 	dynPeers := srv.maxDialedConns()
 	dialer := newDialState(srv.localnode.ID(), srv.StaticNodes, srv.BootstrapNodes, srv.ntab, dynPeers, srv.NetRestrict)
+	dialer.filter = srv.dialFilter()
+	dialer.reputation = srv.reputation
+	if srv.dnsdisc != nil {
+		dialer.dns = srv.dnsdisc
+	}
 	srv.loopWG.Add(1)
 	go srv.run(dialer)
 	return nil
 }
 
+//dialFilter组合所有子协议的拨号过滤器，任何一个过滤器拒绝的节点都不会被动态拨号。
+func (srv *Server) dialFilter() func(*enode.Node) bool {
+	var filters []func(*enode.Node) bool
+	for _, p := range srv.Protocols {
+		if p.DialFilter != nil {
+			filters = append(filters, p.DialFilter)
+		}
+	}
+	if len(filters) == 0 {
+		return nil
+	}
+	return func(n *enode.Node) bool {
+		for _, filter := range filters {
+			if !filter(n) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
 func (srv *Server) setupLocalNode() error {
 //创建devp2p握手。
 	pubkey := crypto.FromECDSAPub(&srv.PrivateKey.PublicKey)
@@ -473,6 +555,7 @@ func (srv *Server) setupLocalNode() error {
 		return err
 	}
 	srv.nodedb = db
+	srv.reputation = newReputation(db)
 	srv.localnode = enode.NewLocalNode(db, srv.PrivateKey)
 	srv.localnode.SetFallbackIP(net.IP{127, 0, 0, 1})
 	srv.localnode.Set(capsByNameAndVersion(srv.ourHandshake.Caps))
@@ -504,7 +587,7 @@ func (srv *Server) setupLocalNode() error {
 }
 
 func (srv *Server) setupDiscovery() error {
-	if srv.NoDiscovery && !srv.DiscoveryV5 {
+	if srv.NoDiscovery && !srv.DiscoveryV5 && !srv.DiscoveryV51 {
 		return nil
 	}
 
@@ -527,11 +610,9 @@ func (srv *Server) setupDiscovery() error {
 
 //发现V4
 	var unhandled chan discover.ReadPacket
-	var sconn *sharedUDPConn
 	if !srv.NoDiscovery {
-		if srv.DiscoveryV5 {
+		if srv.DiscoveryV5 || srv.DiscoveryV51 {
 			unhandled = make(chan discover.ReadPacket, 100)
-			sconn = &sharedUDPConn{conn, unhandled}
 		}
 		cfg := discover.Config{
 			PrivateKey:  srv.PrivateKey,
@@ -544,16 +625,16 @@ func (srv *Server) setupDiscovery() error {
 			return err
 		}
 		srv.ntab = ntab
+	} else if srv.DiscoveryV5 && srv.DiscoveryV51 {
+		unhandled = make(chan discover.ReadPacket, 100)
+		srv.discConn = conn
+		go readDiscoveryPackets(conn, unhandled)
 	}
+	topicConn, v51Conn := splitDiscoveryV5(conn, unhandled, srv.DiscoveryV5, srv.DiscoveryV51)
+
 //发现V5
 	if srv.DiscoveryV5 {
-		var ntab *discv5.Network
-		var err error
-		if sconn != nil {
-			ntab, err = discv5.ListenUDP(srv.PrivateKey, sconn, "", srv.NetRestrict)
-		} else {
-			ntab, err = discv5.ListenUDP(srv.PrivateKey, conn, "", srv.NetRestrict)
-		}
+		ntab, err := discv5.ListenUDP(srv.PrivateKey, topicConn, "", srv.NetRestrict)
 		if err != nil {
 			return err
 		}
@@ -562,6 +643,102 @@ func (srv *Server) setupDiscovery() error {
 		}
 		srv.DiscV5 = ntab
 	}
+//发现V5.1
+	if srv.DiscoveryV51 {
+		bootnodes := srv.BootstrapNodesV51
+		if len(bootnodes) == 0 {
+			bootnodes = srv.BootstrapNodes
+		}
+		cfg := discover.Config{
+			PrivateKey:  srv.PrivateKey,
+			NetRestrict: srv.NetRestrict,
+			Bootnodes:   bootnodes,
+		}
+		ntab, err := discover.ListenV5(v51Conn, srv.localnode, cfg)
+		if err != nil {
+			return err
+		}
+		srv.DiscV51 = ntab
+//如果v4被禁用，则由v5.1提供拨号候选。
+		if srv.ntab == nil {
+			srv.ntab = ntab
+		}
+	}
+	return nil
+}
+
+//udpConn是发现协议读写数据包所用的套接字。
+type udpConn interface {
+	ReadFromUDP(b []byte) (n int, addr *net.UDPAddr, err error)
+	WriteToUDP(b []byte, addr *net.UDPAddr) (n int, err error)
+	Close() error
+	LocalAddr() net.Addr
+}
+
+//splitDiscoveryV5返回v5主题发现和v5.1发现使用的套接字。unhandled为nil时
+//唯一运行的协议直接读取conn，否则从unhandled接收数据包。两者都运行时
+//按线路格式分发数据包：主题发现的数据包以固定的版本前缀开头。
+func splitDiscoveryV5(conn *net.UDPConn, unhandled chan discover.ReadPacket, topic, v51 bool) (udpConn, udpConn) {
+	if unhandled == nil {
+		return conn, conn
+	}
+	if !topic || !v51 {
+		shared := &sharedUDPConn{conn, unhandled}
+		return shared, shared
+	}
+	var (
+		topicConn = &sharedUDPConn{conn, make(chan discover.ReadPacket, 100)}
+		v51Conn   = &sharedUDPConn{conn, make(chan discover.ReadPacket, 100)}
+	)
+	go func() {
+		defer close(topicConn.unhandled)
+		defer close(v51Conn.unhandled)
+
+		for packet := range unhandled {
+			target := v51Conn.unhandled
+			if discv5.IsPacket(packet.Data) {
+				target = topicConn.unhandled
+			}
+			select {
+			case target <- packet:
+			default:
+			}
+		}
+	}()
+	return topicConn, v51Conn
+}
+
+//readDiscoveryPackets在v4被禁用时代替v4读取conn，把数据包发送到unhandled，
+//直到套接字被关闭。
+func readDiscoveryPackets(conn *net.UDPConn, unhandled chan<- discover.ReadPacket) {
+	defer close(unhandled)
+
+	buf := make([]byte, 1280)
+	for {
+		nbytes, from, err := conn.ReadFromUDP(buf)
+		if netutil.IsTemporaryError(err) {
+			continue
+		} else if err != nil {
+			return
+		}
+		select {
+		case unhandled <- discover.ReadPacket{Data: common.CopyBytes(buf[:nbytes]), Addr: from}:
+		default:
+		}
+	}
+}
+
+//setupDNSDiscovery为配置的节点树创建DNS发现客户端。
+func (srv *Server) setupDNSDiscovery() error {
+	if len(srv.DiscoveryDNS) == 0 {
+		return nil
+	}
+	client, err := dnsdisc.NewClient(dnsdisc.Config{Logger: srv.log}, srv.DiscoveryDNS...)
+	if err != nil {
+		return err
+	}
+	client.Start()
+	srv.dnsdisc = client
 	return nil
 }
 
@@ -725,6 +902,8 @@ running:
 				if srv.EnableMsgEvents {
 					p.events = &srv.peerFeed
 				}
+				p.reputation = srv.reputation
+				p.capture = srv.capture
 				name := truncateName(c.name)
 				srv.log.Debug("Adding p2p peer", "name", name, "addr", c.fd.RemoteAddr(), "peers", len(peers)+1)
 				go srv.runPeer(p)
@@ -755,12 +934,21 @@ running:
 	srv.log.Trace("P2P networking is spinning down")
 
 //终止发现。如果有正在运行的查找，它将很快终止。
+	if srv.discConn != nil {
+		srv.discConn.Close()
+	}
 	if srv.ntab != nil {
 		srv.ntab.Close()
 	}
 	if srv.DiscV5 != nil {
 		srv.DiscV5.Close()
 	}
+	if srv.DiscV51 != nil {
+		srv.DiscV51.Close()
+	}
+	if srv.dnsdisc != nil {
+		srv.dnsdisc.Close()
+	}
 //断开所有对等机的连接。
 	for _, p := range peers {
 		p.Disconnect(DiscQuitting)
@@ -773,11 +961,22 @@ running:
 		p.log.Trace("<-delpeer (spindown)", "remainingTasks", len(runningTasks))
 		delete(peers, p.ID())
 	}
+//所有对等机的协议goroutine都已退出，现在可以关闭捕获文件。
+	if srv.capture != nil {
+		srv.capture.close()
+	}
+//在关闭节点数据库之前写入内存中的信誉分数。
+	if srv.reputation != nil {
+		srv.reputation.flush()
+	}
 }
 
 func (srv *Server) protoHandshakeChecks(peers map[enode.ID]*Peer, inboundCount int, c *conn) error {
 //删除没有匹配协议的连接。
 	if len(srv.Protocols) > 0 && countMatchingProtocols(srv.Protocols, c.caps) == 0 {
+		if srv.reputation != nil {
+			srv.reputation.report(c.node.ID(), PeerUseless, c.is(trustedConn))
+		}
 		return DiscUselessPeer
 	}
 //重复加密握手检查，因为
@@ -791,10 +990,14 @@ func (srv *Server) encHandshakeChecks(peers map[enode.ID]*Peer, inboundCount int
 		return DiscTooManyPeers
 	case !c.is(trustedConn) && c.is(inboundConn) && inboundCount >= srv.maxInboundConns():
 		return DiscTooManyPeers
+	case !c.is(trustedConn) && c.is(inboundConn) && srv.inboundSubnetFull(peers, c):
+		return DiscTooManyPeers
 	case peers[c.node.ID()] != nil:
 		return DiscAlreadyConnected
 	case c.node.ID() == srv.localnode.ID():
 		return DiscSelf
+	case !c.is(trustedConn|staticDialedConn) && srv.reputation != nil && srv.reputation.banned(c.node.ID(), time.Now()):
+		return DiscUselessPeer
 	default:
 		return nil
 	}
@@ -804,14 +1007,66 @@ func (srv *Server) maxInboundConns() int {
 	return srv.MaxPeers - srv.maxDialedConns()
 }
 func (srv *Server) maxDialedConns() int {
-	if srv.NoDiscovery || srv.NoDial {
+	if (srv.NoDiscovery && !srv.DiscoveryV51 && len(srv.DiscoveryDNS) == 0) || srv.NoDial {
 		return 0
 	}
 	r := srv.DialRatio
 	if r == 0 {
 		r = defaultDialRatio
 	}
-	return srv.MaxPeers / r
+	n := srv.MaxPeers / r
+	if n < srv.MinOutboundPeers {
+		n = srv.MinOutboundPeers
+	}
+	if n > srv.MaxPeers {
+		n = srv.MaxPeers
+	}
+	return n
+}
+
+//inboundSubnetFull报告来自c所在子网的入站对等端是否已达到上限。
+func (srv *Server) inboundSubnetFull(peers map[enode.ID]*Peer, c *conn) bool {
+	limit := srv.InboundSubnetLimit
+	if limit <= 0 {
+		return false
+	}
+	subnet := srv.subnetOf(c.fd.RemoteAddr())
+	if subnet == nil || netutil.IsLAN(subnet.IP) {
+		return false
+	}
+	count := 0
+	for _, p := range peers {
+		if !p.rw.is(inboundConn) || p.rw.is(trustedConn) {
+			continue
+		}
+		if s := srv.subnetOf(p.RemoteAddr()); s != nil && s.IP.Equal(subnet.IP) {
+			count++
+		}
+	}
+	return count >= limit
+}
+
+//subnetOf返回远程地址所在的子网。如果地址不是TCP地址则返回nil。
+func (srv *Server) subnetOf(addr net.Addr) *net.IPNet {
+	tcp, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return nil
+	}
+	ip, bits, ones := tcp.IP.To4(), 32, int(srv.InboundSubnetBits)
+	if ones == 0 {
+		ones = defaultInboundSubnetBits
+	}
+	if ip == nil {
+		ip, bits, ones = tcp.IP.To16(), 128, inboundSubnetBitsIPv6
+	}
+	if ip == nil {
+		return nil
+	}
+	if ones > bits {
+		ones = bits
+	}
+	mask := net.CIDRMask(ones, bits)
+	return &net.IPNet{IP: ip.Mask(mask), Mask: mask}
 }
 
 //listenloop在自己的goroutine中运行并接受
@@ -1059,9 +1314,24 @@ func (srv *Server) NodeInfo() *NodeInfo {
 func (srv *Server) PeersInfo() []*PeerInfo {
 //收集所有通用和子协议特定的信息
 	infos := make([]*PeerInfo, 0, srv.PeerCount())
-	for _, peer := range srv.Peers() {
+	peers := srv.Peers()
+	subnets := make(map[string]int)
+	for _, peer := range peers {
+		if peer == nil {
+			continue
+		}
+		if subnet := srv.subnetOf(peer.RemoteAddr()); subnet != nil {
+			subnets[subnet.String()]++
+		}
+	}
+	for _, peer := range peers {
 		if peer != nil {
-			infos = append(infos, peer.Info())
+			info := peer.Info()
+			if subnet := srv.subnetOf(peer.RemoteAddr()); subnet != nil {
+				info.Network.Subnet = subnet.String()
+				info.Network.SubnetPeers = subnets[subnet.String()]
+			}
+			infos = append(infos, info)
 		}
 	}
 //按节点标识符的字母顺序对结果数组排序
@@ -23,6 +23,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/discover"
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethereum/go-ethereum/p2p/enr"
 	"golang.org/x/crypto/sha3"
@@ -613,3 +614,118 @@ func randomID() (id enode.ID) {
 	return id
 }
 
+func TestServerInboundSubnetLimit(t *testing.T) {
+	srv := &Server{Config: Config{MaxPeers: 10, InboundSubnetLimit: 2}}
+	newConn := func(ip string, flags connFlag) *conn {
+		fd, _ := net.Pipe()
+		addr := &net.TCPAddr{IP: net.ParseIP(ip), Port: 30303}
+		return &conn{fd: addrConn{fd, addr}, flags: flags}
+	}
+	peers := map[enode.ID]*Peer{
+		uintID(1): {rw: newConn("1.2.3.4", inboundConn)},
+		uintID(2): {rw: newConn("1.2.3.5", inboundConn)},
+		uintID(3): {rw: newConn("1.2.4.4", inboundConn)},
+		uintID(4): {rw: newConn("1.2.4.5", dynDialedConn)},
+		uintID(5): {rw: newConn("1.2.4.6", inboundConn|trustedConn)},
+	}
+	tests := []struct {
+		ip   string
+		full bool
+	}{
+		{"1.2.3.6", true},
+		{"1.2.4.7", false},  //拨出和可信连接不计入
+		{"1.2.5.1", false},  //其他子网
+		{"10.0.0.1", false}, //局域网地址
+	}
+	for _, test := range tests {
+		if full := srv.inboundSubnetFull(peers, newConn(test.ip, inboundConn)); full != test.full {
+			t.Errorf("%s: got full %t, want %t", test.ip, full, test.full)
+		}
+	}
+
+//较小的前缀将两个子网合并在一起。
+	srv.InboundSubnetBits = 16
+	if !srv.inboundSubnetFull(peers, newConn("1.2.5.1", inboundConn)) {
+		t.Error("1.2.5.1: subnet not full with /16 prefix")
+	}
+//零（默认）和负值禁用限制。
+	for _, limit := range []int{0, -1} {
+		srv.InboundSubnetLimit = limit
+		if srv.inboundSubnetFull(peers, newConn("1.2.3.6", inboundConn)) {
+			t.Errorf("limit %d: limit not disabled", limit)
+		}
+	}
+}
+
+func TestServerMinOutboundPeers(t *testing.T) {
+	tests := []struct {
+		maxPeers, minOutbound, want int
+	}{
+		{maxPeers: 25, minOutbound: 0, want: 8},
+		{maxPeers: 25, minOutbound: 12, want: 12},
+		{maxPeers: 25, minOutbound: 4, want: 8},
+		{maxPeers: 10, minOutbound: 20, want: 10},
+	}
+	for _, test := range tests {
+		srv := &Server{Config: Config{MaxPeers: test.maxPeers, MinOutboundPeers: test.minOutbound}}
+		if n := srv.maxDialedConns(); n != test.want {
+			t.Errorf("MaxPeers %d, MinOutboundPeers %d: got %d dialed conns, want %d", test.maxPeers, test.minOutbound, n, test.want)
+		}
+		if n := srv.maxInboundConns(); n != test.maxPeers-test.want {
+			t.Errorf("MaxPeers %d, MinOutboundPeers %d: got %d inbound conns, want %d", test.maxPeers, test.minOutbound, n, test.maxPeers-test.want)
+		}
+	}
+}
+
+//测试v5主题发现和v5.1发现可以在同一个UDP端口上同时运行，包括v4被禁用时。
+func TestServerDiscoveryV5Versions(t *testing.T) {
+	for _, noV4 := range []bool{false, true} {
+		srv := &Server{Config: Config{
+			PrivateKey:   newkey(),
+			MaxPeers:     10,
+			ListenAddr:   "127.0.0.1:0",
+			NoDiscovery:  noV4,
+			DiscoveryV5:  true,
+			DiscoveryV51: true,
+		}}
+		if err := srv.Start(); err != nil {
+			t.Fatalf("v4 disabled %v: could not start: %v", noV4, err)
+		}
+		if srv.DiscV5 == nil || srv.DiscV51 == nil {
+			t.Errorf("v4 disabled %v: v5 protocols not running", noV4)
+		}
+		if noV4 && srv.ntab != srv.DiscV51 {
+			t.Errorf("v4 disabled: v5.1 does not provide dial candidates")
+		}
+		srv.Stop()
+	}
+}
+
+//测试共用套接字时按线路格式分发v5主题发现和v5.1的数据包。
+func TestSplitDiscoveryV5(t *testing.T) {
+	unhandled := make(chan discover.ReadPacket, 2)
+	topicConn, v51Conn := splitDiscoveryV5(nil, unhandled, true, true)
+
+	unhandled <- discover.ReadPacket{Data: []byte("v5.1 packet")}
+	unhandled <- discover.ReadPacket{Data: []byte("temporary discovery v5 packet")}
+	close(unhandled)
+
+	buf := make([]byte, 64)
+	if n, _, err := topicConn.ReadFromUDP(buf); err != nil || string(buf[:n]) != "temporary discovery v5 packet" {
+		t.Errorf("topic discovery packet mismatch: have %q (%v)", buf[:n], err)
+	}
+	if n, _, err := v51Conn.ReadFromUDP(buf); err != nil || string(buf[:n]) != "v5.1 packet" {
+		t.Errorf("v5.1 packet mismatch: have %q (%v)", buf[:n], err)
+	}
+	if _, _, err := v51Conn.ReadFromUDP(buf); err == nil {
+		t.Errorf("read succeeded after close")
+	}
+}
+
+//addrConn覆盖连接的远程地址。
+type addrConn struct {
+	net.Conn
+	remote net.Addr
+}
+
+func (c addrConn) RemoteAddr() net.Addr { return c.remote }
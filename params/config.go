@@ -13,8 +13,10 @@
 package params
 
 import (
+	"bytes"
 	"fmt"
 	"math/big"
+	"sort"
 
 	"github.com/ethereum/go-ethereum/common"
 )
@@ -107,16 +109,16 @@ var (
 //
 //此配置有意不使用键字段强制任何人
 //向配置中添加标志也必须设置这些字段。
-	AllEthashProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, new(EthashConfig), nil}
+	AllEthashProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, new(EthashConfig), nil, nil}
 
 //AllCliqueProtocolChanges包含引入的每个协议更改（EIP）
 //并被以太坊核心开发者接纳为集团共识。
 //
 //此配置有意不使用键字段强制任何人
 //向配置中添加标志也必须设置这些字段。
-	AllCliqueProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, &CliqueConfig{Period: 0, Epoch: 30000}}
+	AllCliqueProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, &CliqueConfig{Period: 0, Epoch: 30000}, nil}
 
-	TestChainConfig = &ChainConfig{big.NewInt(1), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, new(EthashConfig), nil}
+	TestChainConfig = &ChainConfig{big.NewInt(1), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, new(EthashConfig), nil, nil}
 	TestRules       = TestChainConfig.Rules(new(big.Int))
 )
 
@@ -156,9 +158,20 @@ ByzantiumBlock      *big.Int `json:"byzantiumBlock,omitempty"`      //拜占庭
 ConstantinopleBlock *big.Int `json:"constantinopleBlock,omitempty"` //君士坦丁堡开关块（nil=无叉，0=已激活）
 EWASMBlock          *big.Int `json:"ewasmBlock,omitempty"`          //ewasm开关块（nil=无分叉，0=已激活）
 
+//自定义预编译合同（仅用于私有链），按地址在指定块激活
+	Precompiles map[common.Address]*PrecompileConfig `json:"precompiles,omitempty"`
+
 //各种共识引擎
 	Ethash *EthashConfig `json:"ethash,omitempty"`
 	Clique *CliqueConfig `json:"clique,omitempty"`
+	IBFT   *IBFTConfig   `json:"ibft,omitempty"`
+}
+
+//precompileconfig描述在给定块激活的自定义预编译合同。
+//实现本身必须由嵌入程序通过vm.RegisterPrecompile以相同的名称注册。
+type PrecompileConfig struct {
+Name  string   `json:"name"`  //注册的预编译实现的名称
+Block *big.Int `json:"block"` //激活块（nil=未激活，0=从创世开始）
 }
 
 //ethashconfig是基于工作证明的密封的共识引擎配置。
@@ -173,6 +186,10 @@ func (c *EthashConfig) String() string {
 type CliqueConfig struct {
 Period uint64 `json:"period"` //要强制执行的块之间的秒数
 Epoch  uint64 `json:"epoch"`  //重置投票和检查点的epoch长度
+
+Governance      *common.Address `json:"governance,omitempty"`      //在每个epoch提供签名者列表的治理合约（nil=使用头内投票）
+GovernanceSlot  uint64          `json:"governanceSlot,omitempty"`  //合约中签名者地址动态数组的存储槽
+GovernanceBlock uint64          `json:"governanceBlock,omitempty"` //开始使用治理合约的块号（之前仍使用投票）
 }
 
 //字符串实现Stringer接口，返回共识引擎详细信息。
@@ -180,6 +197,17 @@ func (c *CliqueConfig) String() string {
 	return "clique"
 }
 
+//ibftconfig是具有即时最终性的拜占庭容错共识引擎配置。
+type IBFTConfig struct {
+Period         uint64 `json:"period"`         //要强制执行的块之间的最小秒数
+RequestTimeout uint64 `json:"requestTimeout"` //第0轮的超时毫秒数，之后每轮加倍
+}
+
+//字符串实现Stringer接口，返回共识引擎详细信息。
+func (c *IBFTConfig) String() string {
+	return "ibft"
+}
+
 //字符串实现fmt.Stringer接口。
 func (c *ChainConfig) String() string {
 	var engine interface{}
@@ -188,6 +216,8 @@ func (c *ChainConfig) String() string {
 		engine = c.Ethash
 	case c.Clique != nil:
 		engine = c.Clique
+	case c.IBFT != nil:
+		engine = c.IBFT
 	default:
 		engine = "unknown"
 	}
@@ -245,6 +275,15 @@ func (c *ChainConfig) IsEWASM(num *big.Int) bool {
 	return isForked(c.EWASMBlock, num)
 }
 
+//CustomPrecompile返回在给定块时在地址处激活的自定义预编译合同的名称。
+func (c *ChainConfig) CustomPrecompile(addr common.Address, num *big.Int) (string, bool) {
+	p := c.Precompiles[addr]
+	if p == nil || !isForked(p.Block, num) {
+		return "", false
+	}
+	return p.Name, true
+}
+
 //Gastable返回与当前阶段（宅基地或宅基地重印）对应的气体表。
 //
 //在任何情况下，返回的加斯塔布尔的字段都不应该更改。
@@ -313,9 +352,47 @@ func (c *ChainConfig) checkCompatible(newcfg *ChainConfig, head *big.Int) *Confi
 	if isForkIncompatible(c.EWASMBlock, newcfg.EWASMBlock, head) {
 		return newCompatError("ewasm fork block", c.EWASMBlock, newcfg.EWASMBlock)
 	}
+	for _, addr := range precompileAddresses(c, newcfg) {
+		var (
+			oldBlock, newBlock *big.Int
+			oldName, newName   string
+		)
+		if p := c.Precompiles[addr]; p != nil {
+			oldBlock, oldName = p.Block, p.Name
+		}
+		if p := newcfg.Precompiles[addr]; p != nil {
+			newBlock, newName = p.Block, p.Name
+		}
+		if isForkIncompatible(oldBlock, newBlock, head) {
+			return newCompatError(fmt.Sprintf("precompile %x activation block", addr), oldBlock, newBlock)
+		}
+		if isForked(oldBlock, head) && oldName != newName {
+			return newCompatError(fmt.Sprintf("precompile %x implementation", addr), oldBlock, newBlock)
+		}
+	}
 	return nil
 }
 
+//precompileaddresses返回两个配置中配置的所有自定义预编译地址，
+//按排序顺序，以便兼容性检查是确定的。
+func precompileAddresses(a, b *ChainConfig) []common.Address {
+	set := make(map[common.Address]struct{})
+	for addr := range a.Precompiles {
+		set[addr] = struct{}{}
+	}
+	for addr := range b.Precompiles {
+		set[addr] = struct{}{}
+	}
+	addrs := make([]common.Address, 0, len(set))
+	for addr := range set {
+		addrs = append(addrs, addr)
+	}
+	sort.Slice(addrs, func(i, j int) bool {
+		return bytes.Compare(addrs[i][:], addrs[j][:]) < 0
+	})
+	return addrs
+}
+
 //如果无法将在s1上计划的分叉重新计划为，则IsForkCompatible返回true
 //阻塞s2，因为头已经过了分叉。
 func isForkIncompatible(s1, s2, head *big.Int) bool {
@@ -92,6 +92,10 @@ func (err *jsonError) ErrorCode() int {
 	return err.Code
 }
 
+func (err *jsonError) ErrorData() interface{} {
+	return err.Data
+}
+
 //Newcodec创建了一个新的RPC服务器编解码器，支持基于JSON-RPC2.0的
 //关于显式给定的编码和解码方法。
 func NewCodec(rwc io.ReadWriteCloser, encode, decode func(v interface{}) error) ServerCodec {
@@ -55,6 +55,20 @@ func (s *Service) Rets() (string, error) {
 	return "", nil
 }
 
+//dataError是带有自定义错误代码和附加数据的测试错误。
+type dataError struct {
+	message string
+	data    interface{}
+}
+
+func (e *dataError) Error() string          { return e.message }
+func (e *dataError) ErrorCode() int         { return 3 }
+func (e *dataError) ErrorData() interface{} { return e.data }
+
+func (s *Service) ReturnError() error {
+	return &dataError{message: "custom error", data: "some data"}
+}
+
 func (s *Service) InvalidRets1() (error, string) {
 	return nil, ""
 }
@@ -88,8 +102,8 @@ func TestServerRegisterName(t *testing.T) {
 		t.Fatalf("Expected service calc to be registered")
 	}
 
-	if len(svc.callbacks) != 5 {
-		t.Errorf("Expected 5 callbacks for service 'calc', got %d", len(svc.callbacks))
+	if len(svc.callbacks) != 6 {
+		t.Errorf("Expected 6 callbacks for service 'calc', got %d", len(svc.callbacks))
 	}
 
 	if len(svc.subscriptions) != 1 {
@@ -157,3 +171,26 @@ func TestServerMethodWithCtx(t *testing.T) {
 	testServerMethodExecution(t, "echoWithCtx")
 }
 
+func TestServerErrorData(t *testing.T) {
+	server := newTestServer("service", new(Service))
+	defer server.Stop()
+	client := DialInProc(server)
+	defer client.Close()
+
+	var resp interface{}
+	err := client.Call(&resp, "service_returnError")
+	if err == nil {
+		t.Fatal("expected error")
+	}
+//检查代码和数据是否传递给客户端
+	if e, ok := err.(Error); !ok {
+		t.Fatalf("client did not return rpc.Error, got %#v", err)
+	} else if e.ErrorCode() != 3 {
+		t.Fatalf("wrong error code %d, want 3", e.ErrorCode())
+	}
+	if e, ok := err.(DataError); !ok {
+		t.Fatalf("client did not return rpc.DataError, got %#v", err)
+	} else if e.ErrorData() != "some data" {
+		t.Fatalf("wrong error data %#v, want \"some data\"", e.ErrorData())
+	}
+}
@@ -13,6 +13,7 @@
 package rpc
 
 import (
+	"encoding/json"
 	"fmt"
 	"math"
 	"reflect"
@@ -20,6 +21,7 @@ import (
 	"sync"
 
 	mapset "github.com/deckarep/golang-set"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 )
 
@@ -88,6 +90,12 @@ Error() string  //返回消息
 ErrorCode() int //返回代码
 }
 
+//DataError包含一些附加到JSON-RPC错误响应中的额外数据。
+type DataError interface {
+Error() string          //返回消息
+ErrorData() interface{} //返回错误数据
+}
+
 //ServerCodec实现对服务器端的RPC消息的读取、分析和写入
 //一个RPC会话。由于可以调用编解码器，因此实现必须是安全的执行例程。
 //同时执行多个go例程。
@@ -160,3 +168,73 @@ func (bn BlockNumber) Int64() int64 {
 	return (int64)(bn)
 }
 
+//BlockNumberOrHash按编号（包括“latest”等标签）或按哈希引用一个块。
+type BlockNumberOrHash struct {
+	BlockNumber *BlockNumber `json:"blockNumber,omitempty"`
+	BlockHash   *common.Hash `json:"blockHash,omitempty"`
+}
+
+//UnmarshalJSON将给定的JSON片段解析为BlockNumberOrHash。它支持：
+//-“最新”、“最早”或“挂起”作为字符串参数
+//-十六进制编码的块号
+//-32字节的十六进制块哈希
+//-包含blockNumber或blockHash字段（仅其中之一）的对象
+func (bnh *BlockNumberOrHash) UnmarshalJSON(data []byte) error {
+	type erased BlockNumberOrHash
+	e := erased{}
+	if err := json.Unmarshal(data, &e); err == nil {
+		if e.BlockNumber != nil && e.BlockHash != nil {
+			return fmt.Errorf("cannot specify both BlockHash and BlockNumber, choose one or the other")
+		}
+		if e.BlockNumber == nil && e.BlockHash == nil {
+			return fmt.Errorf("either BlockHash or BlockNumber must be specified")
+		}
+		bnh.BlockNumber = e.BlockNumber
+		bnh.BlockHash = e.BlockHash
+		return nil
+	}
+	var input string
+	if err := json.Unmarshal(data, &input); err != nil {
+		return err
+	}
+	if len(input) == 66 {
+		hash := common.Hash{}
+		if err := hash.UnmarshalText([]byte(input)); err != nil {
+			return err
+		}
+		bnh.BlockHash = &hash
+		return nil
+	}
+	number := new(BlockNumber)
+	if err := number.UnmarshalJSON(data); err != nil {
+		return err
+	}
+	bnh.BlockNumber = number
+	return nil
+}
+
+//Number返回引用的块号（如果按编号引用）。
+func (bnh *BlockNumberOrHash) Number() (BlockNumber, bool) {
+	if bnh.BlockNumber != nil {
+		return *bnh.BlockNumber, true
+	}
+	return BlockNumber(0), false
+}
+
+//Hash返回引用的块哈希（如果按哈希引用）。
+func (bnh *BlockNumberOrHash) Hash() (common.Hash, bool) {
+	if bnh.BlockHash != nil {
+		return *bnh.BlockHash, true
+	}
+	return common.Hash{}, false
+}
+
+//BlockNumberOrHashWithNumber创建按编号引用块的BlockNumberOrHash。
+func BlockNumberOrHashWithNumber(blockNr BlockNumber) BlockNumberOrHash {
+	return BlockNumberOrHash{BlockNumber: &blockNr}
+}
+
+//BlockNumberOrHashWithHash创建按哈希引用块的BlockNumberOrHash。
+func BlockNumberOrHashWithHash(hash common.Hash) BlockNumberOrHash {
+	return BlockNumberOrHash{BlockHash: &hash}
+}
@@ -16,6 +16,7 @@ import (
 	"encoding/json"
 	"testing"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/math"
 )
 
@@ -61,3 +62,54 @@ func TestBlockNumberJSONUnmarshal(t *testing.T) {
 	}
 }
 
+func TestBlockNumberOrHashJSONUnmarshal(t *testing.T) {
+	tests := []struct {
+		input    string
+		mustFail bool
+		expected BlockNumberOrHash
+	}{
+		0:  {`"0x"`, true, BlockNumberOrHash{}},
+		1:  {`"0x0"`, false, BlockNumberOrHashWithNumber(0)},
+		2:  {`"0x12"`, false, BlockNumberOrHashWithNumber(18)},
+		3:  {`"0x8000000000000000"`, true, BlockNumberOrHash{}},
+		4:  {`"pending"`, false, BlockNumberOrHashWithNumber(PendingBlockNumber)},
+		5:  {`"latest"`, false, BlockNumberOrHashWithNumber(LatestBlockNumber)},
+		6:  {`"earliest"`, false, BlockNumberOrHashWithNumber(EarliestBlockNumber)},
+		7:  {`someString`, true, BlockNumberOrHash{}},
+		8:  {`""`, true, BlockNumberOrHash{}},
+		9:  {``, true, BlockNumberOrHash{}},
+		10: {`"0x0000000000000000000000000000000000000000000000000000000000000000"`, false, BlockNumberOrHashWithHash(common.HexToHash("0x0000000000000000000000000000000000000000000000000000000000000000"))},
+		11: {`"0x1234567890123456789012345678901234567890123456789012345678901234"`, false, BlockNumberOrHashWithHash(common.HexToHash("0x1234567890123456789012345678901234567890123456789012345678901234"))},
+		12: {`{"blockNumber":"0x10"}`, false, BlockNumberOrHashWithNumber(16)},
+		13: {`{"blockNumber":"latest"}`, false, BlockNumberOrHashWithNumber(LatestBlockNumber)},
+		14: {`{"blockHash":"0x0000000000000000000000000000000000000000000000000000000000000001"}`, false, BlockNumberOrHashWithHash(common.HexToHash("0x01"))},
+		15: {`{"blockNumber":"0x1","blockHash":"0x0000000000000000000000000000000000000000000000000000000000000001"}`, true, BlockNumberOrHash{}},
+		16: {`{}`, true, BlockNumberOrHash{}},
+	}
+
+	for i, test := range tests {
+		var bnh BlockNumberOrHash
+		err := json.Unmarshal([]byte(test.input), &bnh)
+		if test.mustFail && err == nil {
+			t.Errorf("Test %d should fail", i)
+			continue
+		}
+		if !test.mustFail && err != nil {
+			t.Errorf("Test %d should pass but got err: %v", i, err)
+			continue
+		}
+		if test.mustFail {
+			continue
+		}
+		wantNum, wantIsNum := test.expected.Number()
+		gotNum, gotIsNum := bnh.Number()
+		if wantIsNum != gotIsNum || wantNum != gotNum {
+			t.Errorf("Test %d got unexpected number, want %d, got %d", i, wantNum, gotNum)
+		}
+		wantHash, wantIsHash := test.expected.Hash()
+		gotHash, gotIsHash := bnh.Hash()
+		if wantIsHash != gotIsHash || wantHash != gotHash {
+			t.Errorf("Test %d got unexpected hash, want %x, got %x", i, wantHash, gotHash)
+		}
+	}
+}
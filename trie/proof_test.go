@@ -16,6 +16,7 @@ import (
 	"bytes"
 	crand "crypto/rand"
 	mrand "math/rand"
+	"sort"
 	"testing"
 	"time"
 
@@ -139,6 +140,77 @@ func TestMissingKeyProof(t *testing.T) {
 	}
 }
 
+//测试范围证明：完整的范围被接受，缺少条目、篡改值或错误地声称没有更多条目的范围被拒绝。
+func TestRangeProof(t *testing.T) {
+	trie, vals := randomTrie(1024)
+	root := trie.Hash()
+
+	var entries []*kv
+	for _, kv := range vals {
+		entries = append(entries, kv)
+	}
+	sort.Slice(entries, func(i, j int) bool { return bytes.Compare(entries[i].k, entries[j].k) < 0 })
+
+	prove := func(first []byte, last []byte) *ethdb.MemDatabase {
+		proof := ethdb.NewMemDatabase()
+		trie.Prove(first, 0, proof)
+		if last != nil {
+			trie.Prove(last, 0, proof)
+		}
+		return proof
+	}
+	for i := 0; i < 200; i++ {
+		start := mrand.Intn(len(entries))
+		end := start + 1 + mrand.Intn(len(entries)-start)
+
+		var keys, values [][]byte
+		for _, kv := range entries[start:end] {
+			keys = append(keys, kv.k)
+			values = append(values, kv.v)
+		}
+//起点可以是第一个条目，也可以是它之前不存在的键
+		first := keys[0]
+		if start > 0 && mrand.Intn(2) == 0 {
+			if first = common.CopyBytes(entries[start-1].k); first[len(first)-1] < 0xff {
+				first[len(first)-1]++
+			} else {
+				first = keys[0]
+			}
+		}
+		more, err := VerifyRangeProof(root, first, keys, values, prove(first, keys[len(keys)-1]))
+		if err != nil {
+			t.Fatalf("range %d-%d: failed to verify: %v", start, end, err)
+		}
+		if more != (end < len(entries)) {
+			t.Fatalf("range %d-%d: more mismatch: have %v, want %v", start, end, more, end < len(entries))
+		}
+//缺少中间的条目
+		if len(keys) > 2 {
+			index := 1 + mrand.Intn(len(keys)-2)
+			gapKeys := append(append([][]byte{}, keys[:index]...), keys[index+1:]...)
+			gapValues := append(append([][]byte{}, values[:index]...), values[index+1:]...)
+			if _, err := VerifyRangeProof(root, first, gapKeys, gapValues, prove(first, keys[len(keys)-1])); err == nil {
+				t.Fatalf("range %d-%d: missing entry accepted", start, end)
+			}
+		}
+//篡改的值
+		index := mrand.Intn(len(values))
+		tampered := append([][]byte{}, values...)
+		tampered[index] = append(common.CopyBytes(values[index]), 0x01)
+		if _, err := VerifyRangeProof(root, first, keys, tampered, prove(first, keys[len(keys)-1])); err == nil {
+			t.Fatalf("range %d-%d: tampered value accepted", start, end)
+		}
+	}
+//最后一个条目之后的空范围是有效的，之前的空范围不是
+	last := bytes.Repeat([]byte{0xff}, 32)
+	if _, err := VerifyRangeProof(root, last, nil, nil, prove(last, nil)); err != nil {
+		t.Fatalf("empty tail range rejected: %v", err)
+	}
+	if _, err := VerifyRangeProof(root, entries[0].k, nil, nil, prove(entries[0].k, nil)); err == nil {
+		t.Fatalf("empty range with entries accepted")
+	}
+}
+
 //突变字节改变了B中的一个字节。
 func mutateByte(b []byte) {
 	for r := mrand.Intn(len(b)); ; {